@@ -3,15 +3,23 @@ package bootstrap
 
 import (
 	"errors"
+	"github.com/jrobison153/raft/crypto"
 	"github.com/jrobison153/raft/journal"
 	"github.com/jrobison153/raft/policy/client"
 	"github.com/jrobison153/raft/replication"
 	"github.com/jrobison153/raft/server"
 	"github.com/jrobison153/raft/server/grpc"
 	"github.com/jrobison153/raft/state"
+	"github.com/jrobison153/raft/state/process"
+	"github.com/jrobison153/raft/state/wasm"
+	"github.com/jrobison153/raft/telemetry"
+	"github.com/prometheus/client_golang/prometheus"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
 )
 
 type Bootstrap struct {
@@ -23,19 +31,40 @@ type Bootstrap struct {
 }
 
 const (
+	journalCodecEnvVar     = "JOURNAL_CODEC"
+	journalDirEnvVar       = "JOURNAL_DIR"
 	journalTypeEnvVar      = "JOURNAL_TYPE"
 	replicatorTypeEnvVar   = "REPLICATOR_TYPE"
 	stateMachineTypeEnvVar = "STATE_MACHINE_TYPE"
-
-	spyJournalType      = "SPY"
-	spyReplicatorType   = "SPY"
-	spyStateMachineType = "SPY"
+	stateMachineExecEnvVar = "STATE_MACHINE_EXECUTABLE"
+	stateMachineModEnvVar  = "STATE_MACHINE_MODULE"
+
+	defaultJournalDir = "journal-data"
+
+	// stateSnapshotFileSuffix names the file next to a TIERED journal directory that the snapshots of a PROCESS or
+	// WASM state machine are persisted to, outside the directory so re-encrypting the journal leaves it in place
+	stateSnapshotFileSuffix = ".snapshot"
+
+	spyJournalType          = "SPY"
+	tieredJournalType       = "TIERED"
+	spyReplicatorType       = "SPY"
+	spyStateMachineType     = "SPY"
+	mapStateMachineType     = "MAP"
+	btreeStateMachineType   = "BTREE"
+	processStateMachineType = "PROCESS"
+	wasmStateMachineType    = "WASM"
 )
 
 var (
+	ErrInvalidJournalCodec     = errors.New("journal codec specified in the environment is not supported")
 	ErrInvalidJournalType      = errors.New("journal type specified in the environment is not supported")
+	ErrInvalidEncryptionKeys   = errors.New("encryption keys specified in the environment could not be loaded")
+	ErrUnableToOpenJournal     = errors.New("unable to open the journal")
 	ErrInvalidReplicatorType   = errors.New("replicator type specified in the environment is not supported")
 	ErrInvalidStateMachineType = errors.New("state machine type specified in the environment is not supported")
+	ErrMissingStateMachineExec = errors.New("process state machine type requires STATE_MACHINE_EXECUTABLE to be set")
+	ErrMissingStateMachineMod  = errors.New("wasm state machine type requires STATE_MACHINE_MODULE to be set")
+	ErrUnreadableStateMachine  = errors.New("wasm state machine module specified in the environment could not be read")
 )
 
 func New() *Bootstrap {
@@ -66,12 +95,19 @@ func (bootstrapper *Bootstrap) Init() error {
 }
 
 // Start starts the replicator, the state machine (listening for changes to the journal commit index),
-// and the API servers, client APIs listening on port clientApiServerPort
+// and the API servers, client APIs listening on port clientApiServerPort. The servers follow the leadership the
+// replicator reports. A WASM state machine swaps in its module again each time the node receives SIGHUP
 func (bootstrapper *Bootstrap) Start(clientApiServerPort uint32) {
 
 	// TODO handle error on state machine start up
 	_ = bootstrapper.stateMachine.Start()
 
+	if os.Getenv(stateMachineTypeEnvVar) == wasmStateMachineType {
+		go swapModuleOnHangup(bootstrapper.stateMachine.(*process.Proxy), os.Getenv(stateMachineModEnvVar))
+	}
+
+	bootstrapper.server.FollowLeadership(bootstrapper.replicator.Leadership())
+
 	timer := replication.NewSleepTimer()
 	bootstrapper.replicator.Start(timer)
 
@@ -88,6 +124,10 @@ func (bootstrapper *Bootstrap) initializeStateMachineAndReplicator() error {
 
 	bootstrapper.stateMachine, stateMachineResolveErr = resolveStateMachineImpl(bootstrapper.journal)
 
+	if stateMachineResolveErr == nil {
+		registerStateMachineCollectors(bootstrapper.stateMachine)
+	}
+
 	if replResolveErr != nil {
 		err = replResolveErr
 	} else if stateMachineResolveErr != nil {
@@ -105,13 +145,7 @@ func resolveStateMachineImpl(journal journal.Journaler) (state.Renderer, error)
 	var err error
 
 	if isStateMachineTypeSet {
-
-		if strings.Compare(stateMachineType, spyStateMachineType) == 0 {
-			theStateMachine = state.NewStateMachineSpy(journal)
-		} else {
-			err = ErrInvalidStateMachineType
-			log.Printf("Unknown state machine type '%s'", stateMachineType)
-		}
+		theStateMachine, err = createStateMachineOfType(stateMachineType, journal)
 	} else {
 		theStateMachine = state.NewMapStateMachine(journal)
 	}
@@ -119,6 +153,150 @@ func resolveStateMachineImpl(journal journal.Journaler) (state.Renderer, error)
 	return theStateMachine, err
 }
 
+func createStateMachineOfType(stateMachineType string, journal journal.Journaler) (state.Renderer, error) {
+
+	var theStateMachine state.Renderer
+	var err error
+
+	switch stateMachineType {
+
+	case spyStateMachineType:
+		theStateMachine = state.NewStateMachineSpy(journal)
+
+	case mapStateMachineType:
+		theStateMachine = state.NewMapStateMachine(journal)
+
+	case btreeStateMachineType:
+		theStateMachine = state.NewBTreeStateMachine(journal)
+
+	case processStateMachineType:
+		theStateMachine, err = createProcessStateMachine(journal)
+
+	case wasmStateMachineType:
+		theStateMachine, err = createWasmStateMachine(journal)
+
+	default:
+		err = ErrInvalidStateMachineType
+		log.Printf("Unknown state machine type '%s'", stateMachineType)
+	}
+
+	return theStateMachine, err
+}
+
+// registerStateMachineCollectors registers the apply progress metrics of state machines that track it
+func registerStateMachineCollectors(stateMachine state.Renderer) {
+
+	collecting, isCollecting := stateMachine.(interface{ Collectors() []prometheus.Collector })
+
+	if isCollecting {
+		telemetry.Register(collecting.Collectors()...)
+	}
+}
+
+// createProcessStateMachine creates the proxy of a state machine running as the program at STATE_MACHINE_EXECUTABLE.
+// With a TIERED journal the snapshots of the state machine are persisted, sealed with the journal keys
+func createProcessStateMachine(journal journal.Journaler) (state.Renderer, error) {
+
+	executable, isExecutableSet := os.LookupEnv(stateMachineExecEnvVar)
+
+	var theStateMachine state.Renderer
+	var err error
+
+	config := process.NewDefaultConfig(executable)
+
+	if !isExecutableSet || executable == "" {
+		err = ErrMissingStateMachineExec
+	} else if os.Getenv(journalTypeEnvVar) == tieredJournalType {
+		err = persistSnapshots(config)
+	}
+
+	if err == nil {
+		theStateMachine = process.NewProxy(journal, config)
+	}
+
+	return theStateMachine, err
+}
+
+// createWasmStateMachine creates the proxy of a state machine compiled to the WebAssembly module at
+// STATE_MACHINE_MODULE and run in process. With a TIERED journal the snapshots of the state machine are persisted,
+// sealed with the journal keys
+func createWasmStateMachine(journal journal.Journaler) (state.Renderer, error) {
+
+	path, isModuleSet := os.LookupEnv(stateMachineModEnvVar)
+
+	var theStateMachine state.Renderer
+	var module []byte
+	var err error
+
+	config := process.NewDefaultConfig("")
+
+	if !isModuleSet || path == "" {
+		err = ErrMissingStateMachineMod
+	} else {
+		module, err = readModule(path)
+	}
+
+	if err == nil && os.Getenv(journalTypeEnvVar) == tieredJournalType {
+		err = persistSnapshots(config)
+	}
+
+	if err == nil {
+		theStateMachine = wasm.NewProxy(journal, config, module)
+	}
+
+	return theStateMachine, err
+}
+
+func readModule(path string) ([]byte, error) {
+
+	module, err := os.ReadFile(path)
+
+	if err != nil {
+
+		log.Printf("Unable to read the state machine module '%s': %v", path, err)
+		err = ErrUnreadableStateMachine
+	}
+
+	return module, err
+}
+
+// swapModuleOnHangup swaps the module at path into proxy each time the process receives SIGHUP, so the business logic
+// of a WASM state machine is replaced without restarting the node. A module that cannot be swapped in is logged and
+// the running one keeps serving
+func swapModuleOnHangup(proxy *process.Proxy, path string) {
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	for range hangups {
+
+		module, err := readModule(path)
+
+		if err == nil {
+			err = proxy.Swap(wasm.NewLauncher(module))
+		}
+
+		if err == nil {
+			log.Printf("Swapped in the state machine module '%s'", path)
+		} else {
+			log.Printf("Unable to swap in the state machine module '%s', the running module is kept: %v", path, err)
+		}
+	}
+}
+
+// persistSnapshots configures the proxy to persist its snapshots next to the TIERED journal directory
+func persistSnapshots(config *process.Config) error {
+
+	journalConfig, err := resolveTieredJournalConfig()
+
+	if err == nil {
+		config.SnapshotFile = filepath.Clean(journalConfig.Directory) + stateSnapshotFileSuffix
+		config.Keyring = journalConfig.Keyring
+	}
+
+	return err
+}
+
 func resolveJournalImpl() (journal.Journaler, error) {
 
 	journalType, isJournalTypeSet := os.LookupEnv(journalTypeEnvVar)
@@ -127,13 +305,7 @@ func resolveJournalImpl() (journal.Journaler, error) {
 	var err error
 
 	if isJournalTypeSet {
-
-		if strings.Compare(journalType, spyJournalType) == 0 {
-			theJournal = journal.NewJournalSpy()
-		} else {
-			err = ErrInvalidJournalType
-			log.Printf("Unknown journal type '%s'", journalType)
-		}
+		theJournal, err = createJournalOfType(journalType)
 	} else {
 		theJournal = journal.NewArrayJournal()
 	}
@@ -141,6 +313,104 @@ func resolveJournalImpl() (journal.Journaler, error) {
 	return theJournal, err
 }
 
+func createJournalOfType(journalType string) (journal.Journaler, error) {
+
+	var theJournal journal.Journaler
+	var err error
+
+	switch journalType {
+
+	case spyJournalType:
+		theJournal = journal.NewJournalSpy()
+
+	case tieredJournalType:
+		theJournal, err = createTieredJournal()
+
+	default:
+		err = ErrInvalidJournalType
+		log.Printf("Unknown journal type '%s'", journalType)
+	}
+
+	return theJournal, err
+}
+
+func createTieredJournal() (journal.Journaler, error) {
+
+	config, err := resolveTieredJournalConfig()
+
+	var theJournal journal.Journaler
+
+	if err == nil {
+		theJournal, err = openTieredJournal(config)
+	}
+
+	return theJournal, err
+}
+
+func resolveTieredJournalConfig() (*journal.TieredJournalConfig, error) {
+
+	journalDir, isJournalDirSet := os.LookupEnv(journalDirEnvVar)
+
+	if !isJournalDirSet {
+		journalDir = defaultJournalDir
+	}
+
+	config := journal.NewDefaultTieredJournalConfig(journalDir)
+
+	var err error
+
+	codecName, isCodecSet := os.LookupEnv(journalCodecEnvVar)
+
+	if isCodecSet {
+		config.Codec, err = journal.ParseCodec(codecName)
+	}
+
+	if err != nil {
+
+		log.Printf("Unknown journal codec '%s'", codecName)
+		err = ErrInvalidJournalCodec
+	}
+
+	if err == nil {
+		config.Keyring, err = resolveKeyring()
+	}
+
+	return config, err
+}
+
+func resolveKeyring() (*crypto.Keyring, error) {
+
+	keyring, err := crypto.LoadKeyringFromEnvironment()
+
+	if err != nil {
+
+		log.Printf("Unable to load encryption keys: %v", err)
+		err = ErrInvalidEncryptionKeys
+	}
+
+	return keyring, err
+}
+
+func openTieredJournal(config *journal.TieredJournalConfig) (journal.Journaler, error) {
+
+	tieredJournal, openErr := journal.NewTieredJournal(config)
+
+	var theJournal journal.Journaler
+	var err error
+
+	if openErr == nil {
+
+		telemetry.Register(tieredJournal.Collectors()...)
+		theJournal = tieredJournal
+	} else {
+
+		log.Printf("Unable to open tiered journal in '%s': %v", config.Directory, openErr)
+		err = ErrUnableToOpenJournal
+	}
+
+	return theJournal, err
+}
+
 func resolveReplicator(journal journal.Journaler) (replication.Replicator, error) {
 
 	replicatorType, isReplicatorTypeSet := os.LookupEnv(replicatorTypeEnvVar)
@@ -1,11 +1,15 @@
 package bootstrap
 
 import (
+	"encoding/base64"
+	"github.com/jrobison153/raft/crypto"
 	"github.com/jrobison153/raft/journal"
 	"github.com/jrobison153/raft/replication"
 	"github.com/jrobison153/raft/server"
 	"github.com/jrobison153/raft/state"
+	"github.com/jrobison153/raft/state/process"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -183,6 +187,102 @@ func TestWhenJournalTypeEnvVarSetToSpyThenTheSpyJournalImplementationIsUsed(t *t
 	}
 }
 
+func TestWhenJournalTypeEnvVarSetToTieredThenTheTieredJournalImplementationIsUsed(t *testing.T) {
+
+	_ = os.Setenv(journalTypeEnvVar, tieredJournalType)
+	_ = os.Setenv(journalDirEnvVar, t.TempDir())
+	defer envCleanUp(journalTypeEnvVar)
+	defer envCleanUp(journalDirEnvVar)
+
+	bootstrapper := New()
+	_ = bootstrapper.Init()
+
+	expectedJournalType := reflect.TypeOf(&journal.TieredJournal{}).String()
+	actualJournalType := reflect.TypeOf(bootstrapper.journal).String()
+
+	if strings.Compare(expectedJournalType, actualJournalType) != 0 {
+		t.Errorf("Journal should have been set to type '%v' but instead was '%v'",
+			expectedJournalType,
+			actualJournalType)
+	}
+}
+
+func TestWhenTieredJournalCannotBeOpenedThenAnErrorIsReturned(t *testing.T) {
+
+	notADirectory := filepath.Join(t.TempDir(), "file")
+	_ = os.WriteFile(notADirectory, []byte("in the way"), 0o640)
+
+	_ = os.Setenv(journalTypeEnvVar, tieredJournalType)
+	_ = os.Setenv(journalDirEnvVar, notADirectory)
+	defer envCleanUp(journalTypeEnvVar)
+	defer envCleanUp(journalDirEnvVar)
+
+	bootstrapper := New()
+	err := bootstrapper.Init()
+
+	if err != ErrUnableToOpenJournal {
+		t.Errorf("Should have received error '%v' but instead got '%v'", ErrUnableToOpenJournal, err)
+	}
+}
+
+func TestWhenJournalCodecIsUnknownThenAnErrorIsReturned(t *testing.T) {
+
+	_ = os.Setenv(journalTypeEnvVar, tieredJournalType)
+	_ = os.Setenv(journalDirEnvVar, t.TempDir())
+	_ = os.Setenv(journalCodecEnvVar, "lz4")
+	defer envCleanUp(journalTypeEnvVar)
+	defer envCleanUp(journalDirEnvVar)
+	defer envCleanUp(journalCodecEnvVar)
+
+	bootstrapper := New()
+	err := bootstrapper.Init()
+
+	if err != ErrInvalidJournalCodec {
+		t.Errorf("Should have received error '%v' but instead got '%v'", ErrInvalidJournalCodec, err)
+	}
+}
+
+func TestWhenJournalCodecIsSetThenTieredJournalIsConfiguredWithIt(t *testing.T) {
+
+	_ = os.Setenv(journalCodecEnvVar, "gzip")
+	defer envCleanUp(journalCodecEnvVar)
+
+	config, _ := resolveTieredJournalConfig()
+
+	if config.Codec != journal.CodecGzip {
+		t.Errorf("Journal codec should have been gzip but was %d", config.Codec)
+	}
+}
+
+func TestWhenEncryptionKeysAreInvalidThenAnErrorIsReturned(t *testing.T) {
+
+	_ = os.Setenv(journalTypeEnvVar, tieredJournalType)
+	_ = os.Setenv(journalDirEnvVar, t.TempDir())
+	_ = os.Setenv(crypto.KeysEnvVar, "1:not-a-key")
+	defer envCleanUp(journalTypeEnvVar)
+	defer envCleanUp(journalDirEnvVar)
+	defer envCleanUp(crypto.KeysEnvVar)
+
+	bootstrapper := New()
+	err := bootstrapper.Init()
+
+	if err != ErrInvalidEncryptionKeys {
+		t.Errorf("Should have received error '%v' but instead got '%v'", ErrInvalidEncryptionKeys, err)
+	}
+}
+
+func TestWhenEncryptionKeysAreSetThenTieredJournalIsConfiguredWithThem(t *testing.T) {
+
+	_ = os.Setenv(crypto.KeysEnvVar, "7:"+base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	defer envCleanUp(crypto.KeysEnvVar)
+
+	config, _ := resolveTieredJournalConfig()
+
+	if config.Keyring == nil || config.Keyring.ActiveKeyID() != 7 {
+		t.Errorf("Journal should have been configured with key 7 but keyring was %v", config.Keyring)
+	}
+}
+
 func TestWhenStateMachineTypeEnvVarSetToSpyThenTheSpyStateMachineImplementationIsUsed(t *testing.T) {
 
 	bootstrapper := setup()
@@ -198,6 +298,23 @@ func TestWhenStateMachineTypeEnvVarSetToSpyThenTheSpyStateMachineImplementationI
 	}
 }
 
+func TestWhenStateMachineTypeEnvVarSetToBTreeThenTheBTreeStateMachineImplementationIsUsed(t *testing.T) {
+
+	_ = os.Setenv(stateMachineTypeEnvVar, btreeStateMachineType)
+	defer envCleanUp(stateMachineTypeEnvVar)
+
+	theStateMachine, err := resolveStateMachineImpl(journal.NewJournalSpy())
+
+	expectedStateMachineType := reflect.TypeOf(&state.BTreeStateMachine{}).String()
+	actualStateMachineType := reflect.TypeOf(theStateMachine).String()
+
+	if err != nil || strings.Compare(expectedStateMachineType, actualStateMachineType) != 0 {
+		t.Errorf("State machine should have been set to type '%v' but instead was '%v'",
+			expectedStateMachineType,
+			actualStateMachineType)
+	}
+}
+
 func TestWhenUnableToCreateJournalThenTheServerIsNotCreated(t *testing.T) {
 
 	_ = os.Setenv(journalTypeEnvVar, "bogus")
@@ -305,6 +422,24 @@ func TestWhenStartingTheSystemThenTheServerIsStartedOnProvidedPort(t *testing.T)
 	}
 }
 
+func TestWhenStartingTheSystemThenTheServerFollowsTheLeadershipOfTheReplicator(t *testing.T) {
+
+	configEnvForSpies()
+	defer unsetSpiedEnv()
+
+	bootstrapper := New()
+	_ = bootstrapper.Init()
+
+	serverSpy := server.NewLifeCyclerSpy()
+	bootstrapper.server = serverSpy
+
+	bootstrapper.Start(0)
+
+	if !serverSpy.FollowsLeadership(bootstrapper.replicator.Leadership()) {
+		t.Errorf("Client API server should follow the leadership reported by the replicator")
+	}
+}
+
 func setup() *Bootstrap {
 
 	configEnvForSpies()
@@ -339,3 +474,100 @@ func configEnvForSpies() {
 	_ = os.Setenv(stateMachineTypeEnvVar, spyStateMachineType)
 	_ = os.Setenv(replicatorTypeEnvVar, spyReplicatorType)
 }
+
+func TestWhenStateMachineTypeEnvVarSetToProcessThenTheProxyImplementationIsUsed(t *testing.T) {
+
+	_ = os.Setenv(stateMachineTypeEnvVar, processStateMachineType)
+	_ = os.Setenv(stateMachineExecEnvVar, "/usr/local/bin/state-machine")
+	defer envCleanUp(stateMachineTypeEnvVar)
+	defer envCleanUp(stateMachineExecEnvVar)
+
+	theStateMachine, err := resolveStateMachineImpl(journal.NewJournalSpy())
+
+	expectedStateMachineType := reflect.TypeOf(&process.Proxy{}).String()
+	actualStateMachineType := reflect.TypeOf(theStateMachine).String()
+
+	if err != nil || strings.Compare(expectedStateMachineType, actualStateMachineType) != 0 {
+		t.Errorf("State machine should have been set to type '%v' but instead was '%v'",
+			expectedStateMachineType,
+			actualStateMachineType)
+	}
+}
+
+func TestWhenJournalIsTieredThenProcessStateMachineSnapshotsArePersistedNextToIt(t *testing.T) {
+
+	directory := filepath.Join(t.TempDir(), "journal")
+
+	_ = os.Setenv(journalDirEnvVar, directory)
+	defer envCleanUp(journalDirEnvVar)
+
+	config := process.NewDefaultConfig("/usr/local/bin/state-machine")
+
+	err := persistSnapshots(config)
+
+	if err != nil || config.SnapshotFile != directory+stateSnapshotFileSuffix {
+		t.Errorf("Snapshots should have been persisted to '%s%s' but got '%s' and error '%v'", directory,
+			stateSnapshotFileSuffix, config.SnapshotFile, err)
+	}
+}
+
+func TestWhenProcessStateMachineHasNoExecutableThenAnErrorIsReturned(t *testing.T) {
+
+	_ = os.Setenv(stateMachineTypeEnvVar, processStateMachineType)
+	defer envCleanUp(stateMachineTypeEnvVar)
+
+	_, err := resolveStateMachineImpl(journal.NewJournalSpy())
+
+	if err != ErrMissingStateMachineExec {
+		t.Errorf("Should have received error '%v' but instead got '%v'", ErrMissingStateMachineExec, err)
+	}
+}
+
+func TestWhenStateMachineTypeEnvVarSetToWasmThenTheProxyOfTheModuleIsUsed(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "state-machine.wasm")
+	_ = os.WriteFile(path, []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}, 0o600)
+
+	_ = os.Setenv(stateMachineTypeEnvVar, wasmStateMachineType)
+	_ = os.Setenv(stateMachineModEnvVar, path)
+	defer envCleanUp(stateMachineTypeEnvVar)
+	defer envCleanUp(stateMachineModEnvVar)
+
+	theStateMachine, err := resolveStateMachineImpl(journal.NewJournalSpy())
+
+	expectedStateMachineType := reflect.TypeOf(&process.Proxy{}).String()
+	actualStateMachineType := reflect.TypeOf(theStateMachine).String()
+
+	if err != nil || strings.Compare(expectedStateMachineType, actualStateMachineType) != 0 {
+		t.Errorf("State machine should have been set to type '%v' but instead was '%v' with error '%v'",
+			expectedStateMachineType,
+			actualStateMachineType,
+			err)
+	}
+}
+
+func TestWhenWasmStateMachineHasNoModuleThenAnErrorIsReturned(t *testing.T) {
+
+	_ = os.Setenv(stateMachineTypeEnvVar, wasmStateMachineType)
+	defer envCleanUp(stateMachineTypeEnvVar)
+
+	_, err := resolveStateMachineImpl(journal.NewJournalSpy())
+
+	if ErrMissingStateMachineMod != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrMissingStateMachineMod, err)
+	}
+}
+
+func TestWhenWasmStateMachineModuleCannotBeReadThenAnErrorIsReturned(t *testing.T) {
+
+	_ = os.Setenv(stateMachineTypeEnvVar, wasmStateMachineType)
+	_ = os.Setenv(stateMachineModEnvVar, filepath.Join(t.TempDir(), "missing.wasm"))
+	defer envCleanUp(stateMachineTypeEnvVar)
+	defer envCleanUp(stateMachineModEnvVar)
+
+	_, err := resolveStateMachineImpl(journal.NewJournalSpy())
+
+	if ErrUnreadableStateMachine != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrUnreadableStateMachine, err)
+	}
+}
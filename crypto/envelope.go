@@ -0,0 +1,72 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const envelopeHeaderSize = 4
+
+var ErrBadEnvelope = errors.New("data is too short to be a sealed envelope")
+
+// SealEnvelope wraps plaintext in a self describing envelope, the ID of the key used followed by the sealed data.
+// Whole files, such as state machine snapshots, are stored as envelopes. A nil keyring produces an envelope holding
+// the plaintext under NoKeyID
+func SealEnvelope(keyring *Keyring, plaintext []byte) ([]byte, error) {
+
+	keyID := NoKeyID
+	body := plaintext
+
+	var err error
+
+	if keyring != nil {
+		keyID, body, err = keyring.Seal(plaintext, nil)
+	}
+
+	envelope := make([]byte, envelopeHeaderSize, envelopeHeaderSize+len(body))
+	binary.LittleEndian.PutUint32(envelope, keyID)
+
+	return append(envelope, body...), err
+}
+
+// OpenEnvelope returns the plaintext held in envelope, opening it with the key recorded in the envelope when it
+// was sealed
+func OpenEnvelope(keyring *Keyring, envelope []byte) ([]byte, error) {
+
+	var plaintext []byte
+	var err error
+
+	if len(envelope) < envelopeHeaderSize {
+		err = ErrBadEnvelope
+	} else {
+		plaintext, err = openEnvelopeBody(keyring, EnvelopeKeyID(envelope), envelope[envelopeHeaderSize:])
+	}
+
+	return plaintext, err
+}
+
+// EnvelopeKeyID returns the ID of the key envelope was sealed with, NoKeyID for plaintext envelopes
+func EnvelopeKeyID(envelope []byte) uint32 {
+
+	keyID := NoKeyID
+
+	if len(envelope) >= envelopeHeaderSize {
+		keyID = binary.LittleEndian.Uint32(envelope)
+	}
+
+	return keyID
+}
+
+func openEnvelopeBody(keyring *Keyring, keyID uint32, body []byte) ([]byte, error) {
+
+	plaintext := body
+	var err error
+
+	if keyID != NoKeyID && keyring == nil {
+		err = ErrUnknownKeyID
+	} else if keyID != NoKeyID {
+		plaintext, err = keyring.Open(keyID, body, nil)
+	}
+
+	return plaintext, err
+}
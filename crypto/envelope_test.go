@@ -0,0 +1,42 @@
+package crypto
+
+import (
+	"testing"
+)
+
+func TestWhenEnvelopeSealedWithKeyringThenItRecordsTheKeyAndOpens(t *testing.T) {
+
+	keyring, _ := ParseKeyring(keySpec(3))
+
+	envelope, _ := SealEnvelope(keyring, []byte("snapshot"))
+
+	plaintext, err := OpenEnvelope(keyring, envelope)
+
+	if err != nil || string(plaintext) != "snapshot" || EnvelopeKeyID(envelope) != 3 {
+		t.Errorf("Expected envelope sealed with key 3 to open but got '%s' and error '%v'", plaintext, err)
+	}
+}
+
+func TestWhenEnvelopeSealedWithoutKeyringThenItHoldsPlaintext(t *testing.T) {
+
+	envelope, _ := SealEnvelope(nil, []byte("snapshot"))
+
+	plaintext, err := OpenEnvelope(nil, envelope)
+
+	if err != nil || string(plaintext) != "snapshot" || EnvelopeKeyID(envelope) != NoKeyID {
+		t.Errorf("Expected a plaintext envelope but got '%s' and error '%v'", plaintext, err)
+	}
+}
+
+func TestWhenSealedEnvelopeOpenedWithoutKeyringThenUnknownKeyErrorIsReturned(t *testing.T) {
+
+	keyring, _ := ParseKeyring(keySpec(3))
+
+	envelope, _ := SealEnvelope(keyring, []byte("snapshot"))
+
+	_, err := OpenEnvelope(nil, envelope)
+
+	if ErrUnknownKeyID != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrUnknownKeyID, err)
+	}
+}
@@ -0,0 +1,217 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// NoKeyID is the key ID recorded for data that is not encrypted. Keyring key IDs are always greater than NoKeyID
+	NoKeyID uint32 = 0
+
+	// KeyFileEnvVar names the environment variable holding the path of a key spec file, see ParseKeyring
+	KeyFileEnvVar = "ENCRYPTION_KEY_FILE"
+
+	// KeysEnvVar names the environment variable holding a key spec, it is only consulted when KeyFileEnvVar is unset
+	KeysEnvVar = "ENCRYPTION_KEYS"
+)
+
+var (
+	ErrDecryptionFailed = errors.New("encrypted data could not be authenticated, it is corrupt or the key is wrong")
+	ErrInvalidKeySpec   = errors.New("encryption key spec is not a list of <key id>:<base64 AES key> entries")
+	ErrNoKeys           = errors.New("encryption key spec does not contain any keys")
+	ErrUnknownKeyID     = errors.New("data was encrypted with a key that is not in the keyring")
+)
+
+// Keyring holds the AES-GCM keys used to encrypt data at rest. Data is always sealed with the active key, the key
+// with the highest ID, and the key ID is returned so it can be stored alongside the ciphertext. Older keys are kept
+// so data sealed before a rotation can still be opened. Keyring is safe for concurrent use
+type Keyring struct {
+	activeKeyID uint32
+	ciphers     map[uint32]cipher.AEAD
+}
+
+// ParseKeyring creates a Keyring from spec, a list of <key id>:<base64 AES key> entries separated by commas or new
+// lines. Key IDs must be greater than zero and keys must be 16, 24 or 32 bytes long for AES-128, AES-192 or
+// AES-256. Blank lines and lines starting with # are ignored. To rotate keys add a new key with a higher ID, it
+// becomes the active key, and keep the old keys until everything sealed with them has been re-encrypted
+func ParseKeyring(spec string) (*Keyring, error) {
+
+	keyring := &Keyring{ciphers: make(map[uint32]cipher.AEAD)}
+
+	var err error
+
+	for _, keySpec := range splitKeySpec(spec) {
+
+		if err == nil {
+			err = keyring.addKey(keySpec)
+		}
+	}
+
+	if err == nil && len(keyring.ciphers) == 0 {
+		err = ErrNoKeys
+	}
+
+	return keyring, err
+}
+
+// LoadKeyringFile creates a Keyring from the key spec stored in the file at path, see ParseKeyring
+func LoadKeyringFile(path string) (*Keyring, error) {
+
+	var keyring *Keyring
+
+	spec, err := os.ReadFile(path)
+
+	if err == nil {
+		keyring, err = ParseKeyring(string(spec))
+	}
+
+	return keyring, err
+}
+
+// LoadKeyringFromEnvironment creates a Keyring from the key spec file named by KeyFileEnvVar, or failing that from
+// the key spec in KeysEnvVar. Returns a nil Keyring, meaning data at rest is not encrypted, when neither is set
+func LoadKeyringFromEnvironment() (*Keyring, error) {
+
+	var keyring *Keyring
+	var err error
+
+	keyFile, isKeyFileSet := os.LookupEnv(KeyFileEnvVar)
+	keys, isKeysSet := os.LookupEnv(KeysEnvVar)
+
+	if isKeyFileSet {
+		keyring, err = LoadKeyringFile(keyFile)
+	} else if isKeysSet {
+		keyring, err = ParseKeyring(keys)
+	}
+
+	return keyring, err
+}
+
+// ActiveKeyID returns the ID of the key new data is sealed with
+func (keyring *Keyring) ActiveKeyID() uint32 {
+
+	return keyring.activeKeyID
+}
+
+// Seal encrypts and authenticates plaintext, and authenticates additionalData, with the active key. Returns the ID
+// of the key used and the sealed data, a random nonce followed by the ciphertext
+func (keyring *Keyring) Seal(plaintext []byte, additionalData []byte) (uint32, []byte, error) {
+
+	aead := keyring.ciphers[keyring.activeKeyID]
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+
+	_, err := rand.Read(nonce)
+
+	return keyring.activeKeyID, aead.Seal(nonce, nonce, plaintext, additionalData), err
+}
+
+// Open authenticates and decrypts sealed, produced by Seal with the key keyID. Returns ErrUnknownKeyID if the key
+// is not in the keyring and ErrDecryptionFailed if sealed or additionalData have been tampered with
+func (keyring *Keyring) Open(keyID uint32, sealed []byte, additionalData []byte) ([]byte, error) {
+
+	var plaintext []byte
+	var err error
+
+	aead, ok := keyring.ciphers[keyID]
+
+	if !ok {
+		err = ErrUnknownKeyID
+	} else if len(sealed) < aead.NonceSize() {
+		err = ErrDecryptionFailed
+	} else {
+		plaintext, err = aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+	}
+
+	if err != nil && err != ErrUnknownKeyID {
+		err = ErrDecryptionFailed
+	}
+
+	return plaintext, err
+}
+
+func (keyring *Keyring) addKey(keySpec string) error {
+
+	keyID, aead, err := parseKey(keySpec)
+
+	if err == nil {
+
+		keyring.ciphers[keyID] = aead
+
+		if keyID > keyring.activeKeyID {
+			keyring.activeKeyID = keyID
+		}
+	}
+
+	return err
+}
+
+func parseKey(keySpec string) (uint32, cipher.AEAD, error) {
+
+	var aead cipher.AEAD
+	var key []byte
+	var parsedID uint64
+
+	idAndKey := strings.SplitN(keySpec, ":", 2)
+
+	err := ErrInvalidKeySpec
+
+	if len(idAndKey) == 2 {
+		parsedID, err = strconv.ParseUint(strings.TrimSpace(idAndKey[0]), 10, 32)
+	}
+
+	if err == nil && parsedID == uint64(NoKeyID) {
+		err = ErrInvalidKeySpec
+	}
+
+	if err == nil {
+		key, err = base64.StdEncoding.DecodeString(strings.TrimSpace(idAndKey[1]))
+	}
+
+	if err == nil {
+		aead, err = newAEAD(key)
+	}
+
+	if err != nil && !errors.Is(err, ErrInvalidKeySpec) {
+		err = fmt.Errorf("%w: %v", ErrInvalidKeySpec, err)
+	}
+
+	return uint32(parsedID), aead, err
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+
+	var aead cipher.AEAD
+
+	block, err := aes.NewCipher(key)
+
+	if err == nil {
+		aead, err = cipher.NewGCM(block)
+	}
+
+	return aead, err
+}
+
+func splitKeySpec(spec string) []string {
+
+	var keySpecs []string
+
+	for _, line := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+
+		line = strings.TrimSpace(line)
+
+		if line != "" && !strings.HasPrefix(line, "#") {
+			keySpecs = append(keySpecs, line)
+		}
+	}
+
+	return keySpecs
+}
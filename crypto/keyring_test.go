@@ -0,0 +1,130 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWhenKeySpecHasSeveralKeysThenTheHighestIDIsActive(t *testing.T) {
+
+	keyring, err := ParseKeyring(keySpec(3) + "," + keySpec(9) + "\n# retired soon\n" + keySpec(4))
+
+	if err != nil || keyring.ActiveKeyID() != 9 {
+		t.Errorf("Expected key 9 to be active but got %d and error '%v'", keyring.ActiveKeyID(), err)
+	}
+}
+
+func TestWhenKeySpecIsInvalidThenAnErrorIsReturned(t *testing.T) {
+
+	for _, spec := range []string{"no-separator", "0:" + testKey(), "1:not base64", "1:" + shortKey(), "x:" + testKey()} {
+
+		_, err := ParseKeyring(spec)
+
+		if !errors.Is(err, ErrInvalidKeySpec) {
+			t.Errorf("Spec '%s' should have been rejected with '%v' but got '%v'", spec, ErrInvalidKeySpec, err)
+		}
+	}
+}
+
+func TestWhenKeySpecIsEmptyThenNoKeysErrorIsReturned(t *testing.T) {
+
+	_, err := ParseKeyring("# nothing here\n")
+
+	if ErrNoKeys != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrNoKeys, err)
+	}
+}
+
+func TestWhenDataSealedThenItOpensWithTheSameKeyAndAdditionalData(t *testing.T) {
+
+	keyring, _ := ParseKeyring(keySpec(1))
+
+	keyID, sealed, _ := keyring.Seal([]byte("secret"), []byte("header"))
+
+	plaintext, err := keyring.Open(keyID, sealed, []byte("header"))
+
+	if err != nil || string(plaintext) != "secret" || bytes.Contains(sealed, []byte("secret")) {
+		t.Errorf("Expected sealed data to round trip but got '%s' and error '%v'", plaintext, err)
+	}
+}
+
+func TestWhenAdditionalDataAlteredThenOpenFails(t *testing.T) {
+
+	keyring, _ := ParseKeyring(keySpec(1))
+
+	keyID, sealed, _ := keyring.Seal([]byte("secret"), []byte("header"))
+
+	_, err := keyring.Open(keyID, sealed, []byte("altered"))
+
+	if ErrDecryptionFailed != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrDecryptionFailed, err)
+	}
+}
+
+func TestWhenKeysRotatedThenDataSealedWithTheOldKeyStillOpens(t *testing.T) {
+
+	oldKeyring, _ := ParseKeyring(keySpec(1))
+	keyID, sealed, _ := oldKeyring.Seal([]byte("secret"), nil)
+
+	rotatedKeyring, _ := ParseKeyring(keySpec(1) + "," + keySpec(2))
+	plaintext, err := rotatedKeyring.Open(keyID, sealed, nil)
+
+	if err != nil || string(plaintext) != "secret" || rotatedKeyring.ActiveKeyID() != 2 {
+		t.Errorf("Expected old data to open after rotation but got '%s' and error '%v'", plaintext, err)
+	}
+}
+
+func TestWhenKeyIsNotInTheKeyringThenUnknownKeyErrorIsReturned(t *testing.T) {
+
+	keyring, _ := ParseKeyring(keySpec(1))
+
+	_, err := keyring.Open(5, []byte("whatever sealed data"), nil)
+
+	if ErrUnknownKeyID != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrUnknownKeyID, err)
+	}
+}
+
+func TestWhenKeyFileEnvVarSetThenKeyringIsLoadedFromTheFile(t *testing.T) {
+
+	keyFile := filepath.Join(t.TempDir(), "keys")
+	_ = os.WriteFile(keyFile, []byte(keySpec(6)), 0o600)
+
+	t.Setenv(KeyFileEnvVar, keyFile)
+	t.Setenv(KeysEnvVar, keySpec(2))
+
+	keyring, err := LoadKeyringFromEnvironment()
+
+	if err != nil || keyring.ActiveKeyID() != 6 {
+		t.Errorf("Expected keyring from the key file with key 6 but got %v and error '%v'", keyring, err)
+	}
+}
+
+func TestWhenNoKeysInTheEnvironmentThenNoKeyringIsReturned(t *testing.T) {
+
+	keyring, err := LoadKeyringFromEnvironment()
+
+	if err != nil || keyring != nil {
+		t.Errorf("Expected no keyring but got %v and error '%v'", keyring, err)
+	}
+}
+
+func keySpec(keyID int) string {
+
+	return fmt.Sprintf("%d:%s", keyID, testKey())
+}
+
+func testKey() string {
+
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x42}, 32))
+}
+
+func shortKey() string {
+
+	return base64.StdEncoding.EncodeToString([]byte("too short"))
+}
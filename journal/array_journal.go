@@ -11,32 +11,60 @@ var (
 	ErrIndexOutOfBounds       = errors.New("attempt to access journal item with an out of bounds index")
 	ErrInvertedIndexes        = errors.New("start index is greater than end index, indexes are inverted")
 	ErrSubscriptionOnEmptyLog = errors.New("attempt to setup subscription to index on an empty log")
+	ErrUnbufferedSubscription = errors.New("commit subscription channel must be buffered to hold its notification")
 )
 
 const (
-	Append                   = "append"
-	Commit                   = "commit"
-	GetAllUncommittedEntries = "get-all-uncommitted-entries"
+	Append                    = "append"
+	Commit                    = "commit"
+	GetAllCommittedEntries    = "get-all-committed-entries"
+	GetAllEntriesBetween      = "get-all-entries-between"
+	GetAllUncommittedEntries  = "get-all-uncommitted-entries"
+	GetHead                   = "get-head"
+	NotifyOfAllCommitChanges  = "notify-of-all-commit-changes"
+	NotifyOfCommitOnIndexOnce = "notify-of-commit-on-index-once"
+	OpenCommittedEntries      = "open-committed-entries"
 )
 
 type ArrayJournalCommand struct {
 	appendDoneCh                   chan AppendResult
 	appendEntry                    Entry
+	beginIndex                     uint64
 	commitDoneCh                   chan CommitResult
 	commitIndex                    int64
+	cursorDoneCh                   chan Cursor
+	endIndex                       uint64
+	entriesDoneCh                  chan entriesResult
 	getAllUncommittedEntriesDoneCh chan AllUncommittedEntriesResult
+	headDoneCh                     chan headResult
 	name                           string
+	notifyCh                       chan bool
+	notifyDoneCh                   chan error
+	notifyIndex                    uint64
+	subscribeCh                    chan uint64
+}
+
+type entriesResult struct {
+	iterator Iterator
+	err      error
+}
+
+type headResult struct {
+	entry Entry
+	err   error
 }
 
 // ArrayJournal implements an append only Last In First Out (LIFO) stack. This type allows for safe concurrent
-// usage as it is intended to be used as a singleton in a highly parallel execution environment
+// usage as it is intended to be used as a singleton in a highly parallel execution environment. All reads and
+// writes of the log state are serialized through a single command processing routine, callers never touch the
+// log state directly
 type ArrayJournal struct {
 	theLog                             []Entry
 	headIndex                          int64
 	oneTimeCommitChangeSubscribers     map[uint64][]chan bool
 	commitIndex                        int64
 	workQueue                          chan ArrayJournalCommand
-	notifyOfAllCommitChangeSubscribers []chan uint64
+	notifyOfAllCommitChangeSubscribers []*commitForwarder
 }
 
 func NewArrayJournal() *ArrayJournal {
@@ -47,7 +75,7 @@ func NewArrayJournal() *ArrayJournal {
 		headIndex:                          -1,
 		commitIndex:                        -1,
 		oneTimeCommitChangeSubscribers:     make(map[uint64][]chan bool),
-		notifyOfAllCommitChangeSubscribers: make([]chan uint64, 0, 16),
+		notifyOfAllCommitChangeSubscribers: make([]*commitForwarder, 0, 16),
 	}
 
 	go journal.processCommands()
@@ -55,16 +83,13 @@ func NewArrayJournal() *ArrayJournal {
 	return journal
 }
 
-// Append adds an Entry to the log as the new Head item with Key and Item set as the respective Entry
-// Item elements.
+// Append adds an Entry to the log as the new Head item with Item as the Entry Item and its checksum computed.
 // Append is safe for concurrent execution
 func (journal *ArrayJournal) Append(item []byte) chan AppendResult {
 
-	entry := Entry{
-		Item: item,
-	}
+	entry := NewEntry(item)
 
-	doneCh := make(chan AppendResult)
+	doneCh := make(chan AppendResult, 1)
 
 	appendItem := ArrayJournalCommand{
 		name:         Append,
@@ -82,40 +107,40 @@ func (journal *ArrayJournal) Append(item []byte) chan AppendResult {
 // GetHead is safe for concurrent execution
 func (journal *ArrayJournal) GetHead() (Entry, error) {
 
-	var headEntry Entry
-	var err error
-
-	if journal.headIndex >= 0 {
+	doneCh := make(chan headResult, 1)
 
-		headEntry = journal.theLog[journal.headIndex]
-	} else {
-		err = ErrEmptyLog
+	command := ArrayJournalCommand{
+		name:       GetHead,
+		headDoneCh: doneCh,
 	}
 
-	return headEntry, err
+	journal.workQueue <- command
+
+	result := <-doneCh
+
+	return result.entry, result.err
 }
 
 // NotifyOfCommitOnIndexOnce registers notification channel ch with log index. When a Commit is made
 // on an index greater than or equal to index, then the registered ch will receive a value of true if there are
-// no errors and false if there was an error.
+// no errors and false if there was an error. ch must be buffered to hold the notification, the journal never blocks
+// on a subscriber that has stopped waiting and drops the notification of a ch that is already full.
+// Returns ErrUnbufferedSubscription if ch is unbuffered, a notification sent to it could never be delivered
 // NotifyOfCommitOnIndexOnce is safe for concurrent execution
 func (journal *ArrayJournal) NotifyOfCommitOnIndexOnce(index uint64, ch chan bool) error {
 
-	var err error
+	doneCh := make(chan error, 1)
 
-	// TODO need telemetry here
-	if journal.isEmptyLog() {
-
-		err = ErrSubscriptionOnEmptyLog
-	} else if journal.isCommitIndexWithinValidRange(index) {
-
-		journal.oneTimeCommitChangeSubscribers[index] = append(journal.oneTimeCommitChangeSubscribers[index], ch)
-	} else {
-
-		err = ErrIndexBeyondHead
+	command := ArrayJournalCommand{
+		name:         NotifyOfCommitOnIndexOnce,
+		notifyIndex:  index,
+		notifyCh:     ch,
+		notifyDoneCh: doneCh,
 	}
 
-	return err
+	journal.workQueue <- command
+
+	return <-doneCh
 }
 
 // Commit attempts to commit the index. Per the Raft protocol once an index is committed then all
@@ -130,7 +155,7 @@ func (journal *ArrayJournal) NotifyOfCommitOnIndexOnce(index uint64, ch chan boo
 // Commit is safe for concurrent execution
 func (journal *ArrayJournal) Commit(index uint64) chan CommitResult {
 
-	doneCh := make(chan CommitResult)
+	doneCh := make(chan CommitResult, 1)
 
 	command := ArrayJournalCommand{
 		name:         Commit,
@@ -143,34 +168,67 @@ func (journal *ArrayJournal) Commit(index uint64) chan CommitResult {
 	return doneCh
 }
 
-// GetAllCommittedEntries returns a read only Iterator wrapping the committed entries in the journal
+// GetAllCommittedEntries returns a read only Iterator wrapping the committed entries in the journal. The
+// Iterator is a point in time copy, entries appended or committed after the call are not visible through it.
+// GetAllCommittedEntries is safe for concurrent execution
 func (journal *ArrayJournal) GetAllCommittedEntries() Iterator {
 
-	committedEntries := journal.theLog[0 : journal.commitIndex+1]
+	doneCh := make(chan entriesResult, 1)
 
-	return NewArrayJournalIterator(committedEntries)
+	command := ArrayJournalCommand{
+		name:          GetAllCommittedEntries,
+		entriesDoneCh: doneCh,
+	}
+
+	journal.workQueue <- command
+
+	result := <-doneCh
+
+	return result.iterator
 }
 
-// GetAllEntriesBetween returns a read only Iterator wrapping journal entries between beginIndex and sizeOfBackingArray inclusive
-// The values returned can include those that have not been committed yet.
-func (journal *ArrayJournal) GetAllEntriesBetween(beginIndex uint64, endIndex uint64) (Iterator, error) {
+// OpenCommittedEntries returns a Cursor over the entries committed at the time of the call. Entries are fetched
+// from the journal in batches as the Cursor is advanced.
+// OpenCommittedEntries is safe for concurrent execution
+func (journal *ArrayJournal) OpenCommittedEntries() (Cursor, error) {
 
-	var iterator Iterator
+	doneCh := make(chan Cursor, 1)
 
-	err := journal.validateIndexes(beginIndex, endIndex)
+	command := ArrayJournalCommand{
+		name:         OpenCommittedEntries,
+		cursorDoneCh: doneCh,
+	}
 
-	if err == nil {
-		entries := journal.theLog[beginIndex : endIndex+1]
+	journal.workQueue <- command
 
-		iterator = NewArrayJournalIterator(entries)
+	return <-doneCh, nil
+}
+
+// GetAllEntriesBetween returns a read only Iterator wrapping journal entries between beginIndex and endIndex inclusive
+// The values returned can include those that have not been committed yet. The Iterator is a point in time copy,
+// entries appended after the call are not visible through it.
+// GetAllEntriesBetween is safe for concurrent execution
+func (journal *ArrayJournal) GetAllEntriesBetween(beginIndex uint64, endIndex uint64) (Iterator, error) {
+
+	doneCh := make(chan entriesResult, 1)
+
+	command := ArrayJournalCommand{
+		name:          GetAllEntriesBetween,
+		beginIndex:    beginIndex,
+		endIndex:      endIndex,
+		entriesDoneCh: doneCh,
 	}
 
-	return iterator, err
+	journal.workQueue <- command
+
+	result := <-doneCh
+
+	return result.iterator, result.err
 }
 
 func (journal *ArrayJournal) GetAllUncommittedEntries() chan AllUncommittedEntriesResult {
 
-	doneCh := make(chan AllUncommittedEntriesResult)
+	doneCh := make(chan AllUncommittedEntriesResult, 1)
 
 	command := ArrayJournalCommand{
 		name:                           GetAllUncommittedEntries,
@@ -183,10 +241,18 @@ func (journal *ArrayJournal) GetAllUncommittedEntries() chan AllUncommittedEntri
 }
 
 // NotifyOfAllCommitChanges registers channel ch to receive notifications when indexes in the journal
-// are committed. The channel ch will receive the index that was committed.
+// are committed. The channel ch will receive the index that was committed. Notifications are delivered in
+// commit order but a subscriber that falls behind only receives the latest commit index, intermediate indexes
+// are coalesced. A slow subscriber never blocks the journal.
+// NotifyOfAllCommitChanges is safe for concurrent execution
 func (journal *ArrayJournal) NotifyOfAllCommitChanges(ch chan uint64) {
 
-	journal.notifyOfAllCommitChangeSubscribers = append(journal.notifyOfAllCommitChangeSubscribers, ch)
+	command := ArrayJournalCommand{
+		name:        NotifyOfAllCommitChanges,
+		subscribeCh: ch,
+	}
+
+	journal.workQueue <- command
 }
 
 func (journal *ArrayJournal) notifySubscribers(commitIndex uint64) {
@@ -198,33 +264,17 @@ func (journal *ArrayJournal) notifySubscribers(commitIndex uint64) {
 
 func (journal *ArrayJournal) notifyDurableSubscribers(commitIndex uint64) {
 
-	for _, notificationCh := range journal.notifyOfAllCommitChangeSubscribers {
+	for _, forwarder := range journal.notifyOfAllCommitChangeSubscribers {
 
-		notificationCh <- commitIndex
+		forwarder.forward(commitIndex)
 	}
 }
 
 func (journal *ArrayJournal) notifyOneTimeSubscribers(commitIndex uint64) {
 
-	for subscribedIndex, notificationChs := range journal.oneTimeCommitChangeSubscribers {
-
-		if commitIndex >= subscribedIndex {
-
-			journal.notifyAllRegisteredSubscribersForIndex(subscribedIndex, notificationChs)
-		}
-	}
-}
-
-func (journal *ArrayJournal) notifyAllRegisteredSubscribersForIndex(subscribedIndex uint64,
-	notificationChs []chan bool) {
+	notificationChs := removeOneTimeSubscribersUpTo(journal.oneTimeCommitChangeSubscribers, commitIndex)
 
-	// Clean these up before notifying subscribers
-	delete(journal.oneTimeCommitChangeSubscribers, subscribedIndex)
-
-	for _, notificationCh := range notificationChs {
-
-		notificationCh <- true
-	}
+	notifyOneTimeSubscribers(notificationChs)
 }
 
 func (journal *ArrayJournal) isCommitIndexWithinValidRange(index uint64) bool {
@@ -271,13 +321,109 @@ func (journal *ArrayJournal) processCommands() {
 
 			journal.commit(command)
 
+		case GetAllCommittedEntries:
+
+			journal.getAllCommittedEntries(command)
+
+		case GetAllEntriesBetween:
+
+			journal.getAllEntriesBetween(command)
+
 		case GetAllUncommittedEntries:
 
 			journal.getAllUncommittedEntries(command)
+
+		case GetHead:
+
+			journal.getHead(command)
+
+		case NotifyOfAllCommitChanges:
+
+			journal.notifyOfAllCommitChanges(command)
+
+		case NotifyOfCommitOnIndexOnce:
+
+			journal.notifyOfCommitOnIndexOnce(command)
+
+		case OpenCommittedEntries:
+
+			command.cursorDoneCh <- newBatchCursor(journal.GetAllEntriesBetween, journal.commitIndex,
+				defaultCursorBatchSize)
 		}
 	}
 }
 
+func (journal *ArrayJournal) getHead(command ArrayJournalCommand) {
+
+	var result headResult
+
+	if journal.headIndex >= 0 {
+
+		result.entry = journal.theLog[journal.headIndex]
+	} else {
+		result.err = ErrEmptyLog
+	}
+
+	command.headDoneCh <- result
+}
+
+func (journal *ArrayJournal) getAllCommittedEntries(command ArrayJournalCommand) {
+
+	committedEntries := copyEntries(journal.theLog[0 : journal.commitIndex+1])
+
+	command.entriesDoneCh <- entriesResult{
+		iterator: NewArrayJournalIterator(committedEntries),
+	}
+}
+
+func (journal *ArrayJournal) getAllEntriesBetween(command ArrayJournalCommand) {
+
+	var result entriesResult
+
+	result.err = journal.validateIndexes(command.beginIndex, command.endIndex)
+
+	if result.err == nil {
+
+		entries := copyEntries(journal.theLog[command.beginIndex : command.endIndex+1])
+
+		result.iterator = NewArrayJournalIterator(entries)
+	}
+
+	command.entriesDoneCh <- result
+}
+
+func (journal *ArrayJournal) notifyOfAllCommitChanges(command ArrayJournalCommand) {
+
+	forwarder := newCommitForwarder(command.subscribeCh)
+
+	journal.notifyOfAllCommitChangeSubscribers = append(journal.notifyOfAllCommitChangeSubscribers, forwarder)
+}
+
+func (journal *ArrayJournal) notifyOfCommitOnIndexOnce(command ArrayJournalCommand) {
+
+	var err error
+
+	index := command.notifyIndex
+
+	// TODO need telemetry here
+	if cap(command.notifyCh) == 0 {
+
+		err = ErrUnbufferedSubscription
+	} else if journal.isEmptyLog() {
+
+		err = ErrSubscriptionOnEmptyLog
+	} else if journal.isCommitIndexWithinValidRange(index) {
+
+		journal.oneTimeCommitChangeSubscribers[index] = append(journal.oneTimeCommitChangeSubscribers[index],
+			command.notifyCh)
+	} else {
+
+		err = ErrIndexBeyondHead
+	}
+
+	command.notifyDoneCh <- err
+}
+
 func (journal *ArrayJournal) getAllUncommittedEntries(command ArrayJournalCommand) {
 
 	var uncommittedEntries *ArrayJournalIterator
@@ -293,7 +439,7 @@ func (journal *ArrayJournal) getAllUncommittedEntries(command ArrayJournalComman
 			backingArray = journal.theLog[journal.commitIndex+1 : journal.headIndex+1]
 		}
 
-		uncommittedEntries = NewArrayJournalIterator(backingArray)
+		uncommittedEntries = NewArrayJournalIterator(copyEntries(backingArray))
 	} else {
 		uncommittedEntries = NewArrayJournalIterator([]Entry{})
 	}
@@ -342,9 +488,48 @@ func (journal *ArrayJournal) append(command ArrayJournalCommand) {
 	command.appendDoneCh <- result
 }
 
+// removeOneTimeSubscribersUpTo cleans up all subscribers satisfied by commitIndex before any of them are notified
+func removeOneTimeSubscribersUpTo(subscribers map[uint64][]chan bool, commitIndex uint64) []chan bool {
+
+	satisfied := make([]chan bool, 0, len(subscribers))
+
+	for subscribedIndex, notificationChs := range subscribers {
+
+		if commitIndex >= subscribedIndex {
+
+			satisfied = append(satisfied, notificationChs...)
+			delete(subscribers, subscribedIndex)
+		}
+	}
+
+	return satisfied
+}
+
+func notifyOneTimeSubscribers(notificationChs []chan bool) {
+
+	for _, notificationCh := range notificationChs {
+
+		// subscribers are free to ignore their notification, never let one stall the journal
+		select {
+		case notificationCh <- true:
+		default:
+		}
+	}
+}
+
 func indexesInBounds(beginIndex uint64, endIndex uint64, logLen uint64) bool {
 
-	return beginIndex < logLen && endIndex <= logLen
+	return beginIndex < logLen && endIndex < logLen
+}
+
+// copyEntries isolates iterators handed to callers from the live log, later appends can grow or reallocate the
+// backing array of theLog
+func copyEntries(entries []Entry) []Entry {
+
+	isolated := make([]Entry, len(entries))
+	copy(isolated, entries)
+
+	return isolated
 }
 
 func (journal *ArrayJournal) isUncommitted() bool {
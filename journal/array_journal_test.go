@@ -3,7 +3,9 @@ package journal
 import (
 	"bytes"
 	"errors"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestWhenConstructedThenTheAppendQueueIsInitialized(t *testing.T) {
@@ -77,7 +79,7 @@ func TestWhenAttemptingToSubscribeToCommitIndexThatIsBeyondTheHeadOfTheLogThenAn
 
 	testContext.appendEntries(3)
 
-	err := testContext.journal.NotifyOfCommitOnIndexOnce(99, make(chan bool))
+	err := testContext.journal.NotifyOfCommitOnIndexOnce(99, make(chan bool, 1))
 
 	if ErrIndexBeyondHead != err {
 		t.Errorf("Should have received error '%v' but instead got '%v'",
@@ -86,11 +88,26 @@ func TestWhenAttemptingToSubscribeToCommitIndexThatIsBeyondTheHeadOfTheLogThenAn
 	}
 }
 
+func TestWhenAttemptingToSubscribeToCommitIndexWithAnUnbufferedChannelThenAnErrorIsReturned(t *testing.T) {
+
+	testContext := setup()
+
+	testContext.appendEntries(3)
+
+	err := testContext.journal.NotifyOfCommitOnIndexOnce(1, make(chan bool))
+
+	if ErrUnbufferedSubscription != err {
+		t.Errorf("Should have received error '%v' but instead got '%v'",
+			ErrUnbufferedSubscription,
+			err)
+	}
+}
+
 func TestWhenAttemptingToSubscribeToCommitIndexOnEmptyLogThenAnErrorIsReturned(t *testing.T) {
 
 	testContext := setup()
 
-	err := testContext.journal.NotifyOfCommitOnIndexOnce(4, make(chan bool))
+	err := testContext.journal.NotifyOfCommitOnIndexOnce(4, make(chan bool, 1))
 
 	if ErrSubscriptionOnEmptyLog != err {
 		t.Errorf("Should have received error '%v' but instead got '%v'",
@@ -103,7 +120,7 @@ func TestGivenSubscriptionForCommitIndexWhenIndexIsCommittedThenChannelIsNotifie
 
 	testContext := setup()
 
-	subNotifyCh := make(chan bool)
+	subNotifyCh := make(chan bool, 1)
 
 	testContext.appendEntries(2)
 
@@ -123,7 +140,7 @@ func TestGivenSubscriptionForCommitIndexWhenFutureIndexIsCommittedThenChannelIsN
 
 	testContext := setup()
 
-	subNotifyCh := make(chan bool)
+	subNotifyCh := make(chan bool, 1)
 
 	testContext.appendEntries(3)
 
@@ -455,6 +472,201 @@ func TestWhenNonEmptyJournalAndThereAreEntriesToCommitThenGetAllUncommittedEntri
 	}
 }
 
+func TestWhenEntriesAppendedAfterGettingEntriesBetweenThenTheIteratorIsNotAffected(t *testing.T) {
+
+	testContext := setup()
+
+	testContext.appendEntries(3)
+
+	entriesIt, _ := testContext.journal.GetAllEntriesBetween(0, 2)
+
+	otherItem := []byte("appended after the iterator was created")
+	<-testContext.journal.Append(otherItem)
+
+	for entriesIt.HasNext() {
+
+		entry, _ := entriesIt.Next()
+
+		if bytes.Equal(otherItem, entry.Item) {
+			t.Errorf("Iterator should have been isolated from later appends")
+		}
+	}
+
+	if entriesIt.Size() != 3 {
+		t.Errorf("Iterator should have had %d entries but had %d", 3, entriesIt.Size())
+	}
+}
+
+func TestWhenGettingEntriesBetweenJournalIndexesAndEndIndexEqualToLogLengthThenAnErrorIsReturned(t *testing.T) {
+
+	testContext := setup()
+
+	testContext.appendEntries(5)
+
+	_, err := testContext.journal.GetAllEntriesBetween(1, 5)
+
+	if ErrIndexOutOfBounds != err {
+		t.Errorf("Should have received error '%s' but instead got '%s'",
+			ErrIndexOutOfBounds,
+			err)
+	}
+}
+
+func TestGivenSubscriberThatReadsTheJournalWhenManyIndexesAreCommittedThenTheJournalDoesNotDeadlock(t *testing.T) {
+
+	testContext := setup()
+
+	const numEntries = 50
+
+	testContext.appendEntries(numEntries)
+
+	subscriberCh := make(chan uint64)
+	testContext.journal.NotifyOfAllCommitChanges(subscriberCh)
+
+	lastSeenCh := make(chan uint64)
+
+	go func() {
+
+		var index uint64
+
+		for index < numEntries-1 {
+
+			index = <-subscriberCh
+			_, _ = testContext.journal.GetAllEntriesBetween(0, index)
+		}
+
+		lastSeenCh <- index
+	}()
+
+	for i := uint64(0); i < numEntries; i++ {
+		<-testContext.journal.Commit(i)
+	}
+
+	select {
+	case lastSeen := <-lastSeenCh:
+		if lastSeen != numEntries-1 {
+			t.Errorf("Subscriber should have seen commit index %d but saw %d", numEntries-1, lastSeen)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("Subscriber was never notified of the final commit index, journal is deadlocked")
+	}
+}
+
+func TestWhenJournalIsUsedConcurrentlyThenAllAppendedEntriesAreAccountedFor(t *testing.T) {
+
+	testContext := setup()
+
+	const numWriters = 8
+	const appendsPerWriter = 200
+
+	var wg sync.WaitGroup
+
+	for writer := 0; writer < numWriters; writer++ {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			for i := 0; i < appendsPerWriter; i++ {
+
+				result := <-testContext.journal.Append(testContext.item)
+
+				<-testContext.journal.Commit(result.Index)
+			}
+		}()
+	}
+
+	for reader := 0; reader < numWriters; reader++ {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			testContext.readConcurrently(appendsPerWriter)
+		}()
+	}
+
+	wg.Wait()
+
+	head := numWriters*appendsPerWriter - 1
+
+	committedEntriesIt := testContext.journal.GetAllCommittedEntries()
+
+	if committedEntriesIt.Size() != head+1 {
+		t.Errorf("Expected %d committed entries but there were %d", head+1, committedEntriesIt.Size())
+	}
+}
+
+func TestWhenSubscribingAndCommittingConcurrentlyThenEverySubscriberIsNotified(t *testing.T) {
+
+	testContext := setup()
+
+	const numSubscribers = 100
+
+	testContext.appendEntries(numSubscribers)
+
+	var registered sync.WaitGroup
+	var notified sync.WaitGroup
+
+	for i := 0; i < numSubscribers; i++ {
+
+		registered.Add(1)
+		notified.Add(1)
+
+		go func(index uint64) {
+
+			defer notified.Done()
+
+			notifyCh := make(chan bool, 1)
+
+			_ = testContext.journal.NotifyOfCommitOnIndexOnce(index, notifyCh)
+			registered.Done()
+
+			<-notifyCh
+		}(uint64(i))
+	}
+
+	registered.Wait()
+
+	for i := 0; i < numSubscribers; i++ {
+		go testContext.journal.Commit(uint64(i))
+	}
+
+	notifiedCh := make(chan bool)
+	go func() { notified.Wait(); notifiedCh <- true }()
+
+	select {
+	case <-notifiedCh:
+	case <-time.After(2 * time.Second):
+		t.Errorf("All subscribers should have been notified of their commit")
+	}
+}
+
+func TestGivenSubscriberThatStoppedWaitingWhenIndexIsCommittedThenCommitCompletes(t *testing.T) {
+
+	testContext := setup()
+
+	testContext.appendEntries(2)
+
+	abandonedNotifyCh := make(chan bool, 1)
+	abandonedNotifyCh <- true
+
+	_ = testContext.journal.NotifyOfCommitOnIndexOnce(1, abandonedNotifyCh)
+
+	select {
+	case result := <-testContext.journal.Commit(1):
+		if result.Error != nil {
+			t.Errorf("Commit should have succeeded but got error '%v'", result.Error)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("Commit should not wait on a subscriber that stopped waiting")
+	}
+}
+
 type TestContext struct {
 	item     []byte
 	journal  *ArrayJournal
@@ -500,8 +712,8 @@ func (testContext *TestContext) setupMultipleOneTimeNotifications(notifyIndexOne
 	notifyIndexTwo uint64,
 	commitIndex uint64) (chan bool, chan bool) {
 
-	subscriberOneNotifyCh := make(chan bool)
-	subscriberTwoNotifyCh := make(chan bool)
+	subscriberOneNotifyCh := make(chan bool, 1)
+	subscriberTwoNotifyCh := make(chan bool, 1)
 
 	testContext.appendEntries(15)
 
@@ -527,3 +739,19 @@ func (testContext *TestContext) appendEntries(count int) uint64 {
 
 	return index
 }
+
+func (testContext *TestContext) readConcurrently(count int) {
+
+	for i := 0; i < count; i++ {
+
+		_, _ = testContext.journal.GetHead()
+
+		committedEntriesIt := testContext.journal.GetAllCommittedEntries()
+
+		for committedEntriesIt.HasNext() {
+			_, _ = committedEntriesIt.Next()
+		}
+
+		_, _ = testContext.journal.GetAllEntriesBetween(0, 0)
+	}
+}
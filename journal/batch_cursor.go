@@ -0,0 +1,111 @@
+package journal
+
+const defaultCursorBatchSize = 256
+
+type entryRangeReader func(beginIndex uint64, endIndex uint64) (Iterator, error)
+
+// batchCursor is a Cursor over journals that can serve arbitrary index ranges, e.g. ArrayJournal. Entries are
+// fetched batchSize at a time so that iterating a large range never materializes all of it at once
+type batchCursor struct {
+	readRange entryRangeReader
+	endIndex  int64
+	nextIndex uint64
+	lastIndex uint64
+	batch     Iterator
+	batchSize uint64
+}
+
+// newBatchCursor returns a Cursor over the entries from index 0 through endIndex inclusive
+func newBatchCursor(readRange entryRangeReader, endIndex int64, batchSize uint64) *batchCursor {
+
+	return &batchCursor{
+		readRange: readRange,
+		endIndex:  endIndex,
+		batchSize: batchSize,
+	}
+}
+
+func (cursor *batchCursor) Next() (Entry, error) {
+
+	var entry Entry
+	var err error
+
+	if cursor.HasNext() {
+		entry, err = cursor.nextFromBatch()
+	} else {
+		err = ErrNoMoreElements
+	}
+
+	return entry, err
+}
+
+func (cursor *batchCursor) HasNext() bool {
+
+	return int64(cursor.nextIndex) <= cursor.endIndex
+}
+
+func (cursor *batchCursor) Index() uint64 {
+
+	return cursor.lastIndex
+}
+
+// Seek returns ErrIndexOutOfBounds if index is beyond the end of the cursor
+func (cursor *batchCursor) Seek(index uint64) error {
+
+	var err error
+
+	if int64(index) > cursor.endIndex {
+		err = ErrIndexOutOfBounds
+	} else {
+
+		cursor.nextIndex = index
+		cursor.batch = nil
+	}
+
+	return err
+}
+
+func (cursor *batchCursor) Close() error {
+
+	cursor.batch = nil
+	cursor.endIndex = -1
+
+	return nil
+}
+
+func (cursor *batchCursor) nextFromBatch() (Entry, error) {
+
+	var entry Entry
+	var err error
+
+	if cursor.batch == nil || !cursor.batch.HasNext() {
+		cursor.batch, err = cursor.readRange(cursor.nextIndex, cursor.batchEnd())
+	}
+
+	if err == nil {
+		entry, err = cursor.batch.Next()
+		cursor.advance(err)
+	}
+
+	return entry, err
+}
+
+func (cursor *batchCursor) advance(err error) {
+
+	if err == nil {
+
+		cursor.lastIndex = cursor.nextIndex
+		cursor.nextIndex += 1
+	}
+}
+
+func (cursor *batchCursor) batchEnd() uint64 {
+
+	batchEnd := cursor.nextIndex + cursor.batchSize - 1
+
+	if int64(batchEnd) > cursor.endIndex {
+		batchEnd = uint64(cursor.endIndex)
+	}
+
+	return batchEnd
+}
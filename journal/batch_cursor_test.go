@@ -0,0 +1,126 @@
+package journal
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWhenIteratingBatchCursorThenEveryEntryIsReturnedInOrder(t *testing.T) {
+
+	testContext := setupCursorJournal(10)
+
+	cursor := newBatchCursor(testContext.journal.GetAllEntriesBetween, 9, 3)
+
+	for i := 0; cursor.HasNext(); i++ {
+
+		entry, _ := cursor.Next()
+
+		if string(entry.Item) != fmt.Sprintf("item %d", i) {
+			t.Errorf("Entry %d should have been 'item %d' but was '%s'", i, i, entry.Item)
+		}
+	}
+}
+
+func TestWhenBatchCursorReturnsAnEntryThenItsIndexIsReported(t *testing.T) {
+
+	testContext := setupCursorJournal(10)
+
+	cursor := newBatchCursor(testContext.journal.GetAllEntriesBetween, 9, 3)
+
+	for i := uint64(0); i < 5; i++ {
+		_, _ = cursor.Next()
+	}
+
+	if cursor.Index() != 4 {
+		t.Errorf("Index of the fifth entry should have been 4 but was %d", cursor.Index())
+	}
+}
+
+func TestWhenBatchCursorSeeksThenTheNextEntryIsTheOneAtTheSeekIndex(t *testing.T) {
+
+	testContext := setupCursorJournal(10)
+
+	cursor := newBatchCursor(testContext.journal.GetAllEntriesBetween, 9, 3)
+
+	_, _ = cursor.Next()
+	_ = cursor.Seek(7)
+
+	entry, _ := cursor.Next()
+
+	if string(entry.Item) != "item 7" || cursor.Index() != 7 {
+		t.Errorf("Expected 'item 7' at index 7 but got '%s' at index %d", entry.Item, cursor.Index())
+	}
+}
+
+func TestWhenBatchCursorSeeksBeyondItsEndThenAnErrorIsReturned(t *testing.T) {
+
+	testContext := setupCursorJournal(10)
+
+	cursor := newBatchCursor(testContext.journal.GetAllEntriesBetween, 4, 3)
+
+	err := cursor.Seek(5)
+
+	if ErrIndexOutOfBounds != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrIndexOutOfBounds, err)
+	}
+}
+
+func TestWhenBatchCursorExhaustedThenNextReturnsAnError(t *testing.T) {
+
+	testContext := setupCursorJournal(2)
+
+	cursor := newBatchCursor(testContext.journal.GetAllEntriesBetween, 1, 3)
+
+	_, _ = cursor.Next()
+	_, _ = cursor.Next()
+	_, err := cursor.Next()
+
+	if ErrNoMoreElements != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrNoMoreElements, err)
+	}
+}
+
+func TestWhenBatchCursorClosedThenItHasNoMoreEntries(t *testing.T) {
+
+	testContext := setupCursorJournal(5)
+
+	cursor := newBatchCursor(testContext.journal.GetAllEntriesBetween, 4, 3)
+
+	_ = cursor.Close()
+
+	if cursor.HasNext() {
+		t.Errorf("Closed cursor should not have any more entries")
+	}
+}
+
+func TestWhenOpeningCommittedEntriesOnArrayJournalThenOnlyCommittedEntriesAreReturned(t *testing.T) {
+
+	testContext := setupCursorJournal(10)
+
+	<-testContext.journal.Commit(5)
+
+	cursor, _ := testContext.journal.OpenCommittedEntries()
+
+	count := 0
+
+	for cursor.HasNext() {
+
+		_, _ = cursor.Next()
+		count += 1
+	}
+
+	if count != 6 {
+		t.Errorf("Cursor should have returned 6 committed entries but returned %d", count)
+	}
+}
+
+func setupCursorJournal(numEntries int) *TestContext {
+
+	testContext := setup()
+
+	for i := 0; i < numEntries; i++ {
+		<-testContext.journal.Append([]byte(fmt.Sprintf("item %d", i)))
+	}
+
+	return testContext
+}
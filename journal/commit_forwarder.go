@@ -0,0 +1,51 @@
+package journal
+
+import "sync"
+
+// commitForwarder decouples a NotifyOfAllCommitChanges subscriber from the routine that commits entries.
+// Commit indexes are cumulative, per the Raft protocol committing an index commits everything before it, so
+// when a subscriber falls behind only the latest index needs to be delivered.
+type commitForwarder struct {
+	mutex        sync.Mutex
+	latestIndex  uint64
+	pendingCh    chan struct{}
+	subscriberCh chan uint64
+}
+
+func newCommitForwarder(subscriberCh chan uint64) *commitForwarder {
+
+	forwarder := &commitForwarder{
+		pendingCh:    make(chan struct{}, 1),
+		subscriberCh: subscriberCh,
+	}
+
+	go forwarder.deliver()
+
+	return forwarder
+}
+
+// forward records index as the latest commit index for the subscriber. forward never blocks
+func (forwarder *commitForwarder) forward(index uint64) {
+
+	forwarder.mutex.Lock()
+	forwarder.latestIndex = index
+	forwarder.mutex.Unlock()
+
+	select {
+	case forwarder.pendingCh <- struct{}{}:
+	default:
+		// a delivery is already pending, it will pick up the latest index
+	}
+}
+
+func (forwarder *commitForwarder) deliver() {
+
+	for range forwarder.pendingCh {
+
+		forwarder.mutex.Lock()
+		index := forwarder.latestIndex
+		forwarder.mutex.Unlock()
+
+		forwarder.subscriberCh <- index
+	}
+}
@@ -0,0 +1,65 @@
+package journal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWhenIndexForwardedThenTheSubscriberReceivesIt(t *testing.T) {
+
+	subscriberCh := make(chan uint64)
+
+	forwarder := newCommitForwarder(subscriberCh)
+
+	forwarder.forward(7)
+
+	index := <-subscriberCh
+
+	if index != 7 {
+		t.Errorf("Subscriber should have received index %d but got %d", 7, index)
+	}
+}
+
+func TestGivenSubscriberNotReadingWhenIndexesForwardedThenForwardDoesNotBlock(t *testing.T) {
+
+	forwarder := newCommitForwarder(make(chan uint64))
+
+	forwardDoneCh := make(chan bool)
+
+	go func() {
+
+		for i := uint64(0); i < 100; i++ {
+			forwarder.forward(i)
+		}
+
+		forwardDoneCh <- true
+	}()
+
+	select {
+	case <-forwardDoneCh:
+	case <-time.After(time.Second):
+		t.Errorf("Forwarding to a slow subscriber should not have blocked")
+	}
+}
+
+func TestGivenSlowSubscriberWhenManyIndexesForwardedThenTheLatestIndexIsEventuallyDelivered(t *testing.T) {
+
+	subscriberCh := make(chan uint64)
+
+	forwarder := newCommitForwarder(subscriberCh)
+
+	for i := uint64(0); i <= 100; i++ {
+		forwarder.forward(i)
+	}
+
+	var index uint64
+
+	for index != 100 {
+
+		select {
+		case index = <-subscriberCh:
+		case <-time.After(time.Second):
+			t.Fatalf("Latest index 100 was never delivered, last delivered index was %d", index)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package journal
+
+import "container/list"
+
+// entryCache is a least recently used cache of journal entries keyed by index. The cache is bounded by the total
+// size of the cached Entry items rather than by the number of entries, once budgetBytes is exceeded the least
+// recently used entries are evicted. entryCache is not safe for concurrent use
+type entryCache struct {
+	budgetBytes int
+	usedBytes   int
+	recency     *list.List
+	elements    map[uint64]*list.Element
+	evictions   uint64
+}
+
+type cachedEntry struct {
+	index uint64
+	entry Entry
+}
+
+func newEntryCache(budgetBytes int) *entryCache {
+
+	return &entryCache{
+		budgetBytes: budgetBytes,
+		recency:     list.New(),
+		elements:    make(map[uint64]*list.Element),
+	}
+}
+
+func (cache *entryCache) get(index uint64) (Entry, bool) {
+
+	var entry Entry
+
+	element, ok := cache.elements[index]
+
+	if ok {
+		cache.recency.MoveToFront(element)
+		entry = element.Value.(*cachedEntry).entry
+	}
+
+	return entry, ok
+}
+
+// put caches entry, entries larger than the whole budget are never cached
+func (cache *entryCache) put(index uint64, entry Entry) {
+
+	_, alreadyCached := cache.elements[index]
+
+	if !alreadyCached && len(entry.Item) <= cache.budgetBytes {
+
+		cache.elements[index] = cache.recency.PushFront(&cachedEntry{index: index, entry: entry})
+		cache.usedBytes += len(entry.Item)
+
+		cache.evictOverBudget()
+	}
+}
+
+func (cache *entryCache) evictOverBudget() {
+
+	for cache.usedBytes > cache.budgetBytes {
+
+		cache.remove(cache.recency.Back())
+		cache.evictions += 1
+	}
+}
+
+func (cache *entryCache) remove(element *list.Element) {
+
+	cached := cache.recency.Remove(element).(*cachedEntry)
+
+	delete(cache.elements, cached.index)
+	cache.usedBytes -= len(cached.entry.Item)
+}
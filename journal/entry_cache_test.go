@@ -0,0 +1,61 @@
+package journal
+
+import "testing"
+
+func TestWhenEntryCachedThenItCanBeRetrieved(t *testing.T) {
+
+	cache := newEntryCache(100)
+
+	cache.put(3, Entry{Item: []byte("three")})
+
+	entry, ok := cache.get(3)
+
+	if !ok || string(entry.Item) != "three" {
+		t.Errorf("Cached entry should have been retrieved, got '%s' found %t", entry.Item, ok)
+	}
+}
+
+func TestWhenBudgetExceededThenTheLeastRecentlyUsedEntryIsEvicted(t *testing.T) {
+
+	cache := newEntryCache(10)
+
+	cache.put(1, Entry{Item: []byte("aaaa")})
+	cache.put(2, Entry{Item: []byte("bbbb")})
+
+	cache.get(1)
+
+	cache.put(3, Entry{Item: []byte("cccc")})
+
+	_, oneCached := cache.get(1)
+	_, twoCached := cache.get(2)
+
+	if !oneCached || twoCached {
+		t.Errorf("Entry 2 was least recently used and should have been evicted, entry 1 should remain")
+	}
+}
+
+func TestWhenEntriesEvictedThenTheEvictionsAreCounted(t *testing.T) {
+
+	cache := newEntryCache(4)
+
+	cache.put(1, Entry{Item: []byte("aaaa")})
+	cache.put(2, Entry{Item: []byte("bbbb")})
+	cache.put(3, Entry{Item: []byte("cccc")})
+
+	if cache.evictions != 2 {
+		t.Errorf("There should have been 2 evictions but there were %d", cache.evictions)
+	}
+}
+
+func TestWhenEntryIsLargerThanTheBudgetThenItIsNotCached(t *testing.T) {
+
+	cache := newEntryCache(2)
+
+	cache.put(1, Entry{Item: []byte("too big")})
+
+	_, ok := cache.get(1)
+
+	if ok || cache.usedBytes != 0 {
+		t.Errorf("Entry larger than the whole budget should not have been cached")
+	}
+}
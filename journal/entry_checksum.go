@@ -0,0 +1,40 @@
+package journal
+
+import (
+	"errors"
+	"hash/crc32"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("journal entry checksum does not match its item, the entry is corrupt")
+
+	castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// NewEntry returns an Entry wrapping item with its checksum computed. All journals create their entries through
+// NewEntry so that every Entry carries a checksum from the moment it is appended
+func NewEntry(item []byte) Entry {
+
+	return Entry{
+		Item:     item,
+		Checksum: ChecksumOf(item),
+	}
+}
+
+// ChecksumOf returns the CRC32C (Castagnoli) checksum of item
+func ChecksumOf(item []byte) uint32 {
+
+	return crc32.Checksum(item, castagnoliTable)
+}
+
+// Verify returns ErrChecksumMismatch if the Entry Item no longer matches the checksum computed when it was appended
+func (entry Entry) Verify() error {
+
+	var err error
+
+	if ChecksumOf(entry.Item) != entry.Checksum {
+		err = ErrChecksumMismatch
+	}
+
+	return err
+}
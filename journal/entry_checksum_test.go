@@ -0,0 +1,51 @@
+package journal
+
+import (
+	"hash/crc32"
+	"testing"
+)
+
+func TestWhenEntryCreatedThenItsChecksumIsTheCrc32cOfTheItem(t *testing.T) {
+
+	item := []byte("checksum me")
+
+	entry := NewEntry(item)
+
+	expected := crc32.Checksum(item, crc32.MakeTable(crc32.Castagnoli))
+
+	if entry.Checksum != expected {
+		t.Errorf("Checksum should have been %d but was %d", expected, entry.Checksum)
+	}
+}
+
+func TestWhenEntryIsIntactThenVerifyReturnsNoError(t *testing.T) {
+
+	entry := NewEntry([]byte("intact"))
+
+	if err := entry.Verify(); err != nil {
+		t.Errorf("Intact entry should have verified but got '%v'", err)
+	}
+}
+
+func TestWhenEntryItemIsModifiedThenVerifyReturnsChecksumMismatch(t *testing.T) {
+
+	entry := NewEntry([]byte("intact"))
+	entry.Item = []byte("tampered")
+
+	if err := entry.Verify(); ErrChecksumMismatch != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrChecksumMismatch, err)
+	}
+}
+
+func TestWhenItemAppendedToArrayJournalThenTheEntryCarriesItsChecksum(t *testing.T) {
+
+	testContext := setup()
+
+	testContext.appendEntries(1)
+
+	head, _ := testContext.journal.GetHead()
+
+	if head.Checksum != ChecksumOf(testContext.item) {
+		t.Errorf("Head entry should have carried checksum %d but had %d", ChecksumOf(testContext.item), head.Checksum)
+	}
+}
@@ -0,0 +1,240 @@
+package journal
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jrobison153/raft/crypto"
+)
+
+// Codec identifies how the item of a stored or streamed Entry is encoded. The codec is recorded per record so a
+// log written with different codecs over time, or with small entries left uncompressed, stays readable
+type Codec byte
+
+const (
+	CodecNone Codec = iota
+	CodecGzip
+	CodecFlate
+
+	// DefaultCompressionMinBytes is the item size below which entries are stored uncompressed, the codec
+	// framing overhead outweighs any saving on smaller items
+	DefaultCompressionMinBytes = 128
+)
+
+var (
+	ErrUnknownCodec = errors.New("unknown journal entry codec")
+
+	codecNames = map[string]Codec{
+		"NONE":  CodecNone,
+		"GZIP":  CodecGzip,
+		"FLATE": CodecFlate,
+	}
+)
+
+// ParseCodec returns the Codec with the case insensitive name NONE, GZIP or FLATE
+func ParseCodec(name string) (Codec, error) {
+
+	var err error
+
+	codec, ok := codecNames[strings.ToUpper(name)]
+
+	if !ok {
+		err = fmt.Errorf("%w '%s'", ErrUnknownCodec, name)
+	}
+
+	return codec, err
+}
+
+// CompressionStats counts the bytes of Entry items passed through an EntryEncoder and the bytes they were encoded
+// into
+type CompressionStats struct {
+	UncompressedBytes uint64
+	EncodedBytes      uint64
+}
+
+// Ratio returns UncompressedBytes / EncodedBytes, 1 when nothing has been encoded
+func (stats CompressionStats) Ratio() float64 {
+
+	ratio := 1.0
+
+	if stats.EncodedBytes > 0 {
+		ratio = float64(stats.UncompressedBytes) / float64(stats.EncodedBytes)
+	}
+
+	return ratio
+}
+
+// EntryEncoder encodes entries into self describing records, see segment_record.go for the layout, used as the
+// segment file records. Only the segment files are compressed for now, replication is a single node no-op that
+// streams nothing to followers, once it does its frames still need a codec. Items smaller than the minimum size,
+// or that do not shrink, are stored uncompressed. When the encoder has a keyring the compressed item is sealed with
+// the active key. EntryEncoder is safe for concurrent use
+type EntryEncoder struct {
+	codec             Codec
+	minBytes          int
+	keyring           *crypto.Keyring
+	uncompressedBytes atomic.Uint64
+	encodedBytes      atomic.Uint64
+}
+
+// NewEntryEncoder returns an EntryEncoder compressing items of at least minBytes with codec
+func NewEntryEncoder(codec Codec, minBytes int) *EntryEncoder {
+
+	return &EntryEncoder{
+		codec:    codec,
+		minBytes: minBytes,
+	}
+}
+
+// EncryptWith makes the encoder seal every record it encodes with the active key of keyring, and open sealed
+// records it decodes with the matching key. Returns the encoder
+func (encoder *EntryEncoder) EncryptWith(keyring *crypto.Keyring) *EntryEncoder {
+
+	encoder.keyring = keyring
+
+	return encoder
+}
+
+// Encode returns the record for entry to be stored at position, a sealed record only opens at that position
+func (encoder *EntryEncoder) Encode(entry Entry, position RecordPosition) ([]byte, error) {
+
+	codec, payload := encoder.compress(entry.Item)
+
+	encoder.uncompressedBytes.Add(uint64(len(entry.Item)))
+	encoder.encodedBytes.Add(uint64(len(payload)))
+
+	var record []byte
+	var err error
+
+	if encoder.keyring == nil {
+		record = encodeRecord(entry.Checksum, codec, crypto.NoKeyID, payload)
+	} else {
+		record, err = encoder.seal(codec, payload, position)
+	}
+
+	return record, err
+}
+
+// Decode returns the Entry held in record, stored at position. Returns ErrChecksumMismatch if the record is corrupt
+func (encoder *EntryEncoder) Decode(record []byte, position RecordPosition) (Entry, error) {
+
+	return readRecord(bytes.NewReader(record), encoder.keyring, position)
+}
+
+func (encoder *EntryEncoder) seal(codec Codec, payload []byte, position RecordPosition) ([]byte, error) {
+
+	keyID := encoder.keyring.ActiveKeyID()
+
+	_, sealed, err := encoder.keyring.Seal(payload, recordAdditionalData(codec, keyID, position))
+
+	return encodeRecord(ChecksumOf(sealed), codec, keyID, sealed), err
+}
+
+// Stats returns a snapshot of the bytes encoded so far
+func (encoder *EntryEncoder) Stats() CompressionStats {
+
+	return CompressionStats{
+		UncompressedBytes: encoder.uncompressedBytes.Load(),
+		EncodedBytes:      encoder.encodedBytes.Load(),
+	}
+}
+
+func (encoder *EntryEncoder) compress(item []byte) (Codec, []byte) {
+
+	codec := CodecNone
+	payload := item
+
+	if encoder.codec != CodecNone && len(item) >= encoder.minBytes {
+
+		compressed, err := compressItem(encoder.codec, item)
+
+		if err == nil && len(compressed) < len(item) {
+			codec = encoder.codec
+			payload = compressed
+		}
+	}
+
+	return codec, payload
+}
+
+func compressItem(codec Codec, item []byte) ([]byte, error) {
+
+	compressed := &bytes.Buffer{}
+
+	writer, err := newCompressingWriter(codec, compressed)
+
+	if err == nil {
+		_, err = writer.Write(item)
+	}
+
+	if err == nil {
+		err = writer.Close()
+	}
+
+	return compressed.Bytes(), err
+}
+
+func newCompressingWriter(codec Codec, destination io.Writer) (io.WriteCloser, error) {
+
+	var writer io.WriteCloser
+	var err error
+
+	switch codec {
+	case CodecGzip:
+		writer = gzip.NewWriter(destination)
+	case CodecFlate:
+		writer, err = flate.NewWriter(destination, flate.DefaultCompression)
+	default:
+		err = ErrUnknownCodec
+	}
+
+	return writer, err
+}
+
+// decompressPayload returns the item encoded in payload. A payload that cannot be decoded has been corrupted, the
+// raw payload is returned for inspection along with ErrChecksumMismatch
+func decompressPayload(codec Codec, payload []byte) ([]byte, error) {
+
+	item := payload
+	var err error
+
+	if codec != CodecNone {
+		item, err = inflate(codec, payload)
+	}
+
+	if err != nil {
+		item = payload
+		err = ErrChecksumMismatch
+	}
+
+	return item, err
+}
+
+func inflate(codec Codec, payload []byte) ([]byte, error) {
+
+	var reader io.ReadCloser
+	var err error
+
+	switch codec {
+	case CodecGzip:
+		reader, err = gzip.NewReader(bytes.NewReader(payload))
+	case CodecFlate:
+		reader = flate.NewReader(bytes.NewReader(payload))
+	default:
+		err = ErrUnknownCodec
+	}
+
+	var item []byte
+
+	if err == nil {
+		item, err = io.ReadAll(reader)
+	}
+
+	return item, err
+}
@@ -0,0 +1,89 @@
+package journal
+
+import (
+	"bytes"
+	"testing"
+)
+
+var compressibleItem = bytes.Repeat([]byte(`{"Key":"some-key","Data":"c29tZS12YWx1ZQ=="}`), 20)
+
+func TestWhenEntryEncodedWithEachCodecThenItDecodesToTheOriginalEntry(t *testing.T) {
+
+	for _, codec := range []Codec{CodecNone, CodecGzip, CodecFlate} {
+
+		encoder := NewEntryEncoder(codec, 0)
+
+		record, _ := encoder.Encode(NewEntry(compressibleItem), RecordPosition{})
+		entry, err := encoder.Decode(record, RecordPosition{})
+
+		if err != nil || !bytes.Equal(compressibleItem, entry.Item) {
+			t.Errorf("Codec %d did not round trip, error '%v'", codec, err)
+		}
+	}
+}
+
+func TestWhenItemCompressesThenTheRecordIsSmallerAndTaggedWithTheCodec(t *testing.T) {
+
+	record, _ := NewEntryEncoder(CodecGzip, 0).Encode(NewEntry(compressibleItem), RecordPosition{})
+
+	if len(record) >= len(compressibleItem) || Codec(record[recordCodecOffset]) != CodecGzip {
+		t.Errorf("Expected a gzip record smaller than %d bytes but got %d bytes with codec %d",
+			len(compressibleItem),
+			len(record),
+			record[recordCodecOffset])
+	}
+}
+
+func TestWhenItemBelowMinimumSizeThenItIsStoredUncompressed(t *testing.T) {
+
+	record, _ := NewEntryEncoder(CodecGzip, len(compressibleItem)+1).Encode(NewEntry(compressibleItem), RecordPosition{})
+
+	if Codec(record[recordCodecOffset]) != CodecNone {
+		t.Errorf("Expected an uncompressed record but got codec %d", record[recordCodecOffset])
+	}
+}
+
+func TestWhenItemDoesNotShrinkThenItIsStoredUncompressed(t *testing.T) {
+
+	record, _ := NewEntryEncoder(CodecFlate, 0).Encode(NewEntry([]byte("x")), RecordPosition{})
+
+	if Codec(record[recordCodecOffset]) != CodecNone {
+		t.Errorf("Expected an uncompressed record but got codec %d", record[recordCodecOffset])
+	}
+}
+
+func TestWhenCompressedPayloadIsCorruptThenChecksumMismatchIsReturned(t *testing.T) {
+
+	encoder := NewEntryEncoder(CodecGzip, 0)
+
+	record, _ := encoder.Encode(NewEntry(compressibleItem), RecordPosition{})
+	record[recordHeaderSize+12] ^= 0xFF
+
+	_, err := encoder.Decode(record, RecordPosition{})
+
+	if ErrChecksumMismatch != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrChecksumMismatch, err)
+	}
+}
+
+func TestWhenEntriesEncodedThenStatsReportTheCompressionRatio(t *testing.T) {
+
+	encoder := NewEntryEncoder(CodecGzip, 0)
+
+	_, _ = encoder.Encode(NewEntry(compressibleItem), RecordPosition{})
+
+	stats := encoder.Stats()
+
+	if stats.UncompressedBytes != uint64(len(compressibleItem)) || stats.Ratio() <= 1 {
+		t.Errorf("Expected %d uncompressed bytes and a ratio above 1 but got %+v", len(compressibleItem), stats)
+	}
+}
+
+func TestWhenCodecNameIsKnownThenItIsParsedCaseInsensitively(t *testing.T) {
+
+	codec, err := ParseCodec("Flate")
+
+	if err != nil || codec != CodecFlate {
+		t.Errorf("Expected CodecFlate but got %d and error '%v'", codec, err)
+	}
+}
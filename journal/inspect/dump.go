@@ -0,0 +1,126 @@
+package inspect
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jrobison153/raft/journal"
+	"github.com/jrobison153/raft/state"
+)
+
+const (
+	jsonFormat = "json"
+	hexFormat  = "hex"
+)
+
+// DumpedEntry is the JSON form of a single journal entry written by the dump subcommand. Command is only populated
+// when decoding was requested and the entry holds a state.Command, either an envelope or a legacy JSON entry
+type DumpedEntry struct {
+	Index    uint64
+	Checksum uint32
+	Valid    bool
+	Item     []byte
+	Command  *state.Command `json:",omitempty"`
+}
+
+type dumpOptions struct {
+	from   uint64
+	to     int64
+	format string
+	decode bool
+}
+
+func dump(args []string, out io.Writer) error {
+
+	flags, location := newFlagSet("dump", out)
+
+	options := dumpOptions{}
+
+	flags.Uint64Var(&options.from, "from", 0, "first index to dump")
+	flags.Int64Var(&options.to, "to", -1, "last index to dump, defaults to the head of the journal")
+	flags.StringVar(&options.format, "format", jsonFormat, "output format, json or hex")
+	flags.BoolVar(&options.decode, "decode", false, "decode entries as commands, json format only")
+
+	err := flags.Parse(args)
+
+	if err == nil && options.format != jsonFormat && options.format != hexFormat {
+		err = ErrUnsupportedFormat
+	}
+
+	if err == nil {
+		err = withStore(location, func(store *journal.SegmentStore) error {
+			return dumpRange(store, options, out)
+		})
+	}
+
+	return err
+}
+
+func dumpRange(store *journal.SegmentStore, options dumpOptions, out io.Writer) error {
+
+	var err error
+
+	to := options.to
+
+	if to < 0 || uint64(to) >= store.EntryCount() {
+		to = int64(store.EntryCount()) - 1
+	}
+
+	for index := options.from; int64(index) <= to && err == nil; index++ {
+		err = dumpEntry(store, index, options, out)
+	}
+
+	return err
+}
+
+// dumpEntry writes the entry at index, corrupt entries are dumped as they are stored and flagged as not valid
+func dumpEntry(store *journal.SegmentStore, index uint64, options dumpOptions, out io.Writer) error {
+
+	entry, err := store.Read(index)
+
+	dumped := DumpedEntry{
+		Index:    index,
+		Checksum: entry.Checksum,
+		Valid:    err == nil,
+		Item:     entry.Item,
+	}
+
+	if err == nil || errors.Is(err, journal.ErrChecksumMismatch) {
+		err = writeDumpedEntry(dumped, options, out)
+	}
+
+	return err
+}
+
+func writeDumpedEntry(dumped DumpedEntry, options dumpOptions, out io.Writer) error {
+
+	var err error
+
+	if options.format == hexFormat {
+		_, err = fmt.Fprintf(out, "%d %08x %t %x\n", dumped.Index, dumped.Checksum, dumped.Valid, dumped.Item)
+	} else {
+
+		if options.decode {
+			dumped.Command = decodeCommand(dumped.Item)
+		}
+
+		err = json.NewEncoder(out).Encode(dumped)
+	}
+
+	return err
+}
+
+func decodeCommand(item []byte) *state.Command {
+
+	var decoded *state.Command
+
+	command, err := state.DecodeCommand(item)
+
+	if err == nil {
+		decoded = &command
+	}
+
+	return decoded
+}
@@ -0,0 +1,157 @@
+// Package inspect implements the offline journal inspection and repair tooling behind the `raft journal` command.
+// Every subcommand works directly against the segment files written by the TIERED journal and must not be run
+// against a journal directory that a live server has open
+package inspect
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jrobison153/raft/crypto"
+	"github.com/jrobison153/raft/journal"
+)
+
+type subcommand func(args []string, out io.Writer) error
+
+var (
+	ErrCorruptEntries     = errors.New("journal contains corrupt entries")
+	ErrUnknownSubcommand  = errors.New("unknown journal subcommand")
+	ErrUnsupportedFormat  = errors.New("unsupported dump format, expected json or hex")
+	ErrTruncatesCommitted = errors.New("truncation would remove committed entries, re-run with -force to allow it")
+
+	subcommands = map[string]subcommand{
+		"dump":      dump,
+		"meta":      meta,
+		"reencrypt": reencrypt,
+		"truncate":  truncate,
+		"verify":    verify,
+	}
+)
+
+// Run executes the journal subcommand named by args[0] with the remaining args as its flags, writing results to out
+func Run(args []string, out io.Writer) error {
+
+	var err error
+
+	if len(args) == 0 {
+		err = ErrUnknownSubcommand
+	} else if command, ok := subcommands[args[0]]; ok {
+		err = command(args[1:], out)
+	} else {
+		err = fmt.Errorf("%w '%s'", ErrUnknownSubcommand, args[0])
+	}
+
+	if errors.Is(err, ErrUnknownSubcommand) {
+		_, _ = fmt.Fprintf(out, "usage: raft journal <%s> [flags]\n", strings.Join(subcommandNames(), "|"))
+	}
+
+	return err
+}
+
+func subcommandNames() []string {
+
+	names := make([]string, 0, len(subcommands))
+
+	for name := range subcommands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// storeLocation identifies the journal a subcommand works on and the keys needed to read it
+type storeLocation struct {
+	directory string
+	keyFile   string
+}
+
+func newFlagSet(name string, out io.Writer) (*flag.FlagSet, *storeLocation) {
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(out)
+
+	location := &storeLocation{}
+
+	flags.StringVar(&location.directory, "dir", "journal-data", "journal directory holding the segment files")
+	flags.StringVar(&location.keyFile, "key-file", "",
+		"encryption key spec file, defaults to the keys configured in the environment")
+
+	return flags, location
+}
+
+// loadKeyring returns the keyring from the key file when one was given, otherwise from the environment
+func (location *storeLocation) loadKeyring() (*crypto.Keyring, error) {
+
+	var keyring *crypto.Keyring
+	var err error
+
+	if location.keyFile != "" {
+		keyring, err = crypto.LoadKeyringFile(location.keyFile)
+	} else {
+		keyring, err = crypto.LoadKeyringFromEnvironment()
+	}
+
+	return keyring, err
+}
+
+// withStore opens the segment store at location read only, hands it to action and closes it again. The segment
+// files are left exactly as they were found, torn records included
+func withStore(location *storeLocation, action func(store *journal.SegmentStore) error) error {
+
+	return withStoreOpenedBy(location, journal.OpenSegmentStoreReadOnly, action)
+}
+
+// withWritableStore opens the segment store at location for writing, hands it to action and closes it again.
+// Opening the store for writing discards any torn record at the tail of a segment
+func withWritableStore(location *storeLocation, action func(store *journal.SegmentStore) error) error {
+
+	return withStoreOpenedBy(location, openWritableStore, action)
+}
+
+func withStoreOpenedBy(location *storeLocation,
+	open func(directory string) (*journal.SegmentStore, error),
+	action func(store *journal.SegmentStore) error) error {
+
+	var store *journal.SegmentStore
+
+	keyring, err := location.loadKeyring()
+
+	// opening a store creates a missing directory, inspecting a mistyped path should fail rather than create it
+	if err == nil {
+		_, err = os.Stat(location.directory)
+	}
+
+	if err == nil {
+		store, err = open(location.directory)
+	}
+
+	if err == nil {
+		store.UseEncoder(journal.NewEntryEncoder(journal.CodecNone, journal.DefaultCompressionMinBytes).
+			EncryptWith(keyring))
+	}
+
+	if err == nil {
+
+		err = action(store)
+
+		closeErr := store.Close()
+
+		if err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+func openWritableStore(directory string) (*journal.SegmentStore, error) {
+
+	return journal.OpenSegmentStore(directory, journal.DefaultSegmentMaxEntries)
+}
@@ -0,0 +1,373 @@
+package inspect
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jrobison153/raft/crypto"
+	"github.com/jrobison153/raft/journal"
+	"github.com/jrobison153/raft/state"
+)
+
+func TestWhenMetaRequestedThenHeadCommitAndCountsAreReported(t *testing.T) {
+
+	directory := setupJournalDirectory(t, 5, 2)
+
+	out := &bytes.Buffer{}
+	_ = Run([]string{"meta", "-dir", directory}, out)
+
+	metadata := Metadata{}
+	_ = json.Unmarshal(out.Bytes(), &metadata)
+
+	if metadata.Head != 4 || metadata.CommitIndex != 2 || metadata.EntryCount != 5 || metadata.SegmentCount != 1 {
+		t.Errorf("Unexpected metadata %+v", metadata)
+	}
+}
+
+func TestWhenDirectoryDoesNotExistThenItIsNotCreated(t *testing.T) {
+
+	directory := filepath.Join(t.TempDir(), "missing")
+
+	err := Run([]string{"meta", "-dir", directory}, &bytes.Buffer{})
+
+	_, statErr := os.Stat(directory)
+
+	if err == nil || !errors.Is(statErr, os.ErrNotExist) {
+		t.Errorf("Expected an error and no directory but got '%v' and stat error '%v'", err, statErr)
+	}
+}
+
+func TestWhenSubcommandUnknownThenUsageIsWritten(t *testing.T) {
+
+	out := &bytes.Buffer{}
+
+	err := Run([]string{"bogus"}, out)
+
+	if !errors.Is(err, ErrUnknownSubcommand) || !strings.Contains(out.String(), "usage") {
+		t.Errorf("Expected usage and ErrUnknownSubcommand but got '%v' and '%s'", err, out.String())
+	}
+}
+
+func TestWhenRangeDumpedAsJsonThenOnlyEntriesInRangeAreWrittenAndDecoded(t *testing.T) {
+
+	directory := setupJournalDirectory(t, 5, 4)
+
+	out := &bytes.Buffer{}
+	_ = Run([]string{"dump", "-dir", directory, "-from", "1", "-to", "2", "-decode"}, out)
+
+	dumped := decodeDumpedEntries(out)
+
+	if len(dumped) != 2 || dumped[0].Index != 1 || dumped[1].Command == nil || dumped[1].Command.Key != "key-2" {
+		t.Errorf("Expected entries 1 and 2 decoded but got %+v", dumped)
+	}
+}
+
+func TestWhenDumpedAsHexThenOneLinePerEntryIsWritten(t *testing.T) {
+
+	directory := setupJournalDirectory(t, 3, 2)
+
+	out := &bytes.Buffer{}
+	_ = Run([]string{"dump", "-dir", directory, "-format", "hex"}, out)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+
+	if len(lines) != 3 || !strings.HasPrefix(lines[2], "2 ") {
+		t.Errorf("Expected 3 hex lines but got '%s'", out.String())
+	}
+}
+
+func TestWhenDumpFormatUnsupportedThenErrorIsReturned(t *testing.T) {
+
+	directory := setupJournalDirectory(t, 1, 0)
+
+	err := Run([]string{"dump", "-dir", directory, "-format", "xml"}, &bytes.Buffer{})
+
+	if ErrUnsupportedFormat != err {
+		t.Errorf("Expected ErrUnsupportedFormat but got '%v'", err)
+	}
+}
+
+func TestWhenEntryCorruptThenDumpFlagsItAsInvalid(t *testing.T) {
+
+	directory := setupJournalDirectory(t, 3, 1)
+
+	corruptLastEntry(t, directory)
+
+	out := &bytes.Buffer{}
+	_ = Run([]string{"dump", "-dir", directory}, out)
+
+	dumped := decodeDumpedEntries(out)
+
+	if len(dumped) != 3 || !dumped[1].Valid || dumped[2].Valid {
+		t.Errorf("Expected only the last entry to be invalid but got %+v", dumped)
+	}
+}
+
+func TestWhenJournalIntactThenVerifyPasses(t *testing.T) {
+
+	directory := setupJournalDirectory(t, 4, 3)
+
+	out := &bytes.Buffer{}
+	err := Run([]string{"verify", "-dir", directory}, out)
+
+	if err != nil || !strings.Contains(out.String(), "verified 4 entries, 0 corrupt") {
+		t.Errorf("Expected verification to pass but got '%v' and '%s'", err, out.String())
+	}
+}
+
+func TestWhenEntryCorruptThenVerifyReportsIt(t *testing.T) {
+
+	directory := setupJournalDirectory(t, 4, 3)
+
+	corruptLastEntry(t, directory)
+
+	out := &bytes.Buffer{}
+	err := Run([]string{"verify", "-dir", directory}, out)
+
+	if ErrCorruptEntries != err || !strings.Contains(out.String(), "index 3:") {
+		t.Errorf("Expected index 3 to be reported corrupt but got '%v' and '%s'", err, out.String())
+	}
+}
+
+func TestWhenSegmentHasATornTailThenVerifyReportsItWithoutDiscardingIt(t *testing.T) {
+
+	directory := setupJournalDirectory(t, 4, 3)
+
+	segmentFiles, _ := filepath.Glob(filepath.Join(directory, "*.seg"))
+
+	tornSegment, _ := os.OpenFile(segmentFiles[0], os.O_APPEND|os.O_WRONLY, 0o640)
+	_, _ = tornSegment.Write([]byte{42, 0, 0, 0, 'p', 'a', 'r'})
+	_ = tornSegment.Close()
+
+	before, _ := os.Stat(segmentFiles[0])
+
+	out := &bytes.Buffer{}
+	err := Run([]string{"verify", "-dir", directory}, out)
+
+	after, _ := os.Stat(segmentFiles[0])
+
+	if err != nil || !strings.Contains(out.String(), "torn record of 7 bytes") || before.Size() != after.Size() {
+		t.Errorf("Expected the torn tail to be reported and kept but got '%v', '%s' and sizes %d and %d", err,
+			out.String(), before.Size(), after.Size())
+	}
+}
+
+func TestWhenTruncatingWithoutIndexThenJournalIsCutAtFirstCorruptEntry(t *testing.T) {
+
+	directory := setupJournalDirectory(t, 4, 2)
+
+	corruptLastEntry(t, directory)
+
+	_ = Run([]string{"truncate", "-dir", directory}, &bytes.Buffer{})
+
+	store := openTestStore(t, directory)
+
+	if store.EntryCount() != 3 {
+		t.Errorf("Expected 3 entries to remain but there were %d", store.EntryCount())
+	}
+}
+
+func TestWhenTruncationWouldRemoveCommittedEntriesThenItIsRefused(t *testing.T) {
+
+	directory := setupJournalDirectory(t, 4, 3)
+
+	err := Run([]string{"truncate", "-dir", directory, "-from", "2"}, &bytes.Buffer{})
+
+	store := openTestStore(t, directory)
+
+	if ErrTruncatesCommitted != err || store.EntryCount() != 4 {
+		t.Errorf("Expected refusal and 4 entries but got '%v' and %d entries", err, store.EntryCount())
+	}
+}
+
+func TestWhenForcedTruncationRemovesCommittedEntriesThenCommitIndexIsPulledBack(t *testing.T) {
+
+	directory := setupJournalDirectory(t, 4, 3)
+
+	_ = Run([]string{"truncate", "-dir", directory, "-from", "2", "-force"}, &bytes.Buffer{})
+
+	store := openTestStore(t, directory)
+	commitIndex, _ := store.CommitIndex()
+
+	if store.EntryCount() != 2 || commitIndex != 1 {
+		t.Errorf("Expected 2 entries and commit index 1 but got %d entries and %d", store.EntryCount(), commitIndex)
+	}
+}
+
+func setupJournalDirectory(t *testing.T, entryCount int, commitIndex int64) string {
+
+	directory := t.TempDir()
+
+	store := openTestStore(t, directory)
+
+	for i := 0; i < entryCount; i++ {
+
+		item, _ := json.Marshal(state.KeyValItem{Key: fmt.Sprintf("key-%d", i), Data: []byte("value")})
+		_, _ = store.Append(journal.NewEntry(item))
+	}
+
+	_ = store.SaveCommitIndex(commitIndex)
+	_ = store.Close()
+
+	return directory
+}
+
+func openTestStore(t *testing.T, directory string) *journal.SegmentStore {
+
+	store, err := journal.OpenSegmentStore(directory, journal.DefaultSegmentMaxEntries)
+
+	if err != nil {
+		t.Fatalf("Unable to open segment store: %v", err)
+	}
+
+	t.Cleanup(func() { _ = store.Close() })
+
+	return store
+}
+
+// corruptLastEntry flips the final byte of the journal, which is the last byte of the last entry payload
+func corruptLastEntry(t *testing.T, directory string) {
+
+	segmentFiles, _ := filepath.Glob(filepath.Join(directory, "*.seg"))
+	lastSegment := segmentFiles[len(segmentFiles)-1]
+
+	raw, err := os.ReadFile(lastSegment)
+
+	if err != nil {
+		t.Fatalf("Unable to read segment: %v", err)
+	}
+
+	raw[len(raw)-1] ^= 0xFF
+
+	_ = os.WriteFile(lastSegment, raw, 0o640)
+}
+
+func decodeDumpedEntries(out *bytes.Buffer) []DumpedEntry {
+
+	var dumped []DumpedEntry
+
+	decoder := json.NewDecoder(out)
+
+	for decoder.More() {
+
+		entry := DumpedEntry{}
+		_ = decoder.Decode(&entry)
+
+		dumped = append(dumped, entry)
+	}
+
+	return dumped
+}
+
+func TestWhenJournalReencryptedThenEntriesAreSealedWithTheActiveKeyAndStillReadable(t *testing.T) {
+
+	directory := setupJournalDirectory(t, 4, 2)
+	keyFile := writeKeyFile(t, "1:"+base64.StdEncoding.EncodeToString(make([]byte, 32)))
+
+	err := Run([]string{"reencrypt", "-dir", directory, "-key-file", keyFile}, &bytes.Buffer{})
+
+	out := &bytes.Buffer{}
+	verifyErr := Run([]string{"verify", "-dir", directory, "-key-file", keyFile}, out)
+
+	if err != nil || verifyErr != nil || segmentFilesContain(directory, "key-2") {
+		t.Errorf("Expected an encrypted, verifiable journal but got '%v', '%v' and '%s'", err, verifyErr, out.String())
+	}
+}
+
+func TestWhenJournalReencryptedThenTheCommitIndexIsKept(t *testing.T) {
+
+	directory := setupJournalDirectory(t, 4, 2)
+	keyFile := writeKeyFile(t, "1:"+base64.StdEncoding.EncodeToString(make([]byte, 32)))
+
+	_ = Run([]string{"reencrypt", "-dir", directory, "-key-file", keyFile}, &bytes.Buffer{})
+
+	out := &bytes.Buffer{}
+	_ = Run([]string{"meta", "-dir", directory, "-key-file", keyFile}, out)
+
+	metadata := Metadata{}
+	_ = json.Unmarshal(out.Bytes(), &metadata)
+
+	if metadata.CommitIndex != 2 || metadata.EntryCount != 4 {
+		t.Errorf("Expected commit index 2 and 4 entries but got %+v", metadata)
+	}
+}
+
+func TestWhenJournalReencryptedWithoutACodecThenItKeepsTheCodecItIsWrittenWith(t *testing.T) {
+
+	directory := t.TempDir()
+
+	store := openTestStore(t, directory)
+	store.UseEncoder(journal.NewEntryEncoder(journal.CodecFlate, 0))
+	_, _ = store.Append(journal.NewEntry(bytes.Repeat([]byte("compressible "), 20)))
+	_ = store.Close()
+
+	keyFile := writeKeyFile(t, "1:"+base64.StdEncoding.EncodeToString(make([]byte, 32)))
+
+	err := Run([]string{"reencrypt", "-dir", directory, "-key-file", keyFile}, &bytes.Buffer{})
+
+	reencrypted, _ := journal.OpenSegmentStoreReadOnly(directory)
+	codec, codecErr := reencrypted.Codec()
+	_ = reencrypted.Close()
+
+	if err != nil || codecErr != nil || journal.CodecFlate != codec {
+		t.Errorf("Expected the FLATE codec to be kept but got %d and errors '%v' and '%v'", codec, err, codecErr)
+	}
+}
+
+func TestWhenReencryptingWithoutKeysThenAnErrorIsReturned(t *testing.T) {
+
+	directory := setupJournalDirectory(t, 1, 0)
+
+	err := Run([]string{"reencrypt", "-dir", directory}, &bytes.Buffer{})
+
+	if ErrNoEncryptionKeys != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrNoEncryptionKeys, err)
+	}
+}
+
+func TestWhenSnapshotReencryptedThenItOpensWithTheNewActiveKey(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "state.snapshot")
+	_ = state.WriteSnapshotFile(path, state.Snapshot{Index: 9}, nil)
+
+	keyFile := writeKeyFile(t, "4:"+base64.StdEncoding.EncodeToString(make([]byte, 32)))
+
+	err := Run([]string{"reencrypt", "-snapshot", path, "-key-file", keyFile}, &bytes.Buffer{})
+
+	_, openWithoutKeyErr := state.ReadSnapshotFile(path, nil)
+
+	if err != nil || crypto.ErrUnknownKeyID != openWithoutKeyErr {
+		t.Errorf("Expected the snapshot to be sealed but got '%v' and '%v'", err, openWithoutKeyErr)
+	}
+}
+
+func writeKeyFile(t *testing.T, spec string) string {
+
+	keyFile := filepath.Join(t.TempDir(), "keys")
+
+	_ = os.WriteFile(keyFile, []byte(spec), 0o600)
+
+	return keyFile
+}
+
+func segmentFilesContain(directory string, text string) bool {
+
+	segmentFiles, _ := filepath.Glob(filepath.Join(directory, "*.seg"))
+
+	found := false
+
+	for _, segmentFile := range segmentFiles {
+
+		raw, _ := os.ReadFile(segmentFile)
+		found = found || bytes.Contains(raw, []byte(text))
+	}
+
+	return found
+}
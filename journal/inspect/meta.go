@@ -0,0 +1,57 @@
+package inspect
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/jrobison153/raft/journal"
+)
+
+// Metadata describes the state of a journal directory. Head is -1 when the journal is empty. TornTails lists the
+// partially written records left at the tail of segments, e.g. by a crash mid write. Terms are not recorded in the
+// journal so none are reported
+type Metadata struct {
+	Directory    string
+	Head         int64
+	CommitIndex  int64
+	EntryCount   uint64
+	SegmentCount int
+	TornTails    []journal.TornTail `json:",omitempty"`
+}
+
+func meta(args []string, out io.Writer) error {
+
+	flags, location := newFlagSet("meta", out)
+
+	err := flags.Parse(args)
+
+	if err == nil {
+		err = withStore(location, func(store *journal.SegmentStore) error {
+			return writeMetadata(location.directory, store, out)
+		})
+	}
+
+	return err
+}
+
+func writeMetadata(directory string, store *journal.SegmentStore, out io.Writer) error {
+
+	commitIndex, err := store.CommitIndex()
+
+	if err == nil {
+
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+
+		err = encoder.Encode(Metadata{
+			Directory:    directory,
+			Head:         int64(store.EntryCount()) - 1,
+			CommitIndex:  commitIndex,
+			EntryCount:   store.EntryCount(),
+			SegmentCount: store.SegmentCount(),
+			TornTails:    store.TornTails(),
+		})
+	}
+
+	return err
+}
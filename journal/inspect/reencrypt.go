@@ -0,0 +1,179 @@
+package inspect
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jrobison153/raft/crypto"
+	"github.com/jrobison153/raft/journal"
+	"github.com/jrobison153/raft/state"
+)
+
+const (
+	rewriteDirectorySuffix  = ".reencrypt"
+	replacedDirectorySuffix = ".replaced"
+)
+
+var ErrNoEncryptionKeys = errors.New("re-encryption needs encryption keys, pass -key-file or set them in the environment")
+
+type reencryptOptions struct {
+	codec    string
+	snapshot string
+}
+
+// reencrypt rewrites a journal, or a state machine snapshot file, sealing everything with the active key. The key
+// spec must still hold the old keys so the existing data can be read, once re-encryption has finished they can be
+// retired
+func reencrypt(args []string, out io.Writer) error {
+
+	flags, location := newFlagSet("reencrypt", out)
+
+	options := reencryptOptions{}
+
+	flags.StringVar(&options.codec, "codec", "",
+		"codec to compress journal entries with, NONE, GZIP or FLATE, defaults to the codec the journal is written with")
+	flags.StringVar(&options.snapshot, "snapshot", "", "re-encrypt this snapshot file instead of the journal")
+
+	err := flags.Parse(args)
+
+	var keyring *crypto.Keyring
+
+	if err == nil {
+		keyring, err = location.loadKeyring()
+	}
+
+	if err == nil && keyring == nil {
+		err = ErrNoEncryptionKeys
+	}
+
+	if err == nil && options.snapshot != "" {
+		err = reencryptSnapshot(options.snapshot, keyring, out)
+	} else if err == nil {
+		err = reencryptJournal(location, keyring, options.codec, out)
+	}
+
+	return err
+}
+
+func reencryptSnapshot(path string, keyring *crypto.Keyring, out io.Writer) error {
+
+	snapshot, err := state.ReadSnapshotFile(path, keyring)
+
+	if err == nil {
+		err = state.WriteSnapshotFile(path, snapshot, keyring)
+	}
+
+	if err == nil {
+		_, _ = fmt.Fprintf(out, "re-encrypted snapshot %s with key %d\n", path, keyring.ActiveKeyID())
+	}
+
+	return err
+}
+
+// reencryptJournal copies every entry into a new journal sealed with the active key and only swaps it in for the
+// original once the copy is complete, an interrupted run leaves the original journal untouched
+func reencryptJournal(location *storeLocation, keyring *crypto.Keyring, codecName string, out io.Writer) error {
+
+	rewriteDirectory := location.directory + rewriteDirectorySuffix
+
+	err := os.RemoveAll(rewriteDirectory)
+
+	if err == nil {
+		err = withStore(location, func(source *journal.SegmentStore) error {
+
+			codec, codecErr := resolveCodec(source, codecName)
+
+			if codecErr == nil {
+				codecErr = rewriteStore(source, rewriteDirectory,
+					journal.NewEntryEncoder(codec, journal.DefaultCompressionMinBytes).EncryptWith(keyring))
+			}
+
+			return codecErr
+		})
+	}
+
+	if err == nil {
+		err = replaceDirectory(location.directory, rewriteDirectory)
+	}
+
+	if err == nil {
+		_, _ = fmt.Fprintf(out, "re-encrypted journal %s with key %d\n", location.directory, keyring.ActiveKeyID())
+	}
+
+	return err
+}
+
+// resolveCodec returns the codec named codecName, or the codec source is written with when no name was given
+func resolveCodec(source *journal.SegmentStore, codecName string) (journal.Codec, error) {
+
+	var codec journal.Codec
+	var err error
+
+	if codecName == "" {
+		codec, err = source.Codec()
+	} else {
+		codec, err = journal.ParseCodec(codecName)
+	}
+
+	return codec, err
+}
+
+func rewriteStore(source *journal.SegmentStore, directory string, encoder *journal.EntryEncoder) error {
+
+	target, err := journal.OpenSegmentStore(directory, journal.DefaultSegmentMaxEntries)
+
+	if err == nil {
+
+		target.UseEncoder(encoder)
+
+		err = copyEntries(source, target)
+
+		closeErr := target.Close()
+
+		if err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+func copyEntries(source *journal.SegmentStore, target *journal.SegmentStore) error {
+
+	commitIndex, err := source.CommitIndex()
+
+	for index := uint64(0); index < source.EntryCount() && err == nil; index++ {
+
+		var entry journal.Entry
+		entry, err = source.Read(index)
+
+		if err == nil {
+			_, err = target.Append(entry)
+		}
+	}
+
+	if err == nil {
+		err = target.SaveCommitIndex(commitIndex)
+	}
+
+	return err
+}
+
+func replaceDirectory(directory string, replacement string) error {
+
+	replaced := directory + replacedDirectorySuffix
+
+	err := os.Rename(directory, replaced)
+
+	if err == nil {
+		err = os.Rename(replacement, directory)
+	}
+
+	if err == nil {
+		err = os.RemoveAll(replaced)
+	}
+
+	return err
+}
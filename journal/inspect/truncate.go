@@ -0,0 +1,95 @@
+package inspect
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jrobison153/raft/journal"
+)
+
+type truncateOptions struct {
+	from  int64
+	force bool
+}
+
+func truncate(args []string, out io.Writer) error {
+
+	flags, location := newFlagSet("truncate", out)
+
+	options := truncateOptions{}
+
+	flags.Int64Var(&options.from, "from", -1, "first index to remove, defaults to the first corrupt entry")
+	flags.BoolVar(&options.force, "force", false, "allow committed entries to be removed")
+
+	err := flags.Parse(args)
+
+	if err == nil {
+		err = withWritableStore(location, func(store *journal.SegmentStore) error {
+			return truncateStore(store, options, out)
+		})
+	}
+
+	return err
+}
+
+func truncateStore(store *journal.SegmentStore, options truncateOptions, out io.Writer) error {
+
+	from, err := resolveTruncationPoint(store, options.from)
+
+	if err == nil && from < int64(store.EntryCount()) {
+
+		err = truncateFrom(store, from, options.force)
+
+		if err == nil {
+			_, _ = fmt.Fprintf(out, "truncated journal from index %d, %d entries remain\n", from, store.EntryCount())
+		}
+	} else if err == nil {
+		_, _ = fmt.Fprintln(out, "nothing to truncate")
+	}
+
+	return err
+}
+
+// resolveTruncationPoint returns from when given, otherwise the index of the first corrupt entry. When nothing is
+// corrupt the end of the journal is returned
+func resolveTruncationPoint(store *journal.SegmentStore, from int64) (int64, error) {
+
+	var err error
+
+	if from < 0 {
+
+		var corrupt []uint64
+		corrupt, err = corruptIndexes(store)
+
+		from = int64(store.EntryCount())
+
+		if len(corrupt) > 0 {
+			from = int64(corrupt[0])
+		}
+	}
+
+	return from, err
+}
+
+// truncateFrom removes from onwards. Removing committed entries is only allowed with force, in which case the saved
+// commit index is pulled back to the new head so the journal never claims to have committed entries it lacks
+func truncateFrom(store *journal.SegmentStore, from int64, force bool) error {
+
+	commitIndex, err := store.CommitIndex()
+
+	removesCommitted := err == nil && from <= commitIndex
+
+	if removesCommitted && !force {
+		err = ErrTruncatesCommitted
+	}
+
+	if err == nil {
+		err = store.TruncateFrom(uint64(from))
+	}
+
+	if err == nil && removesCommitted {
+		err = store.SaveCommitIndex(from - 1)
+	}
+
+	return err
+}
@@ -0,0 +1,69 @@
+package inspect
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jrobison153/raft/journal"
+)
+
+func verify(args []string, out io.Writer) error {
+
+	flags, location := newFlagSet("verify", out)
+
+	err := flags.Parse(args)
+
+	if err == nil {
+		err = withStore(location, func(store *journal.SegmentStore) error {
+			return verifyAll(store, out)
+		})
+	}
+
+	return err
+}
+
+// verifyAll reports every entry whose checksum does not match, returning ErrCorruptEntries if any were found, and
+// every torn record at the tail of a segment
+func verifyAll(store *journal.SegmentStore, out io.Writer) error {
+
+	corrupt, err := corruptIndexes(store)
+
+	for _, index := range corrupt {
+		_, _ = fmt.Fprintf(out, "index %d: %v\n", index, journal.ErrChecksumMismatch)
+	}
+
+	for _, torn := range store.TornTails() {
+		_, _ = fmt.Fprintf(out, "segment %d: torn record of %d bytes at offset %d, discarded when the journal is "+
+			"next opened for writing\n", torn.FirstIndex, torn.Bytes, torn.Offset)
+	}
+
+	if err == nil {
+
+		_, _ = fmt.Fprintf(out, "verified %d entries, %d corrupt\n", store.EntryCount(), len(corrupt))
+
+		if len(corrupt) > 0 {
+			err = ErrCorruptEntries
+		}
+	}
+
+	return err
+}
+
+func corruptIndexes(store *journal.SegmentStore) ([]uint64, error) {
+
+	var corrupt []uint64
+	var err error
+
+	for index := uint64(0); index < store.EntryCount() && err == nil; index++ {
+
+		_, err = store.Read(index)
+
+		if errors.Is(err, journal.ErrChecksumMismatch) {
+			corrupt = append(corrupt, index)
+			err = nil
+		}
+	}
+
+	return corrupt, err
+}
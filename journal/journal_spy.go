@@ -2,6 +2,7 @@ package journal
 
 import (
 	"errors"
+	"sync"
 )
 
 // TODO look at refactoring this spy as it has become a fake and in reality it could wrap ArrayJournal
@@ -13,10 +14,14 @@ const (
 )
 
 type SpyEntry struct {
-	Item []byte
+	Item     []byte
+	Checksum uint32
 }
 
+// Spy is safe for concurrent use, the log and subscribers are guarded by mutex and channels are never written while
+// it is held so a test reading the spy never blocks a listener it is notifying
 type Spy struct {
+	mutex                                  sync.Mutex
 	appendCalled                           bool
 	allChangesNotifyCh                     chan uint64
 	appendFailMsg                          string
@@ -26,6 +31,7 @@ type Spy struct {
 	isFailingNextNotifyOfCommitOnIndexOnce bool
 	log                                    []SpyEntry
 	notifyOfAllCommitChangesCallCount      int
+	openCommittedEntriesCallCount          int
 	spiedAppendItem                        []byte
 	spiedAppendKey                         []byte
 	subscribers                            map[uint64]chan bool
@@ -64,9 +70,7 @@ type SpyCommand struct {
 
 func (spy *Spy) Append(item []byte) chan AppendResult {
 
-	entry := SpyEntry{
-		Item: item,
-	}
+	entry := SpyEntry(NewEntry(item))
 
 	doneCh := make(chan AppendResult)
 
@@ -83,9 +87,14 @@ func (spy *Spy) Append(item []byte) chan AppendResult {
 
 func (spy *Spy) NotifyOfCommitOnIndexOnce(index uint64, ch chan bool) error {
 
+	spy.mutex.Lock()
+	defer spy.mutex.Unlock()
+
 	var err error
 	if spy.isFailingNextNotifyOfCommitOnIndexOnce {
 		err = errors.New("failing NotifyOfCommitOnIndexOnce for test purposes")
+	} else if cap(ch) == 0 {
+		err = ErrUnbufferedSubscription
 	} else {
 		spy.subscribers[index] = ch
 	}
@@ -95,7 +104,9 @@ func (spy *Spy) NotifyOfCommitOnIndexOnce(index uint64, ch chan bool) error {
 
 func (spy *Spy) Commit(index uint64) chan CommitResult {
 
+	spy.mutex.Lock()
 	spy.commitCalled = true
+	spy.mutex.Unlock()
 
 	doneCh := make(chan CommitResult)
 	command := SpyCommand{
@@ -111,6 +122,9 @@ func (spy *Spy) Commit(index uint64) chan CommitResult {
 
 func (spy *Spy) NotifyOfAllCommitChanges(ch chan uint64) {
 
+	spy.mutex.Lock()
+	defer spy.mutex.Unlock()
+
 	spy.allChangesNotifyCh = ch
 	spy.notifyOfAllCommitChangesCallCount += 1
 }
@@ -120,9 +134,17 @@ func (spy *Spy) GetHead() (Entry, error) {
 	panic("implement me")
 }
 
-// GetAllEntriesBetween startIndex and sizeOfBackingArray are inclusive
+// GetAllEntriesBetween startIndex and sizeOfBackingArray are inclusive, inverted indexes fail like they do for the
+// journals
 func (spy *Spy) GetAllEntriesBetween(beginIndex uint64, endIndex uint64) (Iterator, error) {
 
+	if beginIndex > endIndex {
+		return nil, ErrInvertedIndexes
+	}
+
+	spy.mutex.Lock()
+	defer spy.mutex.Unlock()
+
 	indexToEndOfLog := spy.log[beginIndex : endIndex+1]
 
 	resultEntries := createEntryArray(indexToEndOfLog)
@@ -134,6 +156,9 @@ func (spy *Spy) GetAllEntriesBetween(beginIndex uint64, endIndex uint64) (Iterat
 
 func (spy *Spy) GetAllCommittedEntries() Iterator {
 
+	spy.mutex.Lock()
+	defer spy.mutex.Unlock()
+
 	resultEntries := createEntryArray(spy.log)
 
 	iterator := NewArrayJournalIterator(resultEntries)
@@ -141,6 +166,18 @@ func (spy *Spy) GetAllCommittedEntries() Iterator {
 	return iterator
 }
 
+func (spy *Spy) OpenCommittedEntries() (Cursor, error) {
+
+	spy.mutex.Lock()
+	spy.openCommittedEntriesCallCount += 1
+	headIndex := int64(len(spy.log)) - 1
+	spy.mutex.Unlock()
+
+	cursor := newBatchCursor(spy.GetAllEntriesBetween, headIndex, defaultCursorBatchSize)
+
+	return cursor, nil
+}
+
 func (spy *Spy) GetAllUncommittedEntries() chan AllUncommittedEntriesResult {
 
 	resultCh := make(chan AllUncommittedEntriesResult)
@@ -161,6 +198,9 @@ func (spy *Spy) GetAllUncommittedEntries() chan AllUncommittedEntriesResult {
 
 func (spy *Spy) AppendCalled() bool {
 
+	spy.mutex.Lock()
+	defer spy.mutex.Unlock()
+
 	return spy.appendCalled
 }
 
@@ -171,6 +211,9 @@ func (spy *Spy) AppendKey() []byte {
 
 func (spy *Spy) AppendData() []byte {
 
+	spy.mutex.Lock()
+	defer spy.mutex.Unlock()
+
 	return spy.spiedAppendItem
 }
 
@@ -189,33 +232,72 @@ func (spy *Spy) LatestEntryForKey(key []byte) SpyEntry {
 	return SpyEntry{}
 }
 
+// CorruptEntry flips a bit in the Item stored at index without updating its checksum
+func (spy *Spy) CorruptEntry(index uint64) {
+
+	spy.mutex.Lock()
+	defer spy.mutex.Unlock()
+
+	corrupted := append([]byte{}, spy.log[index].Item...)
+	corrupted[0] ^= 0x01
+
+	spy.log[index].Item = corrupted
+}
+
 func (spy *Spy) FailNextAppend(appendFailMsg string) {
 
+	spy.mutex.Lock()
+	defer spy.mutex.Unlock()
+
 	spy.isFailingNextAppend = true
 	spy.appendFailMsg = appendFailMsg
 }
 
 func (spy *Spy) RegisteredForNotifyOnIndex(journalIndex uint64) bool {
 
+	spy.mutex.Lock()
+	defer spy.mutex.Unlock()
+
 	return spy.subscribers[journalIndex] != nil
 }
 
 func (spy *Spy) RegisteredNotifyChannelOnIndex(journalIndex uint64, ch chan bool) bool {
 
+	spy.mutex.Lock()
+	defer spy.mutex.Unlock()
+
 	return ch == spy.subscribers[journalIndex]
 }
 
 func (spy *Spy) FailNextNotifyOfCommitOnIndexOnce() {
+
+	spy.mutex.Lock()
+	defer spy.mutex.Unlock()
+
 	spy.isFailingNextNotifyOfCommitOnIndexOnce = true
 }
 
 func (spy *Spy) IsChannelRegisteredForAllCommitChanges(ch chan uint64) bool {
 
+	spy.mutex.Lock()
+	defer spy.mutex.Unlock()
+
 	return spy.allChangesNotifyCh == ch
 }
 
+func (spy *Spy) OpenCommittedEntriesCallCount() int {
+
+	spy.mutex.Lock()
+	defer spy.mutex.Unlock()
+
+	return spy.openCommittedEntriesCallCount
+}
+
 func (spy *Spy) NotifyOfAllCommitChangesCallCount() int {
 
+	spy.mutex.Lock()
+	defer spy.mutex.Unlock()
+
 	return spy.notifyOfAllCommitChangesCallCount
 }
 
@@ -241,13 +323,22 @@ func (spy *Spy) processCommands() {
 
 func (spy *Spy) commit(command SpyCommand) {
 
+	spy.mutex.Lock()
+
 	spy.committedEntryIndex = int(command.commitIndex)
 
-	notifySubscribersOfIndexChange(command.commitIndex, spy.subscribers)
+	committed := committedSubscribers(command.commitIndex, spy.subscribers)
+	allChangesNotifyCh := spy.allChangesNotifyCh
 
-	if spy.allChangesNotifyCh != nil {
+	spy.mutex.Unlock()
 
-		spy.allChangesNotifyCh <- command.commitIndex
+	for _, ch := range committed {
+		ch <- true
+	}
+
+	if allChangesNotifyCh != nil {
+
+		allChangesNotifyCh <- command.commitIndex
 	}
 
 	result := CommitResult{
@@ -259,6 +350,8 @@ func (spy *Spy) commit(command SpyCommand) {
 
 func (spy *Spy) append(command SpyCommand) {
 
+	spy.mutex.Lock()
+
 	var err error
 
 	if spy.isFailingNextAppend {
@@ -277,6 +370,8 @@ func (spy *Spy) append(command SpyCommand) {
 
 	headIndex := len(spy.log) - 1
 
+	spy.mutex.Unlock()
+
 	result := AppendResult{
 		Index: uint64(headIndex),
 		Error: err,
@@ -287,16 +382,24 @@ func (spy *Spy) append(command SpyCommand) {
 
 func (spy *Spy) CommitCalled() bool {
 
+	spy.mutex.Lock()
+	defer spy.mutex.Unlock()
+
 	return spy.commitCalled
 }
 
 func (spy *Spy) CommitCalledOnIndex(index uint64) bool {
 
+	spy.mutex.Lock()
+	defer spy.mutex.Unlock()
+
 	return spy.committedEntryIndex == int(index)
 }
 
 func (spy *Spy) getAllUncommittedEntries(command SpyCommand) {
 
+	spy.mutex.Lock()
+
 	var uncommittedSpyEntries []SpyEntry
 
 	numLogEntries := len(spy.log)
@@ -318,17 +421,25 @@ func (spy *Spy) getAllUncommittedEntries(command SpyCommand) {
 		HeadIndex:             numLogEntries - 1,
 	}
 
+	spy.mutex.Unlock()
+
 	command.getAllUncommittedEntriesDoneCh <- uncommittedEntriesResult
 }
 
-func notifySubscribersOfIndexChange(currentCommitIndex uint64, subscribers map[uint64]chan bool) {
+// committedSubscribers removes and returns the subscribers to indexes at or below currentCommitIndex
+func committedSubscribers(currentCommitIndex uint64, subscribers map[uint64]chan bool) []chan bool {
+
+	var committed []chan bool
 
 	for commitIndex, ch := range subscribers {
 
 		if currentCommitIndex >= commitIndex {
-			ch <- true
+			committed = append(committed, ch)
+			delete(subscribers, commitIndex)
 		}
 	}
+
+	return committed
 }
 
 func createEntryArray(journal []SpyEntry) []Entry {
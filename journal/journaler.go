@@ -1,8 +1,11 @@
 // Package journal contains interfaces and implementations of append only Item storage logs/journals
 package journal
 
+// Entry is a single item in the journal. Checksum is computed when the Entry is appended, see NewEntry, and travels
+// with the Entry wherever it goes, to disk and to followers, so the Item can be verified at every hop
 type Entry struct {
-	Item []byte
+	Item     []byte
+	Checksum uint32
 }
 
 type AppendResult struct {
@@ -36,10 +39,27 @@ type Iterator interface {
 	HasNext() bool
 }
 
+// Cursor is a lazily evaluated, forward only view over a range of journal entries. Entries are read from the
+// underlying storage on demand, only a bounded number are held in memory at any time. Cursors hold storage
+// resources, e.g. open files, and must be closed when no longer needed
+type Cursor interface {
+	// Next returns the next Entry, ErrNoMoreElements is returned once the cursor is exhausted
+	Next() (Entry, error)
+	HasNext() bool
+
+	// Index returns the journal index of the Entry most recently returned by Next
+	Index() uint64
+
+	// Seek positions the cursor so the next call to Next returns the Entry at index
+	Seek(index uint64) error
+	Close() error
+}
+
 type Journaler interface {
 	Append(item []byte) chan AppendResult
 	Commit(index uint64) chan CommitResult
 	GetAllCommittedEntries() Iterator
+	OpenCommittedEntries() (Cursor, error)
 	GetAllEntriesBetween(beginIndex uint64, endIndex uint64) (Iterator, error)
 	GetAllUncommittedEntries() chan AllUncommittedEntriesResult
 	GetHead() (Entry, error)
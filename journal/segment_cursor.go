@@ -0,0 +1,185 @@
+package journal
+
+import (
+	"bufio"
+	"os"
+	"sort"
+
+	"github.com/jrobison153/raft/crypto"
+)
+
+// segmentSpan describes the entries held by one segment file at the point a cursor was opened, later appends to
+// the segment are not visible to the cursor
+type segmentSpan struct {
+	path          string
+	firstIndex    uint64
+	formatVersion byte
+}
+
+// segmentCursor is a Cursor that streams entries directly from segment files. The cursor opens its own read only
+// file handle, one segment at a time, so it never contends with the journal writing the tail of the log
+type segmentCursor struct {
+	spans       []segmentSpan
+	endIndex    int64
+	nextIndex   uint64
+	lastIndex   uint64
+	file        *os.File
+	reader      *bufio.Reader
+	readerIndex uint64
+	readerSpan  int
+	keyring     *crypto.Keyring
+}
+
+// newSegmentCursor returns a Cursor over the entries in spans from index 0 through endIndex inclusive
+func newSegmentCursor(spans []segmentSpan, endIndex int64, keyring *crypto.Keyring) *segmentCursor {
+
+	return &segmentCursor{
+		spans:      spans,
+		endIndex:   endIndex,
+		readerSpan: -1,
+		keyring:    keyring,
+	}
+}
+
+func (cursor *segmentCursor) Next() (Entry, error) {
+
+	var entry Entry
+	var err error
+
+	if cursor.HasNext() {
+		entry, err = cursor.readNext()
+	} else {
+		err = ErrNoMoreElements
+	}
+
+	return entry, err
+}
+
+func (cursor *segmentCursor) HasNext() bool {
+
+	return int64(cursor.nextIndex) <= cursor.endIndex
+}
+
+func (cursor *segmentCursor) Index() uint64 {
+
+	return cursor.lastIndex
+}
+
+// Seek returns ErrIndexOutOfBounds if index is beyond the end of the cursor. Seeking is lazy, the segment holding
+// index is opened on the next call to Next
+func (cursor *segmentCursor) Seek(index uint64) error {
+
+	var err error
+
+	if int64(index) > cursor.endIndex {
+		err = ErrIndexOutOfBounds
+	} else {
+		cursor.nextIndex = index
+	}
+
+	return err
+}
+
+// Close releases the file handle held by the cursor, the cursor is exhausted afterwards
+func (cursor *segmentCursor) Close() error {
+
+	cursor.endIndex = -1
+
+	return cursor.closeSegment()
+}
+
+func (cursor *segmentCursor) readNext() (Entry, error) {
+
+	var entry Entry
+
+	err := cursor.positionAt(cursor.nextIndex)
+
+	if err == nil {
+		span := cursor.spans[cursor.readerSpan]
+		position := recordPosition(span.firstIndex, span.formatVersion, cursor.nextIndex)
+
+		entry, err = readRecord(cursor.reader, cursor.keyring, position)
+	}
+
+	if err == nil {
+
+		cursor.lastIndex = cursor.nextIndex
+		cursor.nextIndex += 1
+		cursor.readerIndex += 1
+	} else {
+
+		// the reader position is unknown after a failed read, force the next read to reposition from scratch
+		_ = cursor.closeSegment()
+	}
+
+	return entry, err
+}
+
+// positionAt makes sure the reader is positioned on the record for index, reading sequentially never needs to
+// reposition
+func (cursor *segmentCursor) positionAt(index uint64) error {
+
+	var err error
+
+	spanPosition := cursor.spanFor(index)
+
+	if spanPosition != cursor.readerSpan || index < cursor.readerIndex {
+		err = cursor.openSegment(spanPosition)
+	}
+
+	for err == nil && cursor.readerIndex < index {
+
+		_, err = skipRecord(cursor.reader)
+		cursor.readerIndex += 1
+	}
+
+	return err
+}
+
+func (cursor *segmentCursor) spanFor(index uint64) int {
+
+	position := sort.Search(len(cursor.spans), func(i int) bool {
+		return cursor.spans[i].firstIndex > index
+	})
+
+	return position - 1
+}
+
+func (cursor *segmentCursor) openSegment(spanPosition int) error {
+
+	err := cursor.closeSegment()
+
+	var file *os.File
+
+	if err == nil {
+		file, err = os.Open(cursor.spans[spanPosition].path)
+	}
+
+	if err == nil {
+
+		cursor.file = file
+		cursor.reader = bufio.NewReader(file)
+		cursor.readerSpan = spanPosition
+		cursor.readerIndex = cursor.spans[spanPosition].firstIndex
+
+		_, err = readSegmentHeader(cursor.reader)
+	}
+
+	return err
+}
+
+func (cursor *segmentCursor) closeSegment() error {
+
+	var err error
+
+	if cursor.file != nil {
+
+		err = cursor.file.Close()
+
+		cursor.file = nil
+		cursor.reader = nil
+		cursor.readerSpan = -1
+	}
+
+	return err
+}
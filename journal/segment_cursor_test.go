@@ -0,0 +1,143 @@
+package journal
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWhenIteratingSegmentCursorAcrossSegmentsThenEveryEntryIsReturnedInOrder(t *testing.T) {
+
+	store := setupSegmentStore(t, t.TempDir(), 3)
+	appendSegmentEntries(store, 10)
+
+	cursor := store.OpenCursor(9)
+	defer cursor.Close()
+
+	for i := 0; cursor.HasNext(); i++ {
+
+		entry, _ := cursor.Next()
+
+		if string(entry.Item) != fmt.Sprintf("item %d", i) || cursor.Index() != uint64(i) {
+			t.Errorf("Expected 'item %d' at index %d but got '%s' at index %d", i, i, entry.Item, cursor.Index())
+		}
+	}
+}
+
+func TestWhenSegmentCursorSeeksBackwardsThenTheEntryAtTheSeekIndexIsReturned(t *testing.T) {
+
+	store := setupSegmentStore(t, t.TempDir(), 3)
+	appendSegmentEntries(store, 10)
+
+	cursor := store.OpenCursor(9)
+	defer cursor.Close()
+
+	_ = cursor.Seek(8)
+	_, _ = cursor.Next()
+
+	_ = cursor.Seek(4)
+	entry, _ := cursor.Next()
+
+	if string(entry.Item) != "item 4" || cursor.Index() != 4 {
+		t.Errorf("Expected 'item 4' at index 4 but got '%s' at index %d", entry.Item, cursor.Index())
+	}
+}
+
+func TestWhenSegmentCursorSeeksBeyondItsEndThenAnErrorIsReturned(t *testing.T) {
+
+	store := setupSegmentStore(t, t.TempDir(), 3)
+	appendSegmentEntries(store, 10)
+
+	cursor := store.OpenCursor(5)
+	defer cursor.Close()
+
+	err := cursor.Seek(6)
+
+	if ErrIndexOutOfBounds != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrIndexOutOfBounds, err)
+	}
+}
+
+func TestWhenEntriesAppendedAfterSegmentCursorOpenedThenTheyAreNotVisible(t *testing.T) {
+
+	store := setupSegmentStore(t, t.TempDir(), 3)
+	appendSegmentEntries(store, 4)
+
+	cursor := store.OpenCursor(100)
+	defer cursor.Close()
+
+	appendSegmentEntries(store, 4)
+
+	count := 0
+
+	for cursor.HasNext() {
+
+		_, _ = cursor.Next()
+		count += 1
+	}
+
+	if count != 4 {
+		t.Errorf("Cursor should have only seen the 4 entries present when it was opened but saw %d", count)
+	}
+}
+
+func TestWhenSegmentCursorClosedThenItReleasesItsFileAndHasNoMoreEntries(t *testing.T) {
+
+	store := setupSegmentStore(t, t.TempDir(), 3)
+	appendSegmentEntries(store, 4)
+
+	cursor := store.OpenCursor(3)
+
+	_, _ = cursor.Next()
+	_ = cursor.Close()
+
+	segmentCursor := cursor.(*segmentCursor)
+
+	if segmentCursor.file != nil || cursor.HasNext() {
+		t.Errorf("Closed cursor should have released its file and have no more entries")
+	}
+}
+
+func TestWhenOpeningCommittedEntriesOnTieredJournalThenOnlyCommittedEntriesAreStreamed(t *testing.T) {
+
+	tiered := setupTieredJournal(t, t.TempDir(), 2)
+
+	appendTieredEntries(tiered, 10)
+	<-tiered.Commit(6)
+
+	cursor, _ := tiered.OpenCommittedEntries()
+	defer cursor.Close()
+
+	count := 0
+
+	for cursor.HasNext() {
+
+		_, _ = cursor.Next()
+		count += 1
+	}
+
+	if count != 7 || tiered.CacheStats().Misses != 0 {
+		t.Errorf("Cursor should have streamed 7 entries without touching the cache, streamed %d with stats %+v",
+			count,
+			tiered.CacheStats())
+	}
+}
+
+func TestWhenStreamedRecordIsCorruptThenTheCursorReturnsChecksumMismatch(t *testing.T) {
+
+	directory := t.TempDir()
+	store := setupSegmentStore(t, directory, 4)
+
+	appendSegmentEntries(store, 1)
+	_ = store.Close()
+
+	corruptSegmentByte(directory, segmentHeaderSize+recordHeaderSize)
+
+	cursor := setupSegmentStore(t, directory, 4).OpenCursor(0)
+	defer cursor.Close()
+
+	_, err := cursor.Next()
+
+	if ErrChecksumMismatch != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrChecksumMismatch, err)
+	}
+}
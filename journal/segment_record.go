@@ -0,0 +1,169 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+
+	"github.com/jrobison153/raft/crypto"
+)
+
+// A segment record is laid out as
+//
+//	| payload length uint32 | checksum uint32 | codec byte | key id uint32 | payload |
+//
+// with all integers little endian. The payload is the Entry item encoded with the codec and then, when the key id
+// is not crypto.NoKeyID, sealed with that key. For plaintext records the checksum is the Entry checksum computed
+// on append over the decoded item. For sealed records the checksum covers the sealed payload so corruption can be
+// told apart from a wrong key without leaking anything about the item, the Entry checksum is recomputed once the
+// payload has been authenticated. The checksum is verified whenever the record is read back. Sealed records also
+// authenticate their RecordPosition, so a record copied to another index or segment fails to open
+const (
+	recordHeaderSize     = 13
+	recordChecksumOffset = 4
+	recordCodecOffset    = 8
+	recordKeyIDOffset    = 9
+)
+
+// RecordPosition is where a record is stored, Segment is the index of the first entry of the segment file holding
+// the record and Index the index of its entry
+type RecordPosition struct {
+	Segment uint64
+	Index   uint64
+	// unbound is set for records of format version 1 segments, which were sealed without their position
+	unbound bool
+}
+
+func encodeRecord(checksum uint32, codec Codec, keyID uint32, payload []byte) []byte {
+
+	record := make([]byte, recordHeaderSize+len(payload))
+
+	binary.LittleEndian.PutUint32(record, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[recordChecksumOffset:], checksum)
+	record[recordCodecOffset] = byte(codec)
+	binary.LittleEndian.PutUint32(record[recordKeyIDOffset:], keyID)
+	copy(record[recordHeaderSize:], payload)
+
+	return record
+}
+
+// recordAdditionalData returns the codec and key id header bytes followed by the segment and index of position, they
+// are authenticated along with sealed payloads so none can be altered without detection
+func recordAdditionalData(codec Codec, keyID uint32, position RecordPosition) []byte {
+
+	headerSize := recordHeaderSize - recordCodecOffset
+
+	additionalData := make([]byte, headerSize, headerSize+16)
+
+	additionalData[0] = byte(codec)
+	binary.LittleEndian.PutUint32(additionalData[recordKeyIDOffset-recordCodecOffset:], keyID)
+
+	if !position.unbound {
+		additionalData = binary.LittleEndian.AppendUint64(additionalData, position.Segment)
+		additionalData = binary.LittleEndian.AppendUint64(additionalData, position.Index)
+	}
+
+	return additionalData
+}
+
+// readRecord reads the record stored at position from reader, opening sealed payloads with keyring. Returns
+// ErrChecksumMismatch if the record payload is corrupt, the corrupt Entry is still returned so callers can inspect
+// it. Sealed records that cannot be opened, or were sealed at another position, return crypto.ErrUnknownKeyID or
+// crypto.ErrDecryptionFailed
+func readRecord(reader io.Reader, keyring *crypto.Keyring, position RecordPosition) (Entry, error) {
+
+	header := make([]byte, recordHeaderSize)
+
+	_, err := io.ReadFull(reader, header)
+
+	var payload []byte
+
+	if err == nil {
+
+		payload = make([]byte, binary.LittleEndian.Uint32(header))
+
+		_, err = io.ReadFull(reader, payload)
+	}
+
+	var entry Entry
+
+	if err == nil {
+		entry, err = decodeRecord(header, payload, keyring, position)
+	}
+
+	return entry, err
+}
+
+func decodeRecord(header []byte, payload []byte, keyring *crypto.Keyring, position RecordPosition) (Entry, error) {
+
+	entry := Entry{
+		Item:     payload,
+		Checksum: binary.LittleEndian.Uint32(header[recordChecksumOffset:]),
+	}
+
+	codec := Codec(header[recordCodecOffset])
+	keyID := binary.LittleEndian.Uint32(header[recordKeyIDOffset:])
+
+	var err error
+
+	if keyID != crypto.NoKeyID {
+		entry, err = openSealedPayload(entry, codec, keyID, keyring, position)
+	} else {
+		entry.Item, err = decompressPayload(codec, payload)
+	}
+
+	if err == nil {
+		err = entry.Verify()
+	}
+
+	return entry, err
+}
+
+func openSealedPayload(
+	sealedEntry Entry,
+	codec Codec,
+	keyID uint32,
+	keyring *crypto.Keyring,
+	position RecordPosition) (Entry, error) {
+
+	err := sealedEntry.Verify()
+
+	if err == nil && keyring == nil {
+		err = crypto.ErrUnknownKeyID
+	}
+
+	var payload []byte
+
+	if err == nil {
+		payload, err = keyring.Open(keyID, sealedEntry.Item, recordAdditionalData(codec, keyID, position))
+	}
+
+	entry := sealedEntry
+
+	if err == nil {
+
+		var item []byte
+		item, err = decompressPayload(codec, payload)
+
+		entry = NewEntry(item)
+	}
+
+	return entry, err
+}
+
+// skipRecord advances reader past the next record without verifying it, returning the number of bytes the record
+// occupies on disk
+func skipRecord(reader *bufio.Reader) (int64, error) {
+
+	header := make([]byte, recordHeaderSize)
+
+	_, err := io.ReadFull(reader, header)
+
+	payloadLength := int64(binary.LittleEndian.Uint32(header))
+
+	if err == nil {
+		_, err = io.CopyN(io.Discard, reader, payloadLength)
+	}
+
+	return recordHeaderSize + payloadLength, err
+}
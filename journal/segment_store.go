@@ -0,0 +1,622 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jrobison153/raft/crypto"
+)
+
+const (
+	segmentFileExtension = ".seg"
+
+	// segmentFormatVersion is the record layout new segment files are written with, checksummed records carrying a
+	// codec flag and optionally sealed items. Version 2 seals items bound to their RecordPosition, version 1 segments
+	// sealed them without it and are still read, records appended to a version 1 tail keep its layout
+	segmentFormatVersion = 2
+	unboundFormatVersion = 1
+	segmentHeaderSize    = 5
+	metaFileName         = "journal.meta"
+
+	// DefaultSegmentMaxEntries is the number of entries written to a segment before a new one is started
+	DefaultSegmentMaxEntries = 4096
+)
+
+var (
+	ErrBadSegmentHeader       = errors.New("segment file does not have a valid journal segment header")
+	ErrUnsupportedSegmentFile = errors.New("segment file format version is not supported")
+	ErrReadOnlyStore          = errors.New("segment store was opened read only")
+
+	segmentMagic = []byte("RJSG")
+)
+
+// SegmentStore persists journal entries to a directory of append only segment files. Each segment holds a
+// contiguous run of entries and is named after the index of its first entry. A segment file starts with a
+// magic number and format version followed by checksummed, length prefixed records, one per Entry, whose items
+// are encoded by the store EntryEncoder. SegmentStore is not safe for concurrent use, owners are expected to serialize access to it
+type SegmentStore struct {
+	directory         string
+	maxSegmentEntries int
+	segments          []*segment
+	encoder           *EntryEncoder
+	readOnly          bool
+}
+
+type segment struct {
+	file          *os.File
+	firstIndex    uint64
+	formatVersion byte
+	offsets       []int64
+	size          int64
+	tornBytes     int64
+}
+
+// TornTail is a partially written record found after the last complete record of a segment, Offset is where it
+// starts in the segment file and Bytes is how long it is
+type TornTail struct {
+	FirstIndex uint64
+	Offset     int64
+	Bytes      int64
+}
+
+type segmentMeta struct {
+	CommitIndex int64
+}
+
+// OpenSegmentStore opens, creating if needed, the segment store in directory. Segments are rolled over once they
+// hold maxSegmentEntries entries. A partially written record at the tail of the last segment, e.g. from a crash
+// mid write, is discarded.
+func OpenSegmentStore(directory string, maxSegmentEntries int) (*SegmentStore, error) {
+
+	store := &SegmentStore{
+		directory:         directory,
+		maxSegmentEntries: maxSegmentEntries,
+		encoder:           NewEntryEncoder(CodecNone, DefaultCompressionMinBytes),
+	}
+
+	err := os.MkdirAll(directory, 0o750)
+
+	if err == nil {
+		err = store.loadSegments()
+	}
+
+	return store, err
+}
+
+// OpenSegmentStoreReadOnly opens the existing segment store in directory without modifying it. Nothing is created
+// and a torn record at the tail of a segment is left in place and reported by TornTails. Appends, truncation and
+// saving the commit index fail with ErrReadOnlyStore
+func OpenSegmentStoreReadOnly(directory string) (*SegmentStore, error) {
+
+	store := &SegmentStore{
+		directory:         directory,
+		maxSegmentEntries: DefaultSegmentMaxEntries,
+		encoder:           NewEntryEncoder(CodecNone, DefaultCompressionMinBytes),
+		readOnly:          true,
+	}
+
+	_, err := os.Stat(directory)
+
+	if err == nil {
+		err = store.loadSegments()
+	}
+
+	return store, err
+}
+
+// Append writes entry to the tail of the store, rolling over to a new segment when the current one is full.
+// Returns the index of the appended entry
+func (store *SegmentStore) Append(entry Entry) (uint64, error) {
+
+	index := store.EntryCount()
+
+	var tail *segment
+	err := store.writable()
+
+	if err == nil {
+		tail, err = store.writableTail(index)
+	}
+
+	var record []byte
+
+	if err == nil {
+		record, err = store.encoder.Encode(entry, tail.positionOf(index))
+	}
+
+	if err == nil {
+		err = tail.appendRecord(record)
+	}
+
+	return index, err
+}
+
+// UseEncoder sets the EntryEncoder used for subsequent appends and reads. Entries already stored keep their
+// encoding and remain readable as long as the encoder keyring holds the keys they were sealed with. Stores start
+// out with an encoder that neither compresses nor encrypts
+func (store *SegmentStore) UseEncoder(encoder *EntryEncoder) {
+
+	store.encoder = encoder
+}
+
+// Read returns the Entry stored at index. Returns ErrIndexOutOfBounds if no such entry exists and
+// ErrChecksumMismatch if the stored entry is corrupt
+func (store *SegmentStore) Read(index uint64) (Entry, error) {
+
+	var entry Entry
+
+	theSegment, err := store.segmentFor(index)
+
+	if err == nil {
+		entry, err = theSegment.readRecord(index, store.encoder.keyring)
+	}
+
+	return entry, err
+}
+
+// EntryCount returns the number of entries in the store, the head index is EntryCount() - 1
+func (store *SegmentStore) EntryCount() uint64 {
+
+	var count uint64
+
+	if len(store.segments) > 0 {
+
+		tail := store.segments[len(store.segments)-1]
+		count = tail.firstIndex + uint64(len(tail.offsets))
+	}
+
+	return count
+}
+
+// SegmentCount returns the number of segment files in the store
+func (store *SegmentStore) SegmentCount() int {
+
+	return len(store.segments)
+}
+
+// Codec returns the codec of the most recently written compressed record, which is the codec the journal is being
+// written with. Returns CodecNone when no record is compressed
+func (store *SegmentStore) Codec() (Codec, error) {
+
+	codec := CodecNone
+	var err error
+
+	for position := len(store.segments) - 1; position >= 0 && codec == CodecNone && err == nil; position-- {
+		codec, err = store.segments[position].lastCodec()
+	}
+
+	return codec, err
+}
+
+// TornTails returns the partially written records found at the tail of segments, only a store opened read only can
+// have any, opening a store for writing discards them
+func (store *SegmentStore) TornTails() []TornTail {
+
+	var torn []TornTail
+
+	for _, theSegment := range store.segments {
+
+		if theSegment.tornBytes > 0 {
+			torn = append(torn, TornTail{
+				FirstIndex: theSegment.firstIndex,
+				Offset:     theSegment.size,
+				Bytes:      theSegment.tornBytes,
+			})
+		}
+	}
+
+	return torn
+}
+
+// TruncateFrom permanently removes the entry at index and every entry after it. Segments that only hold removed
+// entries are deleted. Truncating at or beyond the end of the store is a no-op. TruncateFrom does not touch the
+// saved commit index, callers truncating committed entries must save a new one
+func (store *SegmentStore) TruncateFrom(index uint64) error {
+
+	err := store.writable()
+
+	for len(store.segments) > 0 && store.tail().firstIndex >= index && err == nil {
+		err = store.removeTail()
+	}
+
+	if err == nil && index < store.EntryCount() {
+		err = store.tail().truncateAt(index - store.tail().firstIndex)
+	}
+
+	return err
+}
+
+// OpenCursor returns a Cursor streaming the entries from index 0 through endIndex inclusive directly from the
+// segment files. Entries appended after the cursor is opened are not visible to it
+func (store *SegmentStore) OpenCursor(endIndex int64) Cursor {
+
+	spans := make([]segmentSpan, 0, len(store.segments))
+
+	for _, theSegment := range store.segments {
+
+		spans = append(spans, segmentSpan{
+			path:          theSegment.file.Name(),
+			firstIndex:    theSegment.firstIndex,
+			formatVersion: theSegment.formatVersion,
+		})
+	}
+
+	if uint64(endIndex+1) > store.EntryCount() {
+		endIndex = int64(store.EntryCount()) - 1
+	}
+
+	return newSegmentCursor(spans, endIndex, store.encoder.keyring)
+}
+
+// Sync flushes the tail segment to stable storage
+func (store *SegmentStore) Sync() error {
+
+	var err error
+
+	if len(store.segments) > 0 {
+		err = store.segments[len(store.segments)-1].file.Sync()
+	}
+
+	return err
+}
+
+// CommitIndex returns the last commit index saved with SaveCommitIndex, -1 if none has been saved
+func (store *SegmentStore) CommitIndex() (int64, error) {
+
+	meta := segmentMeta{CommitIndex: -1}
+
+	raw, err := os.ReadFile(filepath.Join(store.directory, metaFileName))
+
+	if err == nil {
+		err = json.Unmarshal(raw, &meta)
+	} else if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+
+	return meta.CommitIndex, err
+}
+
+// SaveCommitIndex durably records commitIndex. The write is atomic, a crash leaves either the old or new value
+func (store *SegmentStore) SaveCommitIndex(commitIndex int64) error {
+
+	raw, _ := json.Marshal(segmentMeta{CommitIndex: commitIndex})
+
+	metaPath := filepath.Join(store.directory, metaFileName)
+	tmpPath := metaPath + ".tmp"
+
+	err := store.writable()
+
+	if err == nil {
+		err = os.WriteFile(tmpPath, raw, 0o640)
+	}
+
+	if err == nil {
+		err = os.Rename(tmpPath, metaPath)
+	}
+
+	return err
+}
+
+// Close flushes every segment file to stable storage and releases them
+func (store *SegmentStore) Close() error {
+
+	var err error
+
+	for _, theSegment := range store.segments {
+
+		var syncErr error
+
+		if !store.readOnly {
+			syncErr = theSegment.file.Sync()
+		}
+
+		closeErr := theSegment.file.Close()
+
+		if err == nil {
+			err = syncErr
+		}
+
+		if err == nil {
+			err = closeErr
+		}
+	}
+
+	store.segments = nil
+
+	return err
+}
+
+func (store *SegmentStore) writable() error {
+
+	var err error
+
+	if store.readOnly {
+		err = ErrReadOnlyStore
+	}
+
+	return err
+}
+
+func (store *SegmentStore) tail() *segment {
+
+	return store.segments[len(store.segments)-1]
+}
+
+func (store *SegmentStore) removeTail() error {
+
+	tail := store.tail()
+
+	err := tail.file.Close()
+
+	if err == nil {
+
+		err = os.Remove(tail.file.Name())
+		store.segments = store.segments[:len(store.segments)-1]
+	}
+
+	return err
+}
+
+func (store *SegmentStore) segmentFor(index uint64) (*segment, error) {
+
+	position := sort.Search(len(store.segments), func(i int) bool {
+		return store.segments[i].firstIndex > index
+	})
+
+	var theSegment *segment
+	var err error
+
+	if position == 0 || index >= store.EntryCount() {
+		err = ErrIndexOutOfBounds
+	} else {
+		theSegment = store.segments[position-1]
+	}
+
+	return theSegment, err
+}
+
+func (store *SegmentStore) writableTail(nextIndex uint64) (*segment, error) {
+
+	var tail *segment
+	var err error
+
+	if len(store.segments) > 0 {
+		tail = store.segments[len(store.segments)-1]
+	}
+
+	if tail == nil || len(tail.offsets) >= store.maxSegmentEntries {
+
+		tail, err = createSegment(store.directory, nextIndex)
+
+		if err == nil {
+			store.segments = append(store.segments, tail)
+		}
+	}
+
+	return tail, err
+}
+
+func (store *SegmentStore) loadSegments() error {
+
+	paths, err := filepath.Glob(filepath.Join(store.directory, "*"+segmentFileExtension))
+
+	sort.Strings(paths)
+
+	for i := 0; i < len(paths) && err == nil; i++ {
+
+		var theSegment *segment
+		theSegment, err = openSegment(paths[i], store.readOnly)
+
+		if err == nil {
+			store.segments = append(store.segments, theSegment)
+		}
+	}
+
+	return err
+}
+
+func segmentPath(directory string, firstIndex uint64) string {
+
+	return filepath.Join(directory, fmt.Sprintf("%020d%s", firstIndex, segmentFileExtension))
+}
+
+func createSegment(directory string, firstIndex uint64) (*segment, error) {
+
+	file, err := os.OpenFile(segmentPath(directory, firstIndex), os.O_CREATE|os.O_EXCL|os.O_RDWR, 0o640)
+
+	var theSegment *segment
+
+	if err == nil {
+
+		header := append(append([]byte{}, segmentMagic...), segmentFormatVersion)
+		_, err = file.Write(header)
+
+		theSegment = &segment{
+			file:          file,
+			firstIndex:    firstIndex,
+			formatVersion: segmentFormatVersion,
+			offsets:       make([]int64, 0, 64),
+			size:          segmentHeaderSize,
+		}
+	}
+
+	return theSegment, err
+}
+
+func openSegment(path string, readOnly bool) (*segment, error) {
+
+	var firstIndex uint64
+	_, err := fmt.Sscanf(strings.TrimSuffix(filepath.Base(path), segmentFileExtension), "%d", &firstIndex)
+
+	var file *os.File
+
+	flag := os.O_RDWR
+
+	if readOnly {
+		flag = os.O_RDONLY
+	}
+
+	if err == nil {
+		file, err = os.OpenFile(path, flag, 0o640)
+	}
+
+	theSegment := &segment{
+		file:       file,
+		firstIndex: firstIndex,
+		offsets:    make([]int64, 0, 64),
+	}
+
+	if err == nil && readOnly {
+		err = theSegment.indexRecords()
+	} else if err == nil {
+		err = theSegment.recover()
+	}
+
+	return theSegment, err
+}
+
+// recover indexes every complete record and truncates away the torn write left after the last one, if there is one
+func (theSegment *segment) recover() error {
+
+	err := theSegment.indexRecords()
+
+	if err == nil && theSegment.tornBytes > 0 {
+
+		err = theSegment.file.Truncate(theSegment.size)
+		theSegment.tornBytes = 0
+	}
+
+	return err
+}
+
+// indexRecords validates the segment header and indexes every complete record. Anything after the last complete
+// record is a torn write, its length is recorded in tornBytes
+func (theSegment *segment) indexRecords() error {
+
+	reader := bufio.NewReader(io.NewSectionReader(theSegment.file, 0, 1<<62))
+
+	formatVersion, err := readSegmentHeader(reader)
+
+	theSegment.formatVersion = formatVersion
+	theSegment.size = segmentHeaderSize
+
+	for err == nil {
+
+		var recordSize int64
+		recordSize, err = skipRecord(reader)
+
+		theSegment.indexRecord(recordSize, err)
+	}
+
+	var info os.FileInfo
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		info, err = theSegment.file.Stat()
+	}
+
+	if err == nil {
+		theSegment.tornBytes = info.Size() - theSegment.size
+	}
+
+	return err
+}
+
+func (theSegment *segment) indexRecord(recordSize int64, err error) {
+
+	if err == nil {
+		theSegment.offsets = append(theSegment.offsets, theSegment.size)
+		theSegment.size += recordSize
+	}
+}
+
+// readSegmentHeader returns the format version of the segment whose header reader is positioned on
+func readSegmentHeader(reader io.Reader) (byte, error) {
+
+	header := make([]byte, segmentHeaderSize)
+
+	_, err := io.ReadFull(reader, header)
+
+	formatVersion := header[len(segmentMagic)]
+
+	if err != nil || string(header[:len(segmentMagic)]) != string(segmentMagic) {
+		err = ErrBadSegmentHeader
+	} else if formatVersion != segmentFormatVersion && formatVersion != unboundFormatVersion {
+		err = ErrUnsupportedSegmentFile
+	}
+
+	return formatVersion, err
+}
+
+func (theSegment *segment) appendRecord(record []byte) error {
+
+	_, err := theSegment.file.WriteAt(record, theSegment.size)
+
+	if err == nil {
+		theSegment.offsets = append(theSegment.offsets, theSegment.size)
+		theSegment.size += int64(len(record))
+	}
+
+	return err
+}
+
+func (theSegment *segment) truncateAt(position uint64) error {
+
+	offset := theSegment.offsets[position]
+
+	err := theSegment.file.Truncate(offset)
+
+	if err == nil {
+
+		theSegment.offsets = theSegment.offsets[:position]
+		theSegment.size = offset
+	}
+
+	return err
+}
+
+// lastCodec returns the codec of the last compressed record in the segment, CodecNone if it has none
+func (theSegment *segment) lastCodec() (Codec, error) {
+
+	codec := CodecNone
+	var err error
+
+	codecByte := make([]byte, 1)
+
+	for position := len(theSegment.offsets) - 1; position >= 0 && codec == CodecNone && err == nil; position-- {
+
+		_, err = theSegment.file.ReadAt(codecByte, theSegment.offsets[position]+recordCodecOffset)
+		codec = Codec(codecByte[0])
+	}
+
+	return codec, err
+}
+
+func (theSegment *segment) readRecord(index uint64, keyring *crypto.Keyring) (Entry, error) {
+
+	offset := theSegment.offsets[index-theSegment.firstIndex]
+
+	reader := io.NewSectionReader(theSegment.file, offset, theSegment.size-offset)
+
+	return readRecord(reader, keyring, theSegment.positionOf(index))
+}
+
+// positionOf returns the RecordPosition of the record for index in the segment
+func (theSegment *segment) positionOf(index uint64) RecordPosition {
+
+	return recordPosition(theSegment.firstIndex, theSegment.formatVersion, index)
+}
+
+func recordPosition(segmentFirstIndex uint64, formatVersion byte, index uint64) RecordPosition {
+
+	return RecordPosition{
+		Segment: segmentFirstIndex,
+		Index:   index,
+		unbound: formatVersion == unboundFormatVersion,
+	}
+}
@@ -0,0 +1,516 @@
+package journal
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jrobison153/raft/crypto"
+)
+
+func TestWhenEntryAppendedToSegmentStoreThenItCanBeReadBack(t *testing.T) {
+
+	store := setupSegmentStore(t, t.TempDir(), 4)
+
+	index, _ := store.Append(NewEntry([]byte("some item")))
+
+	entry, _ := store.Read(index)
+
+	if !bytes.Equal([]byte("some item"), entry.Item) {
+		t.Errorf("Expected to read back 'some item' but got '%s'", entry.Item)
+	}
+}
+
+func TestWhenSegmentIsFullThenANewSegmentFileIsStarted(t *testing.T) {
+
+	directory := t.TempDir()
+	store := setupSegmentStore(t, directory, 4)
+
+	appendSegmentEntries(store, 10)
+
+	segmentFiles, _ := filepath.Glob(filepath.Join(directory, "*"+segmentFileExtension))
+
+	if len(segmentFiles) != 3 {
+		t.Errorf("10 entries with 4 entries per segment should have created 3 segments but there were %d",
+			len(segmentFiles))
+	}
+}
+
+func TestWhenSegmentStoreIsReopenedThenPreviouslyAppendedEntriesAreReadable(t *testing.T) {
+
+	directory := t.TempDir()
+	store := setupSegmentStore(t, directory, 4)
+
+	appendSegmentEntries(store, 10)
+	_ = store.Close()
+
+	reopened := setupSegmentStore(t, directory, 4)
+
+	entry, _ := reopened.Read(6)
+
+	if reopened.EntryCount() != 10 || string(entry.Item) != "item 6" {
+		t.Errorf("Reopened store should have had 10 entries with 'item 6' at index 6, had %d entries and '%s'",
+			reopened.EntryCount(),
+			entry.Item)
+	}
+}
+
+func TestWhenLastRecordIsTornThenItIsDiscardedOnOpen(t *testing.T) {
+
+	directory := t.TempDir()
+	store := setupSegmentStore(t, directory, 16)
+
+	appendSegmentEntries(store, 3)
+	_ = store.Close()
+
+	tornSegment, _ := os.OpenFile(segmentPath(directory, 0), os.O_APPEND|os.O_WRONLY, 0o640)
+	_, _ = tornSegment.Write([]byte{42, 0, 0, 0, 'p', 'a', 'r'})
+	_ = tornSegment.Close()
+
+	reopened := setupSegmentStore(t, directory, 16)
+
+	index, _ := reopened.Append(NewEntry([]byte("after recovery")))
+
+	entry, _ := reopened.Read(index)
+
+	if index != 3 || string(entry.Item) != "after recovery" {
+		t.Errorf("Torn record should have been discarded, appended at index %d and read '%s'", index, entry.Item)
+	}
+}
+
+func TestWhenOpenedReadOnlyThenTornRecordIsReportedAndLeftInPlace(t *testing.T) {
+
+	directory := t.TempDir()
+	store := setupSegmentStore(t, directory, 16)
+
+	appendSegmentEntries(store, 3)
+	_ = store.Close()
+
+	tornSegment, _ := os.OpenFile(segmentPath(directory, 0), os.O_APPEND|os.O_WRONLY, 0o640)
+	_, _ = tornSegment.Write([]byte{42, 0, 0, 0, 'p', 'a', 'r'})
+	_ = tornSegment.Close()
+
+	before, _ := os.Stat(segmentPath(directory, 0))
+
+	readOnly, err := OpenSegmentStoreReadOnly(directory)
+
+	torn, count := readOnly.TornTails(), readOnly.EntryCount()
+	_, appendErr := readOnly.Append(NewEntry([]byte("rejected")))
+	_ = readOnly.Close()
+
+	after, _ := os.Stat(segmentPath(directory, 0))
+
+	if err != nil || len(torn) != 1 || torn[0].Bytes != 7 || count != 3 ||
+		ErrReadOnlyStore != appendErr || before.Size() != after.Size() {
+
+		t.Errorf("Expected a 7 byte torn tail left in place but got %+v, errors '%v' and '%v' and sizes %d and %d",
+			torn, err, appendErr, before.Size(), after.Size())
+	}
+}
+
+func TestWhenSegmentHasAnUnknownFormatVersionThenOpeningFails(t *testing.T) {
+
+	directory := t.TempDir()
+
+	header := append(append([]byte{}, segmentMagic...), segmentFormatVersion+1)
+	_ = os.WriteFile(segmentPath(directory, 0), header, 0o640)
+
+	_, err := OpenSegmentStore(directory, 16)
+
+	if ErrUnsupportedSegmentFile != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrUnsupportedSegmentFile, err)
+	}
+}
+
+func TestWhenStoreHoldsCompressedRecordsThenItsCodecIsTheLastOneWritten(t *testing.T) {
+
+	store := setupSegmentStore(t, t.TempDir(), 2)
+
+	store.UseEncoder(NewEntryEncoder(CodecGzip, DefaultCompressionMinBytes))
+	_, _ = store.Append(NewEntry(compressibleItem))
+	appendSegmentEntries(store, 3)
+
+	codec, err := store.Codec()
+
+	if err != nil || CodecGzip != codec {
+		t.Errorf("Expected codec %d but got %d and error '%v'", CodecGzip, codec, err)
+	}
+}
+
+func TestWhenReadingIndexBeyondTheEndOfTheStoreThenAnErrorIsReturned(t *testing.T) {
+
+	store := setupSegmentStore(t, t.TempDir(), 4)
+
+	appendSegmentEntries(store, 2)
+
+	_, err := store.Read(2)
+
+	if ErrIndexOutOfBounds != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrIndexOutOfBounds, err)
+	}
+}
+
+func TestWhenSegmentFileHasBadHeaderThenOpenFails(t *testing.T) {
+
+	directory := t.TempDir()
+
+	_ = os.WriteFile(segmentPath(directory, 0), []byte("not a segment"), 0o640)
+
+	_, err := OpenSegmentStore(directory, 4)
+
+	if ErrBadSegmentHeader != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrBadSegmentHeader, err)
+	}
+}
+
+func TestWhenCommitIndexNeverSavedThenItIsNegativeOne(t *testing.T) {
+
+	store := setupSegmentStore(t, t.TempDir(), 4)
+
+	commitIndex, _ := store.CommitIndex()
+
+	if commitIndex != -1 {
+		t.Errorf("Commit index should have defaulted to -1 but was %d", commitIndex)
+	}
+}
+
+func TestWhenCommitIndexSavedThenItSurvivesReopening(t *testing.T) {
+
+	directory := t.TempDir()
+	store := setupSegmentStore(t, directory, 4)
+
+	_ = store.SaveCommitIndex(7)
+	_ = store.Close()
+
+	commitIndex, _ := setupSegmentStore(t, directory, 4).CommitIndex()
+
+	if commitIndex != 7 {
+		t.Errorf("Commit index should have been 7 but was %d", commitIndex)
+	}
+}
+
+func setupSegmentStore(t *testing.T, directory string, maxSegmentEntries int) *SegmentStore {
+
+	store, err := OpenSegmentStore(directory, maxSegmentEntries)
+
+	if err != nil {
+		t.Fatalf("Unable to open segment store: %v", err)
+	}
+
+	t.Cleanup(func() { _ = store.Close() })
+
+	return store
+}
+
+func appendSegmentEntries(store *SegmentStore, count int) {
+
+	for i := 0; i < count; i++ {
+		_, _ = store.Append(NewEntry([]byte(fmt.Sprintf("item %d", i))))
+	}
+}
+
+func TestWhenStoredRecordIsCorruptThenReadReturnsChecksumMismatch(t *testing.T) {
+
+	directory := t.TempDir()
+	store := setupSegmentStore(t, directory, 4)
+
+	_, _ = store.Append(NewEntry([]byte("soon to be corrupt")))
+	_ = store.Close()
+
+	corruptSegmentByte(directory, segmentHeaderSize+recordHeaderSize)
+
+	_, err := setupSegmentStore(t, directory, 4).Read(0)
+
+	if ErrChecksumMismatch != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrChecksumMismatch, err)
+	}
+}
+
+func corruptSegmentByte(directory string, offset int64) {
+
+	segmentFile, _ := os.OpenFile(segmentPath(directory, 0), os.O_RDWR, 0o640)
+
+	someByte := make([]byte, 1)
+	_, _ = segmentFile.ReadAt(someByte, offset)
+
+	someByte[0] ^= 0xFF
+	_, _ = segmentFile.WriteAt(someByte, offset)
+
+	_ = segmentFile.Close()
+}
+
+func TestWhenStoreTruncatedMidSegmentThenLaterEntriesAreGone(t *testing.T) {
+
+	store := setupSegmentStore(t, t.TempDir(), 4)
+
+	appendSegmentEntries(store, 10)
+
+	_ = store.TruncateFrom(6)
+
+	_, err := store.Read(6)
+
+	if store.EntryCount() != 6 || ErrIndexOutOfBounds != err {
+		t.Errorf("Store should have had 6 entries after truncation but had %d, read of index 6 returned '%v'",
+			store.EntryCount(),
+			err)
+	}
+}
+
+func TestWhenStoreTruncatedAtSegmentBoundaryThenEmptiedSegmentsAreDeleted(t *testing.T) {
+
+	directory := t.TempDir()
+	store := setupSegmentStore(t, directory, 4)
+
+	appendSegmentEntries(store, 10)
+
+	_ = store.TruncateFrom(4)
+
+	segmentFiles, _ := filepath.Glob(filepath.Join(directory, "*"+segmentFileExtension))
+
+	if len(segmentFiles) != 1 || store.SegmentCount() != 1 {
+		t.Errorf("Only the first segment should have remained but there were %d files", len(segmentFiles))
+	}
+}
+
+func TestWhenStoreTruncatedThenAppendsContinueFromTheTruncationPoint(t *testing.T) {
+
+	directory := t.TempDir()
+	store := setupSegmentStore(t, directory, 4)
+
+	appendSegmentEntries(store, 10)
+
+	_ = store.TruncateFrom(5)
+	_ = store.Close()
+
+	reopened := setupSegmentStore(t, directory, 4)
+
+	index, _ := reopened.Append(NewEntry([]byte("replacement")))
+	entry, _ := reopened.Read(5)
+
+	if index != 5 || string(entry.Item) != "replacement" {
+		t.Errorf("Expected 'replacement' at index 5 but appended at %d and read '%s'", index, entry.Item)
+	}
+}
+
+func TestWhenStoreTruncatedBeyondItsEndThenNothingChanges(t *testing.T) {
+
+	store := setupSegmentStore(t, t.TempDir(), 4)
+
+	appendSegmentEntries(store, 3)
+
+	err := store.TruncateFrom(10)
+
+	if err != nil || store.EntryCount() != 3 {
+		t.Errorf("Truncating beyond the end should not have changed the store, got error '%v' and %d entries",
+			err,
+			store.EntryCount())
+	}
+}
+
+func TestWhenSegmentsWrittenWithDifferentCodecsThenTheMixedLogStaysReadable(t *testing.T) {
+
+	directory := t.TempDir()
+	store := setupSegmentStore(t, directory, 4)
+
+	_, _ = store.Append(NewEntry(compressibleItem))
+
+	store.UseEncoder(NewEntryEncoder(CodecGzip, 0))
+	_, _ = store.Append(NewEntry(compressibleItem))
+
+	store.UseEncoder(NewEntryEncoder(CodecFlate, 0))
+	_, _ = store.Append(NewEntry(compressibleItem))
+
+	_ = store.Close()
+
+	reopened := setupSegmentStore(t, directory, 4)
+
+	for index := uint64(0); index < 3; index++ {
+
+		entry, err := reopened.Read(index)
+
+		if err != nil || !bytes.Equal(compressibleItem, entry.Item) {
+			t.Errorf("Entry %d was not read back intact, error '%v'", index, err)
+		}
+	}
+}
+
+func TestWhenStoreEncryptsEntriesThenItemsAreNotOnDiskInPlaintextButReadBack(t *testing.T) {
+
+	directory := t.TempDir()
+	store := setupSegmentStore(t, directory, 4)
+	store.UseEncoder(NewEntryEncoder(CodecGzip, 0).EncryptWith(testKeyring(t, 1)))
+
+	_, _ = store.Append(NewEntry([]byte("customer secret")))
+
+	entry, err := store.Read(0)
+
+	if err != nil || string(entry.Item) != "customer secret" || segmentFilesContain(directory, "customer secret") {
+		t.Errorf("Expected the entry to be encrypted on disk and read back, got '%s' and error '%v'", entry.Item, err)
+	}
+}
+
+func TestWhenEncryptedStoreOpenedWithoutTheKeyThenUnknownKeyErrorIsReturned(t *testing.T) {
+
+	directory := t.TempDir()
+	store := setupSegmentStore(t, directory, 4)
+	store.UseEncoder(NewEntryEncoder(CodecNone, 0).EncryptWith(testKeyring(t, 1)))
+
+	_, _ = store.Append(NewEntry([]byte("customer secret")))
+	_ = store.Close()
+
+	_, err := setupSegmentStore(t, directory, 4).Read(0)
+
+	if crypto.ErrUnknownKeyID != err {
+		t.Errorf("Should have received error '%v' but got '%v'", crypto.ErrUnknownKeyID, err)
+	}
+}
+
+func TestWhenKeysRotatedThenEntriesSealedWithEitherKeyAreReadable(t *testing.T) {
+
+	store := setupSegmentStore(t, t.TempDir(), 4)
+
+	store.UseEncoder(NewEntryEncoder(CodecNone, 0).EncryptWith(testKeyring(t, 1)))
+	_, _ = store.Append(NewEntry([]byte("old key")))
+
+	store.UseEncoder(NewEntryEncoder(CodecNone, 0).EncryptWith(testKeyring(t, 1, 2)))
+	_, _ = store.Append(NewEntry([]byte("new key")))
+
+	oldEntry, oldErr := store.Read(0)
+	newEntry, newErr := store.Read(1)
+
+	if oldErr != nil || newErr != nil || string(oldEntry.Item) != "old key" || string(newEntry.Item) != "new key" {
+		t.Errorf("Expected both entries readable after rotation, got errors '%v' and '%v'", oldErr, newErr)
+	}
+}
+
+func TestWhenEncryptedRecordCorruptThenChecksumMismatchIsReturned(t *testing.T) {
+
+	directory := t.TempDir()
+	store := setupSegmentStore(t, directory, 4)
+	store.UseEncoder(NewEntryEncoder(CodecNone, 0).EncryptWith(testKeyring(t, 1)))
+
+	_, _ = store.Append(NewEntry([]byte("customer secret")))
+	_ = store.Close()
+
+	corruptSegmentByte(directory, segmentHeaderSize+recordHeaderSize+2)
+
+	reopened := setupSegmentStore(t, directory, 4)
+	reopened.UseEncoder(NewEntryEncoder(CodecNone, 0).EncryptWith(testKeyring(t, 1)))
+
+	_, err := reopened.Read(0)
+
+	if ErrChecksumMismatch != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrChecksumMismatch, err)
+	}
+}
+
+func TestWhenSealedRecordIsCopiedToAnotherIndexThenDecryptionFails(t *testing.T) {
+
+	directory := t.TempDir()
+	store := setupEncryptedStore(t, directory, 4)
+
+	_, _ = store.Append(NewEntry([]byte("first secret")))
+	_, _ = store.Append(NewEntry([]byte("other secret")))
+	_ = store.Close()
+
+	raw, _ := os.ReadFile(segmentPath(directory, 0))
+	recordSize := (len(raw) - segmentHeaderSize) / 2
+	copy(raw[segmentHeaderSize+recordSize:], raw[segmentHeaderSize:segmentHeaderSize+recordSize])
+	_ = os.WriteFile(segmentPath(directory, 0), raw, 0o640)
+
+	_, err := setupEncryptedStore(t, directory, 4).Read(1)
+
+	if crypto.ErrDecryptionFailed != err {
+		t.Errorf("Should have received error '%v' but got '%v'", crypto.ErrDecryptionFailed, err)
+	}
+}
+
+func TestWhenSealedRecordIsCopiedToAnotherSegmentAtTheSameIndexThenDecryptionFails(t *testing.T) {
+
+	sourceDirectory := t.TempDir()
+	source := setupEncryptedStore(t, sourceDirectory, 4)
+
+	_, _ = source.Append(NewEntry([]byte("first secret")))
+	_, _ = source.Append(NewEntry([]byte("other secret")))
+	_ = source.Close()
+
+	directory := t.TempDir()
+	store := setupEncryptedStore(t, directory, 1)
+
+	_, _ = store.Append(NewEntry([]byte("first secret")))
+	_, _ = store.Append(NewEntry([]byte("other secret")))
+	_ = store.Close()
+
+	sourceRaw, _ := os.ReadFile(segmentPath(sourceDirectory, 0))
+	raw, _ := os.ReadFile(segmentPath(directory, 1))
+	copy(raw[segmentHeaderSize:], sourceRaw[len(raw):])
+	_ = os.WriteFile(segmentPath(directory, 1), raw, 0o640)
+
+	_, err := setupEncryptedStore(t, directory, 1).Read(1)
+
+	if crypto.ErrDecryptionFailed != err {
+		t.Errorf("Should have received error '%v' but got '%v'", crypto.ErrDecryptionFailed, err)
+	}
+}
+
+func TestWhenVersionOneSegmentHoldsSealedRecordsThenTheyAreStillReadable(t *testing.T) {
+
+	directory := t.TempDir()
+
+	encoder := NewEntryEncoder(CodecNone, 0).EncryptWith(testKeyring(t, 1))
+	record, _ := encoder.Encode(NewEntry([]byte("customer secret")), RecordPosition{unbound: true})
+
+	header := append(append([]byte{}, segmentMagic...), unboundFormatVersion)
+	_ = os.WriteFile(segmentPath(directory, 0), append(header, record...), 0o640)
+
+	entry, err := setupEncryptedStore(t, directory, 4).Read(0)
+
+	if err != nil || string(entry.Item) != "customer secret" {
+		t.Errorf("Expected the version 1 entry to be read back, got '%s' and error '%v'", entry.Item, err)
+	}
+}
+
+func setupEncryptedStore(t *testing.T, directory string, maxSegmentEntries int) *SegmentStore {
+
+	store := setupSegmentStore(t, directory, maxSegmentEntries)
+	store.UseEncoder(NewEntryEncoder(CodecNone, 0).EncryptWith(testKeyring(t, 1)))
+
+	return store
+}
+
+func testKeyring(t *testing.T, keyIDs ...int) *crypto.Keyring {
+
+	specs := make([]string, 0, len(keyIDs))
+
+	for _, keyID := range keyIDs {
+		key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{byte(keyID)}, 32))
+		specs = append(specs, fmt.Sprintf("%d:%s", keyID, key))
+	}
+
+	keyring, err := crypto.ParseKeyring(strings.Join(specs, ","))
+
+	if err != nil {
+		t.Fatalf("Unable to create keyring: %v", err)
+	}
+
+	return keyring
+}
+
+func segmentFilesContain(directory string, text string) bool {
+
+	segmentFiles, _ := filepath.Glob(filepath.Join(directory, "*"+segmentFileExtension))
+
+	found := false
+
+	for _, segmentFile := range segmentFiles {
+
+		raw, _ := os.ReadFile(segmentFile)
+		found = found || bytes.Contains(raw, []byte(text))
+	}
+
+	return found
+}
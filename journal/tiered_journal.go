@@ -0,0 +1,684 @@
+package journal
+
+import (
+	"github.com/jrobison153/raft/crypto"
+	"github.com/prometheus/client_golang/prometheus"
+	"sync/atomic"
+)
+
+const (
+	closeJournal = "close"
+
+	defaultHotCommittedEntries     = 1024
+	defaultHotCommittedBudgetBytes = 16 * 1024 * 1024
+	defaultColdCacheBudgetBytes    = 64 * 1024 * 1024
+)
+
+// A TieredJournalConfig provides fields that can be used to modify the way the TieredJournal behaves
+type TieredJournalConfig struct {
+	// Directory is where the journal segment files are stored
+	Directory string
+
+	// HotCommittedEntries is the number of most recently committed entries kept in memory alongside the
+	// uncommitted entries. Default value is 1024
+	HotCommittedEntries int
+
+	// HotCommittedBudgetBytes bounds the memory used by the committed entries kept in memory, the oldest are moved
+	// out to the LRU cache once their items exceed it, even if there are fewer than HotCommittedEntries of them.
+	// Uncommitted entries are always kept in memory. Default value is 16MiB
+	HotCommittedBudgetBytes int
+
+	// ColdCacheBudgetBytes bounds the memory used to cache entries read back from disk. Least recently used
+	// entries are evicted once the budget is exceeded. Default value is 64MiB
+	ColdCacheBudgetBytes int
+
+	// SegmentMaxEntries is the number of entries written to a segment file before a new one is started.
+	// Default value is 4096
+	SegmentMaxEntries int
+
+	// Codec compresses entry items as they are written to the segment files. Default value is CodecNone
+	Codec Codec
+
+	// CompressionMinBytes is the item size below which entries are written uncompressed. Default value is 128
+	CompressionMinBytes int
+
+	// Keyring, when set, encrypts entries as they are written to the segment files and decrypts them on read.
+	// Default value is nil, entries are not encrypted
+	Keyring *crypto.Keyring
+}
+
+// CacheStats counts where TieredJournal reads were served from
+type CacheStats struct {
+	// HotHits are reads served from the in memory tail of the journal
+	HotHits uint64
+
+	// ColdHits are reads of older entries served from the LRU cache
+	ColdHits uint64
+
+	// Misses are reads that had to go to disk
+	Misses uint64
+
+	// Evictions are entries pushed out of the LRU cache to stay within budget
+	Evictions uint64
+}
+
+// TieredJournal is a Journaler that persists every Entry to disk segments while keeping the recent tail of the
+// log, all uncommitted entries plus the last HotCommittedEntries committed entries within HotCommittedBudgetBytes,
+// in memory for fast
+// replication reads. Older ranges are served from disk through a memory bounded LRU cache.
+// Like ArrayJournal, all operations are serialized through a single command processing routine and TieredJournal
+// is safe for concurrent use
+type TieredJournal struct {
+	config                             TieredJournalConfig
+	store                              *SegmentStore
+	encoder                            *EntryEncoder
+	hotEntries                         []Entry
+	hotStartIndex                      uint64
+	hotCommittedBytes                  int
+	headIndex                          int64
+	commitIndex                        int64
+	coldCache                          *entryCache
+	oneTimeCommitChangeSubscribers     map[uint64][]chan bool
+	notifyOfAllCommitChangeSubscribers []*commitForwarder
+	workQueue                          chan ArrayJournalCommand
+	hotHits                            atomic.Uint64
+	coldHits                           atomic.Uint64
+	misses                             atomic.Uint64
+	evictions                          atomic.Uint64
+}
+
+func NewDefaultTieredJournalConfig(directory string) *TieredJournalConfig {
+
+	return &TieredJournalConfig{
+		Directory:               directory,
+		HotCommittedEntries:     defaultHotCommittedEntries,
+		HotCommittedBudgetBytes: defaultHotCommittedBudgetBytes,
+		ColdCacheBudgetBytes:    defaultColdCacheBudgetBytes,
+		SegmentMaxEntries:       DefaultSegmentMaxEntries,
+		Codec:                   CodecNone,
+		CompressionMinBytes:     DefaultCompressionMinBytes,
+	}
+}
+
+// NewTieredJournal opens the journal stored in config.Directory, creating it if it does not exist, and loads the
+// hot tail of the log into memory
+func NewTieredJournal(config *TieredJournalConfig) (*TieredJournal, error) {
+
+	store, err := OpenSegmentStore(config.Directory, config.SegmentMaxEntries)
+
+	journal := &TieredJournal{
+		config:                             *config,
+		store:                              store,
+		encoder:                            NewEntryEncoder(config.Codec, config.CompressionMinBytes).EncryptWith(config.Keyring),
+		coldCache:                          newEntryCache(config.ColdCacheBudgetBytes),
+		oneTimeCommitChangeSubscribers:     make(map[uint64][]chan bool),
+		notifyOfAllCommitChangeSubscribers: make([]*commitForwarder, 0, 16),
+		workQueue:                          make(chan ArrayJournalCommand, 1024),
+	}
+
+	if err == nil {
+
+		store.UseEncoder(journal.encoder)
+
+		err = journal.loadHotEntries()
+	}
+
+	if err == nil {
+		go journal.processCommands()
+	}
+
+	return journal, err
+}
+
+// Append durably writes item to the journal as the new head Entry.
+// Append is safe for concurrent execution
+func (journal *TieredJournal) Append(item []byte) chan AppendResult {
+
+	doneCh := make(chan AppendResult, 1)
+
+	journal.workQueue <- ArrayJournalCommand{
+		name:         Append,
+		appendEntry:  NewEntry(item),
+		appendDoneCh: doneCh,
+	}
+
+	return doneCh
+}
+
+// Commit durably records index as the commit index, see ArrayJournal.Commit for the notification and error
+// semantics.
+// Commit is safe for concurrent execution
+func (journal *TieredJournal) Commit(index uint64) chan CommitResult {
+
+	doneCh := make(chan CommitResult, 1)
+
+	journal.workQueue <- ArrayJournalCommand{
+		name:         Commit,
+		commitIndex:  int64(index),
+		commitDoneCh: doneCh,
+	}
+
+	return doneCh
+}
+
+// GetAllCommittedEntries returns a read only Iterator over a point in time copy of the committed entries
+// GetAllCommittedEntries is safe for concurrent execution
+func (journal *TieredJournal) GetAllCommittedEntries() Iterator {
+
+	result := journal.entriesCommand(ArrayJournalCommand{name: GetAllCommittedEntries})
+
+	return result.iterator
+}
+
+// OpenCommittedEntries returns a Cursor that streams the entries committed at the time of the call directly from
+// the disk segments, bypassing the in memory tiers so that large scans do not churn the LRU cache.
+// OpenCommittedEntries is safe for concurrent execution
+func (journal *TieredJournal) OpenCommittedEntries() (Cursor, error) {
+
+	doneCh := make(chan Cursor, 1)
+
+	journal.workQueue <- ArrayJournalCommand{
+		name:         OpenCommittedEntries,
+		cursorDoneCh: doneCh,
+	}
+
+	return <-doneCh, nil
+}
+
+// GetAllEntriesBetween returns a read only Iterator over a point in time copy of the journal entries between
+// beginIndex and endIndex inclusive. The values returned can include those that have not been committed yet.
+// GetAllEntriesBetween is safe for concurrent execution
+func (journal *TieredJournal) GetAllEntriesBetween(beginIndex uint64, endIndex uint64) (Iterator, error) {
+
+	result := journal.entriesCommand(ArrayJournalCommand{
+		name:       GetAllEntriesBetween,
+		beginIndex: beginIndex,
+		endIndex:   endIndex,
+	})
+
+	return result.iterator, result.err
+}
+
+// GetAllUncommittedEntries returns the entries that have yet to be committed, these are always served from memory
+// GetAllUncommittedEntries is safe for concurrent execution
+func (journal *TieredJournal) GetAllUncommittedEntries() chan AllUncommittedEntriesResult {
+
+	doneCh := make(chan AllUncommittedEntriesResult, 1)
+
+	journal.workQueue <- ArrayJournalCommand{
+		name:                           GetAllUncommittedEntries,
+		getAllUncommittedEntriesDoneCh: doneCh,
+	}
+
+	return doneCh
+}
+
+// GetHead returns the head Entry of the log. If the log is empty then an ErrEmptyLog is returned.
+// GetHead is safe for concurrent execution
+func (journal *TieredJournal) GetHead() (Entry, error) {
+
+	doneCh := make(chan headResult, 1)
+
+	journal.workQueue <- ArrayJournalCommand{
+		name:       GetHead,
+		headDoneCh: doneCh,
+	}
+
+	result := <-doneCh
+
+	return result.entry, result.err
+}
+
+// NotifyOfAllCommitChanges registers channel ch to receive the index of every commit, with the same coalescing
+// semantics as ArrayJournal.NotifyOfAllCommitChanges.
+// NotifyOfAllCommitChanges is safe for concurrent execution
+func (journal *TieredJournal) NotifyOfAllCommitChanges(ch chan uint64) {
+
+	journal.workQueue <- ArrayJournalCommand{
+		name:        NotifyOfAllCommitChanges,
+		subscribeCh: ch,
+	}
+}
+
+// NotifyOfCommitOnIndexOnce registers ch to receive true once index, or a later index, is committed, ch must be
+// buffered to hold the notification. Returns ErrUnbufferedSubscription if ch is unbuffered
+// NotifyOfCommitOnIndexOnce is safe for concurrent execution
+func (journal *TieredJournal) NotifyOfCommitOnIndexOnce(index uint64, ch chan bool) error {
+
+	doneCh := make(chan error, 1)
+
+	journal.workQueue <- ArrayJournalCommand{
+		name:         NotifyOfCommitOnIndexOnce,
+		notifyIndex:  index,
+		notifyCh:     ch,
+		notifyDoneCh: doneCh,
+	}
+
+	return <-doneCh
+}
+
+// CacheStats returns a snapshot of where reads have been served from
+func (journal *TieredJournal) CacheStats() CacheStats {
+
+	return CacheStats{
+		HotHits:   journal.hotHits.Load(),
+		ColdHits:  journal.coldHits.Load(),
+		Misses:    journal.misses.Load(),
+		Evictions: journal.evictions.Load(),
+	}
+}
+
+// CompressionStats returns a snapshot of the bytes appended to the journal before and after compression
+func (journal *TieredJournal) CompressionStats() CompressionStats {
+
+	return journal.encoder.Stats()
+}
+
+// Collectors returns Prometheus collectors exposing the journal cache hit, miss and eviction counts and the
+// compression ratio achieved on appended entries
+func (journal *TieredJournal) Collectors() []prometheus.Collector {
+
+	return []prometheus.Collector{
+		prometheus.NewCounterFunc(
+			prometheus.CounterOpts{
+				Name: "raft_journal_uncompressed_bytes_total",
+				Help: "Bytes of entry items appended to the journal",
+			},
+			func() float64 { return float64(journal.encoder.Stats().UncompressedBytes) }),
+		prometheus.NewCounterFunc(
+			prometheus.CounterOpts{
+				Name: "raft_journal_encoded_bytes_total",
+				Help: "Bytes of entry items written to the journal segments after compression",
+			},
+			func() float64 { return float64(journal.encoder.Stats().EncodedBytes) }),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "raft_journal_compression_ratio",
+				Help: "Uncompressed over encoded bytes of entry items appended to the journal",
+			},
+			func() float64 { return journal.encoder.Stats().Ratio() }),
+		newCounterFunc("raft_journal_hot_hits_total", "Journal reads served from the in memory tail",
+			&journal.hotHits),
+		newCounterFunc("raft_journal_cold_cache_hits_total", "Journal reads served from the LRU cache",
+			&journal.coldHits),
+		newCounterFunc("raft_journal_cache_misses_total", "Journal reads served from disk", &journal.misses),
+		newCounterFunc("raft_journal_cache_evictions_total", "Journal entries evicted from the LRU cache",
+			&journal.evictions),
+	}
+}
+
+// Close stops accepting new work and releases the segment files. Close must not be called while other
+// operations are in flight
+func (journal *TieredJournal) Close() error {
+
+	doneCh := make(chan error, 1)
+
+	journal.workQueue <- ArrayJournalCommand{
+		name:         closeJournal,
+		notifyDoneCh: doneCh,
+	}
+
+	return <-doneCh
+}
+
+func newCounterFunc(name string, help string, counter *atomic.Uint64) prometheus.CounterFunc {
+
+	return prometheus.NewCounterFunc(
+		prometheus.CounterOpts{Name: name, Help: help},
+		func() float64 { return float64(counter.Load()) })
+}
+
+func (journal *TieredJournal) entriesCommand(command ArrayJournalCommand) entriesResult {
+
+	doneCh := make(chan entriesResult, 1)
+	command.entriesDoneCh = doneCh
+
+	journal.workQueue <- command
+
+	return <-doneCh
+}
+
+func (journal *TieredJournal) loadHotEntries() error {
+
+	commitIndex, err := journal.store.CommitIndex()
+
+	journal.commitIndex = commitIndex
+	journal.headIndex = int64(journal.store.EntryCount()) - 1
+	journal.hotStartIndex = journal.hotStartFor(commitIndex)
+	journal.hotEntries = make([]Entry, 0, journal.config.HotCommittedEntries)
+
+	for index := journal.hotStartIndex; int64(index) <= journal.headIndex && err == nil; index++ {
+
+		var entry Entry
+		entry, err = journal.store.Read(index)
+
+		journal.hotEntries = append(journal.hotEntries, entry)
+	}
+
+	if err == nil {
+		journal.demoteColdEntries(int64(journal.hotStartIndex) - 1)
+	}
+
+	return err
+}
+
+// hotStartFor returns the lowest index that stays in memory when commitIndex is the commit index
+func (journal *TieredJournal) hotStartFor(commitIndex int64) uint64 {
+
+	hotStart := commitIndex + 1 - int64(journal.config.HotCommittedEntries)
+
+	if hotStart < 0 {
+		hotStart = 0
+	}
+
+	return uint64(hotStart)
+}
+
+//nolint:gocyclo
+func (journal *TieredJournal) processCommands() {
+
+	for command := range journal.workQueue {
+
+		switch command.name {
+
+		case Append:
+
+			journal.append(command)
+
+		case Commit:
+
+			journal.commit(command)
+
+		case GetAllCommittedEntries:
+
+			journal.getAllCommittedEntries(command)
+
+		case GetAllEntriesBetween:
+
+			journal.getAllEntriesBetween(command)
+
+		case GetAllUncommittedEntries:
+
+			journal.getAllUncommittedEntries(command)
+
+		case GetHead:
+
+			journal.getHead(command)
+
+		case NotifyOfAllCommitChanges:
+
+			journal.notifyOfAllCommitChangeSubscribers = append(journal.notifyOfAllCommitChangeSubscribers,
+				newCommitForwarder(command.subscribeCh))
+
+		case NotifyOfCommitOnIndexOnce:
+
+			journal.notifyOfCommitOnIndexOnce(command)
+
+		case OpenCommittedEntries:
+
+			command.cursorDoneCh <- journal.store.OpenCursor(journal.commitIndex)
+
+		case closeJournal:
+
+			command.notifyDoneCh <- journal.store.Close()
+			return
+		}
+	}
+}
+
+func (journal *TieredJournal) append(command ArrayJournalCommand) {
+
+	index, err := journal.store.Append(command.appendEntry)
+
+	if err == nil {
+		err = journal.store.Sync()
+	}
+
+	if err == nil {
+
+		journal.hotEntries = append(journal.hotEntries, command.appendEntry)
+		journal.headIndex = int64(index)
+	}
+
+	command.appendDoneCh <- AppendResult{
+		Error: err,
+		Index: index,
+	}
+}
+
+func (journal *TieredJournal) commit(command ArrayJournalCommand) {
+
+	var err error
+
+	if journal.headIndex == -1 {
+		err = ErrCommitOnEmptyLog
+	} else if command.commitIndex <= journal.headIndex {
+		err = journal.commitIndexAndNotify(command.commitIndex)
+	} else {
+		err = ErrIndexBeyondHead
+	}
+
+	command.commitDoneCh <- CommitResult{
+		Error: err,
+	}
+}
+
+// commitIndexAndNotify durably advances the commit index to commitIndex and notifies the subscribers. The commit
+// index never moves back, committing an index that is already committed only notifies the one-time subscribers, the
+// subscribers of all commit changes only hear of an index that advances it
+func (journal *TieredJournal) commitIndexAndNotify(commitIndex int64) error {
+
+	var err error
+
+	isAdvance := commitIndex > journal.commitIndex
+
+	if isAdvance {
+		err = journal.store.SaveCommitIndex(commitIndex)
+	}
+
+	if err == nil && isAdvance {
+
+		previousCommitIndex := journal.commitIndex
+		journal.commitIndex = commitIndex
+		journal.demoteColdEntries(previousCommitIndex)
+	}
+
+	if err == nil {
+
+		notifyOneTimeSubscribers(removeOneTimeSubscribersUpTo(journal.oneTimeCommitChangeSubscribers,
+			uint64(commitIndex)))
+	}
+
+	if err == nil && isAdvance {
+
+		for _, forwarder := range journal.notifyOfAllCommitChangeSubscribers {
+			forwarder.forward(uint64(commitIndex))
+		}
+	}
+
+	return err
+}
+
+// demoteColdEntries accounts for the entries committed after previousCommitIndex and moves the committed entries
+// that have fallen out of the hot tail, by count or by size, into the LRU cache
+func (journal *TieredJournal) demoteColdEntries(previousCommitIndex int64) {
+
+	for index := previousCommitIndex + 1; index <= journal.commitIndex; index++ {
+		journal.hotCommittedBytes += len(journal.hotEntries[uint64(index)-journal.hotStartIndex].Item)
+	}
+
+	newHotStart := journal.hotStartIndex
+
+	for newHotStart < journal.hotStartFor(journal.commitIndex) || journal.isOverHotBudget(newHotStart) {
+
+		entry := journal.hotEntries[newHotStart-journal.hotStartIndex]
+
+		journal.hotCommittedBytes -= len(entry.Item)
+		journal.cacheColdEntry(newHotStart, entry)
+
+		newHotStart++
+	}
+
+	if newHotStart > journal.hotStartIndex {
+
+		journal.hotEntries = copyEntries(journal.hotEntries[newHotStart-journal.hotStartIndex:])
+		journal.hotStartIndex = newHotStart
+	}
+}
+
+// isOverHotBudget reports whether the committed entries kept in memory exceed their budget while hotStart, the
+// oldest of them, is still committed
+func (journal *TieredJournal) isOverHotBudget(hotStart uint64) bool {
+
+	return journal.hotCommittedBytes > journal.config.HotCommittedBudgetBytes && int64(hotStart) <= journal.commitIndex
+}
+
+func (journal *TieredJournal) cacheColdEntry(index uint64, entry Entry) {
+
+	evictionsBefore := journal.coldCache.evictions
+
+	journal.coldCache.put(index, entry)
+
+	journal.evictions.Add(journal.coldCache.evictions - evictionsBefore)
+}
+
+func (journal *TieredJournal) getAllCommittedEntries(command ArrayJournalCommand) {
+
+	entries, err := journal.readRange(0, journal.commitIndex)
+
+	command.entriesDoneCh <- entriesResult{
+		iterator: NewArrayJournalIterator(entries),
+		err:      err,
+	}
+}
+
+func (journal *TieredJournal) getAllEntriesBetween(command ArrayJournalCommand) {
+
+	var result entriesResult
+
+	if command.beginIndex > command.endIndex {
+		result.err = ErrInvertedIndexes
+	} else if int64(command.endIndex) > journal.headIndex {
+		result.err = ErrIndexOutOfBounds
+	} else {
+		result = journal.readRangeResult(command.beginIndex, int64(command.endIndex))
+	}
+
+	command.entriesDoneCh <- result
+}
+
+func (journal *TieredJournal) readRangeResult(beginIndex uint64, endIndex int64) entriesResult {
+
+	entries, err := journal.readRange(beginIndex, endIndex)
+
+	return entriesResult{
+		iterator: NewArrayJournalIterator(entries),
+		err:      err,
+	}
+}
+
+func (journal *TieredJournal) readRange(beginIndex uint64, endIndex int64) ([]Entry, error) {
+
+	entries := make([]Entry, 0, endIndex-int64(beginIndex)+1)
+
+	var err error
+
+	for index := beginIndex; int64(index) <= endIndex && err == nil; index++ {
+
+		var entry Entry
+		entry, err = journal.entryAt(index)
+
+		entries = append(entries, entry)
+	}
+
+	return entries, err
+}
+
+func (journal *TieredJournal) entryAt(index uint64) (Entry, error) {
+
+	var entry Entry
+	var err error
+
+	if index >= journal.hotStartIndex {
+
+		journal.hotHits.Add(1)
+		entry = journal.hotEntries[index-journal.hotStartIndex]
+	} else {
+		entry, err = journal.coldEntryAt(index)
+	}
+
+	return entry, err
+}
+
+func (journal *TieredJournal) coldEntryAt(index uint64) (Entry, error) {
+
+	entry, cached := journal.coldCache.get(index)
+
+	var err error
+
+	if cached {
+		journal.coldHits.Add(1)
+	} else {
+
+		journal.misses.Add(1)
+
+		entry, err = journal.store.Read(index)
+		journal.cacheColdEntryOnSuccess(index, entry, err)
+	}
+
+	return entry, err
+}
+
+func (journal *TieredJournal) cacheColdEntryOnSuccess(index uint64, entry Entry, err error) {
+
+	if err == nil {
+		journal.cacheColdEntry(index, entry)
+	}
+}
+
+func (journal *TieredJournal) getAllUncommittedEntries(command ArrayJournalCommand) {
+
+	uncommittedStart := journal.commitIndex + 1 - int64(journal.hotStartIndex)
+
+	uncommittedEntries := copyEntries(journal.hotEntries[uncommittedStart:])
+
+	command.getAllUncommittedEntriesDoneCh <- AllUncommittedEntriesResult{
+		CommitIndex:           int(journal.commitIndex),
+		HasUncommittedEntries: journal.headIndex != journal.commitIndex,
+		HeadIndex:             int(journal.headIndex),
+		UncommittedEntries:    NewArrayJournalIterator(uncommittedEntries),
+	}
+}
+
+func (journal *TieredJournal) getHead(command ArrayJournalCommand) {
+
+	var result headResult
+
+	// with no committed entries kept in memory a fully committed log has an empty hot tail
+	if journal.headIndex >= 0 {
+		result.entry, result.err = journal.entryAt(uint64(journal.headIndex))
+	} else {
+		result.err = ErrEmptyLog
+	}
+
+	command.headDoneCh <- result
+}
+
+func (journal *TieredJournal) notifyOfCommitOnIndexOnce(command ArrayJournalCommand) {
+
+	var err error
+
+	if cap(command.notifyCh) == 0 {
+		err = ErrUnbufferedSubscription
+	} else if journal.headIndex == -1 {
+		err = ErrSubscriptionOnEmptyLog
+	} else if int64(command.notifyIndex) <= journal.headIndex {
+
+		journal.oneTimeCommitChangeSubscribers[command.notifyIndex] =
+			append(journal.oneTimeCommitChangeSubscribers[command.notifyIndex], command.notifyCh)
+	} else {
+		err = ErrIndexBeyondHead
+	}
+
+	command.notifyDoneCh <- err
+}
@@ -0,0 +1,339 @@
+package journal
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWhenTieredJournalDefaultConfigCreatedThenTheDefaultsAreSet(t *testing.T) {
+
+	config := NewDefaultTieredJournalConfig("somewhere")
+
+	if config.HotCommittedEntries != defaultHotCommittedEntries ||
+		config.HotCommittedBudgetBytes != defaultHotCommittedBudgetBytes ||
+		config.ColdCacheBudgetBytes != defaultColdCacheBudgetBytes ||
+		config.SegmentMaxEntries != DefaultSegmentMaxEntries {
+		t.Errorf("Default config was not set correctly, got %+v", config)
+	}
+}
+
+func TestWhenItemAppendedToTieredJournalThenItIsTheHeadEntry(t *testing.T) {
+
+	tiered := setupTieredJournal(t, t.TempDir(), 2)
+
+	appendTieredEntries(tiered, 3)
+
+	head, _ := tiered.GetHead()
+
+	if string(head.Item) != "item 2" {
+		t.Errorf("Head should have been 'item 2' but was '%s'", head.Item)
+	}
+}
+
+func TestGivenEmptyTieredJournalWhenGettingHeadThenAnErrorIsReturned(t *testing.T) {
+
+	tiered := setupTieredJournal(t, t.TempDir(), 2)
+
+	_, err := tiered.GetHead()
+
+	if ErrEmptyLog != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrEmptyLog, err)
+	}
+}
+
+func TestWhenCommittedEntriesFallOutOfTheHotTailThenTheyAreStillReadable(t *testing.T) {
+
+	tiered := setupTieredJournal(t, t.TempDir(), 2)
+
+	appendTieredEntries(tiered, 10)
+	<-tiered.Commit(7)
+
+	entriesIt, _ := tiered.GetAllEntriesBetween(0, 9)
+
+	for i := 0; entriesIt.HasNext(); i++ {
+
+		entry, _ := entriesIt.Next()
+
+		if string(entry.Item) != fmt.Sprintf("item %d", i) {
+			t.Errorf("Entry %d should have been 'item %d' but was '%s'", i, i, entry.Item)
+		}
+	}
+}
+
+func TestWhenCommittedEntriesFallOutOfTheHotTailThenOnlyTheConfiguredNumberRemainInMemory(t *testing.T) {
+
+	tiered := setupTieredJournal(t, t.TempDir(), 2)
+
+	appendTieredEntries(tiered, 10)
+	<-tiered.Commit(7)
+
+	// uncommitted 8 and 9 plus the last two committed, 6 and 7
+	if tiered.hotStartIndex != 6 {
+		t.Errorf("Hot tail should have started at index 6 but started at %d", tiered.hotStartIndex)
+	}
+}
+
+func TestWhenReadingTheHotTailThenReadsAreCountedAsHotHits(t *testing.T) {
+
+	tiered := setupTieredJournal(t, t.TempDir(), 2)
+
+	appendTieredEntries(tiered, 4)
+
+	_, _ = tiered.GetAllEntriesBetween(0, 3)
+
+	stats := tiered.CacheStats()
+
+	if stats.HotHits != 4 || stats.Misses != 0 {
+		t.Errorf("Expected 4 hot hits and no misses but got %+v", stats)
+	}
+}
+
+func TestWhenReadingColdEntriesThenTheFirstReadMissesAndTheSecondHitsTheCache(t *testing.T) {
+
+	directory := t.TempDir()
+	tiered := setupTieredJournal(t, directory, 1)
+
+	appendTieredEntries(tiered, 5)
+	<-tiered.Commit(4)
+	_ = tiered.Close()
+
+	reopened := setupTieredJournal(t, directory, 1)
+
+	_, _ = reopened.GetAllEntriesBetween(0, 1)
+	_, _ = reopened.GetAllEntriesBetween(0, 1)
+
+	stats := reopened.CacheStats()
+
+	if stats.Misses != 2 || stats.ColdHits != 2 {
+		t.Errorf("Expected 2 misses and 2 cold hits but got %+v", stats)
+	}
+}
+
+func TestWhenColdCacheBudgetExceededThenEvictionsAreCounted(t *testing.T) {
+
+	config := NewDefaultTieredJournalConfig(t.TempDir())
+	config.HotCommittedEntries = 1
+	config.ColdCacheBudgetBytes = len("item 0")
+
+	tiered := openTieredJournal(t, config)
+
+	appendTieredEntries(tiered, 5)
+	<-tiered.Commit(4)
+
+	if tiered.CacheStats().Evictions != 3 {
+		t.Errorf("Expected 3 evictions but got %+v", tiered.CacheStats())
+	}
+}
+
+func TestWhenNoCommittedEntriesAreKeptHotAndAllAreCommittedThenTheHeadIsReadFromDisk(t *testing.T) {
+
+	tiered := setupTieredJournal(t, t.TempDir(), 0)
+
+	appendTieredEntries(tiered, 3)
+	<-tiered.Commit(2)
+
+	head, err := tiered.GetHead()
+
+	if err != nil || string(head.Item) != "item 2" {
+		t.Errorf("Head should have been 'item 2' but was '%s' with error '%v'", head.Item, err)
+	}
+}
+
+func TestWhenHotBudgetExceededThenOldestCommittedEntriesAreMovedOutOfMemory(t *testing.T) {
+
+	config := NewDefaultTieredJournalConfig(t.TempDir())
+	config.HotCommittedBudgetBytes = 2 * len("item 0")
+
+	tiered := openTieredJournal(t, config)
+
+	appendTieredEntries(tiered, 5)
+	<-tiered.Commit(3)
+
+	_, _ = tiered.GetAllEntriesBetween(0, 4)
+
+	stats := tiered.CacheStats()
+
+	if stats.HotHits != 3 || stats.ColdHits != 2 {
+		t.Errorf("Expected 3 hot hits and 2 cold hits but got %+v", stats)
+	}
+}
+
+func TestWhenTieredJournalIsReopenedThenHeadAndCommitIndexAreRestored(t *testing.T) {
+
+	directory := t.TempDir()
+	tiered := setupTieredJournal(t, directory, 2)
+
+	appendTieredEntries(tiered, 6)
+	<-tiered.Commit(3)
+	_ = tiered.Close()
+
+	reopened := setupTieredJournal(t, directory, 2)
+
+	result := <-reopened.GetAllUncommittedEntries()
+
+	if result.HeadIndex != 5 || result.CommitIndex != 3 || result.UncommittedEntries.Size() != 2 {
+		t.Errorf("Expected head 5, commit 3 and 2 uncommitted entries but got %+v", result)
+	}
+}
+
+func TestWhenTieredJournalIndexCommittedThenOneTimeSubscribersAreNotified(t *testing.T) {
+
+	tiered := setupTieredJournal(t, t.TempDir(), 2)
+
+	appendTieredEntries(tiered, 3)
+
+	notifyCh := make(chan bool, 1)
+	_ = tiered.NotifyOfCommitOnIndexOnce(1, notifyCh)
+
+	tiered.Commit(2)
+
+	if !<-notifyCh {
+		t.Errorf("Subscriber should have been notified of the commit")
+	}
+}
+
+func TestWhenTieredJournalSubscriptionUsesAnUnbufferedChannelThenAnErrorIsReturned(t *testing.T) {
+
+	tiered := setupTieredJournal(t, t.TempDir(), 2)
+
+	appendTieredEntries(tiered, 3)
+
+	err := tiered.NotifyOfCommitOnIndexOnce(1, make(chan bool))
+
+	if ErrUnbufferedSubscription != err {
+		t.Errorf("Should have received error '%v' but instead got '%v'", ErrUnbufferedSubscription, err)
+	}
+}
+
+func TestWhenTieredJournalIndexCommittedThenAllCommitSubscribersAreNotified(t *testing.T) {
+
+	tiered := setupTieredJournal(t, t.TempDir(), 2)
+
+	appendTieredEntries(tiered, 3)
+
+	notifyCh := make(chan uint64)
+	tiered.NotifyOfAllCommitChanges(notifyCh)
+
+	tiered.Commit(2)
+
+	if index := <-notifyCh; index != 2 {
+		t.Errorf("Subscriber should have been notified of commit index 2 but got %d", index)
+	}
+}
+
+func TestWhenTieredJournalIndexAlreadyCommittedIsCommittedAgainThenAllCommitSubscribersAreNotNotified(t *testing.T) {
+
+	tiered := setupTieredJournal(t, t.TempDir(), 2)
+
+	appendTieredEntries(tiered, 3)
+
+	notifyCh := make(chan uint64)
+	tiered.NotifyOfAllCommitChanges(notifyCh)
+
+	<-tiered.Commit(2)
+	<-notifyCh
+
+	<-tiered.Commit(1)
+	<-tiered.Commit(2)
+
+	appendTieredEntries(tiered, 1)
+	<-tiered.Commit(3)
+
+	if index := <-notifyCh; index != 3 {
+		t.Errorf("Subscriber should only have been notified of the commit index advancing to 3 but got %d", index)
+	}
+}
+
+func TestWhenCommittingBeyondTheHeadOfTheTieredJournalThenAnErrorIsReturned(t *testing.T) {
+
+	tiered := setupTieredJournal(t, t.TempDir(), 2)
+
+	appendTieredEntries(tiered, 3)
+
+	result := <-tiered.Commit(3)
+
+	if ErrIndexBeyondHead != result.Error {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrIndexBeyondHead, result.Error)
+	}
+}
+
+func TestWhenGettingEntriesBeyondTheHeadOfTheTieredJournalThenAnErrorIsReturned(t *testing.T) {
+
+	tiered := setupTieredJournal(t, t.TempDir(), 2)
+
+	appendTieredEntries(tiered, 3)
+
+	_, err := tiered.GetAllEntriesBetween(1, 3)
+
+	if ErrIndexOutOfBounds != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrIndexOutOfBounds, err)
+	}
+}
+
+func TestWhenTieredJournalCollectorsRequestedThenACollectorIsReturnedForEachCounter(t *testing.T) {
+
+	tiered := setupTieredJournal(t, t.TempDir(), 2)
+
+	if len(tiered.Collectors()) != 7 {
+		t.Errorf("Expected 7 collectors but got %d", len(tiered.Collectors()))
+	}
+}
+
+func setupTieredJournal(t *testing.T, directory string, hotCommittedEntries int) *TieredJournal {
+
+	config := NewDefaultTieredJournalConfig(directory)
+	config.HotCommittedEntries = hotCommittedEntries
+	config.SegmentMaxEntries = 4
+
+	return openTieredJournal(t, config)
+}
+
+func openTieredJournal(t *testing.T, config *TieredJournalConfig) *TieredJournal {
+
+	tiered, err := NewTieredJournal(config)
+
+	if err != nil {
+		t.Fatalf("Unable to open tiered journal: %v", err)
+	}
+
+	return tiered
+}
+
+func appendTieredEntries(tiered *TieredJournal, count int) {
+
+	for i := 0; i < count; i++ {
+		<-tiered.Append([]byte(fmt.Sprintf("item %d", i)))
+	}
+}
+
+func TestWhenTieredJournalConfiguredWithCodecThenAppendedEntriesAreCompressed(t *testing.T) {
+
+	config := NewDefaultTieredJournalConfig(t.TempDir())
+	config.Codec = CodecGzip
+
+	tiered := openTieredJournal(t, config)
+	<-tiered.Append(compressibleItem)
+
+	if tiered.CompressionStats().Ratio() <= 1 {
+		t.Errorf("Expected a compression ratio above 1 but got stats %+v", tiered.CompressionStats())
+	}
+}
+
+func TestWhenTieredJournalConfiguredWithKeyringThenSegmentsAreEncrypted(t *testing.T) {
+
+	directory := t.TempDir()
+
+	config := NewDefaultTieredJournalConfig(directory)
+	config.Keyring = testKeyring(t, 1)
+
+	tiered := openTieredJournal(t, config)
+	<-tiered.Append([]byte("customer secret"))
+	_ = tiered.Close()
+
+	reopened := openTieredJournal(t, config)
+	head, err := reopened.GetHead()
+
+	if err != nil || string(head.Item) != "customer secret" || segmentFilesContain(directory, "customer secret") {
+		t.Errorf("Expected an encrypted journal that reads back, got '%s' and error '%v'", head.Item, err)
+	}
+}
@@ -1,14 +1,30 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/jrobison153/raft/bootstrap"
 	"github.com/jrobison153/raft/environment"
+	"github.com/jrobison153/raft/journal/inspect"
 )
 
-const defaultPort = 3434
+const (
+	defaultPort    = 3434
+	journalCommand = "journal"
+)
 
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == journalCommand {
+		runJournalCommand(os.Args[2:])
+	} else {
+		startServer()
+	}
+}
+
+func startServer() {
+
 	bootstrapper := bootstrap.New()
 
 	err := bootstrapper.Init()
@@ -22,3 +38,15 @@ func main() {
 		bootstrapper.Start(resolvedPort)
 	}
 }
+
+// runJournalCommand runs the offline journal inspection and repair tooling, e.g. `raft journal verify -dir <dir>`
+func runJournalCommand(args []string) {
+
+	err := inspect.Run(args, os.Stdout)
+
+	if err != nil {
+
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,30 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/jrobison153/raft/state"
+)
+
+// ErrNotSupported is an ErrInvalidCommand, the state machine does not keep what the operation needs
+var ErrNotSupported = fmt.Errorf("%w, the state machine does not support the operation", ErrInvalidCommand)
+
+// supports returns false for commands that need a capability the state machine does not have. Leases, namespaces,
+// secondary indexes and queues are only kept by state machines that implement the matching state interface, such a
+// command must not be committed to a state machine whose reads could never reflect it
+func (client *Client) supports(command state.Command) bool {
+
+	_, isLeaseKeeper := client.renderer.(state.LeaseKeeper)
+	_, isNamespaceKeeper := client.renderer.(state.NamespaceKeeper)
+	_, isIndexQuerier := client.renderer.(state.IndexQuerier)
+	_, isQueueKeeper := client.renderer.(state.QueueKeeper)
+
+	usesLeases := command.Lease != 0 || command.Op == state.OpGrantLease
+	usesIndexes := command.Op == state.OpCreateIndex || command.Op == state.OpDropIndex
+	usesQueues := command.Op >= state.OpEnqueue && command.Op <= state.OpNack
+
+	return (isLeaseKeeper || !usesLeases) &&
+		(isNamespaceKeeper || command.Namespace == "") &&
+		(isIndexQuerier || !usesIndexes) &&
+		(isQueueKeeper || !usesQueues)
+}
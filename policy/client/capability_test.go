@@ -0,0 +1,71 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/jrobison153/raft/state"
+)
+
+// renderer exposes only the state.Renderer methods of the renderer it wraps, none of the optional capabilities
+type renderer struct {
+	state.Renderer
+}
+
+func setupWithoutCapabilities() *TestContext {
+
+	testContext := setup()
+	testContext.client = New(testContext.journalSpy, renderer{testContext.stateMachineSpy})
+
+	return testContext
+}
+
+func TestWhenStateMachineKeepsNoLeasesThenALeasedPutIsRejectedBeforeItIsAppended(t *testing.T) {
+
+	testContext := setupWithoutCapabilities()
+
+	command := state.NewPutCommand("theKey", []byte("theValue"))
+	command.Lease = 1
+
+	_, err := testContext.client.Propose(command)
+
+	if ErrNotSupported != err || testContext.journalSpy.AppendCalled() {
+		t.Errorf("Should have received error '%v' without an append but got '%v'", ErrNotSupported, err)
+	}
+}
+
+func TestWhenStateMachineKeepsNoNamespacesThenANamespacedPutIsRejected(t *testing.T) {
+
+	testContext := setupWithoutCapabilities()
+
+	command := state.NewPutCommand("theKey", []byte("theValue"))
+	command.Namespace = "team-a"
+
+	_, err := testContext.client.Propose(command)
+
+	if ErrNotSupported != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrNotSupported, err)
+	}
+}
+
+func TestWhenStateMachineKeepsNoIndexesOrQueuesThenTheirReadsAreNotSupported(t *testing.T) {
+
+	testContext := setupWithoutCapabilities()
+
+	_, indexErr := testContext.client.QueryIndex("by-city", []byte(`"Paris"`), 0)
+	_, peekErr := testContext.client.Peek("jobs")
+
+	if ErrNotSupported != indexErr || ErrNotSupported != peekErr {
+		t.Errorf("Should have received error '%v' but got '%v' and '%v'", ErrNotSupported, indexErr, peekErr)
+	}
+}
+
+func TestWhenStateMachineKeepsNoCapabilitiesThenPlainPutsAreStillProposed(t *testing.T) {
+
+	testContext := setupWithoutCapabilities()
+
+	_, err := testContext.client.Propose(state.NewPutCommand("theKey", []byte("theValue")))
+
+	if err != nil || !testContext.journalSpy.AppendCalled() {
+		t.Errorf("Expected the put to be appended but got error '%v'", err)
+	}
+}
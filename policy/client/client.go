@@ -4,7 +4,9 @@
 package client
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/jrobison153/raft/journal"
 	"github.com/jrobison153/raft/state"
 	"reflect"
@@ -18,7 +20,22 @@ type Client struct {
 
 type Persister interface {
 	Put(item []byte) (chan bool, error)
+	Delete(item []byte) (chan bool, error)
+	Propose(command state.Command) (chan bool, error)
+	ProposeWithResult(command state.Command) (chan state.ApplyResult, error)
 	Get(item []byte) ([]byte, error)
+	GetVersioned(item []byte) (state.VersionedItem, error)
+	GetChunks(item []byte) (state.VersionedItem, [][]byte, error)
+	StagedUploads() []state.StagedUpload
+	Watch(request state.WatchRequest) (*state.Watcher, error)
+	Lease(id uint64) (state.Lease, bool)
+	Leases() []state.Lease
+	Namespace(name string) (state.Namespace, bool)
+	Namespaces() []state.Namespace
+	QueryIndex(name string, value []byte, limit int) ([]string, error)
+	Peek(queue string) (state.QueueMessage, error)
+	InFlight() []state.QueueMessage
+	Scan(request state.ScanRequest) ([]state.KeyValItem, error)
 	TypeOfLogger() string
 	TypeOfStateMachine() string
 }
@@ -26,8 +43,17 @@ type Persister interface {
 var (
 	ErrKeyDoesNotExist                 = errors.New("attempt to get item for a key that does not exist")
 	ErrEmptyKey                        = errors.New("attempt to put item with an empty key")
+	ErrInvalidDeleteRequest            = errors.New("delete request must be a Key with a non empty key")
+	ErrInvalidPutRequest               = errors.New("put request must be a KeyValItem")
+	ErrInvalidCommand                  = errors.New("command is not valid and cannot be applied")
+	ErrReservedKey                     = fmt.Errorf("%w, the key begins with a prefix reserved for the node", ErrInvalidCommand)
+	ErrRegisterForNotificationOnApply  = errors.New("failure to register for notification on apply of journal index")
+	ErrCommitFailed                    = errors.New("command was not committed, replication failed")
 	ErrAppendToJournalFailed           = errors.New("failure appending entry to journal")
 	ErrRegisterForNotificationOnCommit = errors.New("failure to register for notification on commit index")
+	ErrReadsUnavailable                = errors.New("reads are unavailable, the node has detected journal corruption")
+	ErrIndexCompacted                  = errors.New("history at the requested index has been compacted")
+	ErrIndexNotYetApplied              = errors.New("the requested index has not been applied yet")
 )
 
 // New Returns a newly initialized Client that will use journal for journaling
@@ -39,40 +65,228 @@ func New(journal journal.Journaler, renderer state.Renderer) *Client {
 	}
 }
 
-// Put stores item in the journal and replicates to followers in the raft cluster.
-// Put returns a bool channel that will signal when the replication has completed. The value true will
-// be written to the channel should replication succeed and item has been committed to the journal. False
-// will be written in the event replication and ultimately commit to the log fails.
+// Put stores item, a state.KeyValItem, in the journal and replicates to followers in the raft cluster.
+// Put returns a bool channel that will signal when the item has been applied. The value true will
+// be written to the channel should replication succeed, item has been committed to the journal and the state
+// machine has applied it, so a following Get sees the item. False will be written in the event replication and
+// ultimately commit to the log fails, or the state machine could not apply the item.
 // Put returns an error should there be any failure prior to attempting replication.
+// ErrInvalidPutRequest - item is not a state.KeyValItem
+// ErrEmptyKey - the item key is empty
 // ErrAppendToJournalFailed - failure to append the item to the journal
 func (client *Client) Put(item []byte) (chan bool, error) {
 
-	var putErr error
 	var doneCh chan bool
 
-	var index uint64
-	index, putErr = client.appendToJournal(item)
+	keyVal := state.KeyValItem{}
+	putErr := json.Unmarshal(item, &keyVal)
 
-	if putErr == nil {
-		doneCh, putErr = registerForNotificationOnCommitIndex(client.journal, index)
-	} else {
+	if putErr != nil {
+		putErr = ErrInvalidPutRequest
 		doneCh = unblockedChannel(false)
+	} else {
+		doneCh, putErr = client.Propose(state.NewPutCommand(keyVal.Key, keyVal.Data))
 	}
 
 	return doneCh, putErr
 }
 
+// Delete removes the key in item, a state.Key, by storing a delete command in the journal and replicating it to
+// followers exactly like Put. The returned channel signals true once the delete has been committed and applied and
+// false if replication or the apply fails. Deleting a key that does not exist is not an error, the delete is still journaled.
+// ErrInvalidDeleteRequest - item is not a state.Key or its key is empty
+// ErrAppendToJournalFailed - failure to append the delete to the journal
+func (client *Client) Delete(item []byte) (chan bool, error) {
+
+	var doneCh chan bool
+
+	key := state.Key{}
+	deleteErr := json.Unmarshal(item, &key)
+
+	if deleteErr != nil || key.Key == "" {
+		deleteErr = ErrInvalidDeleteRequest
+		doneCh = unblockedChannel(false)
+	} else {
+		doneCh, deleteErr = client.Propose(state.NewDeleteCommand(key.Key))
+	}
+
+	return doneCh, deleteErr
+}
+
+// Propose validates command and, if it is valid, appends its encoded envelope to the journal and replicates it
+// like Put. Validating before the append means a command the state machines cannot apply is never committed. The
+// returned channel signals true once the command has been committed and applied, false otherwise.
+// ErrEmptyKey - the command key is empty
+// ErrReservedKey - the command names a key reserved for the node
+// ErrInvalidCommand - the command fails any other validation
+// ErrNotSupported - the command needs a lease, namespace, index or queue the state machine does not keep
+// ErrNamespaceNotFound - the command stores its key in a namespace that has not been created
+// ErrQuotaExceeded - the command would take its namespace over a quota
+// ErrAppendToJournalFailed - failure to append the command to the journal
+// ErrRegisterForNotificationOnCommit - failure to register for the commit of the command
+// ErrRegisterForNotificationOnApply - failure to register for the apply result of the command
+func (client *Client) Propose(command state.Command) (chan bool, error) {
+
+	resultCh, err := client.ProposeWithResult(command)
+
+	var doneCh chan bool
+
+	if err == nil {
+
+		doneCh = make(chan bool, 1)
+
+		go func() {
+			result := <-resultCh
+			doneCh <- result.Err == nil
+		}()
+	} else {
+		doneCh = unblockedChannel(false)
+	}
+
+	return doneCh, err
+}
+
+// ProposeWithResult proposes command like Propose and waits for the state machine to apply it. The returned channel
+// receives the apply result, whether a conditional command's condition held and the resulting version of its key.
+// Err is set to ErrCommitFailed if replication fails, to the error the state machine hit applying the command, or
+// to the error returned by ProposeWithResult itself. Returns the same errors as Propose
+func (client *Client) ProposeWithResult(command state.Command) (chan state.ApplyResult, error) {
+
+	appliedCh := make(chan state.ApplyResult, 1)
+
+	index, committedCh, err := client.propose(command)
+
+	if err == nil && client.renderer.NotifyOfApplyOnIndexOnce(index, appliedCh) != nil {
+		err = ErrRegisterForNotificationOnApply
+	}
+
+	resultCh := make(chan state.ApplyResult, 1)
+
+	if err == nil {
+		go awaitApplyResult(index, committedCh, appliedCh, resultCh)
+	} else {
+		resultCh <- state.ApplyResult{Index: index, Err: err}
+	}
+
+	return resultCh, err
+}
+
 // Get returns the item per the request item. An error is returned if the request is unable to be satisfied
+// ErrReadsUnavailable - the state machine has raised its corruption alarm
+// ErrKeyDoesNotExist - any other failure to resolve the request
 func (client *Client) Get(item []byte) ([]byte, error) {
 
-	data, err := client.renderer.ResolveRequestToData(item)
+	versioned, err := client.GetVersioned(item)
+
+	return versioned.Value, err
+}
+
+// GetVersioned returns the value and metadata of the key in the request item, a state.Key. A Key with an AtIndex
+// reads the key as it was once the journal entry at that index had been applied.
+// An error is returned if the request is unable to be satisfied
+// ErrReadsUnavailable - the state machine has raised its corruption alarm
+// ErrIndexCompacted - the history at AtIndex has been compacted
+// ErrIndexNotYetApplied - the entry at AtIndex has not been applied on this node yet
+// ErrKeyDoesNotExist - any other failure to resolve the request
+func (client *Client) GetVersioned(item []byte) (state.VersionedItem, error) {
+
+	versioned, err := client.renderer.ResolveRequestToItem(item)
+
+	return versioned, getError(err)
+}
+
+// GetChunks returns the metadata of the key in the request item, a state.Key, as GetVersioned does and its value in
+// the chunks the large value manifest that stored it committed. A value not stored by a manifest, or read from a state
+// machine that does not keep chunks, is returned as one chunk. The item is returned without its value.
+// An error is returned under the same conditions as GetVersioned
+func (client *Client) GetChunks(item []byte) (state.VersionedItem, [][]byte, error) {
+
+	var versioned state.VersionedItem
+	var chunks [][]byte
+	var err error
+
+	if reader, isReader := client.renderer.(state.LargeValueReader); isReader {
+		versioned, chunks, err = reader.ResolveRequestToChunks(item)
+	} else {
+		versioned, err = client.renderer.ResolveRequestToItem(item)
+		chunks, versioned.Value = [][]byte{versioned.Value}, nil
+	}
+
+	return versioned, chunks, getError(err)
+}
+
+// StagedUploads returns the chunks staged for each key as of this node's state machine, a state machine that does not
+// stage chunks has none
+func (client *Client) StagedUploads() []state.StagedUpload {
+
+	var uploads []state.StagedUpload
+
+	if stager, isStager := client.renderer.(state.UploadStager); isStager {
+		uploads = stager.StagedUploads()
+	}
+
+	return uploads
+}
+
+// getError maps an error resolving a read to the error reported to the caller
+func getError(err error) error {
 
 	var getErr error
-	if err != nil {
+
+	if errors.Is(err, state.ErrCorruptionAlarm) {
+		getErr = ErrReadsUnavailable
+	} else if errors.Is(err, state.ErrCompacted) {
+		getErr = ErrIndexCompacted
+	} else if errors.Is(err, state.ErrFutureIndex) {
+		getErr = ErrIndexNotYetApplied
+	} else if err != nil {
 		getErr = ErrKeyDoesNotExist
 	}
 
-	return data, getErr
+	return getErr
+}
+
+// Watch returns a watcher streaming the changes request selects as the state machine applies them, see
+// state.Watcher for its backpressure and cancellation behavior. The caller must Cancel the watcher once done with it
+// ErrReadsUnavailable - the state machine has raised its corruption alarm
+// ErrIndexCompacted - the history at the request StartIndex has been compacted
+func (client *Client) Watch(request state.WatchRequest) (*state.Watcher, error) {
+
+	watcher, err := client.renderer.Watch(request)
+
+	if errors.Is(err, state.ErrCorruptionAlarm) {
+		err = ErrReadsUnavailable
+	} else if errors.Is(err, state.ErrCompacted) {
+		err = ErrIndexCompacted
+	}
+
+	return watcher, err
+}
+
+// Lease returns the granted lease with id as this node's state machine has applied it, a state machine that keeps no
+// leases never finds one
+func (client *Client) Lease(id uint64) (state.Lease, bool) {
+
+	var lease state.Lease
+	var exists bool
+
+	if keeper, isLeaseKeeper := client.renderer.(state.LeaseKeeper); isLeaseKeeper {
+		lease, exists = keeper.Lease(id)
+	}
+
+	return lease, exists
+}
+
+// Leases returns every lease granted as of this node's state machine
+func (client *Client) Leases() []state.Lease {
+
+	var leases []state.Lease
+
+	if keeper, isLeaseKeeper := client.renderer.(state.LeaseKeeper); isLeaseKeeper {
+		leases = keeper.Leases()
+	}
+
+	return leases
 }
 
 func (client *Client) TypeOfLogger() string {
@@ -85,10 +299,64 @@ func (client *Client) TypeOfStateMachine() string {
 	return reflect.TypeOf(client.renderer).String()
 }
 
+func (client *Client) propose(command state.Command) (uint64, chan bool, error) {
+
+	var doneCh chan bool
+	var index uint64
+
+	proposeErr := command.Validate()
+
+	if proposeErr == state.ErrEmptyCommandKey {
+		proposeErr = ErrEmptyKey
+	} else if proposeErr == state.ErrReservedKey {
+		proposeErr = ErrReservedKey
+	} else if proposeErr != nil {
+		proposeErr = fmt.Errorf("%w: %v", ErrInvalidCommand, proposeErr)
+	}
+
+	if proposeErr == nil && !client.supports(command) {
+		proposeErr = ErrNotSupported
+	}
+
+	if proposeErr == nil && command.Namespace != "" && !isNamespaceAdmin(command) {
+		proposeErr = client.checkQuota(command)
+	}
+
+	if proposeErr == nil {
+		index, proposeErr = client.appendToJournal(command.Encode())
+	}
+
+	if proposeErr == nil {
+		doneCh, proposeErr = registerForNotificationOnCommitIndex(client.journal, index)
+	} else {
+		doneCh = unblockedChannel(false)
+	}
+
+	return index, doneCh, proposeErr
+}
+
+// awaitApplyResult forwards the apply result of the entry at index to resultCh once it has committed and been
+// applied
+func awaitApplyResult(
+	index uint64,
+	committedCh chan bool,
+	appliedCh chan state.ApplyResult,
+	resultCh chan state.ApplyResult) {
+
+	result := state.ApplyResult{Index: index, Err: ErrCommitFailed}
+
+	if <-committedCh {
+		result = <-appliedCh
+	}
+
+	resultCh <- result
+}
+
+// unblockedChannel returns a channel already holding val, so a caller that never reads it leaks nothing
 func unblockedChannel(val bool) chan bool {
 
-	theCh := make(chan bool)
-	go func(v bool) { theCh <- v }(val)
+	theCh := make(chan bool, 1)
+	theCh <- val
 
 	return theCh
 }
@@ -110,7 +378,7 @@ func (client *Client) appendToJournal(item []byte) (uint64, error) {
 
 func registerForNotificationOnCommitIndex(journal journal.Journaler, index uint64) (chan bool, error) {
 
-	doneCh := make(chan bool)
+	doneCh := make(chan bool, 1)
 
 	notifyErr := journal.NotifyOfCommitOnIndexOnce(index, doneCh)
 
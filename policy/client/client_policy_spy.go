@@ -2,13 +2,36 @@ package client
 
 import (
 	"errors"
+
+	"github.com/jrobison153/raft/state"
 )
 
 type Spy struct {
 	lastPutItem          []byte
+	lastDeletedItem      []byte
+	lastProposedCommand  state.Command
+	nextApplyResult      state.ApplyResult
+	queuedApplyResults   []state.ApplyResult
+	isFailingDelete      bool
 	isFailingPut         bool
 	isFailingReplication bool
 	isFailingGet         bool
+	lastGetRequest       []byte
+	nextGetItem          state.VersionedItem
+	watchers             *state.WatchHub
+	isFailingWatch       bool
+	leases               []state.Lease
+	namespaces           []state.Namespace
+	nextProposeErr       error
+	proposedCommands     []state.Command
+	indexedKeys          map[string][]string
+	lastIndexQuery       []byte
+	nextChunks           [][]byte
+	stagedUploads        []state.StagedUpload
+	lastScan             state.ScanRequest
+	scannedItems         []state.KeyValItem
+	queuedMessages       []state.QueueMessage
+	inFlight             []state.QueueMessage
 }
 
 func NewSpy() *Spy {
@@ -16,6 +39,7 @@ func NewSpy() *Spy {
 	return &Spy{
 		isFailingPut:         false,
 		isFailingReplication: false,
+		watchers:             state.NewWatchHub(state.DefaultWatchBufferSize),
 	}
 }
 
@@ -51,18 +75,161 @@ func (spy *Spy) Put(item []byte) (chan bool, error) {
 	return doneCh, err
 }
 
+func (spy *Spy) Delete(item []byte) (chan bool, error) {
+
+	var err error
+	var replResult = true
+
+	if spy.isFailingDelete {
+		err = errors.New("failing Delete for test reasons")
+	} else if spy.isFailingReplication {
+		replResult = false
+	} else {
+		spy.lastDeletedItem = item
+	}
+
+	doneCh := make(chan bool)
+
+	go func(ch chan bool, val bool) { ch <- replResult }(doneCh, replResult)
+
+	return doneCh, err
+}
+
+func (spy *Spy) Propose(command state.Command) (chan bool, error) {
+
+	var err error
+	var replResult = true
+
+	if spy.nextProposeErr != nil {
+		err, spy.nextProposeErr = spy.nextProposeErr, nil
+		replResult = false
+	} else if spy.isFailingPut {
+		err = errors.New("failing Propose for test reasons")
+	} else if spy.isFailingReplication {
+		replResult = false
+	} else {
+		spy.lastProposedCommand = command
+	}
+
+	doneCh := make(chan bool)
+
+	go func(ch chan bool, val bool) { ch <- replResult }(doneCh, replResult)
+
+	return doneCh, err
+}
+
+func (spy *Spy) ProposeWithResult(command state.Command) (chan state.ApplyResult, error) {
+
+	var err error
+	result := spy.nextApplyResult
+
+	if len(spy.queuedApplyResults) > 0 {
+		result, spy.queuedApplyResults = spy.queuedApplyResults[0], spy.queuedApplyResults[1:]
+	}
+
+	if spy.nextProposeErr != nil {
+		err, spy.nextProposeErr = spy.nextProposeErr, nil
+		result.Err = err
+	} else if spy.isFailingPut {
+		err = errors.New("failing ProposeWithResult for test reasons")
+		result.Err = err
+	} else if spy.isFailingReplication {
+		result.Err = ErrCommitFailed
+	} else {
+		spy.lastProposedCommand = command
+		spy.proposedCommands = append(spy.proposedCommands, command)
+	}
+
+	resultCh := make(chan state.ApplyResult, 1)
+	resultCh <- result
+
+	return resultCh, err
+}
+
 func (spy *Spy) Get(item []byte) ([]byte, error) {
 
+	versioned, err := spy.GetVersioned(item)
+
+	return versioned.Value, err
+}
+
+func (spy *Spy) GetVersioned(item []byte) (state.VersionedItem, error) {
+
 	var err error
-	var data []byte
+	versioned := spy.nextGetItem
+
+	spy.lastGetRequest = item
 
 	if spy.isFailingGet {
 		err = errors.New("failing Get for test reasons")
-	} else {
-		data = spy.lastPutItem
+	} else if versioned.Value == nil {
+		versioned.Value = spy.lastPutItem
+	}
+
+	return versioned, err
+}
+
+// GetChunks returns the chunks set by GetChunksReturns, or else the value GetVersioned returns as one chunk
+func (spy *Spy) GetChunks(item []byte) (state.VersionedItem, [][]byte, error) {
+
+	versioned, err := spy.GetVersioned(item)
+
+	chunks := spy.nextChunks
+
+	if chunks == nil {
+		chunks = [][]byte{versioned.Value}
 	}
 
-	return data, err
+	versioned.Value = nil
+
+	return versioned, chunks, err
+}
+
+func (spy *Spy) StagedUploads() []state.StagedUpload {
+
+	return spy.stagedUploads
+}
+
+// StagedUploadsReturn sets the uploads returned by the following calls to StagedUploads
+func (spy *Spy) StagedUploadsReturn(uploads []state.StagedUpload) {
+
+	spy.stagedUploads = uploads
+}
+
+// GetChunksReturns sets the chunks returned by the following chunked gets
+func (spy *Spy) GetChunksReturns(chunks [][]byte) {
+
+	spy.nextChunks = chunks
+}
+
+// GetReturns sets the item returned by the following gets, a nil Value returns the last put item
+func (spy *Spy) GetReturns(item state.VersionedItem) {
+
+	spy.nextGetItem = item
+}
+
+func (spy *Spy) LastGetRequest() []byte {
+
+	return spy.lastGetRequest
+}
+
+func (spy *Spy) Scan(request state.ScanRequest) ([]state.KeyValItem, error) {
+
+	spy.lastScan = request
+
+	return spy.scannedItems, nil
+}
+
+// ScanReturns makes every scan return items
+func (spy *Spy) ScanReturns(items []state.KeyValItem) {
+
+	spy.scannedItems = items
+}
+
+// LastScan returns the request of the last scan
+func (spy *Spy) LastScan() state.ScanRequest {
+
+	return spy.lastScan
 }
 
 func (spy *Spy) LastPutItem() []byte {
@@ -70,6 +237,31 @@ func (spy *Spy) LastPutItem() []byte {
 	return spy.lastPutItem
 }
 
+func (spy *Spy) LastProposedCommand() state.Command {
+
+	return spy.lastProposedCommand
+}
+
+// ApplyWith sets the result the state machine reports for the next conditionally proposed command
+func (spy *Spy) ApplyWith(result state.ApplyResult) {
+	spy.nextApplyResult = result
+}
+
+// ApplyInTurn queues the results the state machine reports for the following proposals, one each, once they are used
+// up proposals report the result set with ApplyWith
+func (spy *Spy) ApplyInTurn(results ...state.ApplyResult) {
+	spy.queuedApplyResults = append(spy.queuedApplyResults, results...)
+}
+
+func (spy *Spy) LastDeletedItem() []byte {
+
+	return spy.lastDeletedItem
+}
+
+func (spy *Spy) FailNextDelete() {
+	spy.isFailingDelete = true
+}
+
 func (spy *Spy) FailNextPut() {
 	spy.isFailingPut = true
 }
@@ -81,3 +273,151 @@ func (spy *Spy) FailReplication() {
 func (spy *Spy) FailNextGet() {
 	spy.isFailingGet = true
 }
+
+func (spy *Spy) Watch(request state.WatchRequest) (*state.Watcher, error) {
+
+	var watcher *state.Watcher
+	var err error
+
+	if spy.isFailingWatch {
+		err = errors.New("failing Watch for test reasons")
+	} else {
+		watcher = spy.watchers.Watch(request)
+	}
+
+	return watcher, err
+}
+
+func (spy *Spy) FailNextWatch() {
+
+	spy.isFailingWatch = true
+}
+
+func (spy *Spy) PublishWatchEvents(index uint64, events []state.WatchEvent) {
+
+	spy.watchers.Publish(index, events)
+}
+
+func (spy *Spy) Lease(id uint64) (state.Lease, bool) {
+
+	var lease state.Lease
+	found := false
+
+	for _, granted := range spy.leases {
+
+		if granted.ID == id {
+			lease, found = granted, true
+		}
+	}
+
+	return lease, found
+}
+
+func (spy *Spy) Leases() []state.Lease {
+
+	return spy.leases
+}
+
+func (spy *Spy) AddLease(lease state.Lease) {
+
+	spy.leases = append(spy.leases, lease)
+}
+
+func (spy *Spy) Namespace(name string) (state.Namespace, bool) {
+
+	var namespace state.Namespace
+	found := false
+
+	for _, created := range spy.namespaces {
+
+		if created.Name == name {
+			namespace, found = created, true
+		}
+	}
+
+	return namespace, found
+}
+
+func (spy *Spy) Namespaces() []state.Namespace {
+
+	return spy.namespaces
+}
+
+func (spy *Spy) AddNamespace(namespace state.Namespace) {
+
+	spy.namespaces = append(spy.namespaces, namespace)
+}
+
+// FailNextProposeWith makes the next Propose or ProposeWithResult fail with err before anything is journaled
+func (spy *Spy) FailNextProposeWith(err error) {
+
+	spy.nextProposeErr = err
+}
+
+// ProposedCommands returns every command proposed with ProposeWithResult in the order they were proposed
+func (spy *Spy) ProposedCommands() []state.Command {
+
+	return spy.proposedCommands
+}
+
+func (spy *Spy) QueryIndex(name string, value []byte, limit int) ([]string, error) {
+
+	spy.lastIndexQuery = value
+
+	keys, exists := spy.indexedKeys[name]
+
+	var err error
+
+	if !exists {
+		err = ErrIndexNotFound
+	}
+
+	return keys, err
+}
+
+// IndexReturns makes queries of the index name return keys, queries of any other index fail with ErrIndexNotFound
+func (spy *Spy) IndexReturns(name string, keys []string) {
+
+	if spy.indexedKeys == nil {
+		spy.indexedKeys = make(map[string][]string)
+	}
+
+	spy.indexedKeys[name] = keys
+}
+
+func (spy *Spy) LastIndexQuery() []byte {
+
+	return spy.lastIndexQuery
+}
+
+func (spy *Spy) Peek(queue string) (state.QueueMessage, error) {
+
+	var message state.QueueMessage
+	err := ErrQueueEmpty
+
+	for position := len(spy.queuedMessages) - 1; position >= 0; position-- {
+
+		if spy.queuedMessages[position].Queue == queue {
+			message, err = spy.queuedMessages[position], nil
+		}
+	}
+
+	return message, err
+}
+
+func (spy *Spy) InFlight() []state.QueueMessage {
+
+	return spy.inFlight
+}
+
+// AddQueuedMessage makes message visible for Peek, messages of a queue are peeked in the order they were added
+func (spy *Spy) AddQueuedMessage(message state.QueueMessage) {
+
+	spy.queuedMessages = append(spy.queuedMessages, message)
+}
+
+// AddInFlightMessage makes InFlight return message
+func (spy *Spy) AddInFlightMessage(message state.QueueMessage) {
+
+	spy.inFlight = append(spy.inFlight, message)
+}
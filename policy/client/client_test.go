@@ -1,10 +1,12 @@
 package client
 
 import (
+	"errors"
 	"github.com/jrobison153/raft/journal"
 	"github.com/jrobison153/raft/state"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestWhenAnItemIsPutSuccessfullyThenThereAreNoErrors(t *testing.T) {
@@ -47,7 +49,8 @@ func TestWhenItemIsPutThenChannelReturnedUnblocksOnCommitSuccess(t *testing.T) {
 
 	doneCh, _ := testContext.client.Put(testContext.item)
 
-	go testContext.journalSpy.Commit(1)
+	go testContext.journalSpy.Commit(0)
+	testContext.stateMachineSpy.PublishApplyResult(state.ApplyResult{Index: 0, Applied: true, Version: 1})
 
 	isPutComplete := <-doneCh
 
@@ -84,6 +87,19 @@ func TestWhenLogAppendFailsThenReturnedChannelUnblocks(t *testing.T) {
 	}
 }
 
+func TestWhenLogAppendFailsThenReturnedChannelAlreadyHoldsTheResult(t *testing.T) {
+
+	testContext := setup()
+
+	testContext.journalSpy.FailNextAppend("Failing for test purposes")
+
+	doneCh, _ := testContext.client.Put(testContext.item)
+
+	if len(doneCh) != 1 {
+		t.Error("Channel should have held its result before Put returned so an unread channel leaks nothing")
+	}
+}
+
 func TestWhenItemIsPutThenSubscriptionSetupOnJournalIndex(t *testing.T) {
 
 	testContext := setup()
@@ -95,14 +111,14 @@ func TestWhenItemIsPutThenSubscriptionSetupOnJournalIndex(t *testing.T) {
 	}
 }
 
-func TestWhenItemIsPutThenSubscriptionSetupOnJournalIndexAssociatedWithReturnedChannel(t *testing.T) {
+func TestWhenItemIsPutThenSubscriptionSetupOnStateMachineForApplyOfJournalIndex(t *testing.T) {
 
 	testContext := setup()
 
-	doneCh, _ := testContext.client.Put(testContext.item)
+	testContext.client.Put(testContext.item)
 
-	if !testContext.journalSpy.RegisteredNotifyChannelOnIndex(0, doneCh) {
-		t.Errorf("Channel %v should have been registered for notification with journal index 0", doneCh)
+	if !testContext.stateMachineSpy.RegisteredForApplyOnIndex(0) {
+		t.Errorf("Subscriber for the apply result of journal index 0 should have been registered but was not")
 	}
 }
 
@@ -137,6 +153,120 @@ func TestWhenItemIsPutAndSubscriptionForCommitOnIndexFailsThenTheCorrectErrorIsR
 	}
 }
 
+func TestWhenAnItemIsPutThenAPutCommandEnvelopeIsAppendedToTheJournal(t *testing.T) {
+
+	testContext := setup()
+
+	testContext.client.Put(testContext.item)
+
+	command, err := state.DecodeCommand(testContext.journalSpy.AppendData())
+
+	if err != nil || command.Op != state.OpPut || command.Key != "theKey" || string(command.Value) != "i am some item" {
+		t.Errorf("Expected a put command for key 'theKey' to be appended but got %+v and error '%v'", command, err)
+	}
+}
+
+func TestWhenPutItemIsNotAKeyValItemThenItIsRejectedBeforeAppend(t *testing.T) {
+
+	testContext := setup()
+
+	doneCh, err := testContext.client.Put([]byte("i am not a key value item"))
+
+	if ErrInvalidPutRequest != err || <-doneCh || testContext.journalSpy.AppendCalled() {
+		t.Errorf("Expected error '%v' and nothing appended but got '%v'", ErrInvalidPutRequest, err)
+	}
+}
+
+func TestWhenPutItemHasAnEmptyKeyThenItIsRejectedBeforeAppend(t *testing.T) {
+
+	testContext := setup()
+
+	_, err := testContext.client.Put([]byte(`{"Key":"","Data":"AA=="}`))
+
+	if ErrEmptyKey != err || testContext.journalSpy.AppendCalled() {
+		t.Errorf("Expected error '%v' and nothing appended but got '%v'", ErrEmptyKey, err)
+	}
+}
+
+func TestWhenProposedCommandHasAnUnknownOperationThenItIsRejectedBeforeAppend(t *testing.T) {
+
+	testContext := setup()
+
+	command := state.NewPutCommand("theKey", nil)
+	command.Op = state.CommandOp(99)
+
+	_, err := testContext.client.Propose(command)
+
+	if !errors.Is(err, ErrInvalidCommand) || testContext.journalSpy.AppendCalled() {
+		t.Errorf("Expected error '%v' and nothing appended but got '%v'", ErrInvalidCommand, err)
+	}
+}
+
+func TestWhenProposedCommandNamesAReservedKeyThenItIsRejectedBeforeAppend(t *testing.T) {
+
+	testContext := setup()
+
+	_, err := testContext.client.Propose(state.NewPutCommand(state.NamespacedKey("team-a", "theKey"), nil))
+
+	if ErrReservedKey != err || !errors.Is(err, ErrInvalidCommand) || testContext.journalSpy.AppendCalled() {
+		t.Errorf("Expected error '%v' and nothing appended but got '%v'", ErrReservedKey, err)
+	}
+}
+
+func TestWhenAKeyIsDeletedThenADeleteCommandIsAppendedToTheJournal(t *testing.T) {
+
+	testContext := setup()
+
+	testContext.client.Delete([]byte(`{"Key":"theKey"}`))
+
+	command, _ := state.DecodeCommand(testContext.journalSpy.AppendData())
+
+	if command.Op != state.OpDelete || command.Key != "theKey" {
+		t.Errorf("Expected a delete command for key 'theKey' to be appended but got %+v", command)
+	}
+}
+
+func TestWhenAKeyIsDeletedThenChannelReturnedUnblocksOnCommitSuccess(t *testing.T) {
+
+	testContext := setup()
+
+	doneCh, _ := testContext.client.Delete([]byte(`{"Key":"theKey"}`))
+
+	go testContext.journalSpy.Commit(0)
+	testContext.stateMachineSpy.PublishApplyResult(state.ApplyResult{Index: 0, Applied: true})
+
+	if !<-doneCh {
+		t.Error("Expected delete commit to complete successfully")
+	}
+}
+
+func TestWhenDeletingAnEmptyKeyThenTheRequestIsRejected(t *testing.T) {
+
+	testContext := setup()
+
+	doneCh, err := testContext.client.Delete([]byte(`{"Key":""}`))
+
+	if ErrInvalidDeleteRequest != err || <-doneCh {
+		t.Errorf("Expected error '%v' and an unsuccessful delete but got '%v'", ErrInvalidDeleteRequest, err)
+	}
+
+	if testContext.journalSpy.AppendCalled() {
+		t.Error("Invalid delete should not have been appended to the journal")
+	}
+}
+
+func TestWhenDeleteAppendFailsThenErrorIsReturned(t *testing.T) {
+
+	testContext := setup()
+	testContext.journalSpy.FailNextAppend("failing for test purposes")
+
+	_, err := testContext.client.Delete([]byte(`{"Key":"theKey"}`))
+
+	if ErrAppendToJournalFailed != err {
+		t.Errorf("Expected error '%v' but got '%v'", ErrAppendToJournalFailed, err)
+	}
+}
+
 func TestWhenGettingDataForValidRequestThenTheDataIsReturned(t *testing.T) {
 
 	testContext := setup()
@@ -165,6 +295,33 @@ func TestWhenGettingDataForValidRequestThenNoErrorIsReturned(t *testing.T) {
 	}
 }
 
+func TestWhenGettingDataAndStateMachineCorruptionAlarmIsRaisedThenReadsAreUnavailable(t *testing.T) {
+
+	testContext := setup()
+
+	testContext.stateMachineSpy.AddStateMachineData(testContext.item)
+	testContext.stateMachineSpy.RaiseCorruptionAlarm()
+
+	_, err := testContext.client.Get(testContext.item)
+
+	if ErrReadsUnavailable != err {
+
+		t.Errorf("Should have received error '%v' but got '%v'", ErrReadsUnavailable, err)
+	}
+}
+
+func TestWhenGettingDataThatDoesNotExistThenKeyDoesNotExistErrorIsReturned(t *testing.T) {
+
+	testContext := setup()
+
+	_, err := testContext.client.Get(testContext.item)
+
+	if ErrKeyDoesNotExist != err {
+
+		t.Errorf("Should have received error '%v' but got '%v'", ErrKeyDoesNotExist, err)
+	}
+}
+
 // ============================= Test Support ==================
 
 type TestContext struct {
@@ -185,8 +342,116 @@ func setup() *TestContext {
 		client:          client,
 		journalSpy:      journalSpy,
 		stateMachineSpy: stateMachineSpy,
-		item:            []byte("i am some item"),
+		item:            []byte(`{"Key":"theKey","Data":"aSBhbSBzb21lIGl0ZW0="}`),
 	}
 
 	return testContext
 }
+
+func TestWhenConditionalCommandIsAppliedThenTheApplyResultIsReturned(t *testing.T) {
+
+	testContext := setup()
+
+	resultCh, err := testContext.client.ProposeWithResult(state.NewPutIfAbsentCommand("theKey", []byte("theValue")))
+
+	go testContext.journalSpy.Commit(0)
+	testContext.stateMachineSpy.PublishApplyResult(state.ApplyResult{Index: 0, Applied: false, Version: 3})
+
+	result := <-resultCh
+
+	if err != nil || result.Applied || result.Version != 3 {
+		t.Errorf("Expected the condition failed result but got %+v and error '%v'", result, err)
+	}
+}
+
+func TestWhenConditionalCommandAppendFailsThenTheResultCarriesTheError(t *testing.T) {
+
+	testContext := setup()
+	testContext.journalSpy.FailNextAppend("failing for test purposes")
+
+	resultCh, err := testContext.client.ProposeWithResult(state.NewPutIfAbsentCommand("theKey", nil))
+
+	if result := <-resultCh; ErrAppendToJournalFailed != err || ErrAppendToJournalFailed != result.Err {
+		t.Errorf("Expected error '%v' but got '%v' and result %+v", ErrAppendToJournalFailed, err, result)
+	}
+}
+
+func TestWhenRegisteringForTheApplyResultFailsThenTheCorrectErrorIsReturned(t *testing.T) {
+
+	testContext := setup()
+	testContext.stateMachineSpy.FailNextNotifyOfApplyOnIndexOnce()
+
+	doneCh, err := testContext.client.Put(testContext.item)
+
+	if ErrRegisterForNotificationOnApply != err || <-doneCh {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrRegisterForNotificationOnApply, err)
+	}
+}
+
+func TestWhenItemIsPutAndCommittedButNotYetAppliedThenTheReturnedChannelIsStillBlocked(t *testing.T) {
+
+	testContext := setup()
+
+	doneCh, _ := testContext.client.Put(testContext.item)
+
+	<-testContext.journalSpy.Commit(0)
+
+	select {
+	case <-doneCh:
+		t.Error("Put should not complete before the state machine has applied the item")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	testContext.stateMachineSpy.PublishApplyResult(state.ApplyResult{Index: 0, Applied: true, Version: 1})
+
+	if !<-doneCh {
+		t.Error("Put should have completed successfully once the item was applied")
+	}
+}
+
+func TestWhenStateMachineFailsToApplyTheItemThenTheReturnedChannelSignalsFailure(t *testing.T) {
+
+	testContext := setup()
+
+	doneCh, _ := testContext.client.Put(testContext.item)
+
+	go testContext.journalSpy.Commit(0)
+	testContext.stateMachineSpy.PublishApplyResult(state.ApplyResult{Index: 0, Err: state.ErrUnknownCommandOp})
+
+	if <-doneCh {
+		t.Error("Put should have failed because the state machine could not apply the item")
+	}
+}
+
+func TestWhenPutCompletesThenAnImmediateGetSeesTheItem(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	stateMachine := state.NewMapStateMachine(journalSpy)
+	_ = stateMachine.Start()
+
+	client := New(journalSpy, stateMachine)
+
+	doneCh, _ := client.Put([]byte(`{"Key":"theKey","Data":"dGhlVmFsdWU="}`))
+
+	go journalSpy.Commit(0)
+
+	isPutComplete := <-doneCh
+	value, err := client.Get([]byte(`{"Key":"theKey"}`))
+
+	if !isPutComplete || err != nil || string(value) != "theValue" {
+		t.Errorf("Expected 'theValue' to be readable once put completed but got '%s' and error '%v'", value, err)
+	}
+}
+
+func TestWhenGettingAtAnIndexNotYetAppliedThenIndexNotYetAppliedErrorIsReturned(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	stateMachine := state.NewMapStateMachine(journalSpy)
+	_ = stateMachine.Start()
+
+	_, err := New(journalSpy, stateMachine).GetVersioned([]byte(`{"Key":"theKey","AtIndex":3}`))
+
+	if ErrIndexNotYetApplied != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrIndexNotYetApplied, err)
+	}
+}
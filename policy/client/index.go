@@ -0,0 +1,38 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/jrobison153/raft/state"
+)
+
+var (
+	ErrIndexNotFound     = errors.New("secondary index does not exist, it has not been created or has been dropped")
+	ErrInvalidIndexValue = errors.New("index query value must be a JSON string, number or boolean")
+)
+
+// QueryIndex returns, in key order, up to limit keys whose JSON document holds value, a JSON scalar, at the path of
+// the secondary index name as of this node's state machine, 0 for no limit
+// ErrReadsUnavailable - the state machine has raised its corruption alarm
+// ErrIndexNotFound - the index has not been created or has been dropped
+// ErrInvalidIndexValue - value is not a JSON string, number or boolean
+// ErrNotSupported - the state machine keeps no secondary indexes
+func (client *Client) QueryIndex(name string, value []byte, limit int) ([]string, error) {
+
+	var keys []string
+	err := ErrNotSupported
+
+	if querier, isIndexQuerier := client.renderer.(state.IndexQuerier); isIndexQuerier {
+		keys, err = querier.QueryIndex(name, value, limit)
+	}
+
+	if errors.Is(err, state.ErrCorruptionAlarm) {
+		err = ErrReadsUnavailable
+	} else if errors.Is(err, state.ErrIndexNotFound) {
+		err = ErrIndexNotFound
+	} else if errors.Is(err, state.ErrInvalidIndexValue) {
+		err = ErrInvalidIndexValue
+	}
+
+	return keys, err
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jrobison153/raft/state"
+)
+
+func TestWhenIndexIsQueriedThenTheKeysOfTheStateMachineAreReturned(t *testing.T) {
+
+	testContext := setup()
+	testContext.stateMachineSpy.IndexReturns("by-city", []string{"user/1", "user/2"})
+
+	keys, err := testContext.client.QueryIndex("by-city", []byte(`"Paris"`), 0)
+
+	if err != nil || !reflect.DeepEqual([]string{"user/1", "user/2"}, keys) {
+		t.Errorf("Expected keys [user/1 user/2] but got %v and error '%v'", keys, err)
+	}
+}
+
+func TestWhenQueriedIndexDoesNotExistThenIndexNotFoundIsReturned(t *testing.T) {
+
+	testContext := setup()
+
+	_, err := testContext.client.QueryIndex("by-city", []byte(`"Paris"`), 0)
+
+	if ErrIndexNotFound != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrIndexNotFound, err)
+	}
+}
+
+func TestWhenNamespaceIsFullThenIndexesCanStillBeCreatedOnIt(t *testing.T) {
+
+	testContext := setup()
+	testContext.stateMachineSpy.AddNamespace(state.Namespace{Name: "team-a", MaxKeys: 1, Keys: 1})
+
+	definition := state.IndexDefinition{Name: "by-owner", Namespace: "team-a", Prefix: "job/", Path: "owner"}
+
+	_, err := testContext.client.Propose(state.NewCreateIndexCommand(definition))
+
+	if err != nil || !testContext.journalSpy.AppendCalled() {
+		t.Errorf("Index create should have been appended but got error '%v'", err)
+	}
+}
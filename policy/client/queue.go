@@ -0,0 +1,44 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/jrobison153/raft/state"
+)
+
+var ErrQueueEmpty = errors.New("queue has no message visible to consumers")
+
+// Peek returns the message the next dequeue of queue would deliver as of this node's state machine, without
+// delivering it
+// ErrReadsUnavailable - the state machine has raised its corruption alarm
+// ErrQueueEmpty - the queue has no visible message
+// ErrNotSupported - the state machine keeps no queues
+func (client *Client) Peek(queue string) (state.QueueMessage, error) {
+
+	var message state.QueueMessage
+	err := ErrNotSupported
+
+	if keeper, isQueueKeeper := client.renderer.(state.QueueKeeper); isQueueKeeper {
+		message, err = keeper.Peek(queue)
+	}
+
+	if errors.Is(err, state.ErrCorruptionAlarm) {
+		err = ErrReadsUnavailable
+	} else if errors.Is(err, state.ErrQueueEmpty) {
+		err = ErrQueueEmpty
+	}
+
+	return message, err
+}
+
+// InFlight returns every delivered message not yet acknowledged or returned as of this node's state machine
+func (client *Client) InFlight() []state.QueueMessage {
+
+	var messages []state.QueueMessage
+
+	if keeper, isQueueKeeper := client.renderer.(state.QueueKeeper); isQueueKeeper {
+		messages = keeper.InFlight()
+	}
+
+	return messages
+}
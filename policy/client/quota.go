@@ -0,0 +1,77 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/jrobison153/raft/state"
+)
+
+var (
+	ErrNamespaceNotFound = errors.New("namespace does not exist, it must be created before keys are stored in it")
+	ErrQuotaExceeded     = errors.New("write would exceed the key count or byte quota of its namespace")
+)
+
+// Namespace returns the created namespace name and its usage as of this node's state machine, a state machine that
+// keeps no namespaces never finds one
+func (client *Client) Namespace(name string) (state.Namespace, bool) {
+
+	var namespace state.Namespace
+	var exists bool
+
+	if keeper, isNamespaceKeeper := client.renderer.(state.NamespaceKeeper); isNamespaceKeeper {
+		namespace, exists = keeper.Namespace(name)
+	}
+
+	return namespace, exists
+}
+
+// Namespaces returns every created namespace as of this node's state machine
+func (client *Client) Namespaces() []state.Namespace {
+
+	var namespaces []state.Namespace
+
+	if keeper, isNamespaceKeeper := client.renderer.(state.NamespaceKeeper); isNamespaceKeeper {
+		namespaces = keeper.Namespaces()
+	}
+
+	return namespaces
+}
+
+// checkQuota checks that the namespace of command exists and that, should command write its key, the namespace
+// would stay within its quotas. The check reads the usage this node's state machine has applied and only rejects
+// early, writes proposed concurrently to the same namespace are checked again by the state machine when applied.
+// Returns ErrNamespaceNotFound if the namespace has not been created
+// Returns ErrQuotaExceeded if the write would take the namespace over its key count or byte quota
+func (client *Client) checkQuota(command state.Command) error {
+
+	var err error
+
+	namespace, exists := client.Namespace(command.Namespace)
+
+	if !exists {
+		err = ErrNamespaceNotFound
+	} else if namespace.QuotaExceededBy(command, client.currentValueIn(command.Namespace)) {
+		err = ErrQuotaExceeded
+	}
+
+	return err
+}
+
+// currentValueIn returns the lookup of the value a key of namespace holds as of this node's state machine
+func (client *Client) currentValueIn(namespace string) func(key string) ([]byte, bool) {
+
+	return func(key string) ([]byte, bool) {
+
+		request, _ := json.Marshal(state.Key{Key: key, Namespace: namespace})
+
+		current, err := client.renderer.ResolveRequestToItem(request)
+
+		return current.Value, err == nil
+	}
+}
+
+func isNamespaceAdmin(command state.Command) bool {
+
+	return command.Op == state.OpCreateNamespace || command.Op == state.OpDeleteNamespace
+}
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/jrobison153/raft/journal"
+	"github.com/jrobison153/raft/state"
+)
+
+func TestWhenCommandNamesANamespaceThatDoesNotExistThenItIsRejectedBeforeAppend(t *testing.T) {
+
+	testContext := setup()
+
+	_, err := testContext.client.Propose(putInNamespace("team-a", "theKey", "theValue"))
+
+	if ErrNamespaceNotFound != err || testContext.journalSpy.AppendCalled() {
+		t.Errorf("Should have received error '%v' before append but got '%v'", ErrNamespaceNotFound, err)
+	}
+}
+
+func TestWhenPutWouldExceedTheKeyQuotaThenItIsRejectedBeforeAppend(t *testing.T) {
+
+	testContext := setup()
+	testContext.stateMachineSpy.AddNamespace(state.Namespace{Name: "team-a", MaxKeys: 2, Keys: 2})
+
+	_, err := testContext.client.Propose(putInNamespace("team-a", "theKey", "theValue"))
+
+	if ErrQuotaExceeded != err || testContext.journalSpy.AppendCalled() {
+		t.Errorf("Should have received error '%v' before append but got '%v'", ErrQuotaExceeded, err)
+	}
+}
+
+func TestWhenPutWouldExceedTheByteQuotaThenItIsRejectedBeforeAppend(t *testing.T) {
+
+	testContext := setup()
+	testContext.stateMachineSpy.AddNamespace(state.Namespace{Name: "team-a", MaxBytes: 10, Bytes: 2})
+
+	_, err := testContext.client.Propose(putInNamespace("team-a", "theKey", "theValue"))
+
+	if ErrQuotaExceeded != err || testContext.journalSpy.AppendCalled() {
+		t.Errorf("Should have received error '%v' before append but got '%v'", ErrQuotaExceeded, err)
+	}
+}
+
+func TestWhenPutIsWithinTheNamespaceQuotasThenItIsAppended(t *testing.T) {
+
+	testContext := setup()
+	testContext.stateMachineSpy.AddNamespace(state.Namespace{Name: "team-a", MaxKeys: 3, MaxBytes: 100, Keys: 2})
+
+	_, err := testContext.client.Propose(putInNamespace("team-a", "theKey", "theValue"))
+
+	if err != nil || !testContext.journalSpy.AppendCalled() {
+		t.Errorf("Put within quota should have been appended but got error '%v'", err)
+	}
+}
+
+func TestWhenNamespaceIsFullThenKeysCanStillBeDeleted(t *testing.T) {
+
+	testContext := setup()
+	testContext.stateMachineSpy.AddNamespace(state.Namespace{Name: "team-a", MaxKeys: 1, Keys: 1})
+
+	command := state.NewDeleteCommand("theKey")
+	command.Namespace = "team-a"
+
+	_, err := testContext.client.Propose(command)
+
+	if err != nil || !testContext.journalSpy.AppendCalled() {
+		t.Errorf("Delete from a full namespace should have been appended but got error '%v'", err)
+	}
+}
+
+func TestWhenKeyIsOverwrittenInAFullNamespaceThenOnlyTheChangeInSizeCounts(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	stateMachine := state.NewMapStateMachine(journalSpy)
+	_ = stateMachine.Start()
+
+	client := New(journalSpy, stateMachine)
+
+	proposeAndCommit(client, journalSpy, 0, state.NewCreateNamespaceCommand("team-a", 1, 16))
+	proposeAndCommit(client, journalSpy, 1, putInNamespace("team-a", "theKey", "theValue"))
+
+	result := proposeAndCommit(client, journalSpy, 2, putInNamespace("team-a", "theKey", "newValue"))
+
+	if result.Err != nil || !result.Applied {
+		t.Errorf("Overwriting a key of a full namespace should have been applied but got %+v", result)
+	}
+}
+
+// proposeAndCommit proposes command, commits it at index and returns its apply result
+func proposeAndCommit(client *Client, journalSpy *journal.Spy, index uint64, command state.Command) state.ApplyResult {
+
+	resultCh, _ := client.ProposeWithResult(command)
+
+	go func() { <-journalSpy.Commit(index) }()
+
+	return <-resultCh
+}
+
+func putInNamespace(namespace string, key string, value string) state.Command {
+
+	command := state.NewPutCommand(key, []byte(value))
+	command.Namespace = namespace
+
+	return command
+}
+
+func TestWhenChunkWouldExceedTheByteQuotaThenItIsRejectedBeforeAppend(t *testing.T) {
+
+	testContext := setup()
+	testContext.stateMachineSpy.AddNamespace(state.Namespace{Name: "team-a", MaxBytes: 100, Bytes: 80})
+
+	chunk := state.NewPutChunkCommand("blob", make([]byte, 80))
+	chunk.Namespace = "team-a"
+
+	_, err := testContext.client.Propose(chunk)
+
+	if ErrQuotaExceeded != err || testContext.journalSpy.AppendCalled() {
+		t.Errorf("Should have received error '%v' before append but got '%v'", ErrQuotaExceeded, err)
+	}
+}
+
+func TestWhenManifestCommitsChunksAlreadyWithinTheByteQuotaThenItIsAppended(t *testing.T) {
+
+	testContext := setup()
+	testContext.stateMachineSpy.AddNamespace(state.Namespace{Name: "team-a", MaxBytes: 170, Bytes: 160})
+
+	manifest := state.NewPutLargeCommand("blob", []uint64{1, 2}, 160)
+	manifest.Namespace = "team-a"
+
+	_, err := testContext.client.Propose(manifest)
+
+	if err != nil || !testContext.journalSpy.AppendCalled() {
+		t.Errorf("Manifest of staged chunks should have been appended but got error '%v'", err)
+	}
+}
+
+func TestWhenEitherBranchOfATxnWouldExceedTheKeyQuotaThenItIsRejectedBeforeAppend(t *testing.T) {
+
+	testContext := setup()
+	testContext.stateMachineSpy.AddNamespace(state.Namespace{Name: "team-a", MaxKeys: 2, Keys: 1})
+
+	command := state.NewTxnCommand(state.Txn{
+		Success: []state.TxnOp{{Op: state.OpPut, Key: "a", Value: []byte("1")}},
+		Failure: []state.TxnOp{
+			{Op: state.OpPut, Key: "b", Value: []byte("1")},
+			{Op: state.OpPut, Key: "c", Value: []byte("1")},
+		},
+	})
+	command.Namespace = "team-a"
+
+	_, err := testContext.client.Propose(command)
+
+	if ErrQuotaExceeded != err || testContext.journalSpy.AppendCalled() {
+		t.Errorf("Should have received error '%v' before append but got '%v'", ErrQuotaExceeded, err)
+	}
+}
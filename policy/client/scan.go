@@ -0,0 +1,31 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/jrobison153/raft/state"
+)
+
+var ErrInvalidScanRequest = errors.New("scan range must not end before it starts, its namespace must not contain '/'")
+
+// Scan returns the items selected by request as of this node's state machine, reserved keys are never returned
+// ErrReadsUnavailable - the state machine has raised its corruption alarm
+// ErrInvalidScanRequest - the range ends before it starts or the namespace is not valid
+// ErrNotSupported - the state machine does not keep its keys ordered
+func (client *Client) Scan(request state.ScanRequest) ([]state.KeyValItem, error) {
+
+	var items []state.KeyValItem
+	err := ErrNotSupported
+
+	if scanner, isScanner := client.renderer.(state.Scanner); isScanner {
+		items, err = scanner.Scan(request)
+	}
+
+	if errors.Is(err, state.ErrCorruptionAlarm) {
+		err = ErrReadsUnavailable
+	} else if errors.Is(err, state.ErrInvalidScanRange) || errors.Is(err, state.ErrInvalidNamespace) {
+		err = ErrInvalidScanRequest
+	}
+
+	return items, err
+}
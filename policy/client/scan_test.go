@@ -0,0 +1,31 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/jrobison153/raft/journal"
+	"github.com/jrobison153/raft/state"
+)
+
+func TestWhenStateMachineDoesNotKeepItsKeysOrderedThenScanIsNotSupported(t *testing.T) {
+
+	testContext := setup()
+
+	_, err := testContext.client.Scan(state.ScanRequest{})
+
+	if ErrNotSupported != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrNotSupported, err)
+	}
+}
+
+func TestWhenScanRangeEndsBeforeItStartsThenTheScanRequestIsInvalid(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	client := New(journalSpy, state.NewBTreeStateMachine(journalSpy))
+
+	_, err := client.Scan(state.ScanRequest{Start: "b", End: "a"})
+
+	if ErrInvalidScanRequest != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrInvalidScanRequest, err)
+	}
+}
@@ -0,0 +1,80 @@
+package coordination
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+const ElectionKeyPrefix = state.ElectionKeyPrefix
+
+// Leader is the current leader of an election. Value is what the leader proclaimed when it campaigned and Token the
+// journal index at which it was elected. HasLeader is false while the election has no leader
+type Leader struct {
+	Value     []byte
+	Token     uint64
+	HasLeader bool
+}
+
+// Election elects a single leader among the candidates campaigning under a name. The leader holds the election key
+// exactly like a lock, with its proclaimed value as the key value, until it resigns or its lease expires
+type Election struct {
+	locker *Locker
+}
+
+func NewElection(locker *Locker) *Election {
+
+	return &Election{
+		locker: locker,
+	}
+}
+
+// Campaign blocks until the candidate is elected leader of the election called name, proclaiming value, or ctx is
+// done. The candidate stays leader while the returned lease is kept alive.
+// Returns the error of ctx if it is done before the candidate is elected
+func (election *Election) Campaign(ctx context.Context, name string, value []byte, ttlSeconds int64) (Lock, error) {
+
+	leadership, err := election.locker.acquire(ctx, ElectionKeyPrefix+name, value, ttlSeconds)
+	leadership.Name = name
+
+	return leadership, err
+}
+
+// Resign gives up leadership of the election called name held with lease.
+// Returns ErrLockNotHeld if the candidate holding lease is not the leader
+func (election *Election) Resign(name string, lease uint64) error {
+
+	return election.locker.release(ElectionKeyPrefix+name, lease)
+}
+
+// Leader returns the current leader of the election called name
+func (election *Election) Leader(name string) (Leader, error) {
+
+	request, _ := json.Marshal(state.Key{Key: ElectionKeyPrefix + name})
+
+	current, err := election.locker.persister.GetVersioned(request)
+
+	leader := Leader{Value: current.Value, Token: current.CreateIndex, HasLeader: err == nil}
+
+	if errors.Is(err, client.ErrKeyDoesNotExist) {
+		err = nil
+	}
+
+	return leader, err
+}
+
+// Observe returns a watcher on the election called name, see LeaderOf for the leader each event reports. Watch
+// first and then read the current Leader so no change is missed
+func (election *Election) Observe(name string) (*state.Watcher, error) {
+
+	return election.locker.persister.Watch(state.WatchRequest{Key: ElectionKeyPrefix + name})
+}
+
+// LeaderOf returns the leader after the election change reported by event
+func LeaderOf(event state.WatchEvent) Leader {
+
+	return Leader{Value: event.Value, Token: event.CreateIndex, HasLeader: event.Type == state.EventPut}
+}
@@ -0,0 +1,33 @@
+package coordination
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jrobison153/raft/state"
+)
+
+func TestWhenCandidateCampaignsThenItsValueIsProclaimedUnderTheElectionKey(t *testing.T) {
+
+	spy := setupGrantAt(4)
+	spy.ApplyInTurn(state.ApplyResult{Index: 7, Applied: true})
+
+	leadership, err := NewElection(setupLocker(spy)).Campaign(context.Background(), "primary", []byte("node-a"), 10)
+
+	elected := spy.LastProposedCommand()
+
+	if err != nil || leadership.Token != 7 || elected.Key != ElectionKeyPrefix+"primary" ||
+		string(elected.Value) != "node-a" {
+
+		t.Errorf("Unexpected leadership %+v, error '%v' and proposal %+v", leadership, err, elected)
+	}
+}
+
+func TestWhenLeaderResignsThenTheElectionReportsNoLeader(t *testing.T) {
+
+	leader := LeaderOf(state.WatchEvent{Type: state.EventDelete, Key: ElectionKeyPrefix + "primary", Index: 9})
+
+	if leader.HasLeader {
+		t.Errorf("Election should have no leader after a delete but got %+v", leader)
+	}
+}
@@ -0,0 +1,170 @@
+// Package coordination provides distributed locks and leader election built from the key value state machine,
+// leases and watches.
+package coordination
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/policy/lease"
+	"github.com/jrobison153/raft/state"
+)
+
+const LockKeyPrefix = state.LockKeyPrefix
+
+var ErrLockNotHeld = errors.New("lock is not held with the lease, it was released or its lease expired")
+
+// Lock is a held lock. Token is the journal index of the entry that acquired the lock, tokens only ever increase so
+// a resource guarded by the lock can reject writes carrying a token older than the newest it has seen. The lock is
+// held for as long as Lease is kept alive
+type Lock struct {
+	Name  string
+	Token uint64
+	Lease uint64
+}
+
+// Locker acquires locks by putting the lock key if it is absent, attached to a lease granted for the holder, see
+// state.NewAcquireLockCommand. The key is deleted when the holder unlocks or its lease expires, waiting acquirers
+// watch the key and retry once it has been deleted
+type Locker struct {
+	persister client.Persister
+	lessor    *lease.Lessor
+}
+
+func NewLocker(persister client.Persister, lessor *lease.Lessor) *Locker {
+
+	return &Locker{
+		persister: persister,
+		lessor:    lessor,
+	}
+}
+
+// Lock blocks until the lock called name is acquired with a lease of ttlSeconds or ctx is done.
+// Returns the error of ctx if it is done before the lock is acquired
+// Returns the errors of lease.Lessor Grant if the lease cannot be granted
+func (locker *Locker) Lock(ctx context.Context, name string, ttlSeconds int64) (Lock, error) {
+
+	lock, err := locker.acquire(ctx, LockKeyPrefix+name, nil, ttlSeconds)
+	lock.Name = name
+
+	return lock, err
+}
+
+// Unlock releases the lock called name held with lease, revoking the lease.
+// Returns ErrLockNotHeld if the lock is not held with lease
+func (locker *Locker) Unlock(name string, lease uint64) error {
+
+	return locker.release(LockKeyPrefix+name, lease)
+}
+
+// acquire puts value under key attached to a new lease once key is absent. The lease is kept alive before every
+// attempt so it cannot expire while waiting and is revoked if key is never acquired
+func (locker *Locker) acquire(ctx context.Context, key string, value []byte, ttlSeconds int64) (Lock, error) {
+
+	granted, err := locker.lessor.Grant(ttlSeconds)
+
+	lock := Lock{Lease: granted.ID}
+	isAcquired := false
+
+	for err == nil && !isAcquired {
+
+		_, err = locker.lessor.KeepAlive(granted.ID)
+
+		if err == nil {
+			isAcquired, lock.Token, err = locker.tryAcquire(ctx, key, value, granted.ID)
+		}
+	}
+
+	if err != nil && granted.ID != 0 {
+
+		// best effort, an unrevoked lease has no keys attached and simply expires
+		_ = locker.lessor.Revoke(granted.ID)
+	}
+
+	return lock, err
+}
+
+// tryAcquire makes a single attempt to put key, waiting for key to be deleted if it is already held. The watch is
+// started before the attempt so a delete made between the attempt and the wait is not missed
+func (locker *Locker) tryAcquire(ctx context.Context, key string, value []byte, lease uint64) (bool, uint64, error) {
+
+	var result state.ApplyResult
+
+	watcher, err := locker.persister.Watch(state.WatchRequest{Key: key})
+
+	if err == nil {
+
+		defer watcher.Cancel()
+
+		result, err = propose(locker.persister, state.NewAcquireLockCommand(key, value, lease))
+	}
+
+	if err == nil && !result.Applied {
+		err = awaitDelete(ctx, watcher)
+	}
+
+	return result.Applied, result.Index, err
+}
+
+// awaitDelete waits for watcher to see a delete. A watcher that was dropped returns straight away so the caller
+// retries with a new watch
+func awaitDelete(ctx context.Context, watcher *state.Watcher) error {
+
+	var err error
+
+	for isWaiting := true; isWaiting; {
+
+		select {
+		case <-ctx.Done():
+			err, isWaiting = ctx.Err(), false
+		case event, isOpen := <-watcher.Events():
+			isWaiting = isOpen && event.Type != state.EventDelete
+		}
+	}
+
+	return err
+}
+
+func (locker *Locker) release(key string, lease uint64) error {
+
+	held, isGranted := locker.persister.Lease(lease)
+
+	err := ErrLockNotHeld
+
+	if isGranted && isAttached(held, key) {
+		err = locker.lessor.Revoke(lease)
+	}
+
+	if errors.Is(err, state.ErrLeaseNotFound) {
+		err = ErrLockNotHeld
+	}
+
+	return err
+}
+
+func isAttached(held state.Lease, key string) bool {
+
+	attached := false
+
+	for _, heldKey := range held.Keys {
+		attached = attached || heldKey == key
+	}
+
+	return attached
+}
+
+func propose(persister client.Persister, command state.Command) (state.ApplyResult, error) {
+
+	var result state.ApplyResult
+
+	resultCh, err := persister.ProposeWithResult(command)
+
+	if err == nil {
+
+		result = <-resultCh
+		err = result.Err
+	}
+
+	return result, err
+}
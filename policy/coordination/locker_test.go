@@ -0,0 +1,131 @@
+package coordination
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/policy/lease"
+	"github.com/jrobison153/raft/state"
+)
+
+// blockingTimer never returns from WaitMs so leases are never expired while a test runs
+type blockingTimer struct{}
+
+func (timer blockingTimer) WaitMs(int) {
+
+	select {}
+}
+
+func TestWhenLockIsFreeThenItIsAcquiredWithTheAcquiringIndexAsToken(t *testing.T) {
+
+	spy := setupGrantAt(4)
+	spy.ApplyInTurn(state.ApplyResult{Index: 7, Applied: true})
+
+	lock, err := setupLocker(spy).Lock(context.Background(), "jobs", 10)
+
+	acquire := spy.LastProposedCommand()
+
+	if err != nil || lock.Token != 7 || lock.Lease != state.LeaseIDGrantedAt(4) {
+		t.Errorf("Unexpected lock %+v and error '%v'", lock, err)
+	}
+
+	if acquire.Op != state.OpAcquireLock || acquire.Key != LockKeyPrefix+"jobs" || acquire.Lease != lock.Lease {
+		t.Errorf("Lock should have been put if absent with its lease but got %+v", acquire)
+	}
+}
+
+func TestWhenLockIsHeldThenLockWaitsForItToBeReleased(t *testing.T) {
+
+	spy := setupGrantAt(4)
+	spy.ApplyInTurn(state.ApplyResult{Index: 6, Applied: false}, state.ApplyResult{Index: 9, Applied: true})
+
+	locker := setupLocker(spy)
+	lockCh := make(chan Lock)
+
+	go func() {
+		lock, _ := locker.Lock(context.Background(), "jobs", 10)
+		lockCh <- lock
+	}()
+
+	var lock Lock
+
+	for isWaiting := true; isWaiting; {
+
+		select {
+		case lock = <-lockCh:
+			isWaiting = false
+		case <-time.After(5 * time.Millisecond):
+			spy.PublishWatchEvents(8, []state.WatchEvent{{Type: state.EventDelete, Key: LockKeyPrefix + "jobs"}})
+		}
+	}
+
+	if lock.Token != 9 {
+		t.Errorf("Lock should have been acquired at index 9 once released but got %+v", lock)
+	}
+}
+
+func TestWhenContextIsDoneWhileWaitingThenTheLeaseIsRevoked(t *testing.T) {
+
+	spy := setupGrantAt(4)
+	spy.ApplyInTurn(state.ApplyResult{Index: 6, Applied: false})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := setupLocker(spy).Lock(ctx, "jobs", 10)
+
+	revoke := spy.LastProposedCommand()
+
+	if !errors.Is(err, context.Canceled) || revoke.Op != state.OpRevokeLease ||
+		revoke.Lease != state.LeaseIDGrantedAt(4) {
+
+		t.Errorf("Expected the lease to be revoked after '%v' but got '%v' and %+v", context.Canceled, err, revoke)
+	}
+}
+
+func TestWhenUnlockingWithALeaseThatDoesNotHoldTheLockThenErrLockNotHeld(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.AddLease(state.Lease{ID: 3, TTLSeconds: 10, Keys: []string{LockKeyPrefix + "other"}})
+
+	err := setupLocker(spy).Unlock("jobs", 3)
+
+	if err != ErrLockNotHeld || len(spy.ProposedCommands()) != 0 {
+		t.Errorf("Expected error '%v' and no proposals but got '%v' and %+v", ErrLockNotHeld, err,
+			spy.ProposedCommands())
+	}
+}
+
+func TestWhenUnlockingAHeldLockThenItsLeaseIsRevoked(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.AddLease(state.Lease{ID: 3, TTLSeconds: 10, Keys: []string{LockKeyPrefix + "jobs"}})
+	spy.ApplyWith(state.ApplyResult{Applied: true})
+
+	err := setupLocker(spy).Unlock("jobs", 3)
+
+	if err != nil || spy.LastProposedCommand().Op != state.OpRevokeLease || spy.LastProposedCommand().Lease != 3 {
+		t.Errorf("Lease 3 should have been revoked but got '%v' and %+v", err, spy.LastProposedCommand())
+	}
+}
+
+// setupGrantAt returns a spy whose first proposal grants a lease at index
+func setupGrantAt(index uint64) *client.Spy {
+
+	spy := client.NewSpy()
+	spy.ApplyInTurn(state.ApplyResult{Index: index, Applied: true})
+	spy.AddLease(state.Lease{ID: state.LeaseIDGrantedAt(index), TTLSeconds: 10})
+
+	return spy
+}
+
+func setupLocker(spy *client.Spy) *Locker {
+
+	lessor := lease.NewLessor(spy, lease.NewDefaultConfig())
+	lessor.Promote(blockingTimer{})
+
+	return NewLocker(spy, lessor)
+}
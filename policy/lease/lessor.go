@@ -0,0 +1,255 @@
+// Package lease decides when the leases granted in the state machine expire. Leases themselves are replicated
+// state, granted and revoked through the journal, only the deadlines live here and only on the leader.
+package lease
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/replication"
+	"github.com/jrobison153/raft/state"
+)
+
+const defaultExpiryCheckPeriod = 500
+
+// A Config provides fields that can be used to modify the way the lessor behaves
+type Config struct {
+	// ExpiryCheckPeriod specifies the time in milliseconds to wait between checks for expired leases. Default
+	// value is 500ms
+	ExpiryCheckPeriod int
+}
+
+var ErrNotLeader = errors.New("lease deadlines are only tracked by the leader, this node is not leading")
+
+// Lessor tracks the deadline of every granted lease while this node is the leader and journals the revoke of each
+// lease that passes its deadline, so every replica deletes the keys attached to it at the same journal index. A lease
+// found expired can no longer be kept alive while its revoke is proposed, so a revoke never races a KeepAlive.
+// A new leader knows nothing of the previous leader's deadlines, on Promote it gives every granted lease a full time
+// to live from that moment. A lease can outlive its time to live across a leader change but never expires early.
+// Lessor is safe for concurrent use
+type Lessor struct {
+	persister  client.Persister
+	config     *Config
+	now        func() time.Time
+	mutex      sync.Mutex
+	deadlines  map[uint64]time.Time
+	revoking   map[uint64]bool
+	isLeading  bool
+	generation int
+}
+
+func NewDefaultConfig() *Config {
+
+	return &Config{
+		ExpiryCheckPeriod: defaultExpiryCheckPeriod,
+	}
+}
+
+func NewLessor(persister client.Persister, config *Config) *Lessor {
+
+	return &Lessor{
+		persister: persister,
+		config:    config,
+		now:       time.Now,
+		deadlines: make(map[uint64]time.Time),
+		revoking:  make(map[uint64]bool),
+	}
+}
+
+// Promote starts tracking lease deadlines and revoking expired leases, checking every ExpiryCheckPeriod. It is
+// called when this node becomes the leader
+func (lessor *Lessor) Promote(timer replication.Timer) {
+
+	lessor.mutex.Lock()
+	defer lessor.mutex.Unlock()
+
+	lessor.isLeading = true
+	lessor.generation += 1
+	lessor.deadlines = make(map[uint64]time.Time)
+	lessor.revoking = make(map[uint64]bool)
+
+	go lessor.expireLeases(timer, lessor.generation)
+}
+
+// Demote stops tracking lease deadlines, it is called when this node stops being the leader
+func (lessor *Lessor) Demote() {
+
+	lessor.mutex.Lock()
+	defer lessor.mutex.Unlock()
+
+	lessor.isLeading = false
+	lessor.generation += 1
+	lessor.deadlines = make(map[uint64]time.Time)
+	lessor.revoking = make(map[uint64]bool)
+}
+
+// Grant journals a new lease of ttlSeconds and returns it once it has been applied. Returns the errors of
+// client.Persister ProposeWithResult and any error applying the grant
+func (lessor *Lessor) Grant(ttlSeconds int64) (state.Lease, error) {
+
+	result, err := lessor.propose(state.NewGrantLeaseCommand(ttlSeconds))
+
+	var lease state.Lease
+
+	if err == nil {
+
+		lease = state.Lease{ID: state.LeaseIDGrantedAt(result.Index), TTLSeconds: ttlSeconds}
+		lessor.extend(lease)
+	}
+
+	return lease, err
+}
+
+// KeepAlive restarts the time to live of lease id and returns it in seconds.
+// Returns ErrNotLeader if this node is not tracking lease deadlines
+// Returns state.ErrLeaseNotFound if the lease has expired, is being revoked or has been revoked
+func (lessor *Lessor) KeepAlive(id uint64) (int64, error) {
+
+	lease, isGranted := lessor.persister.Lease(id)
+
+	var err error
+
+	if !lessor.IsLeading() {
+		err = ErrNotLeader
+	} else if !isGranted || !lessor.extend(lease) {
+		err = state.ErrLeaseNotFound
+	}
+
+	return lease.TTLSeconds, err
+}
+
+// Revoke journals the revoke of lease id, deleting the keys attached to it.
+// Returns state.ErrLeaseNotFound if the lease had already expired or been revoked
+// Returns the errors of client.Persister ProposeWithResult
+func (lessor *Lessor) Revoke(id uint64) error {
+
+	result, err := lessor.propose(state.NewRevokeLeaseCommand(id))
+
+	if err == nil && !result.Applied {
+		err = state.ErrLeaseNotFound
+	}
+
+	lessor.forget(id)
+
+	return err
+}
+
+func (lessor *Lessor) IsLeading() bool {
+
+	lessor.mutex.Lock()
+	defer lessor.mutex.Unlock()
+
+	return lessor.isLeading
+}
+
+func (lessor *Lessor) expireLeases(timer replication.Timer, generation int) {
+
+	for lessor.isGeneration(generation) {
+
+		timer.WaitMs(lessor.config.ExpiryCheckPeriod)
+
+		if lessor.isGeneration(generation) {
+			lessor.revokeExpired(lessor.now())
+		}
+	}
+}
+
+// revokeExpired journals the revoke of every lease whose deadline is before now. Leases granted in the state
+// machine without a deadline, those granted before this node was promoted, are given a full time to live from now.
+// A revoke that fails is retried on the next check, until then the lease can be kept alive again
+func (lessor *Lessor) revokeExpired(now time.Time) {
+
+	for _, id := range lessor.expiredAt(now) {
+
+		_, err := lessor.propose(state.NewRevokeLeaseCommand(id))
+
+		if err == nil {
+			lessor.forget(id)
+		} else {
+			lessor.stopRevoking(id)
+		}
+	}
+}
+
+// expiredAt returns every lease whose deadline is before now and marks each as being revoked, from then on KeepAlive
+// can no longer extend its deadline
+func (lessor *Lessor) expiredAt(now time.Time) []uint64 {
+
+	leases := lessor.persister.Leases()
+
+	lessor.mutex.Lock()
+	defer lessor.mutex.Unlock()
+
+	expired := make([]uint64, 0)
+
+	for _, lease := range leases {
+
+		deadline, isTracked := lessor.deadlines[lease.ID]
+
+		if !isTracked {
+			lessor.deadlines[lease.ID] = now.Add(time.Duration(lease.TTLSeconds) * time.Second)
+		} else if deadline.Before(now) {
+			lessor.revoking[lease.ID] = true
+			expired = append(expired, lease.ID)
+		}
+	}
+
+	return expired
+}
+
+// extend restarts the time to live of lease, returns false without extending it if the lease is being revoked
+func (lessor *Lessor) extend(lease state.Lease) bool {
+
+	lessor.mutex.Lock()
+	defer lessor.mutex.Unlock()
+
+	isRevoking := lessor.revoking[lease.ID]
+
+	if lessor.isLeading && !isRevoking {
+		lessor.deadlines[lease.ID] = lessor.now().Add(time.Duration(lease.TTLSeconds) * time.Second)
+	}
+
+	return !isRevoking
+}
+
+func (lessor *Lessor) forget(id uint64) {
+
+	lessor.mutex.Lock()
+	defer lessor.mutex.Unlock()
+
+	delete(lessor.deadlines, id)
+	delete(lessor.revoking, id)
+}
+
+func (lessor *Lessor) stopRevoking(id uint64) {
+
+	lessor.mutex.Lock()
+	defer lessor.mutex.Unlock()
+
+	delete(lessor.revoking, id)
+}
+
+func (lessor *Lessor) isGeneration(generation int) bool {
+
+	lessor.mutex.Lock()
+	defer lessor.mutex.Unlock()
+
+	return lessor.isLeading && lessor.generation == generation
+}
+
+func (lessor *Lessor) propose(command state.Command) (state.ApplyResult, error) {
+
+	var result state.ApplyResult
+
+	resultCh, err := lessor.persister.ProposeWithResult(command)
+
+	if err == nil {
+
+		result = <-resultCh
+		err = result.Err
+	}
+
+	return result, err
+}
@@ -0,0 +1,160 @@
+package lease
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+// blockingTimer never returns from WaitMs so the expiry loop started by Promote never runs a check, tests drive
+// expiry directly with revokeExpired
+type blockingTimer struct{}
+
+func (timer blockingTimer) WaitMs(int) {
+
+	select {}
+}
+
+func TestWhenLeaseIsGrantedThenItsIDComesFromTheGrantIndex(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Index: 9, Applied: true})
+
+	granted, err := NewLessor(spy, NewDefaultConfig()).Grant(5)
+
+	if err != nil || granted.ID != state.LeaseIDGrantedAt(9) || spy.LastProposedCommand().Op != state.OpGrantLease {
+		t.Errorf("Unexpected lease %+v and error '%v'", granted, err)
+	}
+}
+
+func TestWhenLeasePassesItsDeadlineThenARevokeIsJournaled(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Index: 0, Applied: true})
+
+	lessor, clock := setupLeader(spy)
+
+	granted, _ := lessor.Grant(5)
+	spy.AddLease(granted)
+
+	lessor.revokeExpired(clock.Add(6 * time.Second))
+
+	revoke := spy.LastProposedCommand()
+
+	if revoke.Op != state.OpRevokeLease || revoke.Lease != granted.ID {
+		t.Errorf("Expected the revoke of lease %d but got %+v", granted.ID, revoke)
+	}
+}
+
+func TestWhenLeaseIsKeptAliveThenItIsNotRevokedAtItsOriginalDeadline(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Index: 0, Applied: true})
+
+	lessor, clock := setupLeader(spy)
+
+	granted, _ := lessor.Grant(5)
+	spy.AddLease(granted)
+
+	lessor.now = func() time.Time { return clock.Add(4 * time.Second) }
+	ttl, err := lessor.KeepAlive(granted.ID)
+
+	lessor.revokeExpired(clock.Add(6 * time.Second))
+
+	if err != nil || ttl != 5 || len(spy.ProposedCommands()) != 1 {
+		t.Errorf("Lease should have been kept alive, got ttl %d, error '%v' and proposals %+v", ttl, err,
+			spy.ProposedCommands())
+	}
+}
+
+func TestWhenNewLeaderIsPromotedThenGrantedLeasesGetAFullTimeToLive(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.AddLease(state.Lease{ID: 3, TTLSeconds: 5})
+
+	lessor, clock := setupLeader(spy)
+
+	lessor.revokeExpired(clock)
+	lessor.revokeExpired(clock.Add(4 * time.Second))
+	survived := len(spy.ProposedCommands()) == 0
+
+	lessor.revokeExpired(clock.Add(6 * time.Second))
+
+	if !survived || len(spy.ProposedCommands()) != 1 {
+		t.Errorf("Lease should have expired a full time to live after promotion, proposals %+v",
+			spy.ProposedCommands())
+	}
+}
+
+func TestWhenLeaseIsFoundExpiredThenItCanNoLongerBeKeptAliveWhileItsRevokeIsProposed(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.AddLease(state.Lease{ID: 3, TTLSeconds: 5})
+
+	lessor, clock := setupLeader(spy)
+
+	lessor.revokeExpired(clock)
+	lessor.expiredAt(clock.Add(6 * time.Second))
+
+	_, err := lessor.KeepAlive(3)
+
+	if !errors.Is(err, state.ErrLeaseNotFound) {
+		t.Errorf("Should have received error '%v' but got '%v'", state.ErrLeaseNotFound, err)
+	}
+}
+
+func TestWhenRevokeOfAnExpiredLeaseFailsThenTheLeaseCanBeKeptAliveAgain(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.AddLease(state.Lease{ID: 3, TTLSeconds: 5})
+
+	lessor, clock := setupLeader(spy)
+
+	lessor.revokeExpired(clock)
+	spy.FailNextProposeWith(errors.New("no quorum"))
+	lessor.revokeExpired(clock.Add(6 * time.Second))
+
+	_, err := lessor.KeepAlive(3)
+
+	if err != nil {
+		t.Errorf("Lease should have been kept alive but got error '%v'", err)
+	}
+}
+
+func TestWhenNotLeadingThenKeepAliveFails(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.AddLease(state.Lease{ID: 3, TTLSeconds: 5})
+
+	_, err := NewLessor(spy, NewDefaultConfig()).KeepAlive(3)
+
+	if !errors.Is(err, ErrNotLeader) {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrNotLeader, err)
+	}
+}
+
+func TestWhenRevokedLeaseDoesNotExistThenErrLeaseNotFoundIsReturned(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: false})
+
+	err := NewLessor(spy, NewDefaultConfig()).Revoke(3)
+
+	if !errors.Is(err, state.ErrLeaseNotFound) {
+		t.Errorf("Should have received error '%v' but got '%v'", state.ErrLeaseNotFound, err)
+	}
+}
+
+func setupLeader(spy *client.Spy) (*Lessor, time.Time) {
+
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	lessor := NewLessor(spy, NewDefaultConfig())
+	lessor.now = func() time.Time { return clock }
+	lessor.Promote(blockingTimer{})
+
+	return lessor, clock
+}
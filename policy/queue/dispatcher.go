@@ -0,0 +1,254 @@
+// Package queue decides when the deliveries of queue messages time out. Queues themselves are replicated state,
+// messages are enqueued, delivered, acknowledged and returned through the journal, only the visibility deadlines live
+// here and only on the leader.
+package queue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/replication"
+	"github.com/jrobison153/raft/state"
+)
+
+const defaultRedeliveryCheckPeriod = 500
+
+// A Config provides fields that can be used to modify the way the dispatcher behaves
+type Config struct {
+	// RedeliveryCheckPeriod specifies the time in milliseconds to wait between checks for deliveries whose visibility
+	// timeout has passed. Default value is 500ms
+	RedeliveryCheckPeriod int
+}
+
+// delivery identifies one delivery of a message, a message delivered again is a new delivery with its own deadline
+type delivery struct {
+	queue    string
+	id       uint64
+	delivery uint64
+}
+
+// Dispatcher journals the queue commands of consumers and, while this node is the leader, tracks the visibility
+// deadline of every delivered message and journals a nack for each delivery that passes its deadline, so every
+// replica makes the message visible again at the same journal index.
+// A new leader knows nothing of the previous leader's deadlines, on Promote it gives every delivered message a full
+// visibility timeout from that moment. A message can stay hidden longer than its timeout across a leader change but
+// is never redelivered early. Dispatcher is safe for concurrent use
+type Dispatcher struct {
+	persister  client.Persister
+	config     *Config
+	now        func() time.Time
+	mutex      sync.Mutex
+	deadlines  map[delivery]time.Time
+	isLeading  bool
+	generation int
+}
+
+func NewDefaultConfig() *Config {
+
+	return &Config{
+		RedeliveryCheckPeriod: defaultRedeliveryCheckPeriod,
+	}
+}
+
+func NewDispatcher(persister client.Persister, config *Config) *Dispatcher {
+
+	return &Dispatcher{
+		persister: persister,
+		config:    config,
+		now:       time.Now,
+		deadlines: make(map[delivery]time.Time),
+	}
+}
+
+// Promote starts tracking visibility deadlines and redelivering timed out messages, checking every
+// RedeliveryCheckPeriod. It is called when this node becomes the leader
+func (dispatcher *Dispatcher) Promote(timer replication.Timer) {
+
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	dispatcher.isLeading = true
+	dispatcher.generation += 1
+	dispatcher.deadlines = make(map[delivery]time.Time)
+
+	go dispatcher.redeliverTimedOut(timer, dispatcher.generation)
+}
+
+// Demote stops tracking visibility deadlines, it is called when this node stops being the leader
+func (dispatcher *Dispatcher) Demote() {
+
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	dispatcher.isLeading = false
+	dispatcher.generation += 1
+	dispatcher.deadlines = make(map[delivery]time.Time)
+}
+
+// Enqueue journals a message holding body at the back of queue and returns it once it has been applied. Returns the
+// errors of client.Persister ProposeWithResult and any error applying the enqueue
+func (dispatcher *Dispatcher) Enqueue(queue string, body []byte) (state.QueueMessage, error) {
+
+	result, err := dispatcher.propose(state.NewEnqueueCommand(queue, body))
+
+	return result.Message, err
+}
+
+// Dequeue journals the delivery of the first visible message of queue and returns it once it has been applied, the
+// message stays hidden from other consumers for visibilityTimeout seconds unless it is acknowledged or returned.
+// Returns state.ErrQueueEmpty if the queue had no visible message
+// Returns the errors of client.Persister ProposeWithResult
+func (dispatcher *Dispatcher) Dequeue(queue string, visibilityTimeout int64) (state.QueueMessage, error) {
+
+	result, err := dispatcher.propose(state.NewDequeueCommand(queue, visibilityTimeout))
+
+	if err == nil && !result.Applied {
+		err = state.ErrQueueEmpty
+	} else if err == nil {
+		dispatcher.track(result.Message)
+	}
+
+	return result.Message, err
+}
+
+// Ack journals the acknowledgement of delivery of message id, removing the message from queue.
+// Returns state.ErrStaleDelivery if delivery is not the current delivery of the message
+// Returns the errors of client.Persister ProposeWithResult
+func (dispatcher *Dispatcher) Ack(queue string, id uint64, delivery uint64) error {
+
+	return dispatcher.settle(state.NewAckCommand(queue, id, delivery))
+}
+
+// Nack journals the return of delivery of message id, making the message visible again in its place in queue.
+// Returns state.ErrStaleDelivery if delivery is not the current delivery of the message
+// Returns the errors of client.Persister ProposeWithResult
+func (dispatcher *Dispatcher) Nack(queue string, id uint64, delivery uint64) error {
+
+	return dispatcher.settle(state.NewNackCommand(queue, id, delivery))
+}
+
+func (dispatcher *Dispatcher) IsLeading() bool {
+
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	return dispatcher.isLeading
+}
+
+func (dispatcher *Dispatcher) settle(command state.Command) error {
+
+	result, err := dispatcher.propose(command)
+
+	if err == nil && !result.Applied {
+		err = state.ErrStaleDelivery
+	}
+
+	dispatcher.forget(delivery{queue: command.Key, id: command.Queue.MessageID, delivery: command.Queue.Delivery})
+
+	return err
+}
+
+func (dispatcher *Dispatcher) redeliverTimedOut(timer replication.Timer, generation int) {
+
+	for dispatcher.isGeneration(generation) {
+
+		timer.WaitMs(dispatcher.config.RedeliveryCheckPeriod)
+
+		if dispatcher.isGeneration(generation) {
+			dispatcher.redeliverExpired(dispatcher.now())
+		}
+	}
+}
+
+// redeliverExpired journals a nack of every delivery whose deadline is before now. Deliveries in the state machine
+// without a deadline, those made before this node was promoted, are given a full visibility timeout from now. A nack
+// that fails is retried on the next check, a nack that is not applied because the message was acknowledged in the
+// meantime leaves the message acknowledged
+func (dispatcher *Dispatcher) redeliverExpired(now time.Time) {
+
+	for _, expired := range dispatcher.expiredAt(now) {
+
+		_, err := dispatcher.propose(state.NewNackCommand(expired.queue, expired.id, expired.delivery))
+
+		if err == nil {
+			dispatcher.forget(expired)
+		}
+	}
+}
+
+// expiredAt returns the deliveries whose deadline is before now and stops tracking the deliveries that are no longer
+// in flight
+func (dispatcher *Dispatcher) expiredAt(now time.Time) []delivery {
+
+	inFlight := dispatcher.persister.InFlight()
+
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	expired := make([]delivery, 0)
+	deadlines := make(map[delivery]time.Time)
+
+	for _, message := range inFlight {
+
+		current := delivery{queue: message.Queue, id: message.ID, delivery: message.Delivery}
+
+		deadline, isTracked := dispatcher.deadlines[current]
+
+		if !isTracked {
+			deadline = now.Add(time.Duration(message.VisibilityTimeout) * time.Second)
+		} else if deadline.Before(now) {
+			expired = append(expired, current)
+		}
+
+		deadlines[current] = deadline
+	}
+
+	dispatcher.deadlines = deadlines
+
+	return expired
+}
+
+func (dispatcher *Dispatcher) track(message state.QueueMessage) {
+
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	if dispatcher.isLeading {
+
+		current := delivery{queue: message.Queue, id: message.ID, delivery: message.Delivery}
+
+		dispatcher.deadlines[current] = dispatcher.now().Add(time.Duration(message.VisibilityTimeout) * time.Second)
+	}
+}
+
+func (dispatcher *Dispatcher) forget(settled delivery) {
+
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	delete(dispatcher.deadlines, settled)
+}
+
+func (dispatcher *Dispatcher) isGeneration(generation int) bool {
+
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	return dispatcher.isLeading && dispatcher.generation == generation
+}
+
+func (dispatcher *Dispatcher) propose(command state.Command) (state.ApplyResult, error) {
+
+	var result state.ApplyResult
+
+	resultCh, err := dispatcher.persister.ProposeWithResult(command)
+
+	if err == nil {
+
+		result = <-resultCh
+		err = result.Err
+	}
+
+	return result, err
+}
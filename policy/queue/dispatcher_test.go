@@ -0,0 +1,112 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+// blockingTimer never returns from WaitMs so the redelivery loop started by Promote never runs a check, tests drive
+// redelivery directly with redeliverExpired
+type blockingTimer struct{}
+
+func (timer blockingTimer) WaitMs(int) {
+
+	select {}
+}
+
+func TestWhenDeliveryPassesItsVisibilityTimeoutThenANackIsJournaled(t *testing.T) {
+
+	spy := client.NewSpy()
+	message := state.QueueMessage{Queue: "jobs", ID: 4, Delivery: 1, VisibilityTimeout: 5}
+	spy.ApplyWith(state.ApplyResult{Applied: true, Message: message})
+
+	dispatcher, clock := setupLeader(spy)
+
+	_, _ = dispatcher.Dequeue("jobs", 5)
+	spy.AddInFlightMessage(message)
+
+	dispatcher.redeliverExpired(clock.Add(6 * time.Second))
+
+	nack := spy.LastProposedCommand()
+
+	if nack.Op != state.OpNack || nack.Key != "jobs" || nack.Queue.MessageID != 4 || nack.Queue.Delivery != 1 {
+		t.Errorf("Expected the nack of delivery 1 of message 4 but got %+v", nack)
+	}
+}
+
+func TestWhenDeliveryIsAcknowledgedThenItIsNotRedelivered(t *testing.T) {
+
+	spy := client.NewSpy()
+	message := state.QueueMessage{Queue: "jobs", ID: 4, Delivery: 1, VisibilityTimeout: 5}
+	spy.ApplyWith(state.ApplyResult{Applied: true, Message: message})
+
+	dispatcher, clock := setupLeader(spy)
+
+	_, _ = dispatcher.Dequeue("jobs", 5)
+	err := dispatcher.Ack("jobs", 4, 1)
+
+	dispatcher.redeliverExpired(clock.Add(6 * time.Second))
+
+	if err != nil || len(spy.ProposedCommands()) != 2 || spy.LastProposedCommand().Op != state.OpAck {
+		t.Errorf("Only the dequeue and ack should have been proposed, error '%v' and proposals %+v", err,
+			spy.ProposedCommands())
+	}
+}
+
+func TestWhenNewLeaderIsPromotedThenDeliveredMessagesGetAFullVisibilityTimeout(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.AddInFlightMessage(state.QueueMessage{Queue: "jobs", ID: 4, Delivery: 1, VisibilityTimeout: 5})
+
+	dispatcher, clock := setupLeader(spy)
+
+	dispatcher.redeliverExpired(clock)
+	dispatcher.redeliverExpired(clock.Add(4 * time.Second))
+	survived := len(spy.ProposedCommands()) == 0
+
+	dispatcher.redeliverExpired(clock.Add(6 * time.Second))
+
+	if !survived || len(spy.ProposedCommands()) != 1 {
+		t.Errorf("Message should have been redelivered a full timeout after promotion, proposals %+v",
+			spy.ProposedCommands())
+	}
+}
+
+func TestWhenQueueHasNoVisibleMessageThenDequeueReturnsErrQueueEmpty(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: false})
+
+	_, err := NewDispatcher(spy, NewDefaultConfig()).Dequeue("jobs", 5)
+
+	if !errors.Is(err, state.ErrQueueEmpty) {
+		t.Errorf("Should have received error '%v' but got '%v'", state.ErrQueueEmpty, err)
+	}
+}
+
+func TestWhenAckIsForAStaleDeliveryThenErrStaleDeliveryIsReturned(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: false})
+
+	err := NewDispatcher(spy, NewDefaultConfig()).Ack("jobs", 4, 1)
+
+	if !errors.Is(err, state.ErrStaleDelivery) {
+		t.Errorf("Should have received error '%v' but got '%v'", state.ErrStaleDelivery, err)
+	}
+}
+
+func setupLeader(spy *client.Spy) (*Dispatcher, time.Time) {
+
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	dispatcher := NewDispatcher(spy, NewDefaultConfig())
+	dispatcher.now = func() time.Time { return clock }
+	dispatcher.Promote(blockingTimer{})
+
+	return dispatcher, clock
+}
@@ -0,0 +1,178 @@
+// Package upload decides when large value uploads have been abandoned. The chunks of an upload are replicated state,
+// staged and discarded through the journal, only the deadlines live here and only on the leader.
+package upload
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/replication"
+	"github.com/jrobison153/raft/state"
+)
+
+const (
+	defaultExpiryCheckPeriod = 1000
+	defaultAbandonTimeout    = 300
+)
+
+// A Config provides fields that can be used to modify the way the reaper behaves
+type Config struct {
+	// ExpiryCheckPeriod specifies the time in milliseconds to wait between checks for abandoned uploads. Default
+	// value is 1000ms
+	ExpiryCheckPeriod int
+	// AbandonTimeout specifies the time in seconds an upload can go without staging a chunk before it is abandoned.
+	// Default value is 300s
+	AbandonTimeout int64
+}
+
+// stagingKey identifies the key an upload stages chunks for
+type stagingKey struct {
+	namespace string
+	key       string
+}
+
+// progress is the newest chunk staged for a key and when the upload is abandoned unless it stages another
+type progress struct {
+	newest   uint64
+	deadline time.Time
+}
+
+// Reaper tracks, while this node is the leader, when each upload last staged a chunk and journals the discard of the
+// chunks of an upload that stages none for AbandonTimeout, so every replica releases them, and the bytes they count
+// against the quota of their namespace, at the same journal index. An upload still streaming keeps staging chunks
+// and is not discarded, a manifest proposed after its upload was discarded fails with state.ErrChunkNotFound.
+// A new leader knows nothing of when the previous leader saw each chunk, on Promote it gives every staged upload a
+// full timeout from that moment. An upload can stay staged longer than its timeout across a leader change but is
+// never discarded early. Reaper is safe for concurrent use
+type Reaper struct {
+	persister  client.Persister
+	config     *Config
+	now        func() time.Time
+	mutex      sync.Mutex
+	uploads    map[stagingKey]progress
+	isLeading  bool
+	generation int
+}
+
+func NewDefaultConfig() *Config {
+
+	return &Config{
+		ExpiryCheckPeriod: defaultExpiryCheckPeriod,
+		AbandonTimeout:    defaultAbandonTimeout,
+	}
+}
+
+func NewReaper(persister client.Persister, config *Config) *Reaper {
+
+	return &Reaper{
+		persister: persister,
+		config:    config,
+		now:       time.Now,
+		uploads:   make(map[stagingKey]progress),
+	}
+}
+
+// Promote starts tracking staged uploads and discarding abandoned ones, checking every ExpiryCheckPeriod. It is
+// called when this node becomes the leader
+func (reaper *Reaper) Promote(timer replication.Timer) {
+
+	reaper.mutex.Lock()
+	defer reaper.mutex.Unlock()
+
+	reaper.isLeading = true
+	reaper.generation += 1
+	reaper.uploads = make(map[stagingKey]progress)
+
+	go reaper.expireUploads(timer, reaper.generation)
+}
+
+// Demote stops tracking staged uploads, it is called when this node stops being the leader
+func (reaper *Reaper) Demote() {
+
+	reaper.mutex.Lock()
+	defer reaper.mutex.Unlock()
+
+	reaper.isLeading = false
+	reaper.generation += 1
+	reaper.uploads = make(map[stagingKey]progress)
+}
+
+func (reaper *Reaper) IsLeading() bool {
+
+	reaper.mutex.Lock()
+	defer reaper.mutex.Unlock()
+
+	return reaper.isLeading
+}
+
+func (reaper *Reaper) expireUploads(timer replication.Timer, generation int) {
+
+	for reaper.isGeneration(generation) {
+
+		timer.WaitMs(reaper.config.ExpiryCheckPeriod)
+
+		if reaper.isGeneration(generation) {
+			reaper.discardAbandoned(reaper.now())
+		}
+	}
+}
+
+// discardAbandoned journals the discard of the chunks of every upload whose deadline is before now. Uploads in the
+// state machine without a deadline, those staged before this node was promoted, are given a full timeout from now.
+// A discard that fails is retried on the next check, the chunks it names are still staged
+func (reaper *Reaper) discardAbandoned(now time.Time) {
+
+	for _, abandoned := range reaper.expiredAt(now) {
+
+		command := state.NewDiscardChunksCommand(abandoned.Key, abandoned.Chunks)
+		command.Namespace = abandoned.Namespace
+
+		if resultCh, err := reaper.persister.ProposeWithResult(command); err == nil {
+			<-resultCh
+		}
+	}
+}
+
+// expiredAt returns the uploads whose deadline is before now, an upload that has staged a chunk since the last check
+// gets a full timeout from now. Uploads that are no longer staged stop being tracked
+func (reaper *Reaper) expiredAt(now time.Time) []state.StagedUpload {
+
+	staged := reaper.persister.StagedUploads()
+
+	reaper.mutex.Lock()
+	defer reaper.mutex.Unlock()
+
+	timeout := time.Duration(reaper.config.AbandonTimeout) * time.Second
+
+	expired := make([]state.StagedUpload, 0)
+	uploads := make(map[stagingKey]progress)
+
+	for _, upload := range staged {
+
+		current := stagingKey{namespace: upload.Namespace, key: upload.Key}
+		newest := upload.Chunks[len(upload.Chunks)-1]
+
+		tracked, isTracked := reaper.uploads[current]
+
+		if !isTracked || tracked.newest != newest {
+			tracked = progress{newest: newest, deadline: now.Add(timeout)}
+		} else if tracked.deadline.Before(now) {
+			expired = append(expired, upload)
+		}
+
+		uploads[current] = tracked
+	}
+
+	reaper.uploads = uploads
+
+	return expired
+}
+
+func (reaper *Reaper) isGeneration(generation int) bool {
+
+	reaper.mutex.Lock()
+	defer reaper.mutex.Unlock()
+
+	return reaper.isLeading && reaper.generation == generation
+}
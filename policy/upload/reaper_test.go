@@ -0,0 +1,85 @@
+package upload
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+// blockingTimer never returns from WaitMs so the expiry loop started by Promote never runs a check, tests drive
+// expiry directly with discardAbandoned
+type blockingTimer struct{}
+
+func (timer blockingTimer) WaitMs(int) {
+
+	select {}
+}
+
+func TestWhenUploadStagesNoChunkForItsTimeoutThenTheDiscardOfItsChunksIsJournaled(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.StagedUploadsReturn([]state.StagedUpload{{Namespace: "team-a", Key: "blob", Chunks: []uint64{4, 6}}})
+
+	reaper, clock := setupLeader(spy)
+
+	reaper.discardAbandoned(clock)
+	reaper.discardAbandoned(clock.Add(301 * time.Second))
+
+	expected := state.NewDiscardChunksCommand("blob", []uint64{4, 6})
+	expected.Namespace = "team-a"
+
+	if !reflect.DeepEqual(expected, spy.LastProposedCommand()) {
+		t.Errorf("Expected command %+v to be proposed but got %+v", expected, spy.LastProposedCommand())
+	}
+}
+
+func TestWhenUploadKeepsStagingChunksThenItIsNotDiscarded(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.StagedUploadsReturn([]state.StagedUpload{{Key: "blob", Chunks: []uint64{4}}})
+
+	reaper, clock := setupLeader(spy)
+
+	reaper.discardAbandoned(clock)
+
+	spy.StagedUploadsReturn([]state.StagedUpload{{Key: "blob", Chunks: []uint64{4, 6}}})
+	reaper.discardAbandoned(clock.Add(200 * time.Second))
+	reaper.discardAbandoned(clock.Add(400 * time.Second))
+
+	if len(spy.ProposedCommands()) != 0 {
+		t.Errorf("Upload still staging chunks should not have been discarded, proposals %+v", spy.ProposedCommands())
+	}
+}
+
+func TestWhenNewLeaderIsPromotedThenStagedUploadsGetAFullTimeout(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.StagedUploadsReturn([]state.StagedUpload{{Key: "blob", Chunks: []uint64{4}}})
+
+	reaper, clock := setupLeader(spy)
+
+	reaper.discardAbandoned(clock)
+	reaper.discardAbandoned(clock.Add(299 * time.Second))
+	survived := len(spy.ProposedCommands()) == 0
+
+	reaper.discardAbandoned(clock.Add(301 * time.Second))
+
+	if !survived || len(spy.ProposedCommands()) != 1 {
+		t.Errorf("Upload should have been discarded a full timeout after promotion, proposals %+v",
+			spy.ProposedCommands())
+	}
+}
+
+func setupLeader(spy *client.Spy) (*Reaper, time.Time) {
+
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	reaper := NewReaper(spy, NewDefaultConfig())
+	reaper.now = func() time.Time { return clock }
+	reaper.Promote(blockingTimer{})
+
+	return reaper, clock
+}
@@ -5,26 +5,37 @@ import (
 	"reflect"
 )
 
+// NoOpReplicator commits the journal of a cluster of one node, the node leads the cluster once it is started
 type NoOpReplicator struct {
-	journal journal.Journaler
-	config  *Config
-	timer   Timer
+	journal    journal.Journaler
+	config     *Config
+	timer      Timer
+	leadership chan bool
 }
 
 func NewNoOpReplicator(journal journal.Journaler, config *Config) *NoOpReplicator {
 
 	return &NoOpReplicator{
-		journal: journal,
-		config:  config,
+		journal:    journal,
+		config:     config,
+		leadership: make(chan bool, 1),
 	}
 }
 
 func (repl *NoOpReplicator) Start(timer Timer) {
 
 	repl.timer = timer
+	repl.leadership <- true
+
 	go repl.replicateCommits()
 }
 
+// Leadership delivers true once the replicator is started, a node alone in its cluster never stops leading it
+func (repl *NoOpReplicator) Leadership() <-chan bool {
+
+	return repl.leadership
+}
+
 func (repl *NoOpReplicator) TypeOfLogger() string {
 
 	return reflect.TypeOf(repl.journal).String()
@@ -60,6 +60,17 @@ func TestWhenReplicatingDataThenReplicatorWaitsBetweenReplications(t *testing.T)
 	}
 }
 
+func TestWhenReplicatorIsStartedThenTheNodeLeadsTheCluster(t *testing.T) {
+
+	replicator := NewNoOpReplicator(journal.NewJournalSpy(), NewDefaultConfig())
+
+	replicator.Start(NewTimerSpy())
+
+	if isLeader := <-replicator.Leadership(); !isLeader {
+		t.Errorf("A node alone in its cluster should lead it once started")
+	}
+}
+
 func appendMany(journalSpy *journal.Spy, count int) journal.AppendResult {
 
 	var appendResult journal.AppendResult
@@ -9,9 +9,12 @@ type Config struct {
 	JournalPollPeriod int
 }
 
+// A Replicator replicates the journal to the cluster. Leadership delivers true when this node becomes the leader and
+// false when it stops being the leader, the channel is the same on every call
 type Replicator interface {
 	Start(Timer)
 	TypeOfLogger() string
+	Leadership() <-chan bool
 }
 
 func NewDefaultConfig() *Config {
@@ -9,6 +9,7 @@ type Spy struct {
 	startCalled bool
 	journal     journal.Journaler
 	startTimer  Timer
+	leadership  chan bool
 }
 
 func NewReplicatorSpy(journal journal.Journaler) *Spy {
@@ -16,6 +17,7 @@ func NewReplicatorSpy(journal journal.Journaler) *Spy {
 	return &Spy{
 		startCalled: false,
 		journal:     journal,
+		leadership:  make(chan bool, 1),
 	}
 }
 
@@ -39,3 +41,21 @@ func (spy *Spy) TypeOfStartTimer() string {
 
 	return reflect.TypeOf(spy.startTimer).String()
 }
+
+// Leadership delivers the changes made by BecomeLeader and StepDown
+func (spy *Spy) Leadership() <-chan bool {
+
+	return spy.leadership
+}
+
+// BecomeLeader delivers that this node has become the leader
+func (spy *Spy) BecomeLeader() {
+
+	spy.leadership <- true
+}
+
+// StepDown delivers that this node has stopped being the leader
+func (spy *Spy) StepDown() {
+
+	spy.leadership <- false
+}
@@ -1,6 +1,14 @@
 package replication
 
+import (
+	"sync"
+	"time"
+)
+
+// TimerSpy records the duration it is asked to wait and then waits it, so a loop driven by it does not spin. It is
+// safe for concurrent use
 type TimerSpy struct {
+	mutex              sync.Mutex
 	waitMsCallDuration int
 }
 
@@ -11,10 +19,17 @@ func NewTimerSpy() *TimerSpy {
 
 func (spy *TimerSpy) WaitMs(duration int) {
 
+	spy.mutex.Lock()
 	spy.waitMsCallDuration = duration
+	spy.mutex.Unlock()
+
+	time.Sleep(time.Duration(duration) * time.Millisecond)
 }
 
 func (spy *TimerSpy) WaitMsCalledWith() int {
 
+	spy.mutex.Lock()
+	defer spy.mutex.Unlock()
+
 	return spy.waitMsCallDuration
 }
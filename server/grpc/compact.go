@@ -0,0 +1,37 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+var ErrCompactRetryable = errors.New("unable to compact history, the operation is safe to retry")
+
+// Compact is a gRPC controller function used to discard the MVCC history needed only for reads below the requested
+// index. Compaction is journaled so every node discards the same history
+func (clientApi *RaftServer) Compact(ctx context.Context, request *api.CompactRequest) (*api.CompactResponse, error) {
+
+	_, err := clientApi.proposeWithResult(state.NewCompactCommand(request.Index))
+
+	response := &api.CompactResponse{
+		Status:            api.ClientStatusCodes_COMPACT_ERROR,
+		IsRetryable:       api.RetryCodes_YES,
+		ReplicationStatus: api.ReplicationCodes_QUORUM_REACHED,
+	}
+
+	if err == nil {
+		response.Status, response.IsRetryable = api.ClientStatusCodes_COMPACT_OK, api.RetryCodes_NO
+	} else if err == client.ErrCommitFailed {
+		response.ReplicationStatus = api.ReplicationCodes_FAILURE_TO_REACH_QUORUM
+	}
+
+	if err != nil {
+		err = ErrCompactRetryable
+	}
+
+	return response, err
+}
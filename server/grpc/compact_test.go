@@ -0,0 +1,70 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+func TestWhenCompactIsRequestedThenACompactCommandIsProposed(t *testing.T) {
+
+	spy := client.NewSpy()
+
+	_, _ = setup(spy).Compact(context.Background(), &api.CompactRequest{Index: 42})
+
+	if state.OpCompact != spy.LastProposedCommand().Op {
+		t.Errorf("Expected a compact command to be proposed but got %+v", spy.LastProposedCommand())
+	}
+}
+
+func TestWhenCompactIsRequestedThenTheRequestedIndexIsProposed(t *testing.T) {
+
+	spy := client.NewSpy()
+
+	_, _ = setup(spy).Compact(context.Background(), &api.CompactRequest{Index: 42})
+
+	if spy.LastProposedCommand().Compaction.Index != 42 {
+		t.Errorf("Expected compaction up to index 42 but got %+v", spy.LastProposedCommand().Compaction)
+	}
+}
+
+func TestWhenCompactCompletesSuccessfullyThenStatusIsCompactOk(t *testing.T) {
+
+	response, _ := setup(client.NewSpy()).Compact(context.Background(), &api.CompactRequest{Index: 42})
+
+	if api.ClientStatusCodes_COMPACT_OK != response.Status {
+		t.Errorf("CompactResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_COMPACT_OK,
+			response.Status)
+	}
+}
+
+func TestWhenCompactReplicationFailsThenReplicationStatusIsSetToFailureValue(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.FailReplication()
+
+	response, _ := setup(spy).Compact(context.Background(), &api.CompactRequest{Index: 42})
+
+	if api.ReplicationCodes_FAILURE_TO_REACH_QUORUM != response.ReplicationStatus {
+		t.Errorf("Compact should have returned replication status of '%v' but instead we got '%v'",
+			api.ReplicationCodes_FAILURE_TO_REACH_QUORUM,
+			response.ReplicationStatus)
+	}
+}
+
+func TestWhenCompactReplicationFailsThenTheCorrectErrorIsReturned(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.FailReplication()
+
+	_, err := setup(spy).Compact(context.Background(), &api.CompactRequest{Index: 42})
+
+	if !errors.Is(err, ErrCompactRetryable) {
+		t.Errorf("Expected error '%v' but got '%v'", ErrCompactRetryable, err)
+	}
+}
@@ -0,0 +1,133 @@
+package grpc
+
+import (
+	"errors"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+var ErrInvalidCondition = errors.New("condition is not supported by the operation or the item has no key")
+
+// conditionalOutcome holds the response fields shared by conditional puts and deletes
+type conditionalOutcome struct {
+	status            api.ClientStatusCodes
+	isRetryable       api.RetryCodes
+	replicationStatus api.ReplicationCodes
+	version           uint64
+}
+
+func (clientApi *RaftServer) conditionalPutItem(item *api.Item) (*api.PutItemResponse, error) {
+
+	var command state.Command
+	var result state.ApplyResult
+
+	err := ErrInvalidCondition
+
+	if item.Key != "" && item.Condition == api.Condition_IF_ABSENT {
+		command, err = state.NewPutIfAbsentCommand(item.Key, item.Value), nil
+	} else if item.Key != "" && item.Condition == api.Condition_IF_VERSION_EQUALS {
+		command, err = state.NewPutIfVersionCommand(item.Key, item.Value, item.ExpectedVersion), nil
+	}
+
+	if err == nil {
+		command.Lease, command.Namespace = item.Lease, item.Namespace
+		result, err = clientApi.proposeWithResult(command)
+	}
+
+	outcome := newConditionalOutcome(result, err, api.ClientStatusCodes_PUT_OK, api.ClientStatusCodes_PUT_ERROR)
+
+	response := &api.PutItemResponse{
+		Status:            outcome.status,
+		IsRetryable:       outcome.isRetryable,
+		ReplicationStatus: outcome.replicationStatus,
+		Version:           outcome.version,
+	}
+
+	return response, conditionalError(err, ErrPutRetryable)
+}
+
+func (clientApi *RaftServer) conditionalDeleteItem(item *api.Item) (*api.DeleteItemResponse, error) {
+
+	var result state.ApplyResult
+
+	err := ErrInvalidCondition
+
+	if item.Key != "" && item.Condition == api.Condition_IF_VALUE_EQUALS {
+
+		command := state.NewDeleteIfValueCommand(item.Key, item.ExpectedValue)
+		command.Namespace = item.Namespace
+
+		result, err = clientApi.proposeWithResult(command)
+	}
+
+	outcome := newConditionalOutcome(result, err, api.ClientStatusCodes_DELETE_OK, api.ClientStatusCodes_DELETE_ERROR)
+
+	response := &api.DeleteItemResponse{
+		Status:            outcome.status,
+		IsRetryable:       outcome.isRetryable,
+		ReplicationStatus: outcome.replicationStatus,
+		Version:           outcome.version,
+	}
+
+	return response, conditionalError(err, ErrDeleteRetryable)
+}
+
+func (clientApi *RaftServer) proposeWithResult(command state.Command) (state.ApplyResult, error) {
+
+	var result state.ApplyResult
+
+	resultCh, err := clientApi.policyClient.ProposeWithResult(command)
+
+	if err == nil {
+
+		result = <-resultCh
+		err = result.Err
+	}
+
+	return result, err
+}
+
+func newConditionalOutcome(
+	result state.ApplyResult,
+	err error,
+	okStatus api.ClientStatusCodes,
+	errorStatus api.ClientStatusCodes) conditionalOutcome {
+
+	outcome := conditionalOutcome{
+		status:            errorStatus,
+		isRetryable:       api.RetryCodes_YES,
+		replicationStatus: api.ReplicationCodes_QUORUM_REACHED,
+		version:           result.Version,
+	}
+
+	rejectedStatus, isRejected := namespaceRejection(err)
+
+	if err == ErrInvalidCondition {
+		outcome.isRetryable = api.RetryCodes_NO
+	} else if isRejected {
+		outcome.status, outcome.isRetryable = rejectedStatus, api.RetryCodes_NO
+	} else if err == client.ErrCommitFailed {
+		outcome.replicationStatus = api.ReplicationCodes_FAILURE_TO_REACH_QUORUM
+	} else if err == nil && result.Applied {
+		outcome.status, outcome.isRetryable = okStatus, api.RetryCodes_NO
+	} else if err == nil {
+		outcome.status, outcome.isRetryable = api.ClientStatusCodes_CONDITION_FAILED, api.RetryCodes_NO
+	}
+
+	return outcome
+}
+
+// conditionalError returns the error reported to the caller, a condition that did not hold is not an error and a
+// write rejected by its namespace is reported as is
+func conditionalError(err error, retryableErr error) error {
+
+	_, isRejected := namespaceRejection(err)
+
+	if err != nil && err != ErrInvalidCondition && !isRejected {
+		err = retryableErr
+	}
+
+	return err
+}
@@ -0,0 +1,220 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+func TestWhenPutIfAbsentIsRequestedThenAPutIfAbsentCommandIsProposed(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true, Version: 1})
+
+	item := &api.Item{Key: "theKey", Value: []byte("theValue"), Condition: api.Condition_IF_ABSENT}
+
+	_, _ = setup(spy).PutItem(context.Background(), item)
+
+	expected := state.NewPutIfAbsentCommand("theKey", []byte("theValue"))
+
+	if !reflect.DeepEqual(expected, spy.LastProposedCommand()) {
+		t.Errorf("Expected command %+v to be proposed but got %+v", expected, spy.LastProposedCommand())
+	}
+}
+
+func TestWhenPutIfAbsentIsAppliedThenStatusIsPutOk(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true, Version: 1})
+
+	item := &api.Item{Key: "theKey", Value: []byte("theValue"), Condition: api.Condition_IF_ABSENT}
+
+	response, _ := setup(spy).PutItem(context.Background(), item)
+
+	if api.ClientStatusCodes_PUT_OK != response.Status {
+		t.Errorf("PutItemResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_PUT_OK,
+			response.Status)
+	}
+}
+
+func TestWhenPutIfAbsentIsAppliedThenTheNewVersionIsReturned(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true, Version: 1})
+
+	item := &api.Item{Key: "theKey", Value: []byte("theValue"), Condition: api.Condition_IF_ABSENT}
+
+	response, _ := setup(spy).PutItem(context.Background(), item)
+
+	if response.Version != 1 {
+		t.Errorf("PutItemResponse should have carried the new version 1 but got %d", response.Version)
+	}
+}
+
+func TestWhenPutIfVersionIsRequestedThenTheExpectedVersionIsProposed(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: false, Version: 4})
+
+	item := &api.Item{Key: "theKey", Condition: api.Condition_IF_VERSION_EQUALS, ExpectedVersion: 3}
+
+	_, _ = setup(spy).PutItem(context.Background(), item)
+
+	if spy.LastProposedCommand().Condition.Version != 3 {
+		t.Errorf("Expected version 3 to be proposed but got %d", spy.LastProposedCommand().Condition.Version)
+	}
+}
+
+func TestWhenPutIfVersionConditionFailsThenStatusIsConditionFailed(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: false, Version: 4})
+
+	item := &api.Item{Key: "theKey", Condition: api.Condition_IF_VERSION_EQUALS, ExpectedVersion: 3}
+
+	response, _ := setup(spy).PutItem(context.Background(), item)
+
+	if api.ClientStatusCodes_CONDITION_FAILED != response.Status {
+		t.Errorf("PutItemResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_CONDITION_FAILED,
+			response.Status)
+	}
+}
+
+func TestWhenPutIfVersionConditionFailsThenTheCurrentVersionIsReturned(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: false, Version: 4})
+
+	item := &api.Item{Key: "theKey", Condition: api.Condition_IF_VERSION_EQUALS, ExpectedVersion: 3}
+
+	response, _ := setup(spy).PutItem(context.Background(), item)
+
+	if response.Version != 4 {
+		t.Errorf("PutItemResponse should have carried the current version 4 but got %d", response.Version)
+	}
+}
+
+func TestWhenPutIfVersionConditionFailsThenTheRetryStatusIsSetToNo(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: false, Version: 4})
+
+	item := &api.Item{Key: "theKey", Condition: api.Condition_IF_VERSION_EQUALS, ExpectedVersion: 3}
+
+	response, _ := setup(spy).PutItem(context.Background(), item)
+
+	if api.RetryCodes_NO != response.IsRetryable {
+		t.Errorf("PutItem should have returned with retryable code '%v', instead we got '%v'",
+			api.RetryCodes_NO,
+			response.IsRetryable)
+	}
+}
+
+func TestWhenConditionalPutReplicationFailsThenReplicationStatusIsSetToFailureValue(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.FailReplication()
+
+	item := &api.Item{Key: "theKey", Condition: api.Condition_IF_ABSENT}
+
+	response, _ := setup(spy).PutItem(context.Background(), item)
+
+	if api.ReplicationCodes_FAILURE_TO_REACH_QUORUM != response.ReplicationStatus {
+		t.Errorf("PutItem should have returned replication status of '%v' but instead we got '%v'",
+			api.ReplicationCodes_FAILURE_TO_REACH_QUORUM,
+			response.ReplicationStatus)
+	}
+}
+
+func TestWhenConditionalPutReplicationFailsThenTheStatusIsPutError(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.FailReplication()
+
+	item := &api.Item{Key: "theKey", Condition: api.Condition_IF_ABSENT}
+
+	response, _ := setup(spy).PutItem(context.Background(), item)
+
+	if api.ClientStatusCodes_PUT_ERROR != response.Status {
+		t.Errorf("PutItem should have returned put status of '%v' but instead we got '%v'",
+			api.ClientStatusCodes_PUT_ERROR,
+			response.Status)
+	}
+}
+
+func TestWhenConditionalPutReplicationFailsThenTheCorrectErrorIsReturned(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.FailReplication()
+
+	item := &api.Item{Key: "theKey", Condition: api.Condition_IF_ABSENT}
+
+	_, err := setup(spy).PutItem(context.Background(), item)
+
+	if !errors.Is(err, ErrPutRetryable) {
+		t.Errorf("Expected error '%v' but got '%v'", ErrPutRetryable, err)
+	}
+}
+
+func TestWhenPutHasADeleteConditionThenItIsRejected(t *testing.T) {
+
+	item := &api.Item{Key: "theKey", Condition: api.Condition_IF_VALUE_EQUALS}
+
+	_, err := setup(client.NewSpy()).PutItem(context.Background(), item)
+
+	if ErrInvalidCondition != err {
+		t.Errorf("Should have received error '%v' but instead got '%v'", ErrInvalidCondition, err)
+	}
+}
+
+func TestWhenPutHasADeleteConditionThenTheRetryStatusIsSetToNo(t *testing.T) {
+
+	item := &api.Item{Key: "theKey", Condition: api.Condition_IF_VALUE_EQUALS}
+
+	response, _ := setup(client.NewSpy()).PutItem(context.Background(), item)
+
+	if api.RetryCodes_NO != response.IsRetryable {
+		t.Errorf("PutItem should have returned with retryable code '%v', instead we got '%v'",
+			api.RetryCodes_NO,
+			response.IsRetryable)
+	}
+}
+
+func TestWhenDeleteIfValueIsRequestedThenADeleteIfValueCommandIsProposed(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true})
+
+	item := &api.Item{Key: "theKey", Condition: api.Condition_IF_VALUE_EQUALS, ExpectedValue: []byte("theValue")}
+
+	_, _ = setup(spy).DeleteItem(context.Background(), item)
+
+	expected := state.NewDeleteIfValueCommand("theKey", []byte("theValue"))
+
+	if !reflect.DeepEqual(expected, spy.LastProposedCommand()) {
+		t.Errorf("Expected command %+v to be proposed but got %+v", expected, spy.LastProposedCommand())
+	}
+}
+
+func TestWhenDeleteIfValueIsAppliedThenStatusIsDeleteOk(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true})
+
+	item := &api.Item{Key: "theKey", Condition: api.Condition_IF_VALUE_EQUALS, ExpectedValue: []byte("theValue")}
+
+	response, _ := setup(spy).DeleteItem(context.Background(), item)
+
+	if api.ClientStatusCodes_DELETE_OK != response.Status {
+		t.Errorf("DeleteItemResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_DELETE_OK,
+			response.Status)
+	}
+}
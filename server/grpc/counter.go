@@ -0,0 +1,109 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+// SequenceKeyPrefix prefixes the key each sequence keeps the last ID it reserved under
+const SequenceKeyPrefix = state.SequenceKeyPrefix
+
+var ErrCounterRetryable = errors.New("unable to change the counter, the operation is safe to retry")
+
+// Increment is a gRPC controller function used to add delta to a 64 bit counter, a counter that does not exist
+// starts from 0
+func (clientApi *RaftServer) Increment(ctx context.Context, request *api.CounterRequest) (*api.CounterResponse, error) {
+
+	return clientApi.changeCounter(state.NewIncrementCommand(request.Key, request.Delta))
+}
+
+// Decrement is a gRPC controller function used to subtract delta from a 64 bit counter, a counter that does not
+// exist starts from 0 and may go below it
+func (clientApi *RaftServer) Decrement(ctx context.Context, request *api.CounterRequest) (*api.CounterResponse, error) {
+
+	return clientApi.changeCounter(state.NewDecrementCommand(request.Key, request.Delta))
+}
+
+// NextSequence is a gRPC controller function reserving the next batch of IDs of a named sequence with a single
+// journal entry. IDs start at 1 and are never reserved twice, the client can hand out the range without asking again
+func (clientApi *RaftServer) NextSequence(
+	ctx context.Context,
+	request *api.SequenceRequest) (*api.SequenceResponse, error) {
+
+	command := state.NewNextSequenceCommand(SequenceKeyPrefix+request.Name, request.BatchSize)
+
+	result, err := clientApi.proposeWithResult(command)
+
+	outcome := newCounterOutcome(err)
+
+	response := &api.SequenceResponse{
+		Status:            outcome.status,
+		IsRetryable:       outcome.isRetryable,
+		ReplicationStatus: outcome.replicationStatus,
+	}
+
+	if err == nil {
+		response.First, response.Last = uint64(result.Counter)-request.BatchSize+1, uint64(result.Counter)
+	}
+
+	return response, counterError(err)
+}
+
+func (clientApi *RaftServer) changeCounter(command state.Command) (*api.CounterResponse, error) {
+
+	result, err := clientApi.proposeWithResult(command)
+
+	outcome := newCounterOutcome(err)
+
+	response := &api.CounterResponse{
+		Status:            outcome.status,
+		IsRetryable:       outcome.isRetryable,
+		ReplicationStatus: outcome.replicationStatus,
+		Value:             result.Counter,
+		Version:           result.Version,
+	}
+
+	return response, counterError(err)
+}
+
+func newCounterOutcome(err error) leaseOutcome {
+
+	outcome := leaseOutcome{
+		status:            api.ClientStatusCodes_COUNTER_ERROR,
+		isRetryable:       api.RetryCodes_YES,
+		replicationStatus: api.ReplicationCodes_QUORUM_REACHED,
+	}
+
+	if err == nil {
+		outcome.status, outcome.isRetryable = api.ClientStatusCodes_COUNTER_OK, api.RetryCodes_NO
+	} else if errors.Is(err, client.ErrReservedKey) {
+		outcome.status, outcome.isRetryable = api.ClientStatusCodes_RESERVED_KEY, api.RetryCodes_NO
+	} else if isCounterRejected(err) {
+		outcome.isRetryable = api.RetryCodes_NO
+	} else if err == client.ErrCommitFailed {
+		outcome.replicationStatus = api.ReplicationCodes_FAILURE_TO_REACH_QUORUM
+	}
+
+	return outcome
+}
+
+// counterError hides the cause of failures that are safe to retry, a change the state machine rejected is reported
+// as is
+func counterError(err error) error {
+
+	if err != nil && !isCounterRejected(err) {
+		err = ErrCounterRetryable
+	}
+
+	return err
+}
+
+func isCounterRejected(err error) bool {
+
+	return errors.Is(err, client.ErrInvalidCommand) || errors.Is(err, state.ErrNotACounter) ||
+		errors.Is(err, state.ErrCounterOverflow)
+}
@@ -0,0 +1,189 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+func TestWhenCounterIsIncrementedThenAnIncrementCommandIsProposed(t *testing.T) {
+
+	_, spy := increment()
+
+	if state.OpIncrement != spy.LastProposedCommand().Op {
+		t.Errorf("Expected an increment command to be proposed but got %+v", spy.LastProposedCommand())
+	}
+}
+
+func TestWhenCounterIsIncrementedThenStatusIsCounterOk(t *testing.T) {
+
+	response, _ := increment()
+
+	if api.ClientStatusCodes_COUNTER_OK != response.Status {
+		t.Errorf("CounterResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_COUNTER_OK,
+			response.Status)
+	}
+}
+
+func TestWhenCounterIsIncrementedThenItsNewValueIsReturned(t *testing.T) {
+
+	response, _ := increment()
+
+	if response.Value != 12 {
+		t.Errorf("Expected the incremented value 12 but got %d", response.Value)
+	}
+}
+
+func TestWhenCounterIsIncrementedThenItsNewVersionIsReturned(t *testing.T) {
+
+	response, _ := increment()
+
+	if response.Version != 4 {
+		t.Errorf("Expected the counter at version 4 but got %d", response.Version)
+	}
+}
+
+func TestWhenCounterIsDecrementedThenADecrementCommandIsProposed(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true, Counter: -3, Version: 2})
+
+	_, _ = setup(spy).Decrement(context.Background(), &api.CounterRequest{Key: "stock", Delta: 5})
+
+	if state.OpDecrement != spy.LastProposedCommand().Op {
+		t.Errorf("Expected a decrement command to be proposed but got %+v", spy.LastProposedCommand())
+	}
+}
+
+func TestWhenCounterIsDecrementedThenItsNewValueIsReturned(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true, Counter: -3, Version: 2})
+
+	response, _ := setup(spy).Decrement(context.Background(), &api.CounterRequest{Key: "stock", Delta: 5})
+
+	if response.Value != -3 {
+		t.Errorf("Expected the decremented value -3 but got %d", response.Value)
+	}
+}
+
+func TestWhenSequenceBatchIsReservedThenTheFirstIDOfTheRangeIsReturned(t *testing.T) {
+
+	response, _ := reserveSequenceBatch()
+
+	if response.First != 201 {
+		t.Errorf("Expected the range to start at 201 but got %d", response.First)
+	}
+}
+
+func TestWhenSequenceBatchIsReservedThenTheLastIDOfTheRangeIsReturned(t *testing.T) {
+
+	response, _ := reserveSequenceBatch()
+
+	if response.Last != 300 {
+		t.Errorf("Expected the range to end at 300 but got %d", response.Last)
+	}
+}
+
+func TestWhenSequenceBatchIsReservedThenTheSequenceKeyIsIncremented(t *testing.T) {
+
+	_, spy := reserveSequenceBatch()
+
+	if SequenceKeyPrefix+"orders" != spy.LastProposedCommand().Key {
+		t.Errorf("Expected key '%s' to be incremented but got '%s'", SequenceKeyPrefix+"orders",
+			spy.LastProposedCommand().Key)
+	}
+}
+
+func TestWhenKeyIsNotACounterThenTheIncrementFailsWithErrNotACounter(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Err: state.ErrNotACounter})
+
+	_, err := setup(spy).Increment(context.Background(), &api.CounterRequest{Key: "name", Delta: 1})
+
+	if state.ErrNotACounter != err {
+		t.Errorf("Should have received error '%v' but instead got '%v'", state.ErrNotACounter, err)
+	}
+}
+
+func TestWhenKeyIsNotACounterThenTheIncrementIsNotRetryable(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Err: state.ErrNotACounter})
+
+	response, _ := setup(spy).Increment(context.Background(), &api.CounterRequest{Key: "name", Delta: 1})
+
+	if api.RetryCodes_NO != response.IsRetryable {
+		t.Errorf("Increment should have returned with retryable code '%v', instead we got '%v'",
+			api.RetryCodes_NO,
+			response.IsRetryable)
+	}
+}
+
+func TestWhenIncrementNamesTheKeyOfASequenceThenReservedKeyIsReported(t *testing.T) {
+
+	response, _ := incrementSequenceKey()
+
+	if api.ClientStatusCodes_RESERVED_KEY != response.Status {
+		t.Errorf("CounterResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_RESERVED_KEY,
+			response.Status)
+	}
+}
+
+func TestWhenIncrementNamesTheKeyOfASequenceThenErrReservedKeyIsReturned(t *testing.T) {
+
+	_, err := incrementSequenceKey()
+
+	if client.ErrReservedKey != err {
+		t.Errorf("Should have received error '%v' but instead got '%v'", client.ErrReservedKey, err)
+	}
+}
+
+func TestWhenIncrementNamesTheKeyOfASequenceThenItIsNotRetryable(t *testing.T) {
+
+	response, _ := incrementSequenceKey()
+
+	if api.RetryCodes_NO != response.IsRetryable {
+		t.Errorf("Increment should have returned with retryable code '%v', instead we got '%v'",
+			api.RetryCodes_NO,
+			response.IsRetryable)
+	}
+}
+
+// increment adds 2 to the hits counter, applied as the counter reaching 12 at version 4
+func increment() (*api.CounterResponse, *client.Spy) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true, Counter: 12, Version: 4})
+
+	response, _ := setup(spy).Increment(context.Background(), &api.CounterRequest{Key: "hits", Delta: 2})
+
+	return response, spy
+}
+
+// reserveSequenceBatch reserves a batch of 100 IDs from the orders sequence, applied as the sequence reaching 300
+func reserveSequenceBatch() (*api.SequenceResponse, *client.Spy) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true, Counter: 300})
+
+	response, _ := setup(spy).NextSequence(context.Background(), &api.SequenceRequest{Name: "orders", BatchSize: 100})
+
+	return response, spy
+}
+
+func incrementSequenceKey() (*api.CounterResponse, error) {
+
+	spy := client.NewSpy()
+	spy.FailNextProposeWith(client.ErrReservedKey)
+
+	request := &api.CounterRequest{Key: SequenceKeyPrefix + "orders", Delta: 1}
+
+	return setup(spy).Increment(context.Background(), request)
+}
@@ -0,0 +1,104 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+var (
+	ErrIndexRetryable      = errors.New("unable to create or drop the index, the operation is safe to retry")
+	ErrIndexQueryRetryable = errors.New("unable to query the index, the operation is safe to retry")
+)
+
+// CreateIndex is a gRPC controller function used to create a secondary index on a JSON path of the documents stored
+// under a prefix of a namespace. The index definition is journaled so every node builds the same index. Creating an
+// index that already exists reports INDEX_EXISTS without an error and leaves the index as it is
+func (clientApi *RaftServer) CreateIndex(ctx context.Context, request *api.IndexRequest) (*api.IndexResponse, error) {
+
+	command := state.NewCreateIndexCommand(state.IndexDefinition{
+		Name:      request.Name,
+		Namespace: request.Namespace,
+		Prefix:    request.Prefix,
+		Path:      request.Path,
+	})
+
+	result, err := clientApi.proposeWithResult(command)
+
+	return newIndexResponse(result, err, api.ClientStatusCodes_INDEX_EXISTS), indexError(err)
+}
+
+// DropIndex is a gRPC controller function used to drop a secondary index. Dropping an index that does not exist
+// reports INDEX_NOT_FOUND without an error
+func (clientApi *RaftServer) DropIndex(ctx context.Context, request *api.IndexRequest) (*api.IndexResponse, error) {
+
+	result, err := clientApi.proposeWithResult(state.NewDropIndexCommand(request.Name))
+
+	return newIndexResponse(result, err, api.ClientStatusCodes_INDEX_NOT_FOUND), indexError(err)
+}
+
+// QueryByIndex is a gRPC controller function returning, in key order, the keys whose document holds the requested
+// value at the path of an index as of the node serving the request. Querying an index that does not exist reports
+// INDEX_NOT_FOUND without an error
+func (clientApi *RaftServer) QueryByIndex(
+	ctx context.Context,
+	request *api.IndexQueryRequest) (*api.IndexQueryResponse, error) {
+
+	keys, err := clientApi.policyClient.QueryIndex(request.Name, request.Value, int(request.Limit))
+
+	response := &api.IndexQueryResponse{
+		Status:      api.ClientStatusCodes_INDEX_ERROR,
+		IsRetryable: api.RetryCodes_NO,
+	}
+
+	if err == nil {
+		response.Status, response.Keys = api.ClientStatusCodes_INDEX_OK, keys
+	} else if err == client.ErrIndexNotFound {
+		response.Status, err = api.ClientStatusCodes_INDEX_NOT_FOUND, nil
+	} else if err != client.ErrInvalidIndexValue && err != client.ErrNotSupported {
+		response.IsRetryable, err = api.RetryCodes_YES, ErrIndexQueryRetryable
+	}
+
+	return response, err
+}
+
+// newIndexResponse reports notAppliedStatus when the state machine did not apply the command
+func newIndexResponse(result state.ApplyResult, err error, notAppliedStatus api.ClientStatusCodes) *api.IndexResponse {
+
+	response := &api.IndexResponse{
+		Status:            api.ClientStatusCodes_INDEX_ERROR,
+		IsRetryable:       api.RetryCodes_YES,
+		ReplicationStatus: api.ReplicationCodes_QUORUM_REACHED,
+	}
+
+	rejectedStatus, isRejected := namespaceRejection(err)
+
+	if err == nil && result.Applied {
+		response.Status, response.IsRetryable = api.ClientStatusCodes_INDEX_OK, api.RetryCodes_NO
+	} else if err == nil {
+		response.Status, response.IsRetryable = notAppliedStatus, api.RetryCodes_NO
+	} else if isRejected {
+		response.Status, response.IsRetryable = rejectedStatus, api.RetryCodes_NO
+	} else if errors.Is(err, client.ErrInvalidCommand) {
+		response.IsRetryable = api.RetryCodes_NO
+	} else if err == client.ErrCommitFailed {
+		response.ReplicationStatus = api.ReplicationCodes_FAILURE_TO_REACH_QUORUM
+	}
+
+	return response
+}
+
+// indexError hides the cause of failures that are safe to retry
+func indexError(err error) error {
+
+	_, isRejected := namespaceRejection(err)
+
+	if err != nil && !errors.Is(err, client.ErrInvalidCommand) && !isRejected {
+		err = ErrIndexRetryable
+	}
+
+	return err
+}
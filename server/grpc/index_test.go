@@ -0,0 +1,128 @@
+package grpc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+func TestWhenIndexIsCreatedThenItsDefinitionIsJournaled(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true})
+
+	request := &api.IndexRequest{Name: "by-city", Namespace: "team-a", Prefix: "user/", Path: "address.city"}
+
+	_, _ = setup(spy).CreateIndex(context.Background(), request)
+
+	expected := state.NewCreateIndexCommand(
+		state.IndexDefinition{Name: "by-city", Namespace: "team-a", Prefix: "user/", Path: "address.city"})
+
+	if !reflect.DeepEqual(expected, spy.LastProposedCommand()) {
+		t.Errorf("Expected command %+v to be proposed but got %+v", expected, spy.LastProposedCommand())
+	}
+}
+
+func TestWhenIndexIsCreatedThenStatusIsIndexOk(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true})
+
+	response, _ := setup(spy).CreateIndex(context.Background(), &api.IndexRequest{Name: "by-city", Path: "city"})
+
+	if api.ClientStatusCodes_INDEX_OK != response.Status {
+		t.Errorf("IndexResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_INDEX_OK,
+			response.Status)
+	}
+}
+
+func TestWhenCreatedIndexAlreadyExistsThenIndexExistsIsReported(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: false})
+
+	response, _ := setup(spy).CreateIndex(context.Background(), &api.IndexRequest{Name: "by-city", Path: "city"})
+
+	if api.ClientStatusCodes_INDEX_EXISTS != response.Status {
+		t.Errorf("IndexResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_INDEX_EXISTS,
+			response.Status)
+	}
+}
+
+func TestWhenDroppedIndexDoesNotExistThenIndexNotFoundIsReported(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: false})
+
+	response, _ := setup(spy).DropIndex(context.Background(), &api.IndexRequest{Name: "by-city"})
+
+	if api.ClientStatusCodes_INDEX_NOT_FOUND != response.Status {
+		t.Errorf("IndexResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_INDEX_NOT_FOUND,
+			response.Status)
+	}
+}
+
+func TestWhenIndexIsQueriedThenTheQueriedValueIsHandedToTheSupportingPolicyService(t *testing.T) {
+
+	spy := client.NewSpy()
+
+	request := &api.IndexQueryRequest{Name: "by-city", Value: []byte(`"Paris"`)}
+
+	_, _ = setup(spy).QueryByIndex(context.Background(), request)
+
+	if string(spy.LastIndexQuery()) != `"Paris"` {
+		t.Errorf(`Expected the value "Paris" to be queried but got %s`, spy.LastIndexQuery())
+	}
+}
+
+func TestWhenIndexIsQueriedThenStatusIsIndexOk(t *testing.T) {
+
+	response := queryIndexByCity()
+
+	if api.ClientStatusCodes_INDEX_OK != response.Status {
+		t.Errorf("IndexQueryResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_INDEX_OK,
+			response.Status)
+	}
+}
+
+func TestWhenIndexIsQueriedThenTheMatchingKeysAreReturned(t *testing.T) {
+
+	response := queryIndexByCity()
+
+	if !reflect.DeepEqual([]string{"user/1", "user/2"}, response.Keys) {
+		t.Errorf("Expected the matching keys [user/1 user/2] but got %v", response.Keys)
+	}
+}
+
+func TestWhenQueriedIndexDoesNotExistThenIndexNotFoundIsReported(t *testing.T) {
+
+	request := &api.IndexQueryRequest{Name: "by-city", Value: []byte(`"Paris"`)}
+
+	response, _ := setup(client.NewSpy()).QueryByIndex(context.Background(), request)
+
+	if api.ClientStatusCodes_INDEX_NOT_FOUND != response.Status {
+		t.Errorf("IndexQueryResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_INDEX_NOT_FOUND,
+			response.Status)
+	}
+}
+
+func queryIndexByCity() *api.IndexQueryResponse {
+
+	spy := client.NewSpy()
+	spy.IndexReturns("by-city", []string{"user/1", "user/2"})
+
+	request := &api.IndexQueryRequest{Name: "by-city", Value: []byte(`"Paris"`)}
+
+	response, _ := setup(spy).QueryByIndex(context.Background(), request)
+
+	return response
+}
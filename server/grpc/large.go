@@ -0,0 +1,203 @@
+package grpc
+
+import (
+	"errors"
+	"io"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+// largeValueChunkSize is the most data GetLarge sends in one chunk
+const largeValueChunkSize = 1 << 20
+
+// largeUpload is a PutLarge stream in progress, the chunks it has proposed and the results they will be applied with
+type largeUpload struct {
+	key       string
+	namespace string
+	lease     uint64
+	size      uint64
+	results   []chan state.ApplyResult
+	chunks    []uint64
+}
+
+// PutLarge is a gRPC controller function used to store a value too large for a single Item. Every chunk received is
+// journaled as its own entry as it arrives, so a large value never holds up replication of the entries behind it.
+// Once the client closes the stream a manifest entry stores the chunks, in the order they were received, under the
+// key as one put. The value is not visible until the manifest is applied, an upload that fails discards its chunks
+func (clientApi *RaftServer) PutLarge(stream api.Persister_PutLargeServer) error {
+
+	upload := &largeUpload{}
+
+	chunk, err := stream.Recv()
+
+	if err == nil {
+		upload.key, upload.namespace, upload.lease = chunk.Key, chunk.Namespace, chunk.Lease
+	}
+
+	for err == nil {
+
+		err = clientApi.proposeChunk(upload, chunk.Data)
+
+		if err == nil {
+			chunk, err = stream.Recv()
+		}
+	}
+
+	var result state.ApplyResult
+
+	if err == io.EOF {
+		result, err = clientApi.commitUpload(upload)
+	}
+
+	if err != nil {
+		clientApi.discardUpload(upload)
+	}
+
+	response, putErr := newLargePutResponse(result, err)
+
+	sendErr := stream.SendAndClose(response)
+
+	if putErr == nil {
+		putErr = sendErr
+	}
+
+	return putErr
+}
+
+// GetLarge is a gRPC controller function used to retrieve a value too large for a single Item, the value is streamed
+// from the chunk list of the manifest that stored it, a chunk larger than 1 MiB is sent in pieces of at most 1 MiB.
+// The first message names the key, an empty value is sent as that message alone. The Item names the key in the same
+// format used by GetItem
+func (clientApi *RaftServer) GetLarge(item *api.Item, stream api.Persister_GetLargeServer) error {
+
+	_, chunks, err := clientApi.policyClient.GetChunks(keyRequest(item))
+
+	if err != nil {
+		err = ErrGetGeneralFailure
+	}
+
+	first := &api.LargeItemChunk{Key: item.Key, Namespace: item.Namespace}
+
+	for _, data := range chunks {
+
+		for offset := 0; err == nil && offset < len(data); offset += largeValueChunkSize {
+
+			end := offset + largeValueChunkSize
+
+			if end > len(data) {
+				end = len(data)
+			}
+
+			chunk := &api.LargeItemChunk{Data: data[offset:end]}
+
+			if first != nil {
+				chunk.Key, chunk.Namespace, first = first.Key, first.Namespace, nil
+			}
+
+			err = stream.Send(chunk)
+		}
+	}
+
+	if err == nil && first != nil {
+		err = stream.Send(first)
+	}
+
+	return err
+}
+
+// proposeChunk journals data as the next chunk of upload without waiting for it to be applied
+func (clientApi *RaftServer) proposeChunk(upload *largeUpload, data []byte) error {
+
+	command := state.NewPutChunkCommand(upload.key, data)
+	command.Namespace = upload.namespace
+
+	resultCh, err := clientApi.policyClient.ProposeWithResult(command)
+
+	if err == nil {
+		upload.results = append(upload.results, resultCh)
+		upload.size += uint64(len(data))
+	}
+
+	return err
+}
+
+// commitUpload waits for every chunk of upload to be applied and then journals the manifest storing them
+func (clientApi *RaftServer) commitUpload(upload *largeUpload) (state.ApplyResult, error) {
+
+	var result state.ApplyResult
+	var err error
+
+	for _, resultCh := range upload.results {
+
+		result = <-resultCh
+
+		if result.Err == nil {
+			upload.chunks = append(upload.chunks, state.ChunkIDWrittenAt(result.Index))
+		} else if err == nil {
+			err = result.Err
+		}
+	}
+
+	upload.results = nil
+
+	if err == nil {
+
+		command := state.NewPutLargeCommand(upload.key, upload.chunks, upload.size)
+		command.Lease, command.Namespace = upload.lease, upload.namespace
+
+		result, err = clientApi.proposeWithResult(command)
+	}
+
+	return result, err
+}
+
+// discardUpload journals the discard of the chunks of a failed upload, chunks still being applied are waited for so
+// none is left staged. The discard is best effort, it is not retried, chunks a failed discard leaves staged are
+// discarded by the leader once the upload is abandoned
+func (clientApi *RaftServer) discardUpload(upload *largeUpload) {
+
+	for _, resultCh := range upload.results {
+
+		if result := <-resultCh; result.Err == nil {
+			upload.chunks = append(upload.chunks, state.ChunkIDWrittenAt(result.Index))
+		}
+	}
+
+	if len(upload.chunks) > 0 {
+
+		command := state.NewDiscardChunksCommand(upload.key, upload.chunks)
+		command.Namespace = upload.namespace
+
+		_, _ = clientApi.proposeWithResult(command)
+	}
+}
+
+// newLargePutResponse reports the outcome of an upload, a rejected or invalid upload is reported as is and any other
+// failure is safe to retry
+func newLargePutResponse(result state.ApplyResult, err error) (*api.PutItemResponse, error) {
+
+	response := &api.PutItemResponse{
+		Status:            api.ClientStatusCodes_PUT_ERROR,
+		IsRetryable:       api.RetryCodes_YES,
+		ReplicationStatus: api.ReplicationCodes_QUORUM_REACHED,
+		Version:           result.Version,
+	}
+
+	rejectedStatus, isRejected := namespaceRejection(err)
+
+	if err == nil {
+		response.Status, response.IsRetryable = api.ClientStatusCodes_PUT_OK, api.RetryCodes_NO
+	} else if isRejected {
+		response.Status, response.IsRetryable = rejectedStatus, api.RetryCodes_NO
+	} else if errors.Is(err, client.ErrInvalidCommand) || err == client.ErrEmptyKey {
+		response.IsRetryable = api.RetryCodes_NO
+	} else if err == client.ErrCommitFailed {
+		response.ReplicationStatus, err = api.ReplicationCodes_FAILURE_TO_REACH_QUORUM, ErrPutRetryable
+	} else {
+		err = ErrPutRetryable
+	}
+
+	return response, err
+}
@@ -0,0 +1,259 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+	"google.golang.org/grpc"
+)
+
+type putLargeStreamSpy struct {
+	grpc.ServerStream
+	chunks   []*api.LargeItemChunk
+	err      error
+	response *api.PutItemResponse
+}
+
+// Recv returns the chunks in turn and then err, io.EOF when it is not set
+func (stream *putLargeStreamSpy) Recv() (*api.LargeItemChunk, error) {
+
+	var chunk *api.LargeItemChunk
+	err := stream.err
+
+	if len(stream.chunks) > 0 {
+		chunk, stream.chunks, err = stream.chunks[0], stream.chunks[1:], nil
+	} else if err == nil {
+		err = io.EOF
+	}
+
+	return chunk, err
+}
+
+func (stream *putLargeStreamSpy) SendAndClose(response *api.PutItemResponse) error {
+
+	stream.response = response
+
+	return nil
+}
+
+type getLargeStreamSpy struct {
+	grpc.ServerStream
+	sent []*api.LargeItemChunk
+}
+
+func (stream *getLargeStreamSpy) Send(chunk *api.LargeItemChunk) error {
+
+	stream.sent = append(stream.sent, chunk)
+
+	return nil
+}
+
+func TestWhenLargeValueIsUploadedThenEachChunkIsJournaled(t *testing.T) {
+
+	_, spy, _ := uploadLargeValue()
+
+	proposed := spy.ProposedCommands()
+
+	if len(proposed) != 3 || proposed[0].Op != state.OpPutChunk || proposed[1].Op != state.OpPutChunk {
+		t.Errorf("Expected the 2 chunks to be journaled first but got proposals %+v", proposed)
+	}
+}
+
+func TestWhenLargeValueIsUploadedThenTheManifestIsJournaledAfterTheChunks(t *testing.T) {
+
+	_, spy, _ := uploadLargeValue()
+
+	manifest := state.NewPutLargeCommand("blob", []uint64{state.ChunkIDWrittenAt(3), state.ChunkIDWrittenAt(5)}, 11)
+	manifest.Namespace = "team-a"
+
+	proposed := spy.ProposedCommands()
+
+	if len(proposed) != 3 || !reflect.DeepEqual(manifest, proposed[2]) {
+		t.Errorf("Expected manifest %+v to be journaled last but got proposals %+v", manifest, proposed)
+	}
+}
+
+func TestWhenLargeValueIsUploadedThenStatusIsPutOk(t *testing.T) {
+
+	stream, _, _ := uploadLargeValue()
+
+	if api.ClientStatusCodes_PUT_OK != stream.response.Status {
+		t.Errorf("PutItemResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_PUT_OK,
+			stream.response.Status)
+	}
+}
+
+func TestWhenLargeValueIsUploadedThenTheVersionOfTheKeyIsReturned(t *testing.T) {
+
+	stream, _, _ := uploadLargeValue()
+
+	if stream.response.Version != 1 {
+		t.Errorf("PutItemResponse should have carried version 1 but got %d", stream.response.Version)
+	}
+}
+
+func TestWhenLargeValueIsUploadedThenTheUploadCompletesWithoutError(t *testing.T) {
+
+	_, _, err := uploadLargeValue()
+
+	if err != nil {
+		t.Errorf("Upload should have completed without error but got '%v'", err)
+	}
+}
+
+func TestWhenUploadStreamBreaksThenItsChunksAreDiscarded(t *testing.T) {
+
+	_, spy, _ := uploadBrokenStream()
+
+	expected := state.NewDiscardChunksCommand("blob", []uint64{state.ChunkIDWrittenAt(3)})
+
+	if !reflect.DeepEqual(expected, spy.LastProposedCommand()) {
+		t.Errorf("Expected command %+v to be proposed but got %+v", expected, spy.LastProposedCommand())
+	}
+}
+
+func TestWhenUploadStreamBreaksThenTheCorrectErrorIsReturned(t *testing.T) {
+
+	_, _, err := uploadBrokenStream()
+
+	if ErrPutRetryable != err {
+		t.Errorf("Expected error '%v' but got '%v'", ErrPutRetryable, err)
+	}
+}
+
+func TestWhenUploadStreamBreaksThenTheRetryStatusIsSetToYes(t *testing.T) {
+
+	stream, _, _ := uploadBrokenStream()
+
+	if api.RetryCodes_YES != stream.response.IsRetryable {
+		t.Errorf("PutItem should have returned with retryable code '%v', instead we got '%v'",
+			api.RetryCodes_YES,
+			stream.response.IsRetryable)
+	}
+}
+
+func TestWhenLargeValueIsReadThenItIsStreamedInChunks(t *testing.T) {
+
+	sent, _ := readLargeValue(bytes.Repeat([]byte("a"), 2*largeValueChunkSize+10))
+
+	if len(sent) != 3 {
+		t.Errorf("Expected the value in 3 chunks but got %d chunks", len(sent))
+	}
+}
+
+func TestWhenLargeValueIsReadThenTheChunksHoldTheWholeValue(t *testing.T) {
+
+	value := bytes.Repeat([]byte("a"), 2*largeValueChunkSize+10)
+
+	sent, _ := readLargeValue(value)
+
+	var received []byte
+
+	for _, chunk := range sent {
+		received = append(received, chunk.Data...)
+	}
+
+	if !bytes.Equal(value, received) {
+		t.Errorf("Expected %d bytes of the value but received %d bytes", len(value), len(received))
+	}
+}
+
+func TestWhenLargeValueIsReadThenTheFirstChunkNamesTheKey(t *testing.T) {
+
+	sent, _ := readLargeValue([]byte("hello"))
+
+	if len(sent) == 0 || sent[0].Key != "blob" {
+		t.Errorf("Expected the first chunk to name key blob but got %v", sent)
+	}
+}
+
+func TestWhenLargeValueIsReadThenTheStreamCompletesWithoutError(t *testing.T) {
+
+	_, err := readLargeValue([]byte("hello"))
+
+	if err != nil {
+		t.Errorf("Stream should have completed without error but got '%v'", err)
+	}
+}
+
+func TestWhenLargeValueIsReadThenTheChunksOfItsManifestAreStreamed(t *testing.T) {
+
+	sent := readManifestChunks()
+
+	if len(sent) != 2 || string(sent[0].Data) != "hello " || string(sent[1].Data) != "world" {
+		t.Errorf("Expected the 2 chunks of the manifest but got %v", sent)
+	}
+}
+
+func TestWhenChunksOfAManifestAreStreamedThenOnlyTheFirstNamesTheKey(t *testing.T) {
+
+	sent := readManifestChunks()
+
+	if len(sent) != 2 || sent[0].Key != "blob" || sent[1].Key != "" {
+		t.Errorf("Expected only the first chunk to name key blob but got %v", sent)
+	}
+}
+
+// uploadLargeValue uploads hello world to blob in 2 chunks, journaled at indexes 3 and 5 with the manifest at 6
+func uploadLargeValue() (*putLargeStreamSpy, *client.Spy, error) {
+
+	spy := client.NewSpy()
+	spy.ApplyInTurn(state.ApplyResult{Index: 3, Applied: true}, state.ApplyResult{Index: 5, Applied: true})
+	spy.ApplyWith(state.ApplyResult{Index: 6, Applied: true, Version: 1})
+
+	stream := &putLargeStreamSpy{chunks: []*api.LargeItemChunk{
+		{Key: "blob", Namespace: "team-a", Data: []byte("hello ")},
+		{Data: []byte("world")},
+	}}
+
+	err := setup(spy).PutLarge(stream)
+
+	return stream, spy, err
+}
+
+// uploadBrokenStream uploads one chunk of blob, journaled at index 3, before the stream is cancelled
+func uploadBrokenStream() (*putLargeStreamSpy, *client.Spy, error) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Index: 3, Applied: true})
+
+	stream := &putLargeStreamSpy{
+		chunks: []*api.LargeItemChunk{{Key: "blob", Data: []byte("hello")}},
+		err:    context.Canceled,
+	}
+
+	err := setup(spy).PutLarge(stream)
+
+	return stream, spy, err
+}
+
+func readLargeValue(value []byte) ([]*api.LargeItemChunk, error) {
+
+	spy := client.NewSpy()
+	spy.GetReturns(state.VersionedItem{Value: value})
+
+	stream := &getLargeStreamSpy{}
+
+	err := setup(spy).GetLarge(&api.Item{Key: "blob"}, stream)
+
+	return stream.sent, err
+}
+
+func readManifestChunks() []*api.LargeItemChunk {
+
+	spy := client.NewSpy()
+	spy.GetChunksReturns([][]byte{[]byte("hello "), []byte("world")})
+
+	stream := &getLargeStreamSpy{}
+
+	_ = setup(spy).GetLarge(&api.Item{Key: "blob"}, stream)
+
+	return stream.sent
+}
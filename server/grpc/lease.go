@@ -0,0 +1,126 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+var ErrLeaseRetryable = errors.New("unable to grant or revoke the lease, the operation is safe to retry")
+
+// leaseOutcome holds the response fields shared by lease grants and revokes
+type leaseOutcome struct {
+	status            api.ClientStatusCodes
+	isRetryable       api.RetryCodes
+	replicationStatus api.ReplicationCodes
+}
+
+// GrantLease is a gRPC controller function used to grant a lease that keys can be attached to with the Item lease.
+// The lease expires ttl seconds after it was granted unless it is kept alive
+func (clientApi *RaftServer) GrantLease(
+	ctx context.Context,
+	request *api.LeaseGrantRequest) (*api.LeaseGrantResponse, error) {
+
+	granted, err := clientApi.lessor.Grant(request.Ttl)
+
+	outcome := newLeaseOutcome(err)
+
+	response := &api.LeaseGrantResponse{
+		Status:            outcome.status,
+		IsRetryable:       outcome.isRetryable,
+		ReplicationStatus: outcome.replicationStatus,
+		Id:                granted.ID,
+		Ttl:               granted.TTLSeconds,
+	}
+
+	return response, leaseError(err)
+}
+
+// KeepAlive is a gRPC controller function used to keep leases alive, every request restarts the time to live of its
+// lease and is answered with that time to live. A ttl of 0 in the response tells the client the lease has expired or
+// been revoked. The stream ends when the client closes it
+func (clientApi *RaftServer) KeepAlive(stream api.Persister_KeepAliveServer) error {
+
+	request, err := stream.Recv()
+
+	for err == nil {
+
+		ttl, keepAliveErr := clientApi.lessor.KeepAlive(request.Id)
+
+		if errors.Is(keepAliveErr, state.ErrLeaseNotFound) {
+			keepAliveErr = nil
+		}
+
+		err = keepAliveErr
+
+		if err == nil {
+			err = stream.Send(&api.LeaseKeepAliveResponse{Id: request.Id, Ttl: ttl})
+		}
+
+		if err == nil {
+			request, err = stream.Recv()
+		}
+	}
+
+	if err == io.EOF {
+		err = nil
+	}
+
+	return err
+}
+
+// Revoke is a gRPC controller function used to revoke a lease, deleting every key attached to it. Revoking a lease
+// that has already expired or been revoked reports LEASE_NOT_FOUND without an error
+func (clientApi *RaftServer) Revoke(
+	ctx context.Context,
+	request *api.LeaseRevokeRequest) (*api.LeaseRevokeResponse, error) {
+
+	err := clientApi.lessor.Revoke(request.Id)
+
+	outcome := newLeaseOutcome(err)
+
+	response := &api.LeaseRevokeResponse{
+		Status:            outcome.status,
+		IsRetryable:       outcome.isRetryable,
+		ReplicationStatus: outcome.replicationStatus,
+	}
+
+	return response, leaseError(err)
+}
+
+func newLeaseOutcome(err error) leaseOutcome {
+
+	outcome := leaseOutcome{
+		status:            api.ClientStatusCodes_LEASE_ERROR,
+		isRetryable:       api.RetryCodes_YES,
+		replicationStatus: api.ReplicationCodes_QUORUM_REACHED,
+	}
+
+	if err == nil {
+		outcome.status, outcome.isRetryable = api.ClientStatusCodes_LEASE_OK, api.RetryCodes_NO
+	} else if errors.Is(err, state.ErrLeaseNotFound) {
+		outcome.status, outcome.isRetryable = api.ClientStatusCodes_LEASE_NOT_FOUND, api.RetryCodes_NO
+	} else if errors.Is(err, client.ErrInvalidCommand) {
+		outcome.isRetryable = api.RetryCodes_NO
+	} else if err == client.ErrCommitFailed {
+		outcome.replicationStatus = api.ReplicationCodes_FAILURE_TO_REACH_QUORUM
+	}
+
+	return outcome
+}
+
+// leaseError hides the cause of failures that are safe to retry, a lease that does not exist is not an error
+func leaseError(err error) error {
+
+	if errors.Is(err, state.ErrLeaseNotFound) {
+		err = nil
+	} else if err != nil && !errors.Is(err, client.ErrInvalidCommand) {
+		err = ErrLeaseRetryable
+	}
+
+	return err
+}
@@ -0,0 +1,189 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/policy/lease"
+	"github.com/jrobison153/raft/replication"
+	"github.com/jrobison153/raft/state"
+	"google.golang.org/grpc"
+)
+
+type keepAliveStreamSpy struct {
+	grpc.ServerStream
+	requests []*api.LeaseKeepAliveRequest
+	sent     []*api.LeaseKeepAliveResponse
+}
+
+// Recv returns the requests in turn and then io.EOF
+func (stream *keepAliveStreamSpy) Recv() (*api.LeaseKeepAliveRequest, error) {
+
+	var request *api.LeaseKeepAliveRequest
+	err := io.EOF
+
+	if len(stream.requests) > 0 {
+		request, stream.requests, err = stream.requests[0], stream.requests[1:], nil
+	}
+
+	return request, err
+}
+
+func (stream *keepAliveStreamSpy) Send(response *api.LeaseKeepAliveResponse) error {
+
+	stream.sent = append(stream.sent, response)
+
+	return nil
+}
+
+func TestWhenLeaseIsGrantedThenStatusIsLeaseOk(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Index: 4, Applied: true})
+
+	response, _ := setup(spy).GrantLease(context.Background(), &api.LeaseGrantRequest{Ttl: 10})
+
+	if api.ClientStatusCodes_LEASE_OK != response.Status {
+		t.Errorf("LeaseGrantResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_LEASE_OK,
+			response.Status)
+	}
+}
+
+func TestWhenLeaseIsGrantedThenTheLeaseIDIsReturned(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Index: 4, Applied: true})
+
+	response, _ := setup(spy).GrantLease(context.Background(), &api.LeaseGrantRequest{Ttl: 10})
+
+	if response.Id != state.LeaseIDGrantedAt(4) {
+		t.Errorf("Expected lease ID %d but got %d", state.LeaseIDGrantedAt(4), response.Id)
+	}
+}
+
+func TestWhenLeaseIsGrantedThenItsTimeToLiveIsReturned(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Index: 4, Applied: true})
+
+	response, _ := setup(spy).GrantLease(context.Background(), &api.LeaseGrantRequest{Ttl: 10})
+
+	if response.Ttl != 10 {
+		t.Errorf("Expected a time to live of 10 seconds but got %d", response.Ttl)
+	}
+}
+
+func TestWhenRevokedLeaseDoesNotExistThenLeaseNotFoundIsReported(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: false})
+
+	response, _ := setup(spy).Revoke(context.Background(), &api.LeaseRevokeRequest{Id: 3})
+
+	if api.ClientStatusCodes_LEASE_NOT_FOUND != response.Status {
+		t.Errorf("LeaseRevokeResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_LEASE_NOT_FOUND,
+			response.Status)
+	}
+}
+
+func TestWhenPuttingItemWithALeaseThenThePutIsAttachedToTheLease(t *testing.T) {
+
+	spy := client.NewSpy()
+
+	_, _ = setup(spy).PutItem(context.Background(), &api.Item{Key: "session", Value: []byte("value"), Lease: 3})
+
+	if spy.LastProposedCommand().Lease != 3 {
+		t.Errorf("Put should have been attached to lease 3 but got %+v", spy.LastProposedCommand())
+	}
+}
+
+func TestWhenLeaseIsKeptAliveOnTheLeaderThenAResponseIsSentForEachRequest(t *testing.T) {
+
+	stream := &keepAliveStreamSpy{requests: []*api.LeaseKeepAliveRequest{{Id: 3}, {Id: 3}}}
+
+	_ = keepAliveOnTheLeader(stream)
+
+	if len(stream.sent) != 2 {
+		t.Errorf("Expected a response for each of the 2 requests but got %+v", stream.sent)
+	}
+}
+
+func TestWhenLeaseIsKeptAliveOnTheLeaderThenEachResponseNamesTheLease(t *testing.T) {
+
+	stream := &keepAliveStreamSpy{requests: []*api.LeaseKeepAliveRequest{{Id: 3}}}
+
+	_ = keepAliveOnTheLeader(stream)
+
+	if len(stream.sent) != 1 || stream.sent[0].Id != 3 {
+		t.Errorf("Expected a response for lease 3 but got %+v", stream.sent)
+	}
+}
+
+func TestWhenLeaseIsKeptAliveOnTheLeaderThenEachResponseCarriesItsTimeToLive(t *testing.T) {
+
+	stream := &keepAliveStreamSpy{requests: []*api.LeaseKeepAliveRequest{{Id: 3}}}
+
+	_ = keepAliveOnTheLeader(stream)
+
+	if len(stream.sent) != 1 || stream.sent[0].Ttl != 10 {
+		t.Errorf("Expected a response with a time to live of 10 seconds but got %+v", stream.sent)
+	}
+}
+
+func TestWhenLeaseKeepAliveStreamEndsThenTheStreamCompletesWithoutError(t *testing.T) {
+
+	stream := &keepAliveStreamSpy{requests: []*api.LeaseKeepAliveRequest{{Id: 3}}}
+
+	err := keepAliveOnTheLeader(stream)
+
+	if err != nil {
+		t.Errorf("Stream should have completed without error but got '%v'", err)
+	}
+}
+
+func TestWhenLeaseIsKeptAliveOnAFollowerThenTheStreamFailsWithErrNotLeader(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.AddLease(state.Lease{ID: 3, TTLSeconds: 10})
+
+	stream := &keepAliveStreamSpy{requests: []*api.LeaseKeepAliveRequest{{Id: 3}}}
+
+	err := setup(spy).KeepAlive(stream)
+
+	if !errors.Is(err, lease.ErrNotLeader) {
+		t.Errorf("Should have received error '%v' but got '%v'", lease.ErrNotLeader, err)
+	}
+}
+
+func TestWhenLeaseIsKeptAliveOnAFollowerThenNoResponseIsSent(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.AddLease(state.Lease{ID: 3, TTLSeconds: 10})
+
+	stream := &keepAliveStreamSpy{requests: []*api.LeaseKeepAliveRequest{{Id: 3}}}
+
+	_ = setup(spy).KeepAlive(stream)
+
+	if len(stream.sent) != 0 {
+		t.Errorf("No response should have been sent but got %+v", stream.sent)
+	}
+}
+
+// keepAliveOnTheLeader runs KeepAlive for stream on a leader holding lease 3 with a time to live of 10 seconds
+func keepAliveOnTheLeader(stream *keepAliveStreamSpy) error {
+
+	spy := client.NewSpy()
+	spy.AddLease(state.Lease{ID: 3, TTLSeconds: 10})
+
+	server := setup(spy)
+	server.lessor.Promote(replication.NewSleepTimer())
+	defer server.lessor.Demote()
+
+	return server.KeepAlive(stream)
+}
@@ -0,0 +1,172 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/policy/coordination"
+)
+
+var (
+	ErrLockRetryable = errors.New("unable to acquire or release the lock, the operation is safe to retry")
+	ErrObserveFailed = errors.New("unable to observe the election")
+)
+
+// Lock is a gRPC controller function that blocks until the named lock is acquired or the client gives up. The
+// response carries the fencing token of the lock and the lease the client must keep alive to keep holding it
+func (clientApi *RaftServer) Lock(ctx context.Context, request *api.LockRequest) (*api.LockResponse, error) {
+
+	lock, err := clientApi.locker.Lock(ctx, request.Name, request.Ttl)
+
+	outcome := newLockOutcome(err)
+
+	response := &api.LockResponse{
+		Status:            outcome.status,
+		IsRetryable:       outcome.isRetryable,
+		ReplicationStatus: outcome.replicationStatus,
+		Token:             lock.Token,
+		Lease:             lock.Lease,
+	}
+
+	return response, lockError(err)
+}
+
+// Unlock is a gRPC controller function used to release a lock held with a lease. Releasing a lock that is not held
+// with the lease reports LOCK_NOT_HELD without an error
+func (clientApi *RaftServer) Unlock(ctx context.Context, request *api.UnlockRequest) (*api.UnlockResponse, error) {
+
+	err := clientApi.locker.Unlock(request.Name, request.Lease)
+
+	outcome := newLockOutcome(err)
+
+	response := &api.UnlockResponse{
+		Status:            outcome.status,
+		IsRetryable:       outcome.isRetryable,
+		ReplicationStatus: outcome.replicationStatus,
+	}
+
+	return response, lockError(err)
+}
+
+// Campaign is a gRPC controller function that blocks until the candidate is elected leader of the named election or
+// the client gives up. The candidate stays leader while the lease in the response is kept alive
+func (clientApi *RaftServer) Campaign(
+	ctx context.Context,
+	request *api.CampaignRequest) (*api.CampaignResponse, error) {
+
+	leadership, err := clientApi.election.Campaign(ctx, request.Name, request.Value, request.Ttl)
+
+	outcome := newLockOutcome(err)
+
+	response := &api.CampaignResponse{
+		Status:            outcome.status,
+		IsRetryable:       outcome.isRetryable,
+		ReplicationStatus: outcome.replicationStatus,
+		Token:             leadership.Token,
+		Lease:             leadership.Lease,
+	}
+
+	return response, lockError(err)
+}
+
+// Resign is a gRPC controller function used by a leader to give up leadership of an election. A candidate that is not
+// the leader is told LOCK_NOT_HELD without an error
+func (clientApi *RaftServer) Resign(ctx context.Context, request *api.ResignRequest) (*api.ResignResponse, error) {
+
+	err := clientApi.election.Resign(request.Name, request.Lease)
+
+	outcome := newLockOutcome(err)
+
+	response := &api.ResignResponse{
+		Status:            outcome.status,
+		IsRetryable:       outcome.isRetryable,
+		ReplicationStatus: outcome.replicationStatus,
+	}
+
+	return response, lockError(err)
+}
+
+// Observe is a gRPC controller function streaming the leader of an election, starting with the current leader and
+// then every change of leader until the client cancels the stream
+func (clientApi *RaftServer) Observe(request *api.ObserveRequest, stream api.Persister_ObserveServer) error {
+
+	watcher, err := clientApi.election.Observe(request.Name)
+
+	var leader coordination.Leader
+
+	if err == nil {
+
+		defer watcher.Cancel()
+
+		leader, err = clientApi.election.Leader(request.Name)
+	}
+
+	if err == nil {
+		err = stream.Send(newLeaderEvent(leader))
+	}
+
+	for isStreaming := err == nil; isStreaming && err == nil; {
+
+		select {
+		case <-stream.Context().Done():
+			isStreaming = false
+		case event, isOpen := <-watcher.Events():
+			if isOpen {
+				err = stream.Send(newLeaderEvent(coordination.LeaderOf(event)))
+			} else {
+				err = watcher.Err()
+			}
+		}
+	}
+
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrObserveFailed, err)
+	}
+
+	return err
+}
+
+func newLeaderEvent(leader coordination.Leader) *api.LeaderEvent {
+
+	return &api.LeaderEvent{
+		Value:     leader.Value,
+		Token:     leader.Token,
+		HasLeader: leader.HasLeader,
+	}
+}
+
+func newLockOutcome(err error) leaseOutcome {
+
+	outcome := leaseOutcome{
+		status:            api.ClientStatusCodes_LOCK_ERROR,
+		isRetryable:       api.RetryCodes_YES,
+		replicationStatus: api.ReplicationCodes_QUORUM_REACHED,
+	}
+
+	if err == nil {
+		outcome.status, outcome.isRetryable = api.ClientStatusCodes_LOCK_OK, api.RetryCodes_NO
+	} else if errors.Is(err, coordination.ErrLockNotHeld) {
+		outcome.status, outcome.isRetryable = api.ClientStatusCodes_LOCK_NOT_HELD, api.RetryCodes_NO
+	} else if errors.Is(err, client.ErrInvalidCommand) {
+		outcome.isRetryable = api.RetryCodes_NO
+	} else if err == client.ErrCommitFailed {
+		outcome.replicationStatus = api.ReplicationCodes_FAILURE_TO_REACH_QUORUM
+	}
+
+	return outcome
+}
+
+// lockError hides the cause of failures that are safe to retry, a lock that is not held is not an error
+func lockError(err error) error {
+
+	if errors.Is(err, coordination.ErrLockNotHeld) {
+		err = nil
+	} else if err != nil && !errors.Is(err, client.ErrInvalidCommand) {
+		err = ErrLockRetryable
+	}
+
+	return err
+}
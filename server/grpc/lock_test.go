@@ -0,0 +1,257 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/policy/coordination"
+	"github.com/jrobison153/raft/replication"
+	"github.com/jrobison153/raft/state"
+	"google.golang.org/grpc"
+)
+
+type observeStreamSpy struct {
+	grpc.ServerStream
+	ctx    context.Context
+	cancel context.CancelFunc
+	sent   []*api.LeaderEvent
+}
+
+func (stream *observeStreamSpy) Context() context.Context {
+
+	return stream.ctx
+}
+
+// Send records event and cancels the stream once the current leader and one change have been sent
+func (stream *observeStreamSpy) Send(event *api.LeaderEvent) error {
+
+	stream.sent = append(stream.sent, event)
+
+	if len(stream.sent) == 2 {
+		stream.cancel()
+	}
+
+	return nil
+}
+
+func TestWhenLockIsAcquiredThenStatusIsLockOk(t *testing.T) {
+
+	response, _ := lockOnTheLeader()
+
+	if api.ClientStatusCodes_LOCK_OK != response.Status {
+		t.Errorf("LockResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_LOCK_OK,
+			response.Status)
+	}
+}
+
+func TestWhenLockIsAcquiredThenTheFencingTokenIsReturned(t *testing.T) {
+
+	response, _ := lockOnTheLeader()
+
+	if response.Token != 5 {
+		t.Errorf("Expected the fencing token 5, the index the lock was acquired at, but got %d", response.Token)
+	}
+}
+
+func TestWhenLockIsAcquiredThenTheLeaseHoldingItIsReturned(t *testing.T) {
+
+	response, _ := lockOnTheLeader()
+
+	if response.Lease != state.LeaseIDGrantedAt(4) {
+		t.Errorf("Expected lease %d to hold the lock but got %d", state.LeaseIDGrantedAt(4), response.Lease)
+	}
+}
+
+func TestWhenLockIsAcquiredThenNoErrorIsReturned(t *testing.T) {
+
+	_, err := lockOnTheLeader()
+
+	if err != nil {
+		t.Errorf("Lock should have been acquired without error but got '%v'", err)
+	}
+}
+
+func TestWhenUnlockingALockThatIsNotHeldThenLockNotHeldIsReported(t *testing.T) {
+
+	response, _ := setup(client.NewSpy()).Unlock(context.Background(), &api.UnlockRequest{Name: "jobs", Lease: 3})
+
+	if api.ClientStatusCodes_LOCK_NOT_HELD != response.Status {
+		t.Errorf("UnlockResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_LOCK_NOT_HELD,
+			response.Status)
+	}
+}
+
+func TestWhenCandidateIsElectedThenStatusIsLockOk(t *testing.T) {
+
+	response, _ := campaignOnTheLeader()
+
+	if api.ClientStatusCodes_LOCK_OK != response.Status {
+		t.Errorf("CampaignResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_LOCK_OK,
+			response.Status)
+	}
+}
+
+func TestWhenCandidateIsElectedThenItsTokenIsReturned(t *testing.T) {
+
+	response, _ := campaignOnTheLeader()
+
+	if response.Token != 5 {
+		t.Errorf("Expected the token 5, the index the candidate was elected at, but got %d", response.Token)
+	}
+}
+
+func TestWhenCandidateIsElectedThenItsLeaseIsReturned(t *testing.T) {
+
+	response, _ := campaignOnTheLeader()
+
+	if response.Lease != state.LeaseIDGrantedAt(4) {
+		t.Errorf("Expected lease %d to hold the election but got %d", state.LeaseIDGrantedAt(4), response.Lease)
+	}
+}
+
+func TestWhenCandidateCampaignsThenItsValueIsProposed(t *testing.T) {
+
+	_, spy := campaignOnTheLeader()
+
+	if string(spy.LastProposedCommand().Value) != "node-1" {
+		t.Errorf("Expected the candidate value 'node-1' to be proposed but got '%s'", spy.LastProposedCommand().Value)
+	}
+}
+
+func TestWhenCandidateCampaignsOnAFollowerThenAnErrorIsReturned(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Index: 4, Applied: true})
+	spy.AddLease(state.Lease{ID: state.LeaseIDGrantedAt(4), TTLSeconds: 10})
+
+	_, err := setup(spy).Campaign(context.Background(), &api.CampaignRequest{Name: "scheduler", Ttl: 10})
+
+	if err == nil {
+		t.Errorf("A node that is not the leader should have failed the campaign with an error")
+	}
+}
+
+func TestWhenCandidateCampaignsOnAFollowerThenItIsNotElected(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Index: 4, Applied: true})
+	spy.AddLease(state.Lease{ID: state.LeaseIDGrantedAt(4), TTLSeconds: 10})
+
+	response, _ := setup(spy).Campaign(context.Background(), &api.CampaignRequest{Name: "scheduler", Ttl: 10})
+
+	if api.ClientStatusCodes_LOCK_OK == response.Status {
+		t.Errorf("A node that is not the leader should not elect a candidate but got %+v", response)
+	}
+}
+
+func TestWhenCandidateThatIsNotTheLeaderResignsThenLockNotHeldIsReported(t *testing.T) {
+
+	response, _ := setup(client.NewSpy()).Resign(context.Background(), &api.ResignRequest{Name: "scheduler", Lease: 3})
+
+	if api.ClientStatusCodes_LOCK_NOT_HELD != response.Status {
+		t.Errorf("ResignResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_LOCK_NOT_HELD,
+			response.Status)
+	}
+}
+
+func TestWhenElectionIsObservedThenTheCurrentLeaderIsSentFirst(t *testing.T) {
+
+	sent, _ := observeElection()
+
+	if len(sent) == 0 || !sent[0].HasLeader || string(sent[0].Value) != "node-1" {
+		t.Errorf("Expected the current leader 'node-1' to be sent first but got %+v", sent)
+	}
+}
+
+func TestWhenElectionIsObservedThenTheCurrentLeaderIsSentWithItsToken(t *testing.T) {
+
+	sent, _ := observeElection()
+
+	if len(sent) == 0 || sent[0].Token != 5 {
+		t.Errorf("Expected the current leader to be sent with token 5 but got %+v", sent)
+	}
+}
+
+func TestWhenElectedLeaderResignsWhileObservedThenTheChangeIsSentAfterTheCurrentLeader(t *testing.T) {
+
+	sent, _ := observeElection()
+
+	if len(sent) != 2 || sent[1].HasLeader {
+		t.Errorf("Expected the loss of the leader to be sent after the current leader but got %+v", sent)
+	}
+}
+
+func TestWhenObservingClientGoesAwayThenObserveEndsWithoutError(t *testing.T) {
+
+	_, err := observeElection()
+
+	if err != nil {
+		t.Errorf("Observe should have ended without error but got '%v'", err)
+	}
+}
+
+// lockOnTheLeader acquires the jobs lock on a leader, the lease for the lock is granted at index 4 and the lock
+// acquired at index 5
+func lockOnTheLeader() (*api.LockResponse, error) {
+
+	spy := client.NewSpy()
+	spy.ApplyInTurn(state.ApplyResult{Index: 4, Applied: true}, state.ApplyResult{Index: 5, Applied: true})
+	spy.AddLease(state.Lease{ID: state.LeaseIDGrantedAt(4), TTLSeconds: 10})
+
+	server := setup(spy)
+	server.lessor.Promote(replication.NewSleepTimer())
+	defer server.lessor.Demote()
+
+	return server.Lock(context.Background(), &api.LockRequest{Name: "jobs", Ttl: 10})
+}
+
+// campaignOnTheLeader elects node-1 in the scheduler election on a leader, the lease for the candidate is granted at
+// index 4 and the candidate elected at index 5
+func campaignOnTheLeader() (*api.CampaignResponse, *client.Spy) {
+
+	spy := client.NewSpy()
+	spy.ApplyInTurn(state.ApplyResult{Index: 4, Applied: true}, state.ApplyResult{Index: 5, Applied: true})
+	spy.AddLease(state.Lease{ID: state.LeaseIDGrantedAt(4), TTLSeconds: 10})
+
+	server := setup(spy)
+	server.lessor.Promote(replication.NewSleepTimer())
+	defer server.lessor.Demote()
+
+	request := &api.CampaignRequest{Name: "scheduler", Value: []byte("node-1"), Ttl: 10}
+
+	response, _ := server.Campaign(context.Background(), request)
+
+	return response, spy
+}
+
+// observeElection observes the scheduler election led by node-1 until the leader resigns, returning the events sent
+func observeElection() ([]*api.LeaderEvent, error) {
+
+	spy := client.NewSpy()
+	spy.GetReturns(state.VersionedItem{Value: []byte("node-1"), CreateIndex: 5})
+
+	stream := &observeStreamSpy{}
+	stream.ctx, stream.cancel = context.WithCancel(context.Background())
+
+	electionKey := coordination.ElectionKeyPrefix + "scheduler"
+	resigned := []state.WatchEvent{{Type: state.EventDelete, Index: 9, Key: electionKey}}
+
+	// the watcher is registered by the call under test, keep publishing until the stream has sent the change
+	go func() {
+		for stream.ctx.Err() == nil {
+			spy.PublishWatchEvents(9, resigned)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	err := setup(spy).Observe(&api.ObserveRequest{Name: "scheduler"}, stream)
+
+	return stream.sent, err
+}
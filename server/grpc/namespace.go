@@ -0,0 +1,110 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+var ErrNamespaceRetryable = errors.New("unable to create or delete the namespace, the operation is safe to retry")
+
+// CreateNamespace is a gRPC controller function used to create a namespace with quotas on its number of keys and
+// bytes. Creating a namespace that already exists reports NAMESPACE_EXISTS without an error and leaves its quotas
+// as they are
+func (clientApi *RaftServer) CreateNamespace(
+	ctx context.Context,
+	request *api.NamespaceRequest) (*api.NamespaceResponse, error) {
+
+	command := state.NewCreateNamespaceCommand(request.Name, request.MaxKeys, request.MaxBytes)
+
+	result, err := clientApi.proposeWithResult(command)
+
+	return newNamespaceResponse(result, err, api.ClientStatusCodes_NAMESPACE_EXISTS), namespaceError(err)
+}
+
+// DeleteNamespace is a gRPC controller function used to delete a namespace and every key stored in it. Deleting a
+// namespace that does not exist reports NAMESPACE_NOT_FOUND without an error
+func (clientApi *RaftServer) DeleteNamespace(
+	ctx context.Context,
+	request *api.NamespaceRequest) (*api.NamespaceResponse, error) {
+
+	result, err := clientApi.proposeWithResult(state.NewDeleteNamespaceCommand(request.Name))
+
+	return newNamespaceResponse(result, err, api.ClientStatusCodes_NAMESPACE_NOT_FOUND), namespaceError(err)
+}
+
+// ListNamespaces is a gRPC controller function returning every namespace in name order with its quotas and usage as
+// of the node serving the request
+func (clientApi *RaftServer) ListNamespaces(ctx context.Context, _ *api.Empty) (*api.ListNamespacesResponse, error) {
+
+	response := &api.ListNamespacesResponse{}
+
+	for _, namespace := range clientApi.policyClient.Namespaces() {
+
+		response.Namespaces = append(response.Namespaces, &api.NamespaceInfo{
+			Name:     namespace.Name,
+			MaxKeys:  namespace.MaxKeys,
+			MaxBytes: namespace.MaxBytes,
+			Keys:     namespace.Keys,
+			Bytes:    namespace.Bytes,
+		})
+	}
+
+	return response, nil
+}
+
+// newNamespaceResponse reports notAppliedStatus when the state machine did not apply the command
+func newNamespaceResponse(
+	result state.ApplyResult,
+	err error,
+	notAppliedStatus api.ClientStatusCodes) *api.NamespaceResponse {
+
+	response := &api.NamespaceResponse{
+		Status:            api.ClientStatusCodes_NAMESPACE_ERROR,
+		IsRetryable:       api.RetryCodes_YES,
+		ReplicationStatus: api.ReplicationCodes_QUORUM_REACHED,
+	}
+
+	if err == nil && result.Applied {
+		response.Status, response.IsRetryable = api.ClientStatusCodes_NAMESPACE_OK, api.RetryCodes_NO
+	} else if err == nil {
+		response.Status, response.IsRetryable = notAppliedStatus, api.RetryCodes_NO
+	} else if errors.Is(err, client.ErrInvalidCommand) {
+		response.IsRetryable = api.RetryCodes_NO
+	} else if err == client.ErrCommitFailed {
+		response.ReplicationStatus = api.ReplicationCodes_FAILURE_TO_REACH_QUORUM
+	}
+
+	return response
+}
+
+// namespaceError hides the cause of failures that are safe to retry
+func namespaceError(err error) error {
+
+	if err != nil && !errors.Is(err, client.ErrInvalidCommand) {
+		err = ErrNamespaceRetryable
+	}
+
+	return err
+}
+
+// namespaceRejection returns the status reporting a write the namespace of its item rejected, either because the
+// namespace does not exist, because the write would exceed its quota or because the key is reserved, and whether err
+// is such a rejection
+func namespaceRejection(err error) (api.ClientStatusCodes, bool) {
+
+	status := api.ClientStatusCodes_QUOTA_EXCEEDED
+
+	isRejected := errors.Is(err, client.ErrQuotaExceeded) || errors.Is(err, state.ErrQuotaExceeded)
+
+	if errors.Is(err, client.ErrNamespaceNotFound) || errors.Is(err, state.ErrNamespaceNotFound) {
+		status, isRejected = api.ClientStatusCodes_NAMESPACE_NOT_FOUND, true
+	} else if errors.Is(err, client.ErrReservedKey) {
+		status, isRejected = api.ClientStatusCodes_RESERVED_KEY, true
+	}
+
+	return status, isRejected
+}
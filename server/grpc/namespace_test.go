@@ -0,0 +1,228 @@
+package grpc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+func TestWhenNamespaceIsCreatedThenItsQuotasAreJournaled(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true})
+
+	request := &api.NamespaceRequest{Name: "team-a", MaxKeys: 10, MaxBytes: 4096}
+
+	_, _ = setup(spy).CreateNamespace(context.Background(), request)
+
+	expected := state.NewCreateNamespaceCommand("team-a", 10, 4096)
+
+	if !reflect.DeepEqual(expected, spy.LastProposedCommand()) {
+		t.Errorf("Expected command %+v to be proposed but got %+v", expected, spy.LastProposedCommand())
+	}
+}
+
+func TestWhenNamespaceIsCreatedThenStatusIsNamespaceOk(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true})
+
+	response, _ := setup(spy).CreateNamespace(context.Background(), &api.NamespaceRequest{Name: "team-a"})
+
+	if api.ClientStatusCodes_NAMESPACE_OK != response.Status {
+		t.Errorf("NamespaceResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_NAMESPACE_OK,
+			response.Status)
+	}
+}
+
+func TestWhenCreatedNamespaceAlreadyExistsThenNamespaceExistsIsReported(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: false})
+
+	response, _ := setup(spy).CreateNamespace(context.Background(), &api.NamespaceRequest{Name: "team-a"})
+
+	if api.ClientStatusCodes_NAMESPACE_EXISTS != response.Status {
+		t.Errorf("NamespaceResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_NAMESPACE_EXISTS,
+			response.Status)
+	}
+}
+
+func TestWhenDeletedNamespaceDoesNotExistThenNamespaceNotFoundIsReported(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: false})
+
+	response, _ := setup(spy).DeleteNamespace(context.Background(), &api.NamespaceRequest{Name: "team-a"})
+
+	if api.ClientStatusCodes_NAMESPACE_NOT_FOUND != response.Status {
+		t.Errorf("NamespaceResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_NAMESPACE_NOT_FOUND,
+			response.Status)
+	}
+}
+
+func TestWhenNamespacesAreListedThenEachNamespaceIsReturned(t *testing.T) {
+
+	namespaces := listNamespaces()
+
+	if len(namespaces) != 1 || namespaces[0].Name != "team-a" {
+		t.Errorf("Expected namespace team-a to be listed but got %+v", namespaces)
+	}
+}
+
+func TestWhenNamespacesAreListedThenTheirKeyCountIsReturned(t *testing.T) {
+
+	namespaces := listNamespaces()
+
+	if len(namespaces) != 1 || namespaces[0].Keys != 3 {
+		t.Errorf("Expected namespace team-a to hold 3 keys but got %+v", namespaces)
+	}
+}
+
+func TestWhenNamespacesAreListedThenTheirByteCountIsReturned(t *testing.T) {
+
+	namespaces := listNamespaces()
+
+	if len(namespaces) != 1 || namespaces[0].Bytes != 42 {
+		t.Errorf("Expected namespace team-a to hold 42 bytes but got %+v", namespaces)
+	}
+}
+
+func TestWhenPuttingItemInANamespaceThenThePutIsStoredInTheNamespace(t *testing.T) {
+
+	spy := client.NewSpy()
+
+	_, _ = setup(spy).PutItem(context.Background(), &api.Item{Key: "config", Value: []byte("a"), Namespace: "team-a"})
+
+	if spy.LastProposedCommand().Namespace != "team-a" {
+		t.Errorf("Put should have been stored in namespace team-a but got %+v", spy.LastProposedCommand())
+	}
+}
+
+func TestWhenPutWouldExceedTheNamespaceQuotaThenQuotaExceededIsReported(t *testing.T) {
+
+	response, _ := putFailingWith(client.ErrQuotaExceeded, &api.Item{Key: "config", Namespace: "team-a"})
+
+	if api.ClientStatusCodes_QUOTA_EXCEEDED != response.Status {
+		t.Errorf("PutItemResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_QUOTA_EXCEEDED,
+			response.Status)
+	}
+}
+
+func TestWhenPutWouldExceedTheNamespaceQuotaThenErrQuotaExceededIsReturned(t *testing.T) {
+
+	_, err := putFailingWith(client.ErrQuotaExceeded, &api.Item{Key: "config", Namespace: "team-a"})
+
+	if client.ErrQuotaExceeded != err {
+		t.Errorf("Should have received error '%v' but instead got '%v'", client.ErrQuotaExceeded, err)
+	}
+}
+
+func TestWhenPutWouldExceedTheNamespaceQuotaThenItIsNotRetryable(t *testing.T) {
+
+	response, _ := putFailingWith(client.ErrQuotaExceeded, &api.Item{Key: "config", Namespace: "team-a"})
+
+	if api.RetryCodes_NO != response.IsRetryable {
+		t.Errorf("PutItem should have returned with retryable code '%v', instead we got '%v'",
+			api.RetryCodes_NO,
+			response.IsRetryable)
+	}
+}
+
+func TestWhenConditionalPutIsAppliedOverTheNamespaceQuotaThenQuotaExceededIsReported(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Err: state.ErrQuotaExceeded})
+
+	item := &api.Item{Key: "config", Value: []byte("a"), Namespace: "team-a", Condition: api.Condition_IF_ABSENT}
+
+	response, _ := setup(spy).PutItem(context.Background(), item)
+
+	if api.ClientStatusCodes_QUOTA_EXCEEDED != response.Status {
+		t.Errorf("PutItemResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_QUOTA_EXCEEDED,
+			response.Status)
+	}
+}
+
+func TestWhenConditionalPutNamesANamespaceThatDoesNotExistThenNamespaceNotFoundIsReported(t *testing.T) {
+
+	item := &api.Item{Key: "config", Namespace: "team-a", Condition: api.Condition_IF_ABSENT}
+
+	response, _ := putFailingWith(client.ErrNamespaceNotFound, item)
+
+	if api.ClientStatusCodes_NAMESPACE_NOT_FOUND != response.Status {
+		t.Errorf("PutItemResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_NAMESPACE_NOT_FOUND,
+			response.Status)
+	}
+}
+
+func TestWhenConditionalPutNamesANamespaceThatDoesNotExistThenErrNamespaceNotFoundIsReturned(t *testing.T) {
+
+	item := &api.Item{Key: "config", Namespace: "team-a", Condition: api.Condition_IF_ABSENT}
+
+	_, err := putFailingWith(client.ErrNamespaceNotFound, item)
+
+	if client.ErrNamespaceNotFound != err {
+		t.Errorf("Should have received error '%v' but instead got '%v'", client.ErrNamespaceNotFound, err)
+	}
+}
+
+func TestWhenPutNamesAReservedKeyThenReservedKeyIsReported(t *testing.T) {
+
+	response, _ := putFailingWith(client.ErrReservedKey, &api.Item{Key: state.NamespacedKey("team-a", "config")})
+
+	if api.ClientStatusCodes_RESERVED_KEY != response.Status {
+		t.Errorf("PutItemResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_RESERVED_KEY,
+			response.Status)
+	}
+}
+
+func TestWhenPutNamesAReservedKeyThenErrReservedKeyIsReturned(t *testing.T) {
+
+	_, err := putFailingWith(client.ErrReservedKey, &api.Item{Key: state.NamespacedKey("team-a", "config")})
+
+	if client.ErrReservedKey != err {
+		t.Errorf("Should have received error '%v' but instead got '%v'", client.ErrReservedKey, err)
+	}
+}
+
+func TestWhenPutNamesAReservedKeyThenItIsNotRetryable(t *testing.T) {
+
+	response, _ := putFailingWith(client.ErrReservedKey, &api.Item{Key: state.NamespacedKey("team-a", "config")})
+
+	if api.RetryCodes_NO != response.IsRetryable {
+		t.Errorf("PutItem should have returned with retryable code '%v', instead we got '%v'",
+			api.RetryCodes_NO,
+			response.IsRetryable)
+	}
+}
+
+func listNamespaces() []*api.NamespaceInfo {
+
+	spy := client.NewSpy()
+	spy.AddNamespace(state.Namespace{Name: "team-a", MaxKeys: 10, Keys: 3, Bytes: 42})
+
+	response, _ := setup(spy).ListNamespaces(context.Background(), &api.Empty{})
+
+	return response.Namespaces
+}
+
+// putFailingWith puts item with the client policy rejecting the proposal with err
+func putFailingWith(err error, item *api.Item) (*api.PutItemResponse, error) {
+
+	spy := client.NewSpy()
+	spy.FailNextProposeWith(err)
+
+	return setup(spy).PutItem(context.Background(), item)
+}
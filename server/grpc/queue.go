@@ -0,0 +1,111 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+var ErrQueueRetryable = errors.New("unable to complete the queue operation, the operation is safe to retry")
+
+// Enqueue is a gRPC controller function used to append a message to the back of a queue, the response message carries
+// the ID of the message
+func (clientApi *RaftServer) Enqueue(ctx context.Context, request *api.EnqueueRequest) (*api.QueueResponse, error) {
+
+	message, err := clientApi.dispatcher.Enqueue(request.Queue, request.Body)
+
+	return newQueueResponse(message, err), queueError(err)
+}
+
+// Dequeue is a gRPC controller function used to receive the first visible message of a queue. The message is hidden
+// from other consumers until it is acknowledged, returned or its visibility timeout passes. A queue without a visible
+// message reports QUEUE_EMPTY without an error
+func (clientApi *RaftServer) Dequeue(ctx context.Context, request *api.DequeueRequest) (*api.QueueResponse, error) {
+
+	message, err := clientApi.dispatcher.Dequeue(request.Queue, request.VisibilityTimeout)
+
+	return newQueueResponse(message, err), queueError(err)
+}
+
+// Ack is a gRPC controller function used to acknowledge a delivery, removing the message from its queue. A delivery
+// that has timed out or been settled reports STALE_DELIVERY without an error
+func (clientApi *RaftServer) Ack(ctx context.Context, request *api.AckRequest) (*api.QueueResponse, error) {
+
+	err := clientApi.dispatcher.Ack(request.Queue, request.Id, request.Delivery)
+
+	return newQueueResponse(state.QueueMessage{}, err), queueError(err)
+}
+
+// Nack is a gRPC controller function used to return a delivery, making the message visible again in its place in
+// its queue. A delivery that has timed out or been settled reports STALE_DELIVERY without an error
+func (clientApi *RaftServer) Nack(ctx context.Context, request *api.AckRequest) (*api.QueueResponse, error) {
+
+	err := clientApi.dispatcher.Nack(request.Queue, request.Id, request.Delivery)
+
+	return newQueueResponse(state.QueueMessage{}, err), queueError(err)
+}
+
+// Peek is a gRPC controller function returning the message the next dequeue of a queue would deliver as of the node
+// serving the request, without delivering it. A queue without a visible message reports QUEUE_EMPTY without an error
+func (clientApi *RaftServer) Peek(ctx context.Context, request *api.PeekRequest) (*api.QueueResponse, error) {
+
+	message, err := clientApi.policyClient.Peek(request.Queue)
+
+	return newQueueResponse(message, err), queueError(err)
+}
+
+func newQueueResponse(message state.QueueMessage, err error) *api.QueueResponse {
+
+	response := &api.QueueResponse{
+		Status:            api.ClientStatusCodes_QUEUE_ERROR,
+		IsRetryable:       api.RetryCodes_YES,
+		ReplicationStatus: api.ReplicationCodes_QUORUM_REACHED,
+	}
+
+	if err == nil {
+
+		response.Status, response.IsRetryable = api.ClientStatusCodes_QUEUE_OK, api.RetryCodes_NO
+		response.Message = newQueueMessage(message)
+	} else if isQueueEmpty(err) {
+		response.Status, response.IsRetryable = api.ClientStatusCodes_QUEUE_EMPTY, api.RetryCodes_NO
+	} else if errors.Is(err, state.ErrStaleDelivery) {
+		response.Status, response.IsRetryable = api.ClientStatusCodes_STALE_DELIVERY, api.RetryCodes_NO
+	} else if errors.Is(err, client.ErrInvalidCommand) {
+		response.IsRetryable = api.RetryCodes_NO
+	} else if err == client.ErrCommitFailed {
+		response.ReplicationStatus = api.ReplicationCodes_FAILURE_TO_REACH_QUORUM
+	}
+
+	return response
+}
+
+func newQueueMessage(message state.QueueMessage) *api.QueueMessage {
+
+	return &api.QueueMessage{
+		Queue:             message.Queue,
+		Id:                message.ID,
+		Body:              message.Body,
+		Delivery:          message.Delivery,
+		VisibilityTimeout: message.VisibilityTimeout,
+	}
+}
+
+// queueError hides the cause of failures that are safe to retry, an empty queue or a stale delivery is not an error
+func queueError(err error) error {
+
+	if isQueueEmpty(err) || errors.Is(err, state.ErrStaleDelivery) {
+		err = nil
+	} else if err != nil && !errors.Is(err, client.ErrInvalidCommand) {
+		err = ErrQueueRetryable
+	}
+
+	return err
+}
+
+func isQueueEmpty(err error) bool {
+
+	return errors.Is(err, state.ErrQueueEmpty) || errors.Is(err, client.ErrQueueEmpty)
+}
@@ -0,0 +1,161 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+func TestWhenMessageIsEnqueuedThenAnEnqueueCommandIsProposed(t *testing.T) {
+
+	_, spy := enqueue()
+
+	if state.OpEnqueue != spy.LastProposedCommand().Op {
+		t.Errorf("Expected an enqueue command to be proposed but got %+v", spy.LastProposedCommand())
+	}
+}
+
+func TestWhenMessageIsEnqueuedThenItsBodyIsProposed(t *testing.T) {
+
+	_, spy := enqueue()
+
+	if string(spy.LastProposedCommand().Value) != "work" {
+		t.Errorf("Expected the body 'work' to be proposed but got '%s'", spy.LastProposedCommand().Value)
+	}
+}
+
+func TestWhenMessageIsEnqueuedThenStatusIsQueueOk(t *testing.T) {
+
+	response, _ := enqueue()
+
+	if api.ClientStatusCodes_QUEUE_OK != response.Status {
+		t.Errorf("QueueResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_QUEUE_OK,
+			response.Status)
+	}
+}
+
+func TestWhenMessageIsEnqueuedThenItsIDIsReturned(t *testing.T) {
+
+	response, _ := enqueue()
+
+	if response.Message.Id != 5 {
+		t.Errorf("Expected message ID 5 but got %d", response.Message.Id)
+	}
+}
+
+func TestWhenQueueHasNoVisibleMessageThenQueueEmptyIsReported(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: false})
+
+	request := &api.DequeueRequest{Queue: "jobs", VisibilityTimeout: 30}
+
+	response, _ := setup(spy).Dequeue(context.Background(), request)
+
+	if api.ClientStatusCodes_QUEUE_EMPTY != response.Status {
+		t.Errorf("QueueResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_QUEUE_EMPTY,
+			response.Status)
+	}
+}
+
+func TestWhenAckedDeliveryIsStaleThenStaleDeliveryIsReported(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: false})
+
+	response, _ := setup(spy).Ack(context.Background(), &api.AckRequest{Queue: "jobs", Id: 5, Delivery: 1})
+
+	if api.ClientStatusCodes_STALE_DELIVERY != response.Status {
+		t.Errorf("QueueResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_STALE_DELIVERY,
+			response.Status)
+	}
+}
+
+func TestWhenQueueIsPeekedThenStatusIsQueueOk(t *testing.T) {
+
+	response := peek()
+
+	if api.ClientStatusCodes_QUEUE_OK != response.Status {
+		t.Errorf("QueueResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_QUEUE_OK,
+			response.Status)
+	}
+}
+
+func TestWhenQueueIsPeekedThenTheNextVisibleMessageIsReturned(t *testing.T) {
+
+	response := peek()
+
+	if string(response.Message.Body) != "work" {
+		t.Errorf("Expected the message with body 'work' but got '%s'", response.Message.Body)
+	}
+}
+
+func TestWhenMessageIsNackedThenANackCommandIsProposed(t *testing.T) {
+
+	_, spy := nack()
+
+	if state.OpNack != spy.LastProposedCommand().Op {
+		t.Errorf("Expected a nack command to be proposed but got %+v", spy.LastProposedCommand())
+	}
+}
+
+func TestWhenMessageIsNackedThenTheNackedDeliveryIsProposed(t *testing.T) {
+
+	_, spy := nack()
+
+	if spy.LastProposedCommand().Queue.Delivery != 2 {
+		t.Errorf("Expected delivery 2 to be nacked but got %d", spy.LastProposedCommand().Queue.Delivery)
+	}
+}
+
+func TestWhenMessageIsNackedThenStatusIsQueueOk(t *testing.T) {
+
+	response, _ := nack()
+
+	if api.ClientStatusCodes_QUEUE_OK != response.Status {
+		t.Errorf("QueueResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_QUEUE_OK,
+			response.Status)
+	}
+}
+
+// enqueue enqueues a work message on the jobs queue, applied as message 5
+func enqueue() (*api.QueueResponse, *client.Spy) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true, Message: state.QueueMessage{Queue: "jobs", ID: 5}})
+
+	request := &api.EnqueueRequest{Queue: "jobs", Body: []byte("work")}
+
+	response, _ := setup(spy).Enqueue(context.Background(), request)
+
+	return response, spy
+}
+
+func peek() *api.QueueResponse {
+
+	spy := client.NewSpy()
+	spy.AddQueuedMessage(state.QueueMessage{Queue: "jobs", ID: 5, Body: []byte("work")})
+
+	response, _ := setup(spy).Peek(context.Background(), &api.PeekRequest{Queue: "jobs"})
+
+	return response
+}
+
+// nack returns the second delivery of message 7 to the jobs queue
+func nack() (*api.QueueResponse, *client.Spy) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true})
+
+	response, _ := setup(spy).Nack(context.Background(), &api.AckRequest{Queue: "jobs", Id: 7, Delivery: 2})
+
+	return response, spy
+}
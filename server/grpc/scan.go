@@ -0,0 +1,44 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+var ErrScanRetryable = errors.New("unable to scan, the operation is safe to retry")
+
+// Scan is a gRPC controller function returning the items in a key range of a namespace, in key order or reverse key
+// order, as of the node serving the request. Reserved keys are never returned
+func (clientApi *RaftServer) Scan(ctx context.Context, request *api.ScanRequest) (*api.ScanResponse, error) {
+
+	items, err := clientApi.policyClient.Scan(state.ScanRequest{
+		Prefix:    request.Prefix,
+		Start:     request.Start,
+		End:       request.End,
+		Limit:     int(request.Limit),
+		Reverse:   request.Reverse,
+		Namespace: request.Namespace,
+	})
+
+	response := &api.ScanResponse{
+		Status:      api.ClientStatusCodes_SCAN_ERROR,
+		IsRetryable: api.RetryCodes_NO,
+	}
+
+	if err == nil {
+
+		response.Status = api.ClientStatusCodes_SCAN_OK
+
+		for _, item := range items {
+			response.Items = append(response.Items, &api.Item{Key: item.Key, Value: item.Data, Namespace: request.Namespace})
+		}
+	} else if err != client.ErrInvalidScanRequest && err != client.ErrNotSupported {
+		response.IsRetryable, err = api.RetryCodes_YES, ErrScanRetryable
+	}
+
+	return response, err
+}
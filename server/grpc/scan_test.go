@@ -0,0 +1,79 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+func TestWhenRangeIsScannedThenTheRequestIsHandedToTheSupportingPolicyService(t *testing.T) {
+
+	spy := client.NewSpy()
+
+	request := &api.ScanRequest{Prefix: "user/", Start: "user/b", End: "user/x", Limit: 2, Reverse: true}
+
+	_, _ = setup(spy).Scan(context.Background(), request)
+
+	expected := state.ScanRequest{Prefix: "user/", Start: "user/b", End: "user/x", Limit: 2, Reverse: true}
+
+	if expected != spy.LastScan() {
+		t.Errorf("Scan should have been handed to the policy service as %+v but was %+v", expected, spy.LastScan())
+	}
+}
+
+func TestWhenRangeIsScannedThenTheStatusIsScanOk(t *testing.T) {
+
+	spy := client.NewSpy()
+
+	response, _ := setup(spy).Scan(context.Background(), &api.ScanRequest{})
+
+	if api.ClientStatusCodes_SCAN_OK != response.Status {
+		t.Errorf("ScanResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_SCAN_OK,
+			response.Status)
+	}
+}
+
+func TestWhenRangeIsScannedThenEachItemIsReturnedInTheOrderScanned(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ScanReturns([]state.KeyValItem{{Key: "b", Data: []byte("2")}, {Key: "a", Data: []byte("1")}})
+
+	response, _ := setup(spy).Scan(context.Background(), &api.ScanRequest{Reverse: true})
+
+	var keys []string
+
+	for _, item := range response.Items {
+		keys = append(keys, item.Key)
+	}
+
+	if len(keys) != 2 || keys[0] != "b" || keys[1] != "a" {
+		t.Errorf("Expected the items with keys [b a] but got items with keys %v", keys)
+	}
+}
+
+func TestWhenNamespaceIsScannedThenTheNamespaceIsHandedToTheSupportingPolicyService(t *testing.T) {
+
+	spy := client.NewSpy()
+
+	_, _ = setup(spy).Scan(context.Background(), &api.ScanRequest{Namespace: "team-a"})
+
+	if spy.LastScan().Namespace != "team-a" {
+		t.Errorf("Scan should have been handed to the policy service for namespace team-a but was %+v", spy.LastScan())
+	}
+}
+
+func TestWhenNamespaceIsScannedThenEachItemCarriesTheNamespaceOfTheRequest(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ScanReturns([]state.KeyValItem{{Key: "a", Data: []byte("1")}})
+
+	response, _ := setup(spy).Scan(context.Background(), &api.ScanRequest{Namespace: "team-a"})
+
+	if len(response.Items) != 1 || response.Items[0].Namespace != "team-a" {
+		t.Errorf("Expected the item to carry namespace team-a but got %+v", response.Items)
+	}
+}
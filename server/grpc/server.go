@@ -4,11 +4,18 @@ package grpc
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/jrobison153/raft/api"
 	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/policy/coordination"
+	"github.com/jrobison153/raft/policy/lease"
+	"github.com/jrobison153/raft/policy/queue"
+	"github.com/jrobison153/raft/policy/upload"
+	"github.com/jrobison153/raft/replication"
 	"github.com/jrobison153/raft/server"
+	"github.com/jrobison153/raft/state"
 	"github.com/jrobison153/raft/telemetry"
 	"google.golang.org/grpc"
 	"log"
@@ -22,23 +29,37 @@ type RaftServer struct {
 
 	server       *grpc.Server
 	policyClient client.Persister
+	lessor       *lease.Lessor
+	locker       *coordination.Locker
+	election     *coordination.Election
+	dispatcher   *queue.Dispatcher
+	reaper       *upload.Reaper
 }
 
 func New(policyClient client.Persister) *RaftServer {
 
+	lessor := lease.NewLessor(policyClient, lease.NewDefaultConfig())
+	locker := coordination.NewLocker(policyClient, lessor)
+
 	return &RaftServer{
 		policyClient: policyClient,
+		lessor:       lessor,
+		locker:       locker,
+		election:     coordination.NewElection(locker),
+		dispatcher:   queue.NewDispatcher(policyClient, queue.NewDefaultConfig()),
+		reaper:       upload.NewReaper(policyClient, upload.NewDefaultConfig()),
 	}
 }
 
 var (
 	ErrPutRetryable      = errors.New("unable to save data, the operation is safe to retry")
+	ErrDeleteRetryable   = errors.New("unable to delete data, the operation is safe to retry")
 	ErrGetGeneralFailure = errors.New("unable to get data")
 	ErrGetEmptyKey       = errors.New("invalid key, key must not be the empty string")
 )
 
 // Start starts the gRPC server listening on the specified port. Any error encountered during start
-// will be fatal.
+// will be fatal
 func (clientApi *RaftServer) Start(port uint32) {
 
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
@@ -69,8 +90,40 @@ func (clientApi *RaftServer) Start(port uint32) {
 	}
 }
 
+// FollowLeadership expires leases, redelivers queue messages whose visibility timeout has passed and discards the
+// chunks of abandoned uploads while this node is the leader, as reported by changes, and stops once it is not. Only
+// the leader tracks the deadlines, a follower would revoke leases, redeliver messages and discard uploads the leader
+// is still tracking
+func (clientApi *RaftServer) FollowLeadership(changes <-chan bool) {
+
+	go func() {
+
+		isLeading := false
+
+		for isLeader := range changes {
+
+			if isLeader && !isLeading {
+
+				clientApi.lessor.Promote(replication.NewSleepTimer())
+				clientApi.dispatcher.Promote(replication.NewSleepTimer())
+				clientApi.reaper.Promote(replication.NewSleepTimer())
+			} else if !isLeader && isLeading {
+
+				clientApi.lessor.Demote()
+				clientApi.dispatcher.Demote()
+				clientApi.reaper.Demote()
+			}
+
+			isLeading = isLeader
+		}
+	}()
+}
+
 func (clientApi *RaftServer) Stop() {
 
+	clientApi.lessor.Demote()
+	clientApi.dispatcher.Demote()
+	clientApi.reaper.Demote()
 	clientApi.server.Stop()
 	log.Println("client API `server stopped")
 }
@@ -84,10 +137,25 @@ func (clientApi *RaftServer) Health(context.Context, *api.Empty) (*api.HealthRes
 	return response, nil
 }
 
-// PutItem is a gRPC controller function used to store, "Put", an Item to the Raft cluster.
+// PutItem is a gRPC controller function used to store, "Put", an Item to the Raft cluster. Conditional puts report
+// CONDITION_FAILED, without an error, when their condition does not hold
 func (clientApi *RaftServer) PutItem(ctx context.Context, item *api.Item) (*api.PutItemResponse, error) {
 
-	replicationCh, preReplicationErr := clientApi.policyClient.Put(item.Data)
+	var response *api.PutItemResponse
+	var putErr error
+
+	if item.Condition != api.Condition_NONE {
+		response, putErr = clientApi.conditionalPutItem(item)
+	} else {
+		response, putErr = clientApi.unconditionalPutItem(item)
+	}
+
+	return response, putErr
+}
+
+func (clientApi *RaftServer) unconditionalPutItem(item *api.Item) (*api.PutItemResponse, error) {
+
+	replicationCh, preReplicationErr := clientApi.proposePut(item)
 
 	var putErr error
 
@@ -104,6 +172,10 @@ func (clientApi *RaftServer) PutItem(ctx context.Context, item *api.Item) (*api.
 			response = createReplFailResponse()
 			putErr = ErrPutRetryable
 		}
+	} else if status, isRejected := namespaceRejection(preReplicationErr); isRejected {
+
+		response = &api.PutItemResponse{Status: status, IsRetryable: api.RetryCodes_NO}
+		putErr = preReplicationErr
 	} else {
 
 		response = createPreReplFailureResponse()
@@ -113,28 +185,125 @@ func (clientApi *RaftServer) PutItem(ctx context.Context, item *api.Item) (*api.
 	return response, putErr
 }
 
-// GetItem is a gRPC controller function used to retrieve, "Get", an Item from the Raft cluster.
+// GetItem is a gRPC controller function used to retrieve, "Get", an Item from the Raft cluster. The response carries
+// the key version and indexes, an Item with an AtIndex is read as it was at that journal index
 func (clientApi *RaftServer) GetItem(ctx context.Context, item *api.Item) (*api.GetItemResponse, error) {
 
 	var responseErr error
 	var response *api.GetItemResponse
 
-	data, err := clientApi.policyClient.Get(item.Data)
+	versioned, err := clientApi.policyClient.GetVersioned(keyRequest(item))
 
 	if err != nil {
 		response, responseErr = createGeneralGetFailResponse()
 	} else {
-		response = createSuccessResponse(data)
+		response = createSuccessResponse(versioned)
 	}
 
 	return response, responseErr
 }
 
+// DeleteItem is a gRPC controller function used to remove a key, "Delete", from the Raft cluster. The Item data is
+// the key to delete in the same format used by GetItem. Conditional deletes report CONDITION_FAILED, without an
+// error, when their condition does not hold
+func (clientApi *RaftServer) DeleteItem(ctx context.Context, item *api.Item) (*api.DeleteItemResponse, error) {
+
+	var response *api.DeleteItemResponse
+	var deleteErr error
+
+	if item.Condition != api.Condition_NONE {
+		response, deleteErr = clientApi.conditionalDeleteItem(item)
+	} else {
+		response, deleteErr = clientApi.unconditionalDeleteItem(item)
+	}
+
+	return response, deleteErr
+}
+
+func (clientApi *RaftServer) unconditionalDeleteItem(item *api.Item) (*api.DeleteItemResponse, error) {
+
+	replicationCh, preReplicationErr := clientApi.proposeDelete(item)
+
+	response := &api.DeleteItemResponse{
+		Status:      api.ClientStatusCodes_DELETE_ERROR,
+		IsRetryable: api.RetryCodes_YES,
+	}
+
+	deleteErr := ErrDeleteRetryable
+
+	if status, isRejected := namespaceRejection(preReplicationErr); isRejected {
+
+		response.Status, response.IsRetryable = status, api.RetryCodes_NO
+		deleteErr = preReplicationErr
+	} else if preReplicationErr == nil {
+
+		if <-replicationCh {
+
+			response.ReplicationStatus = api.ReplicationCodes_QUORUM_REACHED
+			response.Status = api.ClientStatusCodes_DELETE_OK
+			response.IsRetryable = api.RetryCodes_NO
+			deleteErr = nil
+		} else {
+			response.ReplicationStatus = api.ReplicationCodes_FAILURE_TO_REACH_QUORUM
+		}
+	}
+
+	return response, deleteErr
+}
+
 func (clientApi *RaftServer) PolicyClient() client.Persister {
 
 	return clientApi.policyClient
 }
 
+// proposePut stores the item key and value in the item namespace when the key is set, otherwise item data is a
+// KeyValItem request
+func (clientApi *RaftServer) proposePut(item *api.Item) (chan bool, error) {
+
+	var replicationCh chan bool
+	var err error
+
+	if item.Key != "" {
+		command := state.NewPutCommand(item.Key, item.Value)
+		command.Lease, command.Namespace = item.Lease, item.Namespace
+		replicationCh, err = clientApi.policyClient.Propose(command)
+	} else {
+		replicationCh, err = clientApi.policyClient.Put(item.Data)
+	}
+
+	return replicationCh, err
+}
+
+// proposeDelete deletes the item key from the item namespace when it is set, otherwise item data is a Key request
+func (clientApi *RaftServer) proposeDelete(item *api.Item) (chan bool, error) {
+
+	var replicationCh chan bool
+	var err error
+
+	if item.Key != "" {
+		command := state.NewDeleteCommand(item.Key)
+		command.Namespace = item.Namespace
+		replicationCh, err = clientApi.policyClient.Propose(command)
+	} else {
+		replicationCh, err = clientApi.policyClient.Delete(item.Data)
+	}
+
+	return replicationCh, err
+}
+
+// keyRequest returns the Key request for the item key and namespace when the key is set, otherwise the item data is
+// the request
+func keyRequest(item *api.Item) []byte {
+
+	request := item.Data
+
+	if item.Key != "" {
+		request, _ = json.Marshal(state.Key{Key: item.Key, AtIndex: item.AtIndex, Namespace: item.Namespace})
+	}
+
+	return request
+}
+
 func createPreReplFailureResponse() *api.PutItemResponse {
 
 	response := &api.PutItemResponse{}
@@ -167,14 +336,17 @@ func createReplSuccessResponse() *api.PutItemResponse {
 	return response
 }
 
-func createSuccessResponse(data []byte) *api.GetItemResponse {
+func createSuccessResponse(versioned state.VersionedItem) *api.GetItemResponse {
 
 	response := &api.GetItemResponse{
 		Item: &api.Item{
-			Data: data,
+			Data: versioned.Value,
 		},
 		IsRetryable: api.RetryCodes_YES,
 		Status:      api.ClientStatusCodes_GET_OK,
+		Version:     versioned.Version,
+		CreateIndex: versioned.CreateIndex,
+		ModifyIndex: versioned.ModifyIndex,
 	}
 
 	return response
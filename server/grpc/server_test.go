@@ -5,6 +5,8 @@ import (
 	"errors"
 	"github.com/jrobison153/raft/api"
 	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -215,6 +217,56 @@ func TestWhenGettingItemAndThereIsAnErrorThenErrorMessageSetCorrectly(t *testing
 	}
 }
 
+func TestWhenGettingItemThenTheKeyVersionIsReturned(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.GetReturns(state.VersionedItem{Value: []byte("theValue"), Version: 3, CreateIndex: 4, ModifyIndex: 9})
+
+	response, _ := setup(spy).GetItem(context.Background(), &api.Item{Key: "theKey"})
+
+	if response.Version != 3 {
+		t.Errorf("GetItemResponse should have carried key version 3 but got %d", response.Version)
+	}
+}
+
+func TestWhenGettingItemThenTheCreateIndexIsReturned(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.GetReturns(state.VersionedItem{Value: []byte("theValue"), Version: 3, CreateIndex: 4, ModifyIndex: 9})
+
+	response, _ := setup(spy).GetItem(context.Background(), &api.Item{Key: "theKey"})
+
+	if response.CreateIndex != 4 {
+		t.Errorf("GetItemResponse should have carried create index 4 but got %d", response.CreateIndex)
+	}
+}
+
+func TestWhenGettingItemThenTheModifyIndexIsReturned(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.GetReturns(state.VersionedItem{Value: []byte("theValue"), Version: 3, CreateIndex: 4, ModifyIndex: 9})
+
+	response, _ := setup(spy).GetItem(context.Background(), &api.Item{Key: "theKey"})
+
+	if response.ModifyIndex != 9 {
+		t.Errorf("GetItemResponse should have carried modify index 9 but got %d", response.ModifyIndex)
+	}
+}
+
+func TestWhenGettingItemAtAnIndexThenTheIndexIsPartOfTheKeyRequest(t *testing.T) {
+
+	spy := client.NewSpy()
+	atIndex := uint64(7)
+
+	_, _ = setup(spy).GetItem(context.Background(), &api.Item{Key: "theKey", AtIndex: &atIndex})
+
+	expectedRequest := `{"Key":"theKey","AtIndex":7}`
+
+	if expectedRequest != string(spy.LastGetRequest()) {
+		t.Errorf("Expected key request '%s' but got '%s'", expectedRequest, spy.LastGetRequest())
+	}
+}
+
 func TestWhenGettingItemThatHasBeenPreviouslyPutThenTheItemIsReturnedInTheResponse(t *testing.T) {
 
 	testContext := setupForGet(NoFailures)
@@ -254,6 +306,197 @@ func TestWhenGettingItemThatHasBeenPreviouslyPutThenTheRetryStatusIsSetCorrectly
 	}
 }
 
+func TestWhenItemIsDeletedThenTheKeyIsHandedToTheSupportingPolicyService(t *testing.T) {
+
+	testContext := setupForDelete(NoFailures)
+
+	if string(testContext.ClientPolicySpy.LastDeletedItem()) != expectedData {
+		t.Errorf("Deleted key should have been handed to underlying policy service, expected '%s' but saw '%s'",
+			expectedData,
+			testContext.ClientPolicySpy.LastDeletedItem())
+	}
+}
+
+func TestWhenDeleteCompletesSuccessfullyThenStatusIsDeleteOk(t *testing.T) {
+
+	testContext := setupForDelete(NoFailures)
+
+	status := testContext.DeleteItemResponse.Status
+
+	if api.ClientStatusCodes_DELETE_OK != status {
+		t.Errorf("DeleteItem should have returned with Status '%v', instead we got '%v'",
+			api.ClientStatusCodes_DELETE_OK,
+			status)
+	}
+}
+
+func TestWhenDeleteCompletesSuccessfullyThenReplicationStatusIsSetToSuccessValue(t *testing.T) {
+
+	testContext := setupForDelete(NoFailures)
+
+	replStatus := testContext.DeleteItemResponse.ReplicationStatus
+
+	if api.ReplicationCodes_QUORUM_REACHED != replStatus {
+		t.Errorf("DeleteItem should have returned replication status of '%v' but instead we got '%v'",
+			api.ReplicationCodes_QUORUM_REACHED,
+			replStatus)
+	}
+}
+
+func TestWhenDeleteCompletesSuccessfullyThenRetryStatusIsSetToNo(t *testing.T) {
+
+	testContext := setupForDelete(NoFailures)
+
+	isRetryable := testContext.DeleteItemResponse.IsRetryable
+
+	if api.RetryCodes_NO != isRetryable {
+		t.Errorf("DeleteItem should have returned with retryable code '%v', instead we got '%v'",
+			api.RetryCodes_NO,
+			isRetryable)
+	}
+}
+
+func TestWhenDeleteCompletesSuccessfullyThenNoErrorIsReturned(t *testing.T) {
+
+	testContext := setupForDelete(NoFailures)
+
+	if testContext.DeleteErr != nil {
+		t.Errorf("DeleteItem should have completed without error but got '%v'", testContext.DeleteErr)
+	}
+}
+
+func TestWhenDeleteFailsWithAnErrorThenTheCorrectErrorIsReturned(t *testing.T) {
+
+	testContext := setupForDelete(GeneralFailure)
+
+	if !errors.Is(testContext.DeleteErr, ErrDeleteRetryable) {
+		t.Errorf("DeleteItem should have returned an error '%v', instead we got '%v'",
+			ErrDeleteRetryable,
+			testContext.DeleteErr)
+	}
+}
+
+func TestWhenDeleteFailsWithAnErrorThenTheStatusIsDeleteError(t *testing.T) {
+
+	testContext := setupForDelete(GeneralFailure)
+
+	status := testContext.DeleteItemResponse.Status
+
+	if api.ClientStatusCodes_DELETE_ERROR != status {
+		t.Errorf("DeleteItem should have returned with Status '%v', instead we got '%v'",
+			api.ClientStatusCodes_DELETE_ERROR,
+			status)
+	}
+}
+
+func TestWhenDeleteFailsWithAnErrorThenTheRetryStatusIsSetToYes(t *testing.T) {
+
+	testContext := setupForDelete(GeneralFailure)
+
+	isRetryable := testContext.DeleteItemResponse.IsRetryable
+
+	if api.RetryCodes_YES != isRetryable {
+		t.Errorf("DeleteItem should have returned with retryable code '%v', instead we got '%v'",
+			api.RetryCodes_YES,
+			isRetryable)
+	}
+}
+
+func TestWhenDeleteReplicationFailsThenReplicationStatusIsSetToFailureValue(t *testing.T) {
+
+	testContext := setupForDelete(ReplicationFailure)
+
+	replStatus := testContext.DeleteItemResponse.ReplicationStatus
+
+	if api.ReplicationCodes_FAILURE_TO_REACH_QUORUM != replStatus {
+		t.Errorf("DeleteItem should have returned replication status of '%v' but instead we got '%v'",
+			api.ReplicationCodes_FAILURE_TO_REACH_QUORUM,
+			replStatus)
+	}
+}
+
+func TestWhenDeleteReplicationFailsThenTheStatusIsDeleteError(t *testing.T) {
+
+	testContext := setupForDelete(ReplicationFailure)
+
+	status := testContext.DeleteItemResponse.Status
+
+	if api.ClientStatusCodes_DELETE_ERROR != status {
+		t.Errorf("DeleteItem should have returned delete status of '%v' but instead we got '%v'",
+			api.ClientStatusCodes_DELETE_ERROR,
+			status)
+	}
+}
+
+func TestWhenDeleteReplicationFailsThenTheCorrectErrorIsReturned(t *testing.T) {
+
+	testContext := setupForDelete(ReplicationFailure)
+
+	if !errors.Is(testContext.DeleteErr, ErrDeleteRetryable) {
+		t.Errorf("Expected error '%v' but got '%v'", ErrDeleteRetryable, testContext.DeleteErr)
+	}
+}
+
+func TestWhenItemIsPutWithAKeyThenAPutCommandIsProposedToThePolicyService(t *testing.T) {
+
+	spy := client.NewSpy()
+
+	response, err := setup(spy).PutItem(context.Background(), &api.Item{Key: "theKey", Value: []byte("theValue")})
+
+	command := spy.LastProposedCommand()
+
+	if err != nil || api.ClientStatusCodes_PUT_OK != response.Status ||
+		!reflect.DeepEqual(state.NewPutCommand("theKey", []byte("theValue")), command) {
+
+		t.Errorf("Expected put command for 'theKey' to be proposed but got %+v and error '%v'", command, err)
+	}
+}
+
+func TestWhenItemIsDeletedWithAKeyThenADeleteCommandIsProposedToThePolicyService(t *testing.T) {
+
+	spy := client.NewSpy()
+
+	_, err := setup(spy).DeleteItem(context.Background(), &api.Item{Key: "theKey"})
+
+	if err != nil || !reflect.DeepEqual(state.NewDeleteCommand("theKey"), spy.LastProposedCommand()) {
+		t.Errorf("Expected delete command for 'theKey' to be proposed but got %+v and error '%v'",
+			spy.LastProposedCommand(),
+			err)
+	}
+}
+
+func TestWhenNodeBecomesTheLeaderThenLeasesAndQueuesAreTrackedUntilItStepsDown(t *testing.T) {
+
+	server := setup(client.NewSpy())
+
+	changes := make(chan bool)
+	defer close(changes)
+
+	server.FollowLeadership(changes)
+
+	// changes is unbuffered, a change has been followed once the next one is received
+	changes <- true
+	changes <- true
+
+	isLeadingOnceLeader := server.lessor.IsLeading() && server.dispatcher.IsLeading()
+
+	changes <- false
+	changes <- false
+
+	if !isLeadingOnceLeader || server.lessor.IsLeading() || server.dispatcher.IsLeading() {
+		t.Errorf("Leases and queues should have been tracked only while the node was the leader")
+	}
+}
+
+func TestWhenServerIsCreatedThenLeasesAndQueuesAreNotTrackedUntilTheNodeLeads(t *testing.T) {
+
+	server := setup(client.NewSpy())
+
+	if server.lessor.IsLeading() || server.dispatcher.IsLeading() {
+		t.Errorf("Leases and queues should not be tracked before the node is the leader")
+	}
+}
+
 func setup(spy *client.Spy) *RaftServer {
 
 	server := New(spy)
@@ -267,6 +510,9 @@ type TestContext struct {
 	ClientPolicySpy *client.Spy
 	PutErr          error
 	GetErr          error
+
+	DeleteItemResponse *api.DeleteItemResponse
+	DeleteErr          error
 }
 
 func setupForPut(scenario int) *TestContext {
@@ -324,3 +570,26 @@ func setupForGet(scenario int) *TestContext {
 
 	return testContext
 }
+
+func setupForDelete(scenario int) *TestContext {
+
+	clientPolicySpy := client.NewSpy()
+
+	if scenario == GeneralFailure {
+		clientPolicySpy.FailNextDelete()
+	}
+
+	if scenario == ReplicationFailure {
+		clientPolicySpy.FailReplication()
+	}
+
+	server := setup(clientPolicySpy)
+
+	response, deleteErr := server.DeleteItem(context.Background(), &api.Item{Data: []byte(expectedData)})
+
+	return &TestContext{
+		DeleteItemResponse: response,
+		ClientPolicySpy:    clientPolicySpy,
+		DeleteErr:          deleteErr,
+	}
+}
@@ -0,0 +1,105 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+var ErrTxnRetryable = errors.New("unable to apply transaction, the operation is safe to retry")
+
+var txnOps = map[api.OpType]state.CommandOp{
+	api.OpType_PUT:    state.OpPut,
+	api.OpType_DELETE: state.OpDelete,
+	api.OpType_GET:    state.OpGet,
+}
+
+// Txn is a gRPC controller function used to apply a multi key transaction atomically. A transaction whose compares
+// did not hold still reports TXN_OK, with succeeded false and the results of the failure operations
+func (clientApi *RaftServer) Txn(ctx context.Context, request *api.TxnRequest) (*api.TxnResponse, error) {
+
+	command := state.NewTxnCommand(newTxn(request))
+	command.Namespace = request.Namespace
+
+	result, err := clientApi.proposeWithResult(command)
+
+	rejectedStatus, isRejected := namespaceRejection(err)
+
+	response := &api.TxnResponse{
+		Status:            api.ClientStatusCodes_TXN_ERROR,
+		IsRetryable:       api.RetryCodes_YES,
+		ReplicationStatus: api.ReplicationCodes_QUORUM_REACHED,
+	}
+
+	if err == nil {
+
+		response.Status, response.IsRetryable = api.ClientStatusCodes_TXN_OK, api.RetryCodes_NO
+		response.Succeeded = result.Applied
+		response.Responses = newResponseOps(result.Responses)
+	} else if isRejected {
+		response.Status, response.IsRetryable = rejectedStatus, api.RetryCodes_NO
+	} else if err == client.ErrCommitFailed {
+		response.ReplicationStatus = api.ReplicationCodes_FAILURE_TO_REACH_QUORUM
+	}
+
+	if err != nil && !isRejected {
+		err = ErrTxnRetryable
+	}
+
+	return response, err
+}
+
+func newTxn(request *api.TxnRequest) state.Txn {
+
+	txn := state.Txn{
+		Success: newTxnOps(request.Success),
+		Failure: newTxnOps(request.Failure),
+	}
+
+	for _, compare := range request.Compare {
+
+		txn.Compares = append(txn.Compares, state.Compare{
+			Key:     compare.Key,
+			Target:  state.CompareTarget(compare.Target),
+			Result:  state.CompareResult(compare.Result),
+			Value:   compare.Value,
+			Version: compare.Version,
+		})
+	}
+
+	return txn
+}
+
+// newTxnOps converts request operations, an unknown operation type is left unspecified and fails validation
+func newTxnOps(requestOps []*api.RequestOp) []state.TxnOp {
+
+	ops := make([]state.TxnOp, 0, len(requestOps))
+
+	for _, requestOp := range requestOps {
+		ops = append(ops, state.TxnOp{Op: txnOps[requestOp.Op], Key: requestOp.Key, Value: requestOp.Value})
+	}
+
+	return ops
+}
+
+func newResponseOps(results []state.TxnOpResult) []*api.ResponseOp {
+
+	responses := make([]*api.ResponseOp, 0, len(results))
+
+	for _, result := range results {
+
+		responses = append(responses, &api.ResponseOp{
+			Key:         result.Key,
+			Value:       result.Value,
+			Version:     result.Version,
+			Found:       result.Found,
+			CreateIndex: result.CreateIndex,
+			ModifyIndex: result.ModifyIndex,
+		})
+	}
+
+	return responses
+}
@@ -0,0 +1,207 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+)
+
+func TestWhenTxnIsRequestedThenASingleTxnCommandIsProposed(t *testing.T) {
+
+	spy := client.NewSpy()
+
+	_, _ = setup(spy).Txn(context.Background(), newTxnRequest())
+
+	if spy.LastProposedCommand().Op != state.OpTxn || spy.LastProposedCommand().Txn == nil {
+		t.Errorf("Expected a transaction command to be proposed but got %+v", spy.LastProposedCommand())
+	}
+}
+
+func TestWhenTxnIsRequestedThenItsComparesAreProposed(t *testing.T) {
+
+	spy := client.NewSpy()
+
+	_, _ = setup(spy).Txn(context.Background(), newTxnRequest())
+
+	expected := state.Compare{Key: "a", Target: state.CompareVersion, Result: state.CompareLess, Version: 3}
+
+	if !reflect.DeepEqual([]state.Compare{expected}, spy.LastProposedCommand().Txn.Compares) {
+		t.Errorf("Expected compares %+v to be proposed but got %+v", expected, spy.LastProposedCommand().Txn.Compares)
+	}
+}
+
+func TestWhenTxnIsRequestedThenItsSuccessOperationsAreProposed(t *testing.T) {
+
+	spy := client.NewSpy()
+
+	_, _ = setup(spy).Txn(context.Background(), newTxnRequest())
+
+	success := spy.LastProposedCommand().Txn.Success
+
+	if len(success) != 1 || success[0].Op != state.OpPut {
+		t.Errorf("Expected a put to be proposed on success but got %+v", success)
+	}
+}
+
+func TestWhenTxnIsRequestedThenItsFailureOperationsAreProposed(t *testing.T) {
+
+	spy := client.NewSpy()
+
+	_, _ = setup(spy).Txn(context.Background(), newTxnRequest())
+
+	failure := spy.LastProposedCommand().Txn.Failure
+
+	if len(failure) != 1 || failure[0].Op != state.OpGet {
+		t.Errorf("Expected a get to be proposed on failure but got %+v", failure)
+	}
+}
+
+func TestWhenTxnIsAppliedThenStatusIsTxnOk(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true, Responses: []state.TxnOpResult{{Key: "a", Version: 2, Found: true}}})
+
+	response, _ := setup(spy).Txn(context.Background(), &api.TxnRequest{})
+
+	if api.ClientStatusCodes_TXN_OK != response.Status {
+		t.Errorf("TxnResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_TXN_OK,
+			response.Status)
+	}
+}
+
+func TestWhenTxnComparesSucceedThenTheResponseReportsSuccess(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true})
+
+	response, _ := setup(spy).Txn(context.Background(), &api.TxnRequest{})
+
+	if !response.Succeeded {
+		t.Errorf("TxnResponse should have reported the compares succeeded, got %+v", response)
+	}
+}
+
+func TestWhenTxnIsAppliedThenTheOperationResultsAreReturned(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.ApplyWith(state.ApplyResult{Applied: true, Responses: []state.TxnOpResult{{Key: "a", Version: 2, Found: true}}})
+
+	response, _ := setup(spy).Txn(context.Background(), &api.TxnRequest{})
+
+	if len(response.Responses) != 1 || response.Responses[0].Version != 2 {
+		t.Errorf("Expected the result of the one operation at version 2 but got %+v", response.Responses)
+	}
+}
+
+func TestWhenTxnReplicationFailsThenReplicationStatusIsSetToFailureValue(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.FailReplication()
+
+	response, _ := setup(spy).Txn(context.Background(), &api.TxnRequest{})
+
+	if api.ReplicationCodes_FAILURE_TO_REACH_QUORUM != response.ReplicationStatus {
+		t.Errorf("Txn should have returned replication status of '%v' but instead we got '%v'",
+			api.ReplicationCodes_FAILURE_TO_REACH_QUORUM,
+			response.ReplicationStatus)
+	}
+}
+
+func TestWhenTxnReplicationFailsThenTheStatusIsTxnError(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.FailReplication()
+
+	response, _ := setup(spy).Txn(context.Background(), &api.TxnRequest{})
+
+	if api.ClientStatusCodes_TXN_ERROR != response.Status {
+		t.Errorf("Txn should have returned status of '%v' but instead we got '%v'",
+			api.ClientStatusCodes_TXN_ERROR,
+			response.Status)
+	}
+}
+
+func TestWhenTxnReplicationFailsThenTheCorrectErrorIsReturned(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.FailReplication()
+
+	_, err := setup(spy).Txn(context.Background(), &api.TxnRequest{})
+
+	if !errors.Is(err, ErrTxnRetryable) {
+		t.Errorf("Expected error '%v' but got '%v'", ErrTxnRetryable, err)
+	}
+}
+
+func TestWhenTxnNamesANamespaceThenItIsProposedInTheNamespace(t *testing.T) {
+
+	spy := client.NewSpy()
+
+	request := newTxnRequest()
+	request.Namespace = "team-a"
+
+	_, _ = setup(spy).Txn(context.Background(), request)
+
+	if spy.LastProposedCommand().Namespace != "team-a" {
+		t.Errorf("Transaction should have been proposed in namespace team-a but got %+v", spy.LastProposedCommand())
+	}
+}
+
+func TestWhenTxnWouldExceedTheQuotaOfItsNamespaceThenQuotaExceededIsReported(t *testing.T) {
+
+	response, _ := txnOverQuota()
+
+	if api.ClientStatusCodes_QUOTA_EXCEEDED != response.Status {
+		t.Errorf("TxnResponse status should have been %s but instead we got %s",
+			api.ClientStatusCodes_QUOTA_EXCEEDED,
+			response.Status)
+	}
+}
+
+func TestWhenTxnWouldExceedTheQuotaOfItsNamespaceThenErrQuotaExceededIsReturned(t *testing.T) {
+
+	_, err := txnOverQuota()
+
+	if client.ErrQuotaExceeded != err {
+		t.Errorf("Should have received error '%v' but instead got '%v'", client.ErrQuotaExceeded, err)
+	}
+}
+
+func TestWhenTxnWouldExceedTheQuotaOfItsNamespaceThenItIsNotRetryable(t *testing.T) {
+
+	response, _ := txnOverQuota()
+
+	if api.RetryCodes_NO != response.IsRetryable {
+		t.Errorf("Txn should have returned with retryable code '%v', instead we got '%v'",
+			api.RetryCodes_NO,
+			response.IsRetryable)
+	}
+}
+
+func newTxnRequest() *api.TxnRequest {
+
+	return &api.TxnRequest{
+		Compare: []*api.Compare{{Key: "a", Target: api.CompareTarget_VERSION, Result: api.CompareResult_LESS, Version: 3}},
+		Success: []*api.RequestOp{{Op: api.OpType_PUT, Key: "a", Value: []byte("a value")}},
+		Failure: []*api.RequestOp{{Op: api.OpType_GET, Key: "a"}},
+	}
+}
+
+func txnOverQuota() (*api.TxnResponse, error) {
+
+	spy := client.NewSpy()
+	spy.FailNextProposeWith(client.ErrQuotaExceeded)
+
+	request := &api.TxnRequest{
+		Namespace: "team-a",
+		Success:   []*api.RequestOp{{Op: api.OpType_PUT, Key: "a", Value: []byte("a value")}},
+	}
+
+	return setup(spy).Txn(context.Background(), request)
+}
@@ -0,0 +1,77 @@
+package grpc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/state"
+)
+
+var ErrWatchFailed = errors.New("unable to watch, the state machine cannot serve the requested changes")
+
+// Watch is a gRPC controller function streaming the changes made to a key, or every key with a prefix, as the state
+// machine applies them. The stream ends when the client cancels it, or with an error when the watcher falls too far
+// behind or its start index has been compacted. Either way the client may watch again from the index after the last
+// event it received
+func (clientApi *RaftServer) Watch(request *api.WatchRequest, stream api.Persister_WatchServer) error {
+
+	watcher, err := clientApi.policyClient.Watch(state.WatchRequest{
+		Key:        request.Key,
+		Prefix:     request.Prefix,
+		StartIndex: request.StartIndex,
+	})
+
+	if err == nil {
+
+		defer watcher.Cancel()
+
+		err = streamEvents(watcher, stream)
+	} else {
+		err = fmt.Errorf("%w: %v", ErrWatchFailed, err)
+	}
+
+	return err
+}
+
+// streamEvents sends the watcher events to the stream until the stream is cancelled, sending fails or the watcher is
+// dropped
+func streamEvents(watcher *state.Watcher, stream api.Persister_WatchServer) error {
+
+	var err error
+
+	for isStreaming := true; isStreaming && err == nil; {
+
+		select {
+		case <-stream.Context().Done():
+			isStreaming = false
+		case event, isOpen := <-watcher.Events():
+			if isOpen {
+				err = stream.Send(newWatchEvent(event))
+			} else {
+				err = watcher.Err()
+			}
+		}
+	}
+
+	return err
+}
+
+func newWatchEvent(event state.WatchEvent) *api.WatchEvent {
+
+	eventType := api.EventType_EVENT_PUT
+
+	if event.Type == state.EventDelete {
+		eventType = api.EventType_EVENT_DELETE
+	}
+
+	return &api.WatchEvent{
+		Type:        eventType,
+		Index:       event.Index,
+		Key:         event.Key,
+		Value:       event.Value,
+		Version:     event.Version,
+		CreateIndex: event.CreateIndex,
+		ModifyIndex: event.ModifyIndex,
+	}
+}
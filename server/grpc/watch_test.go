@@ -0,0 +1,93 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jrobison153/raft/api"
+	"github.com/jrobison153/raft/policy/client"
+	"github.com/jrobison153/raft/state"
+	"google.golang.org/grpc"
+)
+
+type watchStreamSpy struct {
+	grpc.ServerStream
+	ctx    context.Context
+	cancel context.CancelFunc
+	sent   []*api.WatchEvent
+}
+
+func (stream *watchStreamSpy) Context() context.Context {
+
+	return stream.ctx
+}
+
+// Send records event and cancels the stream once the first event has been sent
+func (stream *watchStreamSpy) Send(event *api.WatchEvent) error {
+
+	stream.sent = append(stream.sent, event)
+	stream.cancel()
+
+	return nil
+}
+
+func TestWhenWatchedKeyChangesThenTheEventIsSentOnTheStream(t *testing.T) {
+
+	sent, _ := watchKeyDeletedAt(4)
+
+	if len(sent) == 0 || sent[0].Type != api.EventType_EVENT_DELETE {
+		t.Errorf("Expected a delete event to be sent but got %+v", sent)
+	}
+}
+
+func TestWhenWatchedKeyChangesThenTheEventCarriesTheIndexOfTheChange(t *testing.T) {
+
+	sent, _ := watchKeyDeletedAt(4)
+
+	if len(sent) == 0 || sent[0].Index != 4 {
+		t.Errorf("Expected the event of the change at index 4 but got %+v", sent)
+	}
+}
+
+func TestWhenWatchingClientGoesAwayThenWatchEndsWithoutError(t *testing.T) {
+
+	_, err := watchKeyDeletedAt(4)
+
+	if err != nil {
+		t.Errorf("Watch should have ended without error but got '%v'", err)
+	}
+}
+
+func TestWhenWatchCannotStartThenErrWatchFailedIsReturned(t *testing.T) {
+
+	spy := client.NewSpy()
+	spy.FailNextWatch()
+
+	err := setup(spy).Watch(&api.WatchRequest{Key: "theKey"}, &watchStreamSpy{ctx: context.Background()})
+
+	if !errors.Is(err, ErrWatchFailed) {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrWatchFailed, err)
+	}
+}
+
+// watchKeyDeletedAt watches theKey while it is deleted at index until an event has been sent, returning the events
+func watchKeyDeletedAt(index uint64) ([]*api.WatchEvent, error) {
+
+	spy := client.NewSpy()
+	stream := &watchStreamSpy{}
+	stream.ctx, stream.cancel = context.WithCancel(context.Background())
+
+	// the watcher is registered by the call under test, keep publishing until the stream has sent an event
+	go func() {
+		for stream.ctx.Err() == nil {
+			spy.PublishWatchEvents(index, []state.WatchEvent{{Type: state.EventDelete, Index: index, Key: "theKey"}})
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	err := setup(spy).Watch(&api.WatchRequest{Key: "theKey"}, stream)
+
+	return stream.sent, err
+}
@@ -1,6 +1,9 @@
 package server
 
+// LifeCycler starts and stops an API server. FollowLeadership has the server take on the duties of the leader while
+// changes last delivered true and give them up when it delivers false
 type LifeCycler interface {
 	Start(port uint32)
 	Stop()
+	FollowLeadership(changes <-chan bool)
 }
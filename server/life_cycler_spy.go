@@ -1,7 +1,8 @@
 package server
 
 type Spy struct {
-	startPort uint32
+	startPort         uint32
+	leadershipChanges <-chan bool
 }
 
 func NewLifeCyclerSpy() *Spy {
@@ -23,3 +24,13 @@ func (spy *Spy) StartCalledOnPort(port uint32) bool {
 
 	return spy.startPort == port
 }
+
+func (spy *Spy) FollowLeadership(changes <-chan bool) {
+
+	spy.leadershipChanges = changes
+}
+
+func (spy *Spy) FollowsLeadership(changes <-chan bool) bool {
+
+	return spy.leadershipChanges == changes
+}
@@ -0,0 +1,127 @@
+package state
+
+import (
+	"errors"
+	"sync"
+)
+
+// DefaultAppliedResultRetention is the number of recent apply results an ApplyRegistry keeps for writers that
+// register after their entry has already been applied
+const DefaultAppliedResultRetention = 1024
+
+var ErrApplyResultExpired = errors.New("journal entry was applied too long ago, its apply result is no longer held")
+
+// ApplyResult is the outcome of applying the journal entry at Index to a state machine. Applied is false when the
+// condition of a conditional command did not hold, the state machine is unchanged. Version is the version of the
+// command key after it was applied, or its current version when the condition failed, 0 if the key does not exist.
+// For transactions Applied is true when the compares held and the success operations were applied and Responses
+// holds the result of each applied operation. Counter is the value of a counter after it was incremented or
+// decremented, for a sequence it is the last ID reserved. Message is the queue message enqueued or delivered. Err is
+// set when the entry could not be applied at all
+type ApplyResult struct {
+	Index     uint64
+	Applied   bool
+	Version   uint64
+	Responses []TxnOpResult
+	Counter   int64
+	Message   QueueMessage
+	Err       error
+}
+
+// ApplyRegistry hands the result of applying a journal entry to a writer waiting on that entry's index. Results
+// are published in index order by the state machine and the most recent are kept so a writer that registers just
+// after its entry was applied still receives the result. ApplyRegistry is safe for concurrent use
+type ApplyRegistry struct {
+	mutex        sync.Mutex
+	subscribers  map[uint64]chan ApplyResult
+	results      map[uint64]ApplyResult
+	appliedIndex int64
+	retention    uint64
+	failure      error
+}
+
+func NewApplyRegistry(retention uint64) *ApplyRegistry {
+
+	return &ApplyRegistry{
+		subscribers:  make(map[uint64]chan ApplyResult),
+		results:      make(map[uint64]ApplyResult),
+		appliedIndex: -1,
+		retention:    retention,
+	}
+}
+
+// NotifyOfApplyOnIndexOnce writes the result of applying the entry at index to ch once it has been applied, or
+// straight away if it already has been. ch must be buffered to hold the result, the registry never blocks on a
+// writer that has stopped waiting.
+// Once the registry has failed an entry that has not been applied receives a result with the failure straight away.
+// Returns ErrApplyResultExpired if the entry was applied so long ago its result is no longer held
+func (registry *ApplyRegistry) NotifyOfApplyOnIndexOnce(index uint64, ch chan ApplyResult) error {
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	var err error
+
+	result, isHeld := registry.results[index]
+
+	if isHeld {
+		sendApplyResult(ch, result)
+	} else if int64(index) <= registry.appliedIndex {
+		err = ErrApplyResultExpired
+	} else if registry.failure != nil {
+		sendApplyResult(ch, ApplyResult{Index: index, Err: registry.failure})
+	} else {
+		registry.subscribers[index] = ch
+	}
+
+	return err
+}
+
+// Publish records result and notifies the writer waiting on its index, if there is one
+func (registry *ApplyRegistry) Publish(result ApplyResult) {
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.results[result.Index] = result
+	registry.appliedIndex = int64(result.Index)
+
+	if result.Index >= registry.retention {
+		delete(registry.results, result.Index-registry.retention)
+	}
+
+	ch, isSubscribed := registry.subscribers[result.Index]
+
+	if isSubscribed {
+
+		delete(registry.subscribers, result.Index)
+
+		sendApplyResult(ch, result)
+	}
+}
+
+// Fail hands every waiting writer a result with err, no later entry will be applied. Writers that register for an
+// entry that has not been applied after Fail receive the same result
+func (registry *ApplyRegistry) Fail(err error) {
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.failure = err
+
+	for index, ch := range registry.subscribers {
+
+		delete(registry.subscribers, index)
+
+		sendApplyResult(ch, ApplyResult{Index: index, Err: err})
+	}
+}
+
+// sendApplyResult hands result to ch without blocking, a result a full channel has no room for is dropped
+func sendApplyResult(ch chan ApplyResult, result ApplyResult) {
+
+	select {
+	case ch <- result:
+	default:
+	}
+}
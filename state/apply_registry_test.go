@@ -0,0 +1,82 @@
+package state
+
+import "testing"
+
+func TestWhenResultPublishedThenTheWriterWaitingOnItsIndexReceivesIt(t *testing.T) {
+
+	registry := NewApplyRegistry(DefaultAppliedResultRetention)
+
+	resultCh := make(chan ApplyResult, 1)
+	_ = registry.NotifyOfApplyOnIndexOnce(3, resultCh)
+
+	registry.Publish(ApplyResult{Index: 3, Applied: true, Version: 7})
+
+	if result := <-resultCh; !result.Applied || result.Version != 7 {
+		t.Errorf("Expected the published result but got %+v", result)
+	}
+}
+
+func TestWhenWriterRegistersAfterItsIndexWasAppliedThenItStillReceivesTheResult(t *testing.T) {
+
+	registry := NewApplyRegistry(DefaultAppliedResultRetention)
+
+	registry.Publish(ApplyResult{Index: 0, Applied: false, Version: 2})
+
+	resultCh := make(chan ApplyResult, 1)
+	err := registry.NotifyOfApplyOnIndexOnce(0, resultCh)
+
+	if result := <-resultCh; err != nil || result.Applied || result.Version != 2 {
+		t.Errorf("Expected the held result but got %+v and error '%v'", result, err)
+	}
+}
+
+func TestWhenResultIsNoLongerHeldThenRegisteringForItFails(t *testing.T) {
+
+	registry := NewApplyRegistry(2)
+
+	for index := uint64(0); index < 5; index++ {
+		registry.Publish(ApplyResult{Index: index, Applied: true})
+	}
+
+	err := registry.NotifyOfApplyOnIndexOnce(1, make(chan ApplyResult, 1))
+
+	if ErrApplyResultExpired != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrApplyResultExpired, err)
+	}
+}
+
+func TestWhenWriterHasStoppedWaitingThenPublishDoesNotBlock(t *testing.T) {
+
+	registry := NewApplyRegistry(DefaultAppliedResultRetention)
+
+	resultCh := make(chan ApplyResult, 1)
+	resultCh <- ApplyResult{Index: 9}
+
+	_ = registry.NotifyOfApplyOnIndexOnce(3, resultCh)
+
+	registry.Publish(ApplyResult{Index: 3, Applied: true})
+
+	if result := <-resultCh; result.Index != 9 {
+		t.Errorf("Expected the undelivered result to be dropped but got %+v", result)
+	}
+}
+
+func TestWhenRegistryFailsThenWaitingAndLaterWritersReceiveTheFailure(t *testing.T) {
+
+	registry := NewApplyRegistry(DefaultAppliedResultRetention)
+
+	waitingCh := make(chan ApplyResult, 1)
+	_ = registry.NotifyOfApplyOnIndexOnce(3, waitingCh)
+
+	registry.Fail(ErrCorruptionAlarm)
+
+	laterCh := make(chan ApplyResult, 1)
+	err := registry.NotifyOfApplyOnIndexOnce(4, laterCh)
+
+	waiting, later := <-waitingCh, <-laterCh
+
+	if err != nil || ErrCorruptionAlarm != waiting.Err || ErrCorruptionAlarm != later.Err || later.Index != 4 {
+		t.Errorf("Expected both writers to receive '%v' but got %+v, %+v and error '%v'", ErrCorruptionAlarm,
+			waiting, later, err)
+	}
+}
@@ -0,0 +1,560 @@
+package state
+
+import "sort"
+
+const defaultBTreeDegree = 32
+
+// bTree is an in memory B-Tree of key value items ordered by key. Every node other than the root holds between
+// degree - 1 and 2 * degree - 1 items.
+// Trees support cheap copy on write clones, clone returns a tree sharing all nodes with the original and a node
+// is only copied the first time either tree writes to it. Each tree stamps the nodes it owns with its
+// copyOnWriteContext, a node stamped with another context is shared and must be copied before it is modified.
+// bTree is not safe for concurrent use but a clone may be read while the original is written
+type bTree struct {
+	degree int
+	length int
+	root   *bTreeNode
+	cow    *copyOnWriteContext
+}
+
+type bTreeItem struct {
+	key string
+	versionedValue
+}
+
+type bTreeNode struct {
+	items    []bTreeItem
+	children []*bTreeNode
+	cow      *copyOnWriteContext
+}
+
+// copyOnWriteContext identifies the tree owning a node, only its address matters but a zero sized type could
+// share an address with other zero sized values
+type copyOnWriteContext struct {
+	_ byte
+}
+
+func newBTree(degree int) *bTree {
+
+	return &bTree{
+		degree: degree,
+		cow:    &copyOnWriteContext{},
+	}
+}
+
+// clone returns a copy of the tree in constant time, both trees stop owning the existing nodes so whichever writes
+// to a node first copies it
+func (tree *bTree) clone() *bTree {
+
+	cloned := *tree
+
+	tree.cow = &copyOnWriteContext{}
+	cloned.cow = &copyOnWriteContext{}
+
+	return &cloned
+}
+
+func (tree *bTree) get(key string) ([]byte, bool) {
+
+	item, found := tree.lookup(key)
+
+	return item.value, found
+}
+
+func (tree *bTree) lookup(key string) (bTreeItem, bool) {
+
+	var item bTreeItem
+	found := false
+
+	for node := tree.root; node != nil && !found; {
+
+		var position int
+		position, found = node.find(key)
+
+		if found {
+			item = node.items[position]
+		} else {
+			node = node.childAt(position)
+		}
+	}
+
+	return item, found
+}
+
+// put stores value under key, replacing any existing value
+func (tree *bTree) put(key string, value []byte) {
+
+	tree.putItem(bTreeItem{key: key, versionedValue: versionedValue{value: value}})
+}
+
+// putItem stores item, replacing any existing item with the same key
+func (tree *bTree) putItem(item bTreeItem) {
+
+	if tree.root == nil {
+		tree.root = tree.newNode()
+	}
+
+	tree.root = tree.root.mutableFor(tree.cow)
+
+	if len(tree.root.items) >= tree.maxItems() {
+		tree.growRoot()
+	}
+
+	replaced := tree.root.insert(item, tree.maxItems())
+
+	if !replaced {
+		tree.length += 1
+	}
+}
+
+// delete removes key from the tree, returning true if it was present. Nodes left with too few items borrow from a
+// sibling or are merged with one on the way down so the tree stays balanced
+func (tree *bTree) delete(key string) bool {
+
+	removed := false
+
+	if tree.root != nil {
+
+		tree.root = tree.root.mutableFor(tree.cow)
+
+		_, removed = tree.root.remove(key, tree.minItems(), false)
+
+		tree.shrinkRoot()
+	}
+
+	if removed {
+		tree.length -= 1
+	}
+
+	return removed
+}
+
+// ascend visits, in key order, the items with start <= key < end until visit returns false. An empty end is
+// unbounded
+func (tree *bTree) ascend(start string, end string, visit func(item bTreeItem) bool) {
+
+	if tree.root != nil {
+		tree.root.ascend(start, end, visit)
+	}
+}
+
+// descend visits, in reverse key order, the items with start <= key < end until visit returns false. An empty end
+// is unbounded
+func (tree *bTree) descend(start string, end string, visit func(item bTreeItem) bool) {
+
+	if tree.root != nil {
+		tree.root.descend(start, end, visit)
+	}
+}
+
+func (tree *bTree) maxItems() int {
+
+	return tree.degree*2 - 1
+}
+
+func (tree *bTree) minItems() int {
+
+	return tree.degree - 1
+}
+
+// shrinkRoot drops an empty root left behind by a merge of its last two children, the tree shrinks by one level
+func (tree *bTree) shrinkRoot() {
+
+	if len(tree.root.items) == 0 && !tree.root.isLeaf() {
+		tree.root = tree.root.children[0]
+	}
+}
+
+func (tree *bTree) newNode() *bTreeNode {
+
+	return &bTreeNode{cow: tree.cow}
+}
+
+// growRoot splits a full root, the tree grows by one level
+func (tree *bTree) growRoot() {
+
+	middle, second := tree.root.split(tree.maxItems() / 2)
+
+	oldRoot := tree.root
+
+	tree.root = tree.newNode()
+	tree.root.items = append(tree.root.items, middle)
+	tree.root.children = append(tree.root.children, oldRoot, second)
+}
+
+// find returns the position of the first item with a key >= key and whether that item has exactly key
+func (node *bTreeNode) find(key string) (int, bool) {
+
+	position := sort.Search(len(node.items), func(i int) bool {
+		return node.items[i].key >= key
+	})
+
+	return position, position < len(node.items) && node.items[position].key == key
+}
+
+func (node *bTreeNode) isLeaf() bool {
+
+	return len(node.children) == 0
+}
+
+func (node *bTreeNode) childAt(position int) *bTreeNode {
+
+	var child *bTreeNode
+
+	if !node.isLeaf() {
+		child = node.children[position]
+	}
+
+	return child
+}
+
+// mutableFor returns node if it is owned by cow, otherwise a copy of node owned by cow
+func (node *bTreeNode) mutableFor(cow *copyOnWriteContext) *bTreeNode {
+
+	mutable := node
+
+	if node.cow != cow {
+
+		mutable = &bTreeNode{
+			items:    append(make([]bTreeItem, 0, cap(node.items)), node.items...),
+			children: append(make([]*bTreeNode, 0, cap(node.children)), node.children...),
+			cow:      cow,
+		}
+	}
+
+	return mutable
+}
+
+func (node *bTreeNode) mutableChild(position int) *bTreeNode {
+
+	child := node.children[position].mutableFor(node.cow)
+	node.children[position] = child
+
+	return child
+}
+
+// insert adds item to the subtree rooted at node, which must not be full. Returns true if an existing item with the
+// same key was replaced
+func (node *bTreeNode) insert(item bTreeItem, maxItems int) bool {
+
+	position, found := node.find(item.key)
+
+	replaced := found
+
+	if found {
+		node.items[position] = item
+	} else if node.isLeaf() {
+		node.items = insertItemAt(node.items, position, item)
+	} else {
+		replaced = node.insertIntoChild(position, item, maxItems)
+	}
+
+	return replaced
+}
+
+func (node *bTreeNode) insertIntoChild(position int, item bTreeItem, maxItems int) bool {
+
+	replaced := false
+
+	if node.splitChildIfFull(position, maxItems) {
+
+		promoted := node.items[position]
+
+		if item.key == promoted.key {
+			node.items[position] = item
+			replaced = true
+		} else if item.key > promoted.key {
+			position += 1
+		}
+	}
+
+	if !replaced {
+		replaced = node.mutableChild(position).insert(item, maxItems)
+	}
+
+	return replaced
+}
+
+// splitChildIfFull splits the child at position when it is full, promoting its middle item into node
+func (node *bTreeNode) splitChildIfFull(position int, maxItems int) bool {
+
+	isFull := len(node.children[position].items) >= maxItems
+
+	if isFull {
+
+		middle, second := node.mutableChild(position).split(maxItems / 2)
+
+		node.items = insertItemAt(node.items, position, middle)
+		node.children = insertChildAt(node.children, position+1, second)
+	}
+
+	return isFull
+}
+
+// split removes the item at position and everything after it from node. Returns the removed item and a new node
+// holding the items, and children, that followed it
+func (node *bTreeNode) split(position int) (bTreeItem, *bTreeNode) {
+
+	middle := node.items[position]
+
+	second := &bTreeNode{
+		items: append([]bTreeItem{}, node.items[position+1:]...),
+		cow:   node.cow,
+	}
+
+	node.items = append([]bTreeItem{}, node.items[:position]...)
+
+	if !node.isLeaf() {
+
+		second.children = append([]*bTreeNode{}, node.children[position+1:]...)
+		node.children = append([]*bTreeNode{}, node.children[:position+1]...)
+	}
+
+	return middle, second
+}
+
+// remove removes key, or the largest item when removeMax is set, from the subtree rooted at node. Node must hold
+// more than minItems items unless it is the root. Returns the removed item and whether anything was removed
+func (node *bTreeNode) remove(key string, minItems int, removeMax bool) (bTreeItem, bool) {
+
+	position, found := node.find(key)
+
+	if removeMax {
+		position, found = len(node.items), false
+	}
+
+	var removed bTreeItem
+	var wasRemoved bool
+
+	if node.isLeaf() {
+		removed, wasRemoved = node.removeFromLeaf(position, found, removeMax)
+	} else if len(node.children[position].items) <= minItems {
+
+		node.growChild(position, minItems)
+
+		removed, wasRemoved = node.remove(key, minItems, removeMax)
+	} else if found {
+
+		removed, wasRemoved = node.items[position], true
+
+		node.items[position], _ = node.mutableChild(position).remove("", minItems, true)
+	} else {
+		removed, wasRemoved = node.mutableChild(position).remove(key, minItems, removeMax)
+	}
+
+	return removed, wasRemoved
+}
+
+func (node *bTreeNode) removeFromLeaf(position int, found bool, removeMax bool) (bTreeItem, bool) {
+
+	var removed bTreeItem
+	wasRemoved := false
+
+	if removeMax && len(node.items) > 0 {
+		position, wasRemoved = len(node.items)-1, true
+	}
+
+	if found || wasRemoved {
+
+		removed, wasRemoved = node.items[position], true
+		node.items = removeItemAt(node.items, position)
+	}
+
+	return removed, wasRemoved
+}
+
+// growChild gives the child at position, which holds only minItems items, an extra item by borrowing one from a
+// sibling through node, or failing that by merging it with a sibling
+func (node *bTreeNode) growChild(position int, minItems int) {
+
+	if position > 0 && len(node.children[position-1].items) > minItems {
+		node.borrowFromLeft(position)
+	} else if position < len(node.items) && len(node.children[position+1].items) > minItems {
+		node.borrowFromRight(position)
+	} else if position < len(node.items) {
+		node.mergeChildren(position)
+	} else {
+		node.mergeChildren(position - 1)
+	}
+}
+
+func (node *bTreeNode) borrowFromLeft(position int) {
+
+	child := node.mutableChild(position)
+	sibling := node.mutableChild(position - 1)
+
+	child.items = insertItemAt(child.items, 0, node.items[position-1])
+	node.items[position-1] = sibling.items[len(sibling.items)-1]
+	sibling.items = removeItemAt(sibling.items, len(sibling.items)-1)
+
+	if !sibling.isLeaf() {
+
+		child.children = insertChildAt(child.children, 0, sibling.children[len(sibling.children)-1])
+		sibling.children = removeChildAt(sibling.children, len(sibling.children)-1)
+	}
+}
+
+func (node *bTreeNode) borrowFromRight(position int) {
+
+	child := node.mutableChild(position)
+	sibling := node.mutableChild(position + 1)
+
+	child.items = append(child.items, node.items[position])
+	node.items[position] = sibling.items[0]
+	sibling.items = removeItemAt(sibling.items, 0)
+
+	if !sibling.isLeaf() {
+
+		child.children = append(child.children, sibling.children[0])
+		sibling.children = removeChildAt(sibling.children, 0)
+	}
+}
+
+// mergeChildren merges the child after position, and the item separating them, into the child at position
+func (node *bTreeNode) mergeChildren(position int) {
+
+	child := node.mutableChild(position)
+	sibling := node.children[position+1]
+
+	child.items = append(child.items, node.items[position])
+	child.items = append(child.items, sibling.items...)
+	child.children = append(child.children, sibling.children...)
+
+	node.items = removeItemAt(node.items, position)
+	node.children = removeChildAt(node.children, position+1)
+}
+
+func (node *bTreeNode) ascend(start string, end string, visit func(item bTreeItem) bool) bool {
+
+	position, _ := node.find(start)
+
+	visiting := true
+
+	for ; position < len(node.items) && visiting; position++ {
+
+		visiting = node.ascendChild(position, start, end, visit)
+
+		item := node.items[position]
+
+		visiting = visiting && (end == "" || item.key < end) && visit(item)
+	}
+
+	return visiting && node.ascendChild(len(node.items), start, end, visit)
+}
+
+func (node *bTreeNode) ascendChild(position int, start string, end string, visit func(item bTreeItem) bool) bool {
+
+	return node.isLeaf() || node.children[position].ascend(start, end, visit)
+}
+
+func (node *bTreeNode) descend(start string, end string, visit func(item bTreeItem) bool) bool {
+
+	position := len(node.items)
+
+	if end != "" {
+		position, _ = node.find(end)
+	}
+
+	visiting := node.descendChild(position, start, end, visit)
+
+	for position -= 1; position >= 0 && visiting; position-- {
+
+		item := node.items[position]
+
+		visiting = item.key >= start && visit(item) && node.descendChild(position, start, end, visit)
+	}
+
+	return visiting
+}
+
+func (node *bTreeNode) descendChild(position int, start string, end string, visit func(item bTreeItem) bool) bool {
+
+	return node.isLeaf() || node.children[position].descend(start, end, visit)
+}
+
+func insertItemAt(items []bTreeItem, position int, item bTreeItem) []bTreeItem {
+
+	items = append(items, bTreeItem{})
+	copy(items[position+1:], items[position:])
+	items[position] = item
+
+	return items
+}
+
+func removeItemAt(items []bTreeItem, position int) []bTreeItem {
+
+	copy(items[position:], items[position+1:])
+	items[len(items)-1] = bTreeItem{}
+
+	return items[:len(items)-1]
+}
+
+func removeChildAt(children []*bTreeNode, position int) []*bTreeNode {
+
+	copy(children[position:], children[position+1:])
+	children[len(children)-1] = nil
+
+	return children[:len(children)-1]
+}
+
+func insertChildAt(children []*bTreeNode, position int, child *bTreeNode) []*bTreeNode {
+
+	children = append(children, nil)
+	copy(children[position+1:], children[position:])
+	children[position] = child
+
+	return children
+}
+
+// bTreeIterator walks a tree in key order. The tree must not be written while it is being walked, iterate over a
+// clone to walk a tree that is still being written
+type bTreeIterator struct {
+	stack []bTreeIteratorFrame
+}
+
+// bTreeIteratorFrame is a node being walked and the position of its next item, frames are popped as soon as their
+// last item is returned
+type bTreeIteratorFrame struct {
+	node     *bTreeNode
+	position int
+}
+
+func newBTreeIterator(tree *bTree) *bTreeIterator {
+
+	iterator := &bTreeIterator{}
+
+	iterator.pushLeftmostPath(tree.root)
+
+	return iterator
+}
+
+func (iterator *bTreeIterator) hasNext() bool {
+
+	return len(iterator.stack) > 0
+}
+
+func (iterator *bTreeIterator) next() bTreeItem {
+
+	top := &iterator.stack[len(iterator.stack)-1]
+
+	node := top.node
+	item := node.items[top.position]
+
+	top.position += 1
+	nextPosition := top.position
+
+	if nextPosition == len(node.items) {
+		iterator.stack = iterator.stack[:len(iterator.stack)-1]
+	}
+
+	iterator.pushLeftmostPath(node.childAt(nextPosition))
+
+	return item
+}
+
+func (iterator *bTreeIterator) pushLeftmostPath(node *bTreeNode) {
+
+	for ; node != nil && len(node.items) > 0; node = node.childAt(0) {
+		iterator.stack = append(iterator.stack, bTreeIteratorFrame{node: node})
+	}
+}
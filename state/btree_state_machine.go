@@ -0,0 +1,305 @@
+package state
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/jrobison153/raft/journal"
+)
+
+var ErrInvalidScanRange = errors.New("scan range start must not be after its end")
+
+// BTreeStateMachine is a Renderer that keeps its data ordered by key in an in memory B-Tree. On top of key lookups
+// it supports ordered range and prefix scans and point in time snapshots.
+// BTreeStateMachine is safe for concurrent use, reads and scans may run while committed entries are applied
+type BTreeStateMachine struct {
+	*journalApplier
+	data *bTreeKeyspace
+}
+
+// ScanRequest selects the items returned by BTreeStateMachine.Scan. Items of Namespace with Start <= key < End that
+// begin with Prefix are returned in key order, or reverse key order when Reverse is set. An empty End is unbounded and
+// a Limit of zero returns every matching item. The default namespace never returns reserved keys
+type ScanRequest struct {
+	Prefix    string
+	Start     string
+	End       string
+	Limit     int
+	Reverse   bool
+	Namespace string
+}
+
+// SnapshotIterator walks a point in time copy of a BTreeStateMachine in key order. Entries applied after the
+// snapshot was taken are not visible to it
+type SnapshotIterator struct {
+	index    int64
+	iterator *bTreeIterator
+}
+
+// bTreeKeyValues reads and writes a bTree without any locking
+type bTreeKeyValues struct {
+	tree *bTree
+}
+
+// bTreeKeyspace guards a bTree with a read write lock and tracks the last journal index applied to it
+type bTreeKeyspace struct {
+	mutex        sync.RWMutex
+	tree         *bTree
+	appliedIndex int64
+}
+
+func NewBTreeStateMachine(journal journal.Journaler) *BTreeStateMachine {
+
+	data := &bTreeKeyspace{
+		tree:         newBTree(defaultBTreeDegree),
+		appliedIndex: -1,
+	}
+
+	return &BTreeStateMachine{
+		journalApplier: newJournalApplier(journal, data),
+		data:           data,
+	}
+}
+
+// Scan returns the items selected by request, keyed without their namespace.
+// Returns ErrInvalidScanRange if the request End is before its Start
+// Returns ErrInvalidNamespace if the Namespace contains '/'
+// Returns ErrCorruptionAlarm if a corrupt journal entry has been seen, the state can no longer be trusted
+func (stateMachine *BTreeStateMachine) Scan(request ScanRequest) ([]KeyValItem, error) {
+
+	var items []KeyValItem
+	var err error
+
+	if stateMachine.IsCorruptionAlarmRaised() {
+		err = ErrCorruptionAlarm
+	} else if request.End != "" && request.Start > request.End {
+		err = ErrInvalidScanRange
+	} else if request.Namespace != "" && validateNamespace(request.Namespace) != nil {
+		err = ErrInvalidNamespace
+	} else {
+
+		start, end := request.inKeyspace().bounds()
+		items = stateMachine.data.scan(start, end, request.Limit, request.Reverse, request.selects)
+
+		namespacePrefix := NamespacedKey(request.Namespace, "")
+
+		for position := range items {
+			items[position].Key = strings.TrimPrefix(items[position].Key, namespacePrefix)
+		}
+	}
+
+	return items, err
+}
+
+// Len returns the number of keys in the state machine
+func (stateMachine *BTreeStateMachine) Len() int {
+
+	stateMachine.data.mutex.RLock()
+	defer stateMachine.data.mutex.RUnlock()
+
+	return stateMachine.data.tree.length
+}
+
+// Snapshot returns an iterator over the state machine as it is now. Taking a snapshot is cheap, the B-Tree is
+// cloned copy on write, and the iterator never blocks entries from being applied
+func (stateMachine *BTreeStateMachine) Snapshot() *SnapshotIterator {
+
+	stateMachine.data.mutex.Lock()
+	defer stateMachine.data.mutex.Unlock()
+
+	return &SnapshotIterator{
+		index:    stateMachine.data.appliedIndex,
+		iterator: newBTreeIterator(stateMachine.data.tree.clone()),
+	}
+}
+
+// Index returns the index of the last journal entry reflected in the snapshot, -1 if none had been applied
+func (snapshot *SnapshotIterator) Index() int64 {
+
+	return snapshot.index
+}
+
+func (snapshot *SnapshotIterator) HasNext() bool {
+
+	return snapshot.iterator.hasNext()
+}
+
+func (snapshot *SnapshotIterator) Next() KeyValItem {
+
+	item := snapshot.iterator.next()
+
+	return KeyValItem{Key: item.key, Data: item.value}
+}
+
+// inKeyspace returns the request with its keys replaced by the keyspace keys they are stored under in its namespace
+func (request ScanRequest) inKeyspace() ScanRequest {
+
+	if request.Namespace != "" {
+
+		request.Prefix = NamespacedKey(request.Namespace, request.Prefix)
+
+		if request.Start != "" {
+			request.Start = NamespacedKey(request.Namespace, request.Start)
+		}
+
+		if request.End != "" {
+			request.End = NamespacedKey(request.Namespace, request.End)
+		}
+	}
+
+	return request
+}
+
+// selects returns true if the request returns keyspace key, a scan of the default namespace skips reserved keys
+func (request ScanRequest) selects(key string) bool {
+
+	return request.Namespace != "" || !IsReservedKey(key)
+}
+
+// bounds returns the key range selected by the request, narrowed to the keys beginning with Prefix
+func (request ScanRequest) bounds() (string, string) {
+
+	start := request.Start
+	end := request.End
+
+	if request.Prefix != "" {
+
+		if start < request.Prefix {
+			start = request.Prefix
+		}
+
+		prefixEnd := prefixUpperBound(request.Prefix)
+
+		if end == "" || (prefixEnd != "" && prefixEnd < end) {
+			end = prefixEnd
+		}
+	}
+
+	return start, end
+}
+
+// prefixUpperBound returns the smallest key greater than every key beginning with prefix, empty when there is no
+// such key because prefix is all 0xFF bytes
+func prefixUpperBound(prefix string) string {
+
+	trimmed := strings.TrimRight(prefix, "\xff")
+
+	upperBound := ""
+
+	if trimmed != "" {
+		upperBound = trimmed[:len(trimmed)-1] + string([]byte{trimmed[len(trimmed)-1] + 1})
+	}
+
+	return upperBound
+}
+
+func (data *bTreeKeyspace) get(key string) (versionedValue, bool) {
+
+	data.mutex.RLock()
+	defer data.mutex.RUnlock()
+
+	return bTreeKeyValues{data.tree}.get(key)
+}
+
+func (data *bTreeKeyspace) set(key string, value versionedValue) {
+
+	data.mutex.Lock()
+	defer data.mutex.Unlock()
+
+	bTreeKeyValues{data.tree}.set(key, value)
+}
+
+func (data *bTreeKeyspace) delete(key string) {
+
+	data.mutex.Lock()
+	defer data.mutex.Unlock()
+
+	bTreeKeyValues{data.tree}.delete(key)
+}
+
+// atomically applies the writes to a copy on write clone of the tree that replaces the tree once apply is done,
+// commit is called under the same lock as the swap. Only the journalApplier writes to the keyspace so no other write
+// can be lost by the swap
+func (data *bTreeKeyspace) atomically(apply func(data keyValues), commit func()) {
+
+	data.mutex.Lock()
+	working := data.tree.clone()
+	data.mutex.Unlock()
+
+	apply(bTreeKeyValues{working})
+
+	data.mutex.Lock()
+	data.tree = working
+	commit()
+	data.mutex.Unlock()
+}
+
+func (data *bTreeKeyspace) appliedThrough(index uint64) {
+
+	data.mutex.Lock()
+	defer data.mutex.Unlock()
+
+	data.appliedIndex = int64(index)
+}
+
+func (data *bTreeKeyspace) withPrefix(prefix string, visit func(key string, value versionedValue)) {
+
+	data.mutex.RLock()
+	defer data.mutex.RUnlock()
+
+	data.tree.ascend(prefix, prefixUpperBound(prefix), func(item bTreeItem) bool {
+
+		visit(item.key, item.versionedValue)
+
+		return true
+	})
+}
+
+// scan returns up to limit items with start <= key < end that selects returns true for
+func (data *bTreeKeyspace) scan(
+	start string,
+	end string,
+	limit int,
+	reverse bool,
+	selects func(key string) bool) []KeyValItem {
+
+	data.mutex.RLock()
+	defer data.mutex.RUnlock()
+
+	items := make([]KeyValItem, 0, 16)
+
+	collect := func(item bTreeItem) bool {
+
+		if selects(item.key) {
+			items = append(items, KeyValItem{Key: item.key, Data: item.value})
+		}
+
+		return limit <= 0 || len(items) < limit
+	}
+
+	if reverse {
+		data.tree.descend(start, end, collect)
+	} else {
+		data.tree.ascend(start, end, collect)
+	}
+
+	return items
+}
+
+func (data bTreeKeyValues) get(key string) (versionedValue, bool) {
+
+	item, found := data.tree.lookup(key)
+
+	return item.versionedValue, found
+}
+
+func (data bTreeKeyValues) set(key string, value versionedValue) {
+
+	data.tree.putItem(bTreeItem{key: key, versionedValue: value})
+}
+
+func (data bTreeKeyValues) delete(key string) {
+
+	data.tree.delete(key)
+}
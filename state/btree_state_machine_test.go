@@ -0,0 +1,215 @@
+package state
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jrobison153/raft/journal"
+)
+
+func TestWhenBTreeStateMachineStartedThenKeysCanBeResolved(t *testing.T) {
+
+	stateMachine := setupBTreeStateMachine(map[string][]byte{"a": []byte("a value")})
+
+	value, err := stateMachine.ResolveRequestToData(createKeyRequest("a"))
+
+	if err != nil || string(value) != "a value" {
+		t.Errorf("Expected 'a value' but got '%s' and error '%v'", value, err)
+	}
+}
+
+func TestWhenPrefixScannedThenOnlyKeysWithThePrefixAreReturnedInOrder(t *testing.T) {
+
+	stateMachine := setupBTreeStateMachine(map[string][]byte{
+		"user/2": nil, "user/1": nil, "order/1": nil, "user/3": nil, "users": nil,
+	})
+
+	items, _ := stateMachine.Scan(ScanRequest{Prefix: "user/"})
+
+	if keysOf(items) != "[user/1 user/2 user/3]" {
+		t.Errorf("Unexpected keys scanned %s", keysOf(items))
+	}
+}
+
+func TestWhenRangeScannedInReverseWithLimitThenTheHighestKeysInRangeAreReturned(t *testing.T) {
+
+	stateMachine := setupBTreeStateMachine(map[string][]byte{"a": nil, "b": nil, "c": nil, "d": nil, "e": nil})
+
+	items, _ := stateMachine.Scan(ScanRequest{Start: "b", End: "e", Limit: 2, Reverse: true})
+
+	if keysOf(items) != "[d c]" {
+		t.Errorf("Unexpected keys scanned %s", keysOf(items))
+	}
+}
+
+func TestWhenScanRangeStartIsAfterItsEndThenAnErrorIsReturned(t *testing.T) {
+
+	stateMachine := setupBTreeStateMachine(map[string][]byte{})
+
+	_, err := stateMachine.Scan(ScanRequest{Start: "z", End: "a"})
+
+	if ErrInvalidScanRange != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrInvalidScanRange, err)
+	}
+}
+
+func TestWhenPrefixIsOutsideTheScanRangeThenNothingIsReturned(t *testing.T) {
+
+	stateMachine := setupBTreeStateMachine(map[string][]byte{"a1": nil, "b1": nil})
+
+	items, err := stateMachine.Scan(ScanRequest{Prefix: "a", Start: "b"})
+
+	if err != nil || len(items) != 0 {
+		t.Errorf("Expected no items but got %s and error '%v'", keysOf(items), err)
+	}
+}
+
+func TestWhenPrefixIsAllMaxBytesThenScanIsUnboundedAbove(t *testing.T) {
+
+	if prefixUpperBound("\xff\xff") != "" || prefixUpperBound("a\xff") != "b" {
+		t.Errorf("Unexpected upper bounds '%q' and '%q'", prefixUpperBound("\xff\xff"), prefixUpperBound("a\xff"))
+	}
+}
+
+func TestWhenSnapshotTakenThenLaterCommitsAreNotVisibleToIt(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	stateMachine := NewBTreeStateMachine(journalSpy)
+
+	loadJournalAndCommit(map[string][]byte{"a": nil, "b": nil}, journalSpy)
+	_ = stateMachine.Start()
+
+	snapshot := stateMachine.Snapshot()
+
+	loadJournalAndCommit(map[string][]byte{"c": nil}, journalSpy)
+
+	// gross but need to give listener time to update state before reading, eventual consistency :)
+	time.Sleep(50 * time.Millisecond)
+
+	snapshotKeys := make([]KeyValItem, 0, 2)
+
+	for snapshot.HasNext() {
+		snapshotKeys = append(snapshotKeys, snapshot.Next())
+	}
+
+	if keysOf(snapshotKeys) != "[a b]" || snapshot.Index() != 1 || stateMachine.Len() != 3 {
+		t.Errorf("Snapshot should have held [a b] at index 1 but held %s at index %d",
+			keysOf(snapshotKeys),
+			snapshot.Index())
+	}
+}
+
+func TestWhenKeyDeletedThenItIsNoLongerScannedOrInSnapshots(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	stateMachine := NewBTreeStateMachine(journalSpy)
+
+	loadJournalAndCommit(map[string][]byte{"a": nil, "b": nil, "c": nil}, journalSpy)
+	appendAndCommit(journalSpy, NewDeleteCommand("b").Encode())
+
+	_ = stateMachine.Start()
+
+	items, _ := stateMachine.Scan(ScanRequest{})
+
+	snapshot := stateMachine.Snapshot()
+	snapshotKeys := make([]KeyValItem, 0, 2)
+
+	for snapshot.HasNext() {
+		snapshotKeys = append(snapshotKeys, snapshot.Next())
+	}
+
+	if keysOf(items) != "[a c]" || keysOf(snapshotKeys) != "[a c]" || stateMachine.Len() != 2 {
+		t.Errorf("Deleted key should be gone, scanned %s and snapshot held %s", keysOf(items), keysOf(snapshotKeys))
+	}
+}
+
+func TestWhenPutIfVersionAppliedToBTreeThenKeyVersionsAreTracked(t *testing.T) {
+
+	stateMachine := NewBTreeStateMachine(journal.NewJournalSpy())
+
+	first := stateMachine.applyCommand(NewPutCommand("theKey", []byte("first")).Encode(), 0)
+	stale := stateMachine.applyCommand(NewPutIfVersionCommand("theKey", []byte("stale"), 2).Encode(), 1)
+	second := stateMachine.applyCommand(NewPutIfVersionCommand("theKey", []byte("second"), 1).Encode(), 2)
+
+	if first.Version != 1 || stale.Applied || stale.Version != 1 || !second.Applied || second.Version != 2 {
+		t.Errorf("Unexpected results %+v %+v %+v", first, stale, second)
+	}
+}
+
+func TestWhenCorruptionAlarmRaisedThenScansAreRefused(t *testing.T) {
+
+	stateMachine := setupBTreeStateMachine(map[string][]byte{})
+	stateMachine.raiseCorruptionAlarm()
+
+	_, err := stateMachine.Scan(ScanRequest{})
+
+	if ErrCorruptionAlarm != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrCorruptionAlarm, err)
+	}
+}
+
+func setupBTreeStateMachine(rawKeyValData map[string][]byte) *BTreeStateMachine {
+
+	journalSpy := journal.NewJournalSpy()
+	stateMachine := NewBTreeStateMachine(journalSpy)
+
+	if len(rawKeyValData) > 0 {
+		loadJournalAndCommit(rawKeyValData, journalSpy)
+	}
+
+	_ = stateMachine.Start()
+
+	return stateMachine
+}
+
+func keysOf(items []KeyValItem) string {
+
+	keys := make([]string, 0, len(items))
+
+	for _, item := range items {
+		keys = append(keys, item.Key)
+	}
+
+	return fmt.Sprint(keys)
+}
+
+func TestWhenDefaultNamespaceIsScannedThenReservedKeysAreSkippedBeforeTheLimit(t *testing.T) {
+
+	stateMachine := NewBTreeStateMachine(journal.NewJournalSpy())
+
+	for index, command := range []Command{
+		NewCreateNamespaceCommand("team-a", 0, 0),
+		putInNamespace("team-a", "config", "tenant"),
+		NewNextSequenceCommand(SequenceKeyPrefix+"orders", 1),
+		NewPutCommand("a", []byte("1")),
+		NewPutCommand("b", []byte("2")),
+	} {
+		stateMachine.applyCommand(command.Encode(), uint64(index))
+	}
+
+	items, err := stateMachine.Scan(ScanRequest{Limit: 2})
+
+	if err != nil || keysOf(items) != "[a b]" {
+		t.Errorf("Expected only keys a and b but got %s and error '%v'", keysOf(items), err)
+	}
+}
+
+func TestWhenNamespaceIsScannedThenItsKeysAreReturnedWithoutTheNamespace(t *testing.T) {
+
+	stateMachine := NewBTreeStateMachine(journal.NewJournalSpy())
+
+	for index, command := range []Command{
+		NewCreateNamespaceCommand("team-a", 0, 0),
+		putInNamespace("team-a", "config", "tenant"),
+		NewPutCommand("config", []byte("global")),
+	} {
+		stateMachine.applyCommand(command.Encode(), uint64(index))
+	}
+
+	items, err := stateMachine.Scan(ScanRequest{Namespace: "team-a"})
+
+	if err != nil || keysOf(items) != "[config]" || string(items[0].Data) != "tenant" {
+		t.Errorf("Expected only the config key of team-a but got %+v and error '%v'", items, err)
+	}
+}
@@ -0,0 +1,238 @@
+package state
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestWhenManyKeysPutInRandomOrderThenTheyAreIteratedInKeyOrder(t *testing.T) {
+
+	tree, keys := setupRandomTree(2, 500)
+
+	iterated := make([]string, 0, len(keys))
+
+	for iterator := newBTreeIterator(tree); iterator.hasNext(); {
+		iterated = append(iterated, iterator.next().key)
+	}
+
+	sort.Strings(keys)
+
+	if fmt.Sprint(keys) != fmt.Sprint(iterated) || tree.length != len(keys) {
+		t.Errorf("Expected %d keys in order but iterated %d", len(keys), len(iterated))
+	}
+}
+
+func TestWhenKeysPutThenEachCanBeFound(t *testing.T) {
+
+	tree, keys := setupRandomTree(3, 300)
+
+	for _, key := range keys {
+
+		value, found := tree.get(key)
+
+		if !found || string(value) != "value of "+key {
+			t.Errorf("Expected to find key '%s' but found %t with value '%s'", key, found, value)
+		}
+	}
+}
+
+func TestWhenExistingKeyPutThenTheValueIsReplaced(t *testing.T) {
+
+	tree, keys := setupRandomTree(2, 100)
+
+	tree.put(keys[42], []byte("replaced"))
+
+	value, _ := tree.get(keys[42])
+
+	if string(value) != "replaced" || tree.length != len(keys) {
+		t.Errorf("Expected value 'replaced' and %d keys but got '%s' and %d keys", len(keys), value, tree.length)
+	}
+}
+
+func TestWhenRangeAscendedThenOnlyKeysInRangeAreVisitedInOrder(t *testing.T) {
+
+	tree := setupSequentialTree(2, 100)
+
+	visited := make([]string, 0, 8)
+
+	tree.ascend("key-010", "key-015", func(item bTreeItem) bool {
+		visited = append(visited, item.key)
+		return true
+	})
+
+	if fmt.Sprint(visited) != "[key-010 key-011 key-012 key-013 key-014]" {
+		t.Errorf("Unexpected keys visited %v", visited)
+	}
+}
+
+func TestWhenRangeDescendedThenOnlyKeysInRangeAreVisitedInReverseOrder(t *testing.T) {
+
+	tree := setupSequentialTree(2, 100)
+
+	visited := make([]string, 0, 8)
+
+	tree.descend("key-010", "key-015", func(item bTreeItem) bool {
+		visited = append(visited, item.key)
+		return true
+	})
+
+	if fmt.Sprint(visited) != "[key-014 key-013 key-012 key-011 key-010]" {
+		t.Errorf("Unexpected keys visited %v", visited)
+	}
+}
+
+func TestWhenVisitReturnsFalseThenAscendStops(t *testing.T) {
+
+	tree := setupSequentialTree(2, 100)
+
+	visitCount := 0
+
+	tree.ascend("", "", func(item bTreeItem) bool {
+		visitCount += 1
+		return visitCount < 7
+	})
+
+	if visitCount != 7 {
+		t.Errorf("Expected ascend to stop after 7 visits but made %d", visitCount)
+	}
+}
+
+func TestWhenClonedTreeIsWrittenThenTheOriginalIsUnchanged(t *testing.T) {
+
+	tree := setupSequentialTree(2, 100)
+
+	cloned := tree.clone()
+
+	cloned.put("key-050", []byte("changed in clone"))
+	tree.put("key-999", []byte("added to original"))
+
+	originalValue, _ := tree.get("key-050")
+	_, clonedHasNewKey := cloned.get("key-999")
+
+	if string(originalValue) != "value of key-050" || clonedHasNewKey || cloned.length != 100 {
+		t.Errorf("Clone and original should be independent, original value '%s', clone has new key %t",
+			originalValue,
+			clonedHasNewKey)
+	}
+}
+
+func TestWhenKeysDeletedInRandomOrderThenOnlyTheRemainingKeysAreFound(t *testing.T) {
+
+	for _, degree := range []int{2, 3, 8} {
+
+		tree, keys := setupRandomTree(degree, 500)
+		remaining := make(map[string]bool)
+
+		for _, key := range keys {
+			remaining[key] = true
+		}
+
+		for i, position := range rand.New(rand.NewSource(11)).Perm(len(keys)) {
+
+			if !tree.delete(keys[position]) {
+				t.Fatalf("Delete of present key %s reported it missing, degree %d", keys[position], degree)
+			}
+
+			delete(remaining, keys[position])
+
+			if i%50 == 0 {
+				assertTreeHoldsExactly(t, tree, remaining)
+			}
+		}
+
+		assertTreeHoldsExactly(t, tree, remaining)
+	}
+}
+
+func TestWhenMissingKeyDeletedThenNothingIsRemoved(t *testing.T) {
+
+	tree := setupSequentialTree(2, 20)
+
+	if tree.delete("not-a-key") || tree.length != 20 {
+		t.Errorf("Deleting a missing key should not change the tree, length is %d", tree.length)
+	}
+}
+
+func TestWhenClonedTreeHasKeysDeletedThenTheOriginalIsUnchanged(t *testing.T) {
+
+	tree := setupSequentialTree(2, 100)
+
+	cloned := tree.clone()
+
+	for i := 0; i < 100; i += 2 {
+		cloned.delete(fmt.Sprintf("key-%03d", i))
+	}
+
+	_, originalHasKey := tree.get("key-050")
+	_, clonedHasKey := cloned.get("key-050")
+
+	if !originalHasKey || clonedHasKey || tree.length != 100 || cloned.length != 50 {
+		t.Errorf("Deletes in the clone should not be visible in the original, original has key %t, clone has key %t",
+			originalHasKey,
+			clonedHasKey)
+	}
+}
+
+func TestWhenTreeIsEmptyThenIteratorHasNothing(t *testing.T) {
+
+	if newBTreeIterator(newBTree(2)).hasNext() {
+		t.Errorf("Iterator over an empty tree should not have a next item")
+	}
+}
+
+func setupRandomTree(degree int, count int) (*bTree, []string) {
+
+	random := rand.New(rand.NewSource(7))
+
+	tree := newBTree(degree)
+	keys := make([]string, 0, count)
+
+	for _, position := range random.Perm(count) {
+
+		key := fmt.Sprintf("key-%05d", position)
+		keys = append(keys, key)
+
+		tree.put(key, []byte("value of "+key))
+	}
+
+	return tree, keys
+}
+
+func setupSequentialTree(degree int, count int) *bTree {
+
+	tree := newBTree(degree)
+
+	for i := 0; i < count; i++ {
+
+		key := fmt.Sprintf("key-%03d", i)
+		tree.put(key, []byte("value of "+key))
+	}
+
+	return tree
+}
+
+func assertTreeHoldsExactly(t *testing.T, tree *bTree, keys map[string]bool) {
+
+	t.Helper()
+
+	visited := 0
+	previousKey := ""
+
+	tree.ascend("", "", func(item bTreeItem) bool {
+
+		if !keys[item.key] || item.key <= previousKey && visited > 0 {
+			t.Fatalf("Unexpected key %s visited after %s", item.key, previousKey)
+		}
+
+		previousKey = item.key
+		visited++
+
+		return true
+	})
+
+	if visited != len(keys) || tree.length != len(keys) {
+		t.Fatalf("Expected %d keys but visited %d with tree length %d", len(keys), visited, tree.length)
+	}
+}
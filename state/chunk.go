@@ -0,0 +1,269 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNoChunks          = errors.New("large value manifest or chunk discard must name at least one chunk")
+	ErrChunkNotFound     = errors.New("chunk was not staged for the key or has already been committed or discarded")
+	ErrChunkSizeMismatch = errors.New("staged chunks do not add up to the value size of the manifest")
+)
+
+// chunkTable stages the chunks of large values until the manifest committing them is applied. A staged chunk is
+// not visible to reads, the manifest reassembles the value from its chunks and stores it under its key as one write.
+// The chunk list of the manifest is kept until the key is next written so the value can be read back in the chunks
+// it was uploaded in, the chunks slice the stored value rather than copy it.
+// chunkTable is safe for concurrent use
+type chunkTable struct {
+	mutex     sync.Mutex
+	chunks    map[uint64]stagedChunk
+	manifests map[string]committedManifest
+}
+
+// StagedUpload is the chunks staged for the key of an upload that has neither committed nor discarded them, in the
+// order they were staged. The leader discards the chunks of an upload that has stopped staging them
+type StagedUpload struct {
+	Namespace string
+	Key       string
+	Chunks    []uint64
+}
+
+type stagedChunk struct {
+	key  string
+	data []byte
+}
+
+// committedManifest is the chunk list of the large value a manifest stored at modifyIndex
+type committedManifest struct {
+	modifyIndex uint64
+	chunks      [][]byte
+}
+
+// ChunkIDWrittenAt returns the ID of the chunk staged by the journal entry at index
+func ChunkIDWrittenAt(index uint64) uint64 {
+
+	return index + 1
+}
+
+func newChunkTable() *chunkTable {
+
+	return &chunkTable{
+		chunks:    make(map[uint64]stagedChunk),
+		manifests: make(map[string]committedManifest),
+	}
+}
+
+func (table *chunkTable) stage(id uint64, key string, data []byte) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	table.chunks[id] = stagedChunk{key: key, data: data}
+}
+
+// take removes the chunks ids staged for key and returns their data concatenated in the order of ids, along with the
+// chunks as slices of the value. Nothing is removed unless every chunk is staged for key and their data adds up to
+// size.
+// Returns ErrChunkNotFound if a chunk is not staged for key
+// Returns ErrChunkSizeMismatch if the chunks do not add up to size
+func (table *chunkTable) take(key string, ids []uint64, size uint64) ([]byte, [][]byte, error) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	var err error
+	var staged uint64
+
+	for _, id := range ids {
+
+		chunk, exists := table.chunks[id]
+
+		if !exists || chunk.key != key {
+			err = ErrChunkNotFound
+		}
+
+		staged += uint64(len(chunk.data))
+	}
+
+	if err == nil && staged != size {
+		err = ErrChunkSizeMismatch
+	}
+
+	var value []byte
+	var chunks [][]byte
+
+	if err == nil {
+
+		value = make([]byte, 0, size)
+		chunks = make([][]byte, 0, len(ids))
+
+		for _, id := range ids {
+
+			start := len(value)
+
+			value = append(value, table.chunks[id].data...)
+			chunks = append(chunks, value[start:len(value):len(value)])
+			delete(table.chunks, id)
+		}
+	}
+
+	return value, chunks, err
+}
+
+// commit keeps chunks as the chunk list of the large value stored under key at modifyIndex
+func (table *chunkTable) commit(key string, modifyIndex uint64, chunks [][]byte) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	table.manifests[key] = committedManifest{modifyIndex: modifyIndex, chunks: chunks}
+}
+
+// release drops the chunk list kept for key, the key has been written since its manifest was applied
+func (table *chunkTable) release(key string) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	delete(table.manifests, key)
+}
+
+// committedAt returns the chunk list kept for key if it is the list of the value stored at modifyIndex
+func (table *chunkTable) committedAt(key string, modifyIndex uint64) ([][]byte, bool) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	manifest, exists := table.manifests[key]
+
+	return manifest.chunks, exists && manifest.modifyIndex == modifyIndex
+}
+
+// discard removes the chunks ids staged for key, returning the bytes they held and whether any was staged
+func (table *chunkTable) discard(key string, ids []uint64) (uint64, bool) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	var size uint64
+	discarded := false
+
+	for _, id := range ids {
+
+		if chunk, exists := table.chunks[id]; exists && chunk.key == key {
+
+			delete(table.chunks, id)
+			size += uint64(len(chunk.data))
+			discarded = true
+		}
+	}
+
+	return size, discarded
+}
+
+// uploads returns the chunks staged for each key in namespace and key order
+func (table *chunkTable) uploads() []StagedUpload {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	chunksByKey := make(map[string][]uint64)
+
+	for id, chunk := range table.chunks {
+		chunksByKey[chunk.key] = append(chunksByKey[chunk.key], id)
+	}
+
+	uploads := make([]StagedUpload, 0, len(chunksByKey))
+
+	for key, ids := range chunksByKey {
+
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		namespace, _ := namespaceOf(key)
+
+		uploads = append(uploads, StagedUpload{
+			Namespace: namespace,
+			Key:       strings.TrimPrefix(key, NamespacedKey(namespace, "")),
+			Chunks:    ids,
+		})
+	}
+
+	sort.Slice(uploads, func(i, j int) bool {
+		return uploads[i].Namespace < uploads[j].Namespace ||
+			(uploads[i].Namespace == uploads[j].Namespace && uploads[i].Key < uploads[j].Key)
+	})
+
+	return uploads
+}
+
+// StagedUploads returns the chunks staged for each key in namespace and key order, a newly elected leader starts
+// tracking abandoned uploads from these
+func (state *journalApplier) StagedUploads() []StagedUpload {
+
+	return state.chunks.uploads()
+}
+
+// applyChunkCommand stages a chunk, commits the chunks of a manifest or discards the chunks of an abandoned upload.
+// A manifest whose chunks are not all staged for its key fails and leaves them staged so the upload can discard them.
+// Staged chunks count against the byte quota of the namespace of their key until they are committed or discarded, by
+// the upload or by the leader once the upload is abandoned
+func (state *journalApplier) applyChunkCommand(command Command, index uint64) ApplyResult {
+
+	result := ApplyResult{Index: index}
+
+	switch command.Op {
+	case OpPutChunk:
+		state.chunks.stage(ChunkIDWrittenAt(index), command.Key, command.Value)
+		state.namespaces.stage(command.Key, uint64(len(command.Value)))
+		result.Applied = true
+	case OpPutLarge:
+
+		var value []byte
+		var chunks [][]byte
+		value, chunks, result.Err = state.chunks.take(command.Key, command.Large.Chunks, command.Large.Size)
+
+		if result.Err == nil {
+			state.namespaces.unstage(command.Key, command.Large.Size)
+			result.Applied = true
+			result.Version = state.writerAt(state.keyspace, index).put(command.Key, value, command.Lease)
+			state.chunks.commit(command.Key, index, chunks)
+		}
+	default:
+
+		var size uint64
+		size, result.Applied = state.chunks.discard(command.Key, command.Large.Chunks)
+
+		state.namespaces.unstage(command.Key, size)
+	}
+
+	return result
+}
+
+// ResolveRequestToChunks resolves request, a Key, like ResolveRequestToItem and returns the value in the chunks the
+// manifest that stored it committed, the item is returned without its value. A value that was not stored by a
+// manifest is returned as one chunk
+func (state *journalApplier) ResolveRequestToChunks(request []byte) (VersionedItem, [][]byte, error) {
+
+	item, err := state.ResolveRequestToItem(request)
+
+	chunks := [][]byte{item.Value}
+
+	if err == nil {
+
+		key := &Key{}
+		_ = json.Unmarshal(request, key)
+
+		if committed, found := state.chunks.committedAt(NamespacedKey(key.Namespace, key.Key), item.ModifyIndex); found {
+			chunks = committed
+		}
+	}
+
+	item.Value = nil
+
+	return item, chunks, err
+}
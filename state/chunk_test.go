@@ -0,0 +1,216 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jrobison153/raft/journal"
+)
+
+func TestWhenManifestIsAppliedThenTheValueIsReassembledFromItsChunks(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewPutChunkCommand("blob", []byte("hello ")),
+		NewPutChunkCommand("blob", []byte("world")),
+		NewPutLargeCommand("blob", []uint64{ChunkIDWrittenAt(0), ChunkIDWrittenAt(1)}, 11))
+
+	data, err := stateMachine.ResolveRequestToData(createKeyRequest("blob"))
+
+	if err != nil || string(data) != "hello world" || results[2].Version != 1 {
+		t.Errorf("Expected 'hello world' at version 1 but got '%s', %+v and error '%v'", data, results[2], err)
+	}
+}
+
+func TestWhenLargeValueIsReadInChunksThenTheChunksOfItsManifestAreReturned(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine,
+		NewPutChunkCommand("blob", []byte("hello ")),
+		NewPutChunkCommand("blob", []byte("world")),
+		NewPutLargeCommand("blob", []uint64{ChunkIDWrittenAt(0), ChunkIDWrittenAt(1)}, 11))
+
+	item, chunks, err := stateMachine.ResolveRequestToChunks(createKeyRequest("blob"))
+
+	expected := [][]byte{[]byte("hello "), []byte("world")}
+
+	if err != nil || !reflect.DeepEqual(expected, chunks) || item.Version != 1 || item.Value != nil {
+		t.Errorf("Expected the chunks %q but got %q, %+v and error '%v'", expected, chunks, item, err)
+	}
+}
+
+func TestWhenLargeValueIsOverwrittenThenTheNewValueIsReadAsOneChunk(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine,
+		NewPutChunkCommand("blob", []byte("hello ")),
+		NewPutChunkCommand("blob", []byte("world")),
+		NewPutLargeCommand("blob", []uint64{ChunkIDWrittenAt(0), ChunkIDWrittenAt(1)}, 11),
+		NewPutCommand("blob", []byte("small")))
+
+	_, chunks, err := stateMachine.ResolveRequestToChunks(createKeyRequest("blob"))
+
+	if err != nil || !reflect.DeepEqual([][]byte{[]byte("small")}, chunks) {
+		t.Errorf("Expected the one chunk 'small' but got %q and error '%v'", chunks, err)
+	}
+}
+
+func TestWhenOnlyChunksAreAppliedThenTheKeyIsNotVisible(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine, NewPutChunkCommand("blob", []byte("hello")))
+
+	_, err := stateMachine.ResolveRequestToData(createKeyRequest("blob"))
+
+	if ErrKeyNotFound != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrKeyNotFound, err)
+	}
+}
+
+func TestWhenManifestNamesAChunkOfAnotherKeyThenItFails(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewPutChunkCommand("other", []byte("hello")),
+		NewPutLargeCommand("blob", []uint64{ChunkIDWrittenAt(0)}, 5))
+
+	if ErrChunkNotFound != results[1].Err || results[1].Applied {
+		t.Errorf("Should have received error '%v' but got %+v", ErrChunkNotFound, results[1])
+	}
+}
+
+func TestWhenChunksDoNotAddUpToTheManifestSizeThenItFailsAndTheChunksStayStaged(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewPutChunkCommand("blob", []byte("hello")),
+		NewPutLargeCommand("blob", []uint64{ChunkIDWrittenAt(0)}, 6),
+		NewPutLargeCommand("blob", []uint64{ChunkIDWrittenAt(0)}, 5))
+
+	if ErrChunkSizeMismatch != results[1].Err || !results[2].Applied {
+		t.Errorf("Unexpected manifest results %+v", results)
+	}
+}
+
+func TestWhenChunksAreDiscardedThenAManifestCanNoLongerCommitThem(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewPutChunkCommand("blob", []byte("hello")),
+		NewDiscardChunksCommand("blob", []uint64{ChunkIDWrittenAt(0)}),
+		NewPutLargeCommand("blob", []uint64{ChunkIDWrittenAt(0)}, 5))
+
+	if !results[1].Applied || ErrChunkNotFound != results[2].Err {
+		t.Errorf("Unexpected discard and manifest results %+v", results)
+	}
+}
+
+func TestWhenManifestIsEncodedThenItDecodesToTheSameCommand(t *testing.T) {
+
+	command := NewPutLargeCommand("blob", []uint64{1, 300, 70000}, 4096)
+
+	decoded, err := DecodeCommand(command.Encode())
+
+	if err != nil || !reflect.DeepEqual(command, decoded) {
+		t.Errorf("Expected %+v to survive encoding but got %+v and error '%v'", command, decoded, err)
+	}
+}
+
+func TestWhenManifestNamesNoChunksThenTheCommandIsInvalid(t *testing.T) {
+
+	err := NewPutLargeCommand("blob", nil, 0).Validate()
+
+	if ErrNoChunks != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrNoChunks, err)
+	}
+}
+
+func TestWhenChunksAreStagedInANamespaceThenTheyCountAgainstItsBytesUntilCommittedOrDiscarded(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	chunk := NewPutChunkCommand("blob", []byte("hello"))
+	chunk.Namespace = "team-a"
+
+	manifest := NewPutLargeCommand("blob", []uint64{ChunkIDWrittenAt(1)}, 5)
+	manifest.Namespace = "team-a"
+
+	discard := NewDiscardChunksCommand("blob", []uint64{ChunkIDWrittenAt(3)})
+	discard.Namespace = "team-a"
+
+	stagingStateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stagingStateMachine, NewCreateNamespaceCommand("team-a", 0, 0), chunk)
+	staged, _ := stagingStateMachine.Namespace("team-a")
+
+	applyCommands(stateMachine, NewCreateNamespaceCommand("team-a", 0, 0), chunk, manifest, chunk, discard)
+	committed, _ := stateMachine.Namespace("team-a")
+
+	if staged.Bytes != 5 || committed.Bytes != StoredSize("blob", []byte("hello")) {
+		t.Errorf("Expected 5 staged bytes and then only the committed value but got %+v and %+v", staged, committed)
+	}
+}
+
+func TestWhenChunksAreStagedThenTheyAreListedAsTheUploadOfTheirKey(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	chunk := NewPutChunkCommand("blob", []byte("hello"))
+	chunk.Namespace = "team-a"
+
+	applyCommands(stateMachine, NewCreateNamespaceCommand("team-a", 0, 0), chunk, chunk)
+
+	chunks := []uint64{ChunkIDWrittenAt(1), ChunkIDWrittenAt(2)}
+	expected := []StagedUpload{{Namespace: "team-a", Key: "blob", Chunks: chunks}}
+
+	if !reflect.DeepEqual(expected, stateMachine.StagedUploads()) {
+		t.Errorf("Expected staged uploads %+v but got %+v", expected, stateMachine.StagedUploads())
+	}
+}
+
+func TestWhenAbandonedUploadIsDiscardedThenItIsNoLongerStaged(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	chunk := NewPutChunkCommand("blob", []byte("hello"))
+	chunk.Namespace = "team-a"
+
+	discard := NewDiscardChunksCommand("blob", []uint64{ChunkIDWrittenAt(1)})
+	discard.Namespace = "team-a"
+
+	applyCommands(stateMachine, NewCreateNamespaceCommand("team-a", 0, 0), chunk, discard)
+
+	if len(stateMachine.StagedUploads()) != 0 {
+		t.Errorf("Discarded upload should no longer be staged but got %+v", stateMachine.StagedUploads())
+	}
+}
+
+func TestWhenAbandonedUploadIsDiscardedThenItsBytesStopCountingAgainstTheNamespaceQuota(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	chunk := NewPutChunkCommand("blob", []byte("hello"))
+	chunk.Namespace = "team-a"
+
+	discard := NewDiscardChunksCommand("blob", []uint64{ChunkIDWrittenAt(1)})
+	discard.Namespace = "team-a"
+
+	results := applyCommands(stateMachine,
+		NewCreateNamespaceCommand("team-a", 0, 6),
+		chunk,
+		putInNamespace("team-a", "a", "1"),
+		discard,
+		putInNamespace("team-a", "a", "1"))
+
+	if ErrQuotaExceeded != results[2].Err || !results[4].Applied {
+		t.Errorf("Put should have fit the quota only once the upload was discarded, got %+v and then %+v",
+			results[2], results[4])
+	}
+}
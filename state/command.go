@@ -0,0 +1,779 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/jrobison153/raft/api/envelope"
+	"google.golang.org/protobuf/proto"
+)
+
+// CommandVersion is the version of the command envelope written to the journal. Decoding rejects envelopes with a
+// higher version, they were written by a newer node and may hold operations this node would apply incorrectly.
+// Version 2 carries the parameters of an operation in a typed payload, version 1 envelopes are still read
+const CommandVersion uint32 = 2
+
+// commandVersion1 is the envelope that carried every parameter of every operation as a field of its own
+const commandVersion1 uint32 = 1
+
+// CommandOp is the operation a Command performs when it is applied
+type CommandOp int32
+
+const (
+	OpUnspecified CommandOp = iota
+	OpPut
+	OpDelete
+	OpPutIfAbsent
+	OpPutIfVersion
+	OpDeleteIfValue
+	OpTxn
+
+	// OpGet reads a key, it is only valid as a TxnOp
+	OpGet
+	OpCompact
+	OpGrantLease
+	OpRevokeLease
+	OpIncrement
+	OpDecrement
+	OpNextSequence
+	OpCreateNamespace
+	OpDeleteNamespace
+	OpCreateIndex
+	OpDropIndex
+	OpEnqueue
+	OpDequeue
+	OpAck
+	OpNack
+	OpPutChunk
+	OpPutLarge
+	OpDiscardChunks
+	OpAcquireLock
+)
+
+var (
+	ErrEmptyCommandKey           = errors.New("journal command must have a non empty key")
+	ErrUnknownCommandOp          = errors.New("journal command has an operation the state machine does not support")
+	ErrUnsupportedCommandVersion = errors.New("journal command was written with a newer envelope version")
+	ErrInvalidCommandPayload     = errors.New("journal command must carry the payload of its operation and no other")
+	ErrLockWithoutLease          = errors.New("lock must be acquired with a lease")
+)
+
+// Command is the journal entry applied by the state machines. Commands are stored in the journal as a versioned
+// protobuf envelope, see command.proto and Encode. Key, Value, Metadata, Lease and Namespace are shared by the
+// operations, the other parameters of an operation are carried in its payload and a command carries at most one.
+// Metadata is carried with the command but never interpreted by the state machines. A put with a Lease attaches its
+// key to that lease and a Namespace stores Key in that namespace.
+// OpPutIfVersion and OpDeleteIfValue carry their Condition, it is evaluated when the command is applied so every
+// node reaches the same outcome. OpTxn carries its Txn, applied to the keys of Namespace, and OpCompact its
+// Compaction, neither has a Key.
+// OpGrantLease carries its LeaseGrant and OpRevokeLease names the lease in Lease, lease commands have no Key either.
+// OpIncrement, OpDecrement and OpNextSequence carry the CounterChange applied to the counter or sequence under Key.
+// OpCreateNamespace creates Namespace with its NamespaceQuota and OpDeleteNamespace deletes it and every key stored
+// in it, namespace commands have no Key. OpCreateIndex creates the secondary index of its IndexSpec over the
+// documents under the keys beginning with Key in Namespace and OpDropIndex drops the index it names.
+// Queue commands name their queue in Key, OpEnqueue appends Value to the queue and OpDequeue, OpAck and OpNack
+// carry their QueueDelivery. OpPutChunk stages Value as a chunk of a large value for Key, OpPutLarge stores the
+// chunks of its LargeValue under Key as one put and OpDiscardChunks discards the chunks of an abandoned upload.
+// OpAcquireLock puts Value under Key if it is absent, attached to Lease, it is the only operation that writes the
+// keys of locks and elections
+type Command struct {
+	Version    uint32
+	Op         CommandOp
+	Key        string
+	Value      []byte            `json:",omitempty"`
+	Metadata   map[string]string `json:",omitempty"`
+	Lease      uint64            `json:",omitempty"`
+	Namespace  string            `json:",omitempty"`
+	Condition  *Condition        `json:",omitempty"`
+	Txn        *Txn              `json:",omitempty"`
+	Compaction *Compaction       `json:",omitempty"`
+	LeaseGrant *LeaseGrant       `json:",omitempty"`
+	Counter    *CounterChange    `json:",omitempty"`
+	Quota      *NamespaceQuota   `json:",omitempty"`
+	Index      *IndexSpec        `json:",omitempty"`
+	Queue      *QueueDelivery    `json:",omitempty"`
+	Large      *LargeValue       `json:",omitempty"`
+}
+
+// Condition is the condition of a conditional write, the Version OpPutIfVersion expects, 0 for a key that does not
+// exist, or the Value OpDeleteIfValue expects
+type Condition struct {
+	Version uint64 `json:",omitempty"`
+	Value   []byte `json:",omitempty"`
+}
+
+// Compaction discards the MVCC history needed only for reads below Index
+type Compaction struct {
+	Index uint64
+}
+
+// LeaseGrant grants a lease of TTL seconds
+type LeaseGrant struct {
+	TTL int64
+}
+
+// CounterChange is the amount a counter is changed by or the number of IDs of a sequence reserved
+type CounterChange struct {
+	Delta uint64
+}
+
+// NamespaceQuota is the most keys and bytes a namespace may hold, 0 for no quota
+type NamespaceQuota struct {
+	MaxKeys  uint64 `json:",omitempty"`
+	MaxBytes uint64 `json:",omitempty"`
+}
+
+// IndexSpec names a secondary index and, when it is created, the document path it indexes
+type IndexSpec struct {
+	Name string
+	Path string `json:",omitempty"`
+}
+
+// QueueDelivery is the VisibilityTimeout of a dequeue in seconds or the MessageID and Delivery acknowledged or
+// returned
+type QueueDelivery struct {
+	MessageID         uint64 `json:",omitempty"`
+	Delivery          uint64 `json:",omitempty"`
+	VisibilityTimeout int64  `json:",omitempty"`
+}
+
+// LargeValue names the staged chunks of a large value, Size bytes in all
+type LargeValue struct {
+	Chunks []uint64
+	Size   uint64 `json:",omitempty"`
+}
+
+// legacyCommand is the JSON journal entry written before the command envelope. A put was a KeyValItem, a delete
+// carried an Op of DELETE
+type legacyCommand struct {
+	Op   string
+	Key  string
+	Data []byte
+}
+
+// NewPutCommand returns the command that stores value under key once it is committed
+func NewPutCommand(key string, value []byte) Command {
+
+	return Command{Version: CommandVersion, Op: OpPut, Key: key, Value: value}
+}
+
+// NewDeleteCommand returns the command that removes key from the state machines once it is committed
+func NewDeleteCommand(key string) Command {
+
+	return Command{Version: CommandVersion, Op: OpDelete, Key: key}
+}
+
+// NewPutIfAbsentCommand returns the command that stores value under key only if key does not exist
+func NewPutIfAbsentCommand(key string, value []byte) Command {
+
+	return Command{Version: CommandVersion, Op: OpPutIfAbsent, Key: key, Value: value}
+}
+
+// NewAcquireLockCommand returns the command that acquires the lock or election key by putting value under it,
+// attached to lease, only if key does not exist
+func NewAcquireLockCommand(key string, value []byte, lease uint64) Command {
+
+	return Command{Version: CommandVersion, Op: OpAcquireLock, Key: key, Value: value, Lease: lease}
+}
+
+// NewPutIfVersionCommand returns the command that stores value under key only if the key version is version. A
+// version of 0 matches a key that does not exist
+func NewPutIfVersionCommand(key string, value []byte, version uint64) Command {
+
+	return Command{
+		Version:   CommandVersion,
+		Op:        OpPutIfVersion,
+		Key:       key,
+		Value:     value,
+		Condition: &Condition{Version: version},
+	}
+}
+
+// NewDeleteIfValueCommand returns the command that removes key only if its value is value
+func NewDeleteIfValueCommand(key string, value []byte) Command {
+
+	return Command{Version: CommandVersion, Op: OpDeleteIfValue, Key: key, Condition: &Condition{Value: value}}
+}
+
+// NewCompactCommand returns the command that discards the MVCC history needed only for reads below index
+func NewCompactCommand(index uint64) Command {
+
+	return Command{Version: CommandVersion, Op: OpCompact, Compaction: &Compaction{Index: index}}
+}
+
+// NewGrantLeaseCommand returns the command that grants a lease of ttlSeconds, see LeaseIDGrantedAt for its ID
+func NewGrantLeaseCommand(ttlSeconds int64) Command {
+
+	return Command{Version: CommandVersion, Op: OpGrantLease, LeaseGrant: &LeaseGrant{TTL: ttlSeconds}}
+}
+
+// NewRevokeLeaseCommand returns the command that revokes lease id and deletes the keys attached to it
+func NewRevokeLeaseCommand(id uint64) Command {
+
+	return Command{Version: CommandVersion, Op: OpRevokeLease, Lease: id}
+}
+
+// NewIncrementCommand returns the command that adds delta to the counter under key
+func NewIncrementCommand(key string, delta uint64) Command {
+
+	return Command{Version: CommandVersion, Op: OpIncrement, Key: key, Counter: &CounterChange{Delta: delta}}
+}
+
+// NewDecrementCommand returns the command that subtracts delta from the counter under key
+func NewDecrementCommand(key string, delta uint64) Command {
+
+	return Command{Version: CommandVersion, Op: OpDecrement, Key: key, Counter: &CounterChange{Delta: delta}}
+}
+
+// NewNextSequenceCommand returns the command that reserves the next batchSize IDs of the sequence under key
+func NewNextSequenceCommand(key string, batchSize uint64) Command {
+
+	return Command{Version: CommandVersion, Op: OpNextSequence, Key: key, Counter: &CounterChange{Delta: batchSize}}
+}
+
+// NewCreateNamespaceCommand returns the command that creates namespace name with quotas of maxKeys keys and maxBytes
+// bytes, 0 for no quota
+func NewCreateNamespaceCommand(name string, maxKeys uint64, maxBytes uint64) Command {
+
+	return Command{
+		Version:   CommandVersion,
+		Op:        OpCreateNamespace,
+		Namespace: name,
+		Quota:     &NamespaceQuota{MaxKeys: maxKeys, MaxBytes: maxBytes},
+	}
+}
+
+// NewDeleteNamespaceCommand returns the command that deletes namespace name and every key stored in it
+func NewDeleteNamespaceCommand(name string) Command {
+
+	return Command{Version: CommandVersion, Op: OpDeleteNamespace, Namespace: name}
+}
+
+// NewCreateIndexCommand returns the command that creates the secondary index declared by definition
+func NewCreateIndexCommand(definition IndexDefinition) Command {
+
+	return Command{
+		Version:   CommandVersion,
+		Op:        OpCreateIndex,
+		Key:       definition.Prefix,
+		Namespace: definition.Namespace,
+		Index:     &IndexSpec{Name: definition.Name, Path: definition.Path},
+	}
+}
+
+// NewDropIndexCommand returns the command that drops the secondary index name
+func NewDropIndexCommand(name string) Command {
+
+	return Command{Version: CommandVersion, Op: OpDropIndex, Index: &IndexSpec{Name: name}}
+}
+
+// NewEnqueueCommand returns the command that appends a message holding body to queue, see MessageIDEnqueuedAt for
+// its ID
+func NewEnqueueCommand(queue string, body []byte) Command {
+
+	return Command{Version: CommandVersion, Op: OpEnqueue, Key: queue, Value: body}
+}
+
+// NewDequeueCommand returns the command that delivers the first visible message of queue, hiding it from other
+// consumers for visibilityTimeout seconds
+func NewDequeueCommand(queue string, visibilityTimeout int64) Command {
+
+	return Command{
+		Version: CommandVersion,
+		Op:      OpDequeue,
+		Key:     queue,
+		Queue:   &QueueDelivery{VisibilityTimeout: visibilityTimeout},
+	}
+}
+
+// NewAckCommand returns the command that removes message id from queue, provided delivery is its current delivery
+func NewAckCommand(queue string, id uint64, delivery uint64) Command {
+
+	return Command{
+		Version: CommandVersion,
+		Op:      OpAck,
+		Key:     queue,
+		Queue:   &QueueDelivery{MessageID: id, Delivery: delivery},
+	}
+}
+
+// NewNackCommand returns the command that makes message id of queue visible again, provided delivery is its current
+// delivery
+func NewNackCommand(queue string, id uint64, delivery uint64) Command {
+
+	return Command{
+		Version: CommandVersion,
+		Op:      OpNack,
+		Key:     queue,
+		Queue:   &QueueDelivery{MessageID: id, Delivery: delivery},
+	}
+}
+
+// NewPutChunkCommand returns the command that stages data as a chunk of a large value for key, see ChunkIDWrittenAt
+// for its ID
+func NewPutChunkCommand(key string, data []byte) Command {
+
+	return Command{Version: CommandVersion, Op: OpPutChunk, Key: key, Value: data}
+}
+
+// NewPutLargeCommand returns the manifest that stores the chunks staged for key, concatenated in the order of
+// chunks, as the value of key. size is the size of the value, the manifest fails if the chunks do not add up to it
+func NewPutLargeCommand(key string, chunks []uint64, size uint64) Command {
+
+	return Command{Version: CommandVersion, Op: OpPutLarge, Key: key, Large: &LargeValue{Chunks: chunks, Size: size}}
+}
+
+// NewDiscardChunksCommand returns the command that discards the chunks staged for key by an abandoned upload
+func NewDiscardChunksCommand(key string, chunks []uint64) Command {
+
+	return Command{Version: CommandVersion, Op: OpDiscardChunks, Key: key, Large: &LargeValue{Chunks: chunks}}
+}
+
+// IsConditional returns true for commands that are only applied when their condition holds
+func (command Command) IsConditional() bool {
+
+	return command.Op == OpPutIfAbsent || command.Op == OpPutIfVersion || command.Op == OpDeleteIfValue ||
+		command.Op == OpAcquireLock
+}
+
+// Validate checks that the command can be applied, it is called before a command is appended to the journal so a
+// malformed command is never committed.
+// Returns ErrUnsupportedCommandVersion if the envelope version is not known to this node
+// Returns ErrUnknownCommandOp if the operation is not supported
+// Returns ErrInvalidTxn if a transaction is missing or has an invalid compare or operation
+// Returns ErrInvalidCommandPayload if the command does not carry the payload of its operation or carries another
+// Returns ErrReservedKey if the key, or a transaction key, begins with a prefix reserved from the operation
+// Returns ErrEmptyCommandKey if the key is empty
+// Returns ErrInvalidLeaseTTL if a lease grant has a time to live below one second
+// Returns ErrInvalidDelta if a counter or sequence command has a Delta of 0
+// Returns ErrEmptyNamespace if a namespace command has no Namespace
+// Returns ErrInvalidNamespace if the Namespace contains '/'
+// Returns ErrEmptyIndexName if an index command has no index name
+// Returns ErrInvalidIndexPath if an index is created without a valid path
+// Returns ErrInvalidVisibilityTimeout if a dequeue has a visibility timeout below one second
+// Returns ErrNamespacedQueue if a queue command names a Namespace
+// Returns ErrNoChunks if a large value manifest or chunk discard names no chunk
+// Returns ErrLockWithoutLease if a lock is acquired without a lease
+func (command Command) Validate() error {
+
+	var err error
+
+	if command.Version == 0 || command.Version > CommandVersion {
+		err = ErrUnsupportedCommandVersion
+	} else if command.Op <= OpUnspecified || command.Op > OpAcquireLock || command.Op == OpGet {
+		err = ErrUnknownCommandOp
+	} else if command.Op == OpTxn {
+		err = validateTxn(command.Txn)
+	} else if !command.hasPayloadOfOp() || command.payloadCount() > 1 {
+		err = ErrInvalidCommandPayload
+	} else if command.namesReservedKey() {
+		err = ErrReservedKey
+	} else if command.Op == OpGrantLease && command.LeaseGrant.TTL < 1 {
+		err = ErrInvalidLeaseTTL
+	} else if command.Op == OpDequeue && command.Queue.VisibilityTimeout < 1 {
+		err = ErrInvalidVisibilityTimeout
+	} else if command.isQueueOp() && command.Namespace != "" {
+		err = ErrNamespacedQueue
+	} else if (command.Op == OpPutLarge || command.Op == OpDiscardChunks) && len(command.Large.Chunks) == 0 {
+		err = ErrNoChunks
+	} else if command.Op == OpAcquireLock && command.Lease == 0 {
+		err = ErrLockWithoutLease
+	} else if command.isCounter() && command.Counter.Delta == 0 {
+		err = ErrInvalidDelta
+	} else if command.isNamespaceAdmin() {
+		err = validateNamespace(command.Namespace)
+	} else if command.Namespace != "" && validateNamespace(command.Namespace) != nil {
+		err = ErrInvalidNamespace
+	} else if command.isIndexAdmin() && command.Index.Name == "" {
+		err = ErrEmptyIndexName
+	} else if command.Op == OpCreateIndex {
+		err = validateIndexPath(command.Index.Path)
+	} else if command.Key == "" && !command.isKeyless() {
+		err = ErrEmptyCommandKey
+	}
+
+	return err
+}
+
+// hasPayloadOfOp returns true if the command carries the payload its operation takes, or carries none when the
+// operation takes none
+func (command Command) hasPayloadOfOp() bool {
+
+	var hasPayload bool
+
+	switch {
+	case command.Op == OpPutIfVersion || command.Op == OpDeleteIfValue:
+		hasPayload = command.Condition != nil
+	case command.Op == OpTxn:
+		hasPayload = command.Txn != nil
+	case command.Op == OpCompact:
+		hasPayload = command.Compaction != nil
+	case command.Op == OpGrantLease:
+		hasPayload = command.LeaseGrant != nil
+	case command.isCounter():
+		hasPayload = command.Counter != nil
+	case command.Op == OpCreateNamespace:
+		hasPayload = command.Quota != nil
+	case command.isIndexAdmin():
+		hasPayload = command.Index != nil
+	case command.Op == OpDequeue || command.Op == OpAck || command.Op == OpNack:
+		hasPayload = command.Queue != nil
+	case command.Op == OpPutLarge || command.Op == OpDiscardChunks:
+		hasPayload = command.Large != nil
+	default:
+		hasPayload = command.payloadCount() == 0
+	}
+
+	return hasPayload
+}
+
+// namesReservedKey returns true if the command names a key the node keeps for itself that its operation may not write
+func (command Command) namesReservedKey() bool {
+
+	return isReservedFrom(command.Key, command.Op)
+}
+
+func (command Command) payloadCount() int {
+
+	count := 0
+
+	for _, isSet := range []bool{
+		command.Condition != nil,
+		command.Txn != nil,
+		command.Compaction != nil,
+		command.LeaseGrant != nil,
+		command.Counter != nil,
+		command.Quota != nil,
+		command.Index != nil,
+		command.Queue != nil,
+		command.Large != nil,
+	} {
+
+		if isSet {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (command Command) isKeyless() bool {
+
+	return command.Op == OpCompact || command.Op == OpGrantLease || command.Op == OpRevokeLease || command.isIndexAdmin()
+}
+
+func (command Command) isNamespaceAdmin() bool {
+
+	return command.Op == OpCreateNamespace || command.Op == OpDeleteNamespace
+}
+
+func (command Command) isIndexAdmin() bool {
+
+	return command.Op == OpCreateIndex || command.Op == OpDropIndex
+}
+
+func (command Command) isQueueOp() bool {
+
+	return command.Op >= OpEnqueue && command.Op <= OpNack
+}
+
+func (command Command) isChunkOp() bool {
+
+	return command.Op >= OpPutChunk && command.Op <= OpDiscardChunks
+}
+
+// countsAgainstQuota returns true if command writes a key or stages a chunk that counts against the quota of its
+// namespace, a transaction is counted by its puts
+func (command Command) countsAgainstQuota() bool {
+
+	return command.Op != OpDelete &&
+		command.Op != OpDeleteIfValue &&
+		command.Op != OpTxn &&
+		command.Op != OpCreateIndex &&
+		command.Op != OpDropIndex &&
+		command.Op != OpDiscardChunks &&
+		!command.isNamespaceAdmin()
+}
+
+func (command Command) isCounter() bool {
+
+	return command.Op == OpIncrement || command.Op == OpDecrement || command.Op == OpNextSequence
+}
+
+func validateTxn(txn *Txn) error {
+
+	err := ErrInvalidTxn
+
+	if txn != nil {
+		err = txn.validate()
+	}
+
+	return err
+}
+
+// Encode returns the protobuf encoding of the command envelope. Metadata is written in key order so equal commands
+// always encode to the same bytes
+func (command Command) Encode() []byte {
+
+	message := &envelope.Command{
+		Version:   command.Version,
+		Op:        envelope.Op(command.Op),
+		Key:       []byte(command.Key),
+		Value:     command.Value,
+		Metadata:  encodeMetadata(command.Metadata),
+		Lease:     command.Lease,
+		Namespace: []byte(command.Namespace),
+	}
+
+	switch {
+	case command.Condition != nil:
+		message.Payload = &envelope.Command_Condition{Condition: &envelope.Condition{
+			Version: command.Condition.Version,
+			Value:   command.Condition.Value,
+		}}
+	case command.Txn != nil:
+		message.Payload = &envelope.Command_Txn{Txn: command.Txn.encode()}
+	case command.Compaction != nil:
+		message.Payload = &envelope.Command_Compaction{Compaction: &envelope.Compaction{
+			Index: command.Compaction.Index,
+		}}
+	case command.LeaseGrant != nil:
+		message.Payload = &envelope.Command_LeaseGrant{LeaseGrant: &envelope.LeaseGrant{
+			TtlSeconds: command.LeaseGrant.TTL,
+		}}
+	case command.Counter != nil:
+		message.Payload = &envelope.Command_CounterChange{CounterChange: &envelope.CounterChange{
+			Delta: command.Counter.Delta,
+		}}
+	case command.Quota != nil:
+		message.Payload = &envelope.Command_NamespaceQuota{NamespaceQuota: &envelope.NamespaceQuota{
+			MaxKeys:  command.Quota.MaxKeys,
+			MaxBytes: command.Quota.MaxBytes,
+		}}
+	case command.Index != nil:
+		message.Payload = &envelope.Command_Index{Index: &envelope.IndexSpec{
+			Name: []byte(command.Index.Name),
+			Path: []byte(command.Index.Path),
+		}}
+	case command.Queue != nil:
+		message.Payload = &envelope.Command_QueueDelivery{QueueDelivery: &envelope.QueueDelivery{
+			MessageID:         command.Queue.MessageID,
+			Delivery:          command.Queue.Delivery,
+			VisibilityTimeout: command.Queue.VisibilityTimeout,
+		}}
+	case command.Large != nil:
+		message.Payload = &envelope.Command_LargeValue{LargeValue: &envelope.LargeValue{
+			Chunks: command.Large.Chunks,
+			Size:   command.Large.Size,
+		}}
+	}
+
+	// the envelope has no string fields, which are the only fields marshalling can fail on
+	encoded, _ := proto.Marshal(message)
+
+	return encoded
+}
+
+// DecodeCommand decodes a journal entry into a Command. Version 1 envelopes are read into the payloads of their
+// operations and entries written before the command envelope was introduced are JSON and are read as put or delete
+// commands.
+// Returns ErrCannotUnmarshalKeyValData if raw is neither a command envelope nor a legacy JSON entry
+// Returns ErrUnsupportedCommandVersion if the envelope was written by a newer node
+func DecodeCommand(raw []byte) (Command, error) {
+
+	var command Command
+	var err error
+
+	if isLegacyCommand(raw) {
+		command, err = decodeLegacyCommand(raw)
+	} else {
+		command, err = decodeEnvelope(raw)
+	}
+
+	if err == nil && command.Version > CommandVersion {
+		err = ErrUnsupportedCommandVersion
+	}
+
+	return command, err
+}
+
+func isLegacyCommand(raw []byte) bool {
+
+	trimmed := bytes.TrimLeft(raw, " \t\r\n")
+
+	// an envelope always starts with the version field tag, never with a JSON object
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+func decodeLegacyCommand(raw []byte) (Command, error) {
+
+	legacy := legacyCommand{}
+
+	err := json.Unmarshal(raw, &legacy)
+
+	command := Command{Version: CommandVersion, Key: legacy.Key, Value: legacy.Data}
+
+	if err != nil {
+		err = ErrCannotUnmarshalKeyValData
+	} else if legacy.Op == "" || legacy.Op == "PUT" {
+		command.Op = OpPut
+	} else if legacy.Op == "DELETE" {
+		command.Op = OpDelete
+	} else {
+		err = ErrUnknownCommandOp
+	}
+
+	return command, err
+}
+
+// decodeEnvelope decodes a version 2 envelope, or a version 1 envelope once the version has been read from it. The
+// fields the versions share have the same numbers, those only version 1 has are reserved in version 2
+func decodeEnvelope(raw []byte) (Command, error) {
+
+	message := &envelope.Command{}
+
+	err := proto.Unmarshal(raw, message)
+
+	var command Command
+
+	if err != nil || message.Version == 0 {
+		err = ErrCannotUnmarshalKeyValData
+	} else if message.Version == commandVersion1 {
+		command, err = decodeEnvelopeV1(raw)
+	} else {
+		command = decodeCommand(message)
+	}
+
+	return command, err
+}
+
+func decodeCommand(message *envelope.Command) Command {
+
+	command := Command{
+		Version:   message.Version,
+		Op:        CommandOp(message.Op),
+		Key:       string(message.Key),
+		Value:     message.Value,
+		Metadata:  decodeMetadata(message.Metadata),
+		Lease:     message.Lease,
+		Namespace: string(message.Namespace),
+	}
+
+	switch payload := message.Payload.(type) {
+	case *envelope.Command_Condition:
+		command.Condition = &Condition{Version: payload.Condition.Version, Value: payload.Condition.Value}
+	case *envelope.Command_Txn:
+		command.Txn = decodeTxn(payload.Txn)
+	case *envelope.Command_Compaction:
+		command.Compaction = &Compaction{Index: payload.Compaction.Index}
+	case *envelope.Command_LeaseGrant:
+		command.LeaseGrant = &LeaseGrant{TTL: payload.LeaseGrant.TtlSeconds}
+	case *envelope.Command_CounterChange:
+		command.Counter = &CounterChange{Delta: payload.CounterChange.Delta}
+	case *envelope.Command_NamespaceQuota:
+		command.Quota = &NamespaceQuota{
+			MaxKeys:  payload.NamespaceQuota.MaxKeys,
+			MaxBytes: payload.NamespaceQuota.MaxBytes,
+		}
+	case *envelope.Command_Index:
+		command.Index = &IndexSpec{Name: string(payload.Index.Name), Path: string(payload.Index.Path)}
+	case *envelope.Command_QueueDelivery:
+		command.Queue = &QueueDelivery{
+			MessageID:         payload.QueueDelivery.MessageID,
+			Delivery:          payload.QueueDelivery.Delivery,
+			VisibilityTimeout: payload.QueueDelivery.VisibilityTimeout,
+		}
+	case *envelope.Command_LargeValue:
+		command.Large = &LargeValue{Chunks: payload.LargeValue.Chunks, Size: payload.LargeValue.Size}
+	}
+
+	return command
+}
+
+// decodeEnvelopeV1 decodes a version 1 envelope, moving the parameters of its operation into their payload. The
+// command keeps version 1, it is applied the same as the version 2 command it decodes to
+func decodeEnvelopeV1(raw []byte) (Command, error) {
+
+	message := &envelope.CommandV1{}
+
+	err := proto.Unmarshal(raw, message)
+
+	command := Command{
+		Version:   message.Version,
+		Op:        CommandOp(message.Op),
+		Key:       string(message.Key),
+		Value:     message.Value,
+		Metadata:  decodeMetadata(message.Metadata),
+		Lease:     message.Lease,
+		Namespace: string(message.Namespace),
+	}
+
+	switch {
+	case command.Op == OpPutIfVersion || command.Op == OpDeleteIfValue:
+		command.Condition = &Condition{Version: message.ExpectedVersion, Value: message.ExpectedValue}
+	case command.Op == OpTxn && message.Txn != nil:
+		command.Txn = decodeTxn(message.Txn)
+	case command.Op == OpCompact:
+		command.Compaction = &Compaction{Index: message.CompactIndex}
+	case command.Op == OpGrantLease:
+		command.LeaseGrant = &LeaseGrant{TTL: message.LeaseTTL}
+	case command.isCounter():
+		command.Counter = &CounterChange{Delta: message.Delta}
+	case command.Op == OpCreateNamespace:
+		command.Quota = &NamespaceQuota{MaxKeys: message.MaxKeys, MaxBytes: message.MaxBytes}
+	case command.isIndexAdmin():
+		command.Index = &IndexSpec{Name: string(message.IndexName), Path: string(message.IndexPath)}
+	case command.Op == OpDequeue || command.Op == OpAck || command.Op == OpNack:
+		command.Queue = &QueueDelivery{
+			MessageID:         message.MessageID,
+			Delivery:          message.Delivery,
+			VisibilityTimeout: message.VisibilityTimeout,
+		}
+	case command.Op == OpPutLarge || command.Op == OpDiscardChunks:
+		command.Large = &LargeValue{Chunks: message.Chunks, Size: message.ValueSize}
+	}
+
+	if err != nil {
+		err = ErrCannotUnmarshalKeyValData
+	}
+
+	return command, err
+}
+
+// encodeMetadata returns the entries of metadata in key order
+func encodeMetadata(metadata map[string]string) []*envelope.MetadataEntry {
+
+	keys := make([]string, 0, len(metadata))
+
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var entries []*envelope.MetadataEntry
+
+	for _, key := range keys {
+		entries = append(entries, &envelope.MetadataEntry{Key: []byte(key), Value: []byte(metadata[key])})
+	}
+
+	return entries
+}
+
+func decodeMetadata(entries []*envelope.MetadataEntry) map[string]string {
+
+	var metadata map[string]string
+
+	for _, entry := range entries {
+
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+
+		metadata[string(entry.Key)] = string(entry.Value)
+	}
+
+	return metadata
+}
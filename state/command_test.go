@@ -0,0 +1,117 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestWhenCommandIsEncodedThenItDecodesToTheSameCommand(t *testing.T) {
+
+	command := NewPutCommand("theKey", []byte("theValue"))
+	command.Metadata = map[string]string{"client": "acceptance", "trace": "abc123"}
+
+	decoded, err := DecodeCommand(command.Encode())
+
+	if err != nil || !reflect.DeepEqual(command, decoded) {
+		t.Errorf("Expected %+v to survive encoding but got %+v and error '%v'", command, decoded, err)
+	}
+}
+
+func TestWhenLegacyJsonPutIsDecodedThenItIsReadAsAPutCommand(t *testing.T) {
+
+	decoded, err := DecodeCommand(createKeyValRequestItem("theKey", []byte("theValue")))
+
+	if err != nil || !reflect.DeepEqual(NewPutCommand("theKey", []byte("theValue")), decoded) {
+		t.Errorf("Legacy put should decode as a put command but got %+v and error '%v'", decoded, err)
+	}
+}
+
+func TestWhenLegacyJsonDeleteIsDecodedThenItIsReadAsADeleteCommand(t *testing.T) {
+
+	decoded, err := DecodeCommand([]byte(`{"Op":"DELETE","Key":"theKey"}`))
+
+	if err != nil || !reflect.DeepEqual(NewDeleteCommand("theKey"), decoded) {
+		t.Errorf("Legacy delete should decode as a delete command but got %+v and error '%v'", decoded, err)
+	}
+}
+
+func TestWhenEnvelopeHasANewerVersionThenItIsRejected(t *testing.T) {
+
+	command := NewPutCommand("theKey", nil)
+	command.Version = CommandVersion + 1
+
+	_, err := DecodeCommand(command.Encode())
+
+	if ErrUnsupportedCommandVersion != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrUnsupportedCommandVersion, err)
+	}
+}
+
+func TestWhenEnvelopeHasAnUnknownFieldThenItIsSkipped(t *testing.T) {
+
+	encoded := protowire.AppendTag(NewDeleteCommand("theKey").Encode(), 99, protowire.BytesType)
+	encoded = protowire.AppendString(encoded, "added by a later minor version")
+
+	decoded, err := DecodeCommand(encoded)
+
+	if err != nil || decoded.Key != "theKey" {
+		t.Errorf("Unknown field should have been skipped but got %+v and error '%v'", decoded, err)
+	}
+}
+
+func TestWhenEnvelopeIsTruncatedThenItCannotBeDecoded(t *testing.T) {
+
+	encoded := NewPutCommand("theKey", []byte("theValue")).Encode()
+
+	_, err := DecodeCommand(encoded[:len(encoded)-3])
+
+	if ErrCannotUnmarshalKeyValData != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrCannotUnmarshalKeyValData, err)
+	}
+}
+
+func TestWhenCommandHasAnEmptyKeyThenItIsNotValid(t *testing.T) {
+
+	if NewPutCommand("", []byte("theValue")).Validate() != ErrEmptyCommandKey {
+		t.Errorf("Command with an empty key should not be valid")
+	}
+}
+
+func TestWhenVersion1EnvelopeIsDecodedThenItsParametersAreReadIntoThePayloadOfItsOperation(t *testing.T) {
+
+	encoded := protowire.AppendTag(nil, 1, protowire.VarintType)
+	encoded = protowire.AppendVarint(encoded, 1)
+	encoded = protowire.AppendTag(encoded, 2, protowire.VarintType)
+	encoded = protowire.AppendVarint(encoded, uint64(OpPutIfVersion))
+	encoded = protowire.AppendTag(encoded, 3, protowire.BytesType)
+	encoded = protowire.AppendString(encoded, "theKey")
+	encoded = protowire.AppendTag(encoded, 4, protowire.BytesType)
+	encoded = protowire.AppendString(encoded, "theValue")
+	encoded = protowire.AppendTag(encoded, 6, protowire.VarintType)
+	encoded = protowire.AppendVarint(encoded, 3)
+
+	expected := NewPutIfVersionCommand("theKey", []byte("theValue"), 3)
+	expected.Version = 1
+
+	decoded, err := DecodeCommand(encoded)
+
+	if err != nil || !reflect.DeepEqual(expected, decoded) || decoded.Validate() != nil {
+		t.Errorf("Expected the version 1 envelope to decode to %+v but got %+v and error '%v'", expected, decoded, err)
+	}
+}
+
+func TestWhenCommandCarriesThePayloadOfAnotherOperationThenItIsNotValid(t *testing.T) {
+
+	missing := NewIncrementCommand("hits", 1)
+	missing.Counter = nil
+
+	other := NewPutCommand("theKey", []byte("theValue"))
+	other.Counter = &CounterChange{Delta: 1}
+
+	if missing.Validate() != ErrInvalidCommandPayload || other.Validate() != ErrInvalidCommandPayload {
+		t.Errorf("Should have received error '%v' but got '%v' and '%v'", ErrInvalidCommandPayload,
+			missing.Validate(), other.Validate())
+	}
+}
@@ -0,0 +1,82 @@
+package state
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+var (
+	ErrNotACounter     = errors.New("key holds a value that is not a counter")
+	ErrCounterOverflow = errors.New("counter change would overflow a 64 bit integer")
+	ErrInvalidDelta    = errors.New("counter delta and sequence batch size must be at least one")
+)
+
+// applyCounterCommand increments, decrements or reserves a sequence range from the counter under the command key.
+// A counter is an ordinary key holding a signed 64 bit integer in decimal, a key that does not exist counts as 0, so
+// counters are read with a get and every change is versioned, kept in history and watched like a put. A sequence is
+// a counter holding the last ID reserved, reserving a batch adds the batch size and the result Counter is the last
+// ID of the range. A change that would overflow or a key that is not a counter leaves the key unchanged
+func (state *journalApplier) applyCounterCommand(command Command, index uint64) ApplyResult {
+
+	writer := state.writerAt(state.keyspace, index)
+
+	current, exists := writer.get(command.Key)
+
+	count, err := decodeCounter(current.value, exists)
+
+	if err == nil {
+		count, err = changeCounter(count, command)
+	}
+
+	result := ApplyResult{Index: index, Version: current.version, Err: err}
+
+	if err == nil {
+
+		result.Applied, result.Counter = true, count
+		result.Version = writer.put(command.Key, []byte(strconv.FormatInt(count, 10)), command.Lease)
+	}
+
+	return result
+}
+
+func decodeCounter(value []byte, exists bool) (int64, error) {
+
+	var count int64
+	var err error
+
+	if exists {
+		count, err = strconv.ParseInt(string(value), 10, 64)
+	}
+
+	if err != nil {
+		err = ErrNotACounter
+	}
+
+	return count, err
+}
+
+// changeCounter returns count changed by the command Delta.
+// Returns ErrCounterOverflow if the result does not fit in an int64, or a sequence would hand out an ID below 1
+func changeCounter(count int64, command Command) (int64, error) {
+
+	var err error
+
+	delta := int64(command.Counter.Delta)
+
+	if command.Counter.Delta > math.MaxInt64 {
+		err = ErrCounterOverflow
+	} else if command.Op == OpDecrement && count < math.MinInt64+delta {
+		err = ErrCounterOverflow
+	} else if command.Op != OpDecrement && count > math.MaxInt64-delta {
+		err = ErrCounterOverflow
+	} else if command.Op == OpNextSequence && count < 0 {
+		err = ErrCounterOverflow
+	} else if command.Op == OpDecrement {
+		count -= delta
+	} else {
+		count += delta
+	}
+
+	return count, err
+}
@@ -0,0 +1,88 @@
+package state
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/jrobison153/raft/journal"
+)
+
+func TestWhenCounterIsIncrementedAndDecrementedThenItHoldsTheNetChange(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewIncrementCommand("hits", 5),
+		NewIncrementCommand("hits", 3),
+		NewDecrementCommand("hits", 10))
+
+	value, _ := stateMachine.ResolveRequestToData(createKeyRequest("hits"))
+
+	if results[2].Counter != -2 || results[2].Version != 3 || string(value) != "-2" {
+		t.Errorf("Counter should have been -2 at version 3 but got %+v and value '%s'", results[2], value)
+	}
+}
+
+func TestWhenSequenceReservesBatchesThenTheRangesFollowOnFromEachOther(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine, NewNextSequenceCommand("orders", 100), NewNextSequenceCommand("orders", 50))
+
+	if results[0].Counter != 100 || results[1].Counter != 150 {
+		t.Errorf("Sequence should have reserved up to 100 and then 150 but got %+v", results)
+	}
+}
+
+func TestWhenKeyDoesNotHoldACounterThenIncrementFailsAndTheKeyIsUnchanged(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewPutCommand("name", []byte("not a number")),
+		NewIncrementCommand("name", 1))
+
+	value, _ := stateMachine.ResolveRequestToData(createKeyRequest("name"))
+
+	if ErrNotACounter != results[1].Err || results[1].Applied || string(value) != "not a number" {
+		t.Errorf("Unexpected increment result %+v and value '%s'", results[1], value)
+	}
+}
+
+func TestWhenIncrementWouldOverflowThenItFails(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewIncrementCommand("hits", math.MaxInt64),
+		NewIncrementCommand("hits", 1))
+
+	if ErrCounterOverflow != results[1].Err {
+		t.Errorf("Should have received error '%v' but got %+v", ErrCounterOverflow, results[1])
+	}
+}
+
+func TestWhenCounterCommandsAreEncodedThenTheyDecodeToTheSameCommands(t *testing.T) {
+
+	for _, command := range []Command{
+		NewIncrementCommand("k", 2),
+		NewDecrementCommand("k", 3),
+		NewNextSequenceCommand("k", 1000)} {
+
+		decoded, err := DecodeCommand(command.Encode())
+
+		if err != nil || !reflect.DeepEqual(command, decoded) {
+			t.Errorf("Expected %+v to survive encoding but got %+v and error '%v'", command, decoded, err)
+		}
+	}
+}
+
+func TestWhenSequenceBatchIsEmptyThenTheCommandIsNotValid(t *testing.T) {
+
+	command := NewNextSequenceCommand("orders", 0)
+
+	if ErrInvalidDelta != command.Validate() {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrInvalidDelta, command.Validate())
+	}
+}
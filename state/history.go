@@ -0,0 +1,291 @@
+package state
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// DefaultHistoryRetention is the number of journal indexes of MVCC history a state machine keeps, older history is
+// compacted away as entries are applied
+const DefaultHistoryRetention = 10000
+
+var (
+	ErrCompacted   = errors.New("requested index has been compacted, its history is no longer held")
+	ErrFutureIndex = errors.New("requested index has not been applied yet")
+)
+
+// revision is the value a key was given by the journal entry at index until the entry at replacedAt replaced or
+// deleted it, or its removal by the entry at index when deleted is set
+type revision struct {
+	index      uint64
+	value      versionedValue
+	replacedAt uint64
+	deleted    bool
+}
+
+// mvccHistory keeps the revisions of every key that have been replaced or deleted so the keyspace can be read as it
+// was at a past journal index, the current value of a key is only held by the keyspace. History below the compacted
+// index is discarded, a read at index i needs the revisions still current at i so compacting to i drops the values
+// replaced and the deletes made at or below it. mvccHistory is safe for concurrent use
+type mvccHistory struct {
+	mutex          sync.RWMutex
+	revisions      map[string][]revision
+	compactedIndex uint64
+	appliedIndex   int64
+	retention      uint64
+}
+
+func newMVCCHistory(retention uint64) *mvccHistory {
+
+	return &mvccHistory{
+		revisions:    make(map[string][]revision),
+		appliedIndex: -1,
+		retention:    retention,
+	}
+}
+
+// record adds a revision of key that has been replaced or deleted, or a delete of key
+func (history *mvccHistory) record(key string, change revision) {
+
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	history.revisions[key] = append(history.revisions[key], change)
+}
+
+// appliedThrough marks the entry at index as applied, compacting history that has fallen out of the retention
+// window. Compaction runs once a second window has built up so its cost is spread over many entries
+func (history *mvccHistory) appliedThrough(index uint64) {
+
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	history.appliedIndex = int64(index)
+
+	if history.retention > 0 && index >= history.compactedIndex+2*history.retention {
+		history.compactTo(index - history.retention)
+	}
+}
+
+// readableAt checks that the keyspace can be read as it was once the journal entry at index had been applied.
+// Returns ErrCompacted if index is below the compacted index
+// Returns ErrFutureIndex if the entry at index has not been applied yet
+func (history *mvccHistory) readableAt(index uint64) error {
+
+	history.mutex.RLock()
+	defer history.mutex.RUnlock()
+
+	return history.checkReadableAt(index)
+}
+
+func (history *mvccHistory) checkReadableAt(index uint64) error {
+
+	var err error
+
+	if int64(index) > history.appliedIndex {
+		err = ErrFutureIndex
+	} else if index < history.compactedIndex {
+		err = ErrCompacted
+	}
+
+	return err
+}
+
+// getAt returns the value of key as it was once the journal entry at index had been applied, when that value has
+// since been replaced or deleted. A value still current at index is only found in the keyspace.
+// Returns ErrCompacted if index is below the compacted index
+// Returns ErrFutureIndex if the entry at index has not been applied yet
+func (history *mvccHistory) getAt(key string, index uint64) (versionedValue, bool, error) {
+
+	history.mutex.RLock()
+	defer history.mutex.RUnlock()
+
+	var value versionedValue
+	var found bool
+
+	err := history.checkReadableAt(index)
+
+	if err == nil {
+
+		revisions := history.revisions[key]
+		position := newestRevisionAtOrBelow(revisions, index)
+
+		if position >= 0 && !revisions[position].deleted {
+			value = revisions[position].value
+			found = true
+		}
+	}
+
+	return value, found, err
+}
+
+// compact discards the history needed only for reads below index, an index beyond the last applied entry compacts
+// up to that entry. Compacting never moves the compacted index backwards
+func (history *mvccHistory) compact(index uint64) {
+
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	if int64(index) > history.appliedIndex {
+		index = uint64(history.appliedIndex)
+	}
+
+	if history.appliedIndex >= 0 && index > history.compactedIndex {
+		history.compactTo(index)
+	}
+}
+
+// lastApplied returns the index of the last entry applied, -1 if none have been
+func (history *mvccHistory) lastApplied() int64 {
+
+	history.mutex.RLock()
+	defer history.mutex.RUnlock()
+
+	return history.appliedIndex
+}
+
+// compactedThrough returns the lowest index history can still be read at
+func (history *mvccHistory) compactedThrough() uint64 {
+
+	history.mutex.RLock()
+	defer history.mutex.RUnlock()
+
+	return history.compactedIndex
+}
+
+func (history *mvccHistory) compactTo(index uint64) {
+
+	for key, revisions := range history.revisions {
+
+		keepFrom := sort.Search(len(revisions), func(position int) bool {
+			return revisions[position].isCurrentAbove(index)
+		})
+
+		if keepFrom >= len(revisions) {
+			delete(history.revisions, key)
+		} else if keepFrom > 0 {
+			history.revisions[key] = append([]revision{}, revisions[keepFrom:]...)
+		}
+	}
+
+	history.compactedIndex = index
+}
+
+// isCurrentAbove returns true if a read above index can still need the revision, a value is needed until it was
+// replaced and a delete until it was made. Revisions are recorded in index order so once one is needed every later
+// one is too
+func (revision revision) isCurrentAbove(index uint64) bool {
+
+	current := revision.replacedAt > index
+
+	if revision.deleted {
+		current = revision.index > index
+	}
+
+	return current
+}
+
+// newestRevisionAtOrBelow returns the position of the last revision made at or below index, -1 if there is none
+func newestRevisionAtOrBelow(revisions []revision, index uint64) int {
+
+	return sort.Search(len(revisions), func(position int) bool {
+		return revisions[position].index > index
+	}) - 1
+}
+
+// revisionWriter applies the writes of the journal entry at index to data. Puts are stamped with their version and
+// indexes, the values they replace and deletes are recorded in the MVCC history before data changes so a reader
+// that finds a newer value in data finds the one it replaced in the history. Every write is collected as a
+// WatchEvent in events and the lease table
+// tracks which keys are attached to which lease. A writer with staged set leaves the history, lease, namespace,
+// index and chunk tables alone and collects their updates in staged instead, see journalApplier atomically
+type revisionWriter struct {
+	data       keyValues
+	history    *mvccHistory
+	leases     *leaseTable
+	namespaces *namespaceTable
+	indexes    *indexTable
+	chunks     *chunkTable
+	index      uint64
+	events     *[]WatchEvent
+	staged     *[]func()
+}
+
+func (writer revisionWriter) get(key string) (versionedValue, bool) {
+
+	return writer.data.get(key)
+}
+
+// put stores value under key attached to lease, 0 for none, and returns the new version of the key
+func (writer revisionWriter) put(key string, value []byte, lease uint64) uint64 {
+
+	previous, exists := writer.data.get(key)
+
+	stored := versionedValue{
+		value:       value,
+		version:     previous.version + 1,
+		createIndex: previous.createIndex,
+		modifyIndex: writer.index,
+		lease:       lease,
+	}
+
+	if !exists {
+		stored.createIndex = writer.index
+	} else {
+		writer.record(key, revision{index: previous.modifyIndex, value: previous, replacedAt: writer.index})
+	}
+
+	writer.data.set(key, stored)
+	writer.publish(revision{index: writer.index, value: stored}.event(key))
+
+	writer.updateTables(func() {
+		writer.leases.reattach(key, previous.lease, lease)
+		writer.namespaces.store(key, value)
+		writer.indexes.store(key, value)
+		writer.chunks.release(key)
+	})
+
+	return stored.version
+}
+
+func (writer revisionWriter) delete(key string) {
+
+	previous, exists := writer.data.get(key)
+
+	if exists {
+		deleted := revision{index: writer.index, deleted: true}
+
+		writer.record(key, revision{index: previous.modifyIndex, value: previous, replacedAt: writer.index})
+		writer.record(key, deleted)
+		writer.data.delete(key)
+		writer.publish(deleted.event(key))
+
+		writer.updateTables(func() {
+			writer.leases.reattach(key, previous.lease, 0)
+			writer.namespaces.release(key)
+			writer.indexes.release(key)
+			writer.chunks.release(key)
+		})
+	}
+}
+
+func (writer revisionWriter) record(key string, change revision) {
+
+	writer.updateTables(func() { writer.history.record(key, change) })
+}
+
+func (writer revisionWriter) publish(event WatchEvent) {
+
+	*writer.events = append(*writer.events, event)
+}
+
+// updateTables applies update to the side tables now, or stages it when the writer is staged
+func (writer revisionWriter) updateTables(update func()) {
+
+	if writer.staged != nil {
+		*writer.staged = append(*writer.staged, update)
+	} else {
+		update()
+	}
+}
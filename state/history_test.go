@@ -0,0 +1,152 @@
+package state
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jrobison153/raft/journal"
+)
+
+func TestWhenKeyIsPutThenItCarriesTheIndexesOfTheCreatingAndLastPuts(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine,
+		NewPutCommand("theKey", []byte("first")),
+		NewPutCommand("otherKey", []byte("other")),
+		NewPutCommand("theKey", []byte("second")))
+
+	item, err := stateMachine.ResolveRequestToItem(createKeyRequest("theKey"))
+
+	if err != nil || item.CreateIndex != 0 || item.ModifyIndex != 2 || item.Version != 2 ||
+		string(item.Value) != "second" {
+
+		t.Errorf("Unexpected item %+v and error '%v'", item, err)
+	}
+}
+
+func TestWhenKeyIsReadAtAPastIndexThenItsValueAtThatIndexIsReturned(t *testing.T) {
+
+	stateMachine := setupBTreeStateMachine(map[string][]byte{})
+
+	for index, value := range []string{"first", "second", "third"} {
+		stateMachine.applyAt(NewPutCommand("theKey", []byte(value)).Encode(), uint64(index))
+	}
+
+	item, err := stateMachine.ResolveRequestToItem(createKeyRequestAt("theKey", 1))
+
+	if err != nil || string(item.Value) != "second" || item.Version != 2 || item.ModifyIndex != 1 {
+		t.Errorf("Unexpected item %+v and error '%v'", item, err)
+	}
+}
+
+func TestWhenKeyHasBeenDeletedThenItIsStillReadableAtAnIndexBeforeTheDelete(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine, NewPutCommand("theKey", []byte("theValue")), NewDeleteCommand("theKey"))
+
+	before, beforeErr := stateMachine.ResolveRequestToItem(createKeyRequestAt("theKey", 0))
+	_, afterErr := stateMachine.ResolveRequestToItem(createKeyRequestAt("theKey", 1))
+
+	if beforeErr != nil || string(before.Value) != "theValue" || ErrKeyNotFound != afterErr {
+		t.Errorf("Unexpected item %+v with errors '%v' and '%v'", before, beforeErr, afterErr)
+	}
+}
+
+func TestWhenReadingAtAnIndexNotYetAppliedThenErrFutureIndexIsReturned(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine, NewPutCommand("theKey", []byte("theValue")))
+
+	_, err := stateMachine.ResolveRequestToItem(createKeyRequestAt("theKey", 5))
+
+	if ErrFutureIndex != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrFutureIndex, err)
+	}
+}
+
+func TestWhenHistoryIsCompactedThenOnlyReadsBelowTheCompactedIndexFail(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine,
+		NewPutCommand("theKey", []byte("first")),
+		NewPutCommand("theKey", []byte("second")),
+		NewPutCommand("otherKey", []byte("other")),
+		NewCompactCommand(2))
+
+	_, compactedErr := stateMachine.ResolveRequestToItem(createKeyRequestAt("theKey", 0))
+	item, err := stateMachine.ResolveRequestToItem(createKeyRequestAt("theKey", 2))
+
+	if ErrCompacted != compactedErr || err != nil || string(item.Value) != "second" ||
+		stateMachine.CompactedIndex() != 2 {
+
+		t.Errorf("Unexpected item %+v with errors '%v' and '%v'", item, compactedErr, err)
+	}
+}
+
+func TestWhenHistoryOutgrowsItsRetentionThenTheOldestHistoryIsCompacted(t *testing.T) {
+
+	history := newMVCCHistory(10)
+
+	for index := uint64(0); index < 25; index++ {
+
+		history.record("theKey", revision{index: index, value: versionedValue{version: index + 1}, replacedAt: index + 1})
+		history.appliedThrough(index)
+	}
+
+	_, _, compactedErr := history.getAt("theKey", 5)
+	value, _, err := history.getAt("theKey", 14)
+
+	if ErrCompacted != compactedErr || err != nil || value.version != 15 || len(history.revisions["theKey"]) != 15 {
+		t.Errorf("Unexpected value %+v with errors '%v' and '%v'", value, compactedErr, err)
+	}
+}
+
+func TestWhenTxnComparesTheModifyIndexThenOnlyAnUnchangedKeyIsWritten(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	compareAndSwap := func(value string) Command {
+		return NewTxnCommand(Txn{
+			Compares: []Compare{{Key: "theKey", Target: CompareModifyIndex, Result: CompareEqual, Version: 0}},
+			Success:  []TxnOp{{Op: OpPut, Key: "theKey", Value: []byte(value)}},
+		})
+	}
+
+	results := applyCommands(stateMachine, NewPutCommand("theKey", []byte("first")), compareAndSwap("swapped"),
+		compareAndSwap("stale"))
+
+	if !results[1].Applied || results[1].Responses[0].ModifyIndex != 1 || results[1].Responses[0].CreateIndex != 0 ||
+		results[2].Applied {
+
+		t.Errorf("Unexpected compare and swap results %+v", results)
+	}
+}
+
+func TestWhenHistoryIsCompactedThenKeysThatWereNotChangedSinceAreServedFromTheKeyspace(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine,
+		NewPutCommand("theKey", []byte("first")),
+		NewPutCommand("theKey", []byte("second")),
+		NewPutCommand("otherKey", []byte("other")),
+		NewCompactCommand(3))
+
+	item, err := stateMachine.ResolveRequestToItem(createKeyRequestAt("theKey", 3))
+
+	if err != nil || string(item.Value) != "second" || len(stateMachine.history.revisions) != 0 {
+		t.Errorf("Expected only the keyspace to hold the current values but got %+v, error '%v' and history %+v",
+			item, err, stateMachine.history.revisions)
+	}
+}
+
+func createKeyRequestAt(key string, index uint64) []byte {
+
+	marshalledKey, _ := json.Marshal(Key{Key: key, AtIndex: &index})
+
+	return marshalledKey
+}
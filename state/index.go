@@ -0,0 +1,327 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrIndexNotFound     = errors.New("secondary index does not exist, it has not been created or has been dropped")
+	ErrEmptyIndexName    = errors.New("index command must name an index")
+	ErrInvalidIndexPath  = errors.New("index path must be a non empty, dot separated JSON path")
+	ErrInvalidIndexValue = errors.New("index query value must be a JSON string, number or boolean")
+)
+
+// IndexDefinition declares a secondary index on the JSON documents stored under the keys beginning with Prefix in
+// Namespace. Path is the dot separated path of the indexed field, numeric segments index into arrays. Documents
+// that are not JSON or hold no string, number or boolean at Path are left out of the index
+type IndexDefinition struct {
+	Name      string
+	Namespace string
+	Prefix    string
+	Path      string
+}
+
+// indexTable holds the secondary indexes and the indexed value of every key in each. indexTable is safe for
+// concurrent use
+type indexTable struct {
+	mutex   sync.RWMutex
+	indexes map[string]*secondaryIndex
+}
+
+// secondaryIndex maps the indexed values of an index to the keyspace keys holding them and back
+type secondaryIndex struct {
+	definition     IndexDefinition
+	keyspacePrefix string
+	path           []string
+	keysByValue    map[string]map[string]bool
+	valueByKey     map[string]string
+}
+
+func newIndexTable() *indexTable {
+
+	return &indexTable{
+		indexes: make(map[string]*secondaryIndex),
+	}
+}
+
+// create adds the index declared by definition, returning it and false if an index with its name already exists
+func (table *indexTable) create(definition IndexDefinition) (*secondaryIndex, bool) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	index, exists := table.indexes[definition.Name]
+
+	if !exists {
+
+		index = &secondaryIndex{
+			definition:     definition,
+			keyspacePrefix: NamespacedKey(definition.Namespace, definition.Prefix),
+			path:           strings.Split(definition.Path, "."),
+			keysByValue:    make(map[string]map[string]bool),
+			valueByKey:     make(map[string]string),
+		}
+
+		table.indexes[definition.Name] = index
+	}
+
+	return index, !exists
+}
+
+// drop removes the index name, returning whether it existed
+func (table *indexTable) drop(name string) bool {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	_, exists := table.indexes[name]
+
+	delete(table.indexes, name)
+
+	return exists
+}
+
+// dropNamespace removes every index of namespace
+func (table *indexTable) dropNamespace(namespace string) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	for name, index := range table.indexes {
+
+		if index.definition.Namespace == namespace {
+			delete(table.indexes, name)
+		}
+	}
+}
+
+// store indexes value as the document now held by keyspace key in every index covering the key. An index of the
+// default namespace does not cover the reserved keys under its prefix
+func (table *indexTable) store(key string, value []byte) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	for _, index := range table.indexes {
+
+		if strings.HasPrefix(key, index.keyspacePrefix) && (index.definition.Namespace != "" || !IsReservedKey(key)) {
+			index.add(key, value)
+		}
+	}
+}
+
+// release removes keyspace key from every index, the key was deleted
+func (table *indexTable) release(key string) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	for _, index := range table.indexes {
+		index.remove(key)
+	}
+}
+
+// query returns, in key order, up to limit keys of the index name whose document holds value, 0 for no limit.
+// Returns ErrIndexNotFound if the index does not exist
+// Returns ErrInvalidIndexValue if value is not a JSON string, number or boolean
+func (table *indexTable) query(name string, value []byte, limit int) ([]string, error) {
+
+	table.mutex.RLock()
+	defer table.mutex.RUnlock()
+
+	var keys []string
+
+	index, exists := table.indexes[name]
+
+	indexed, isIndexable := canonicalIndexValue(value)
+
+	err := ErrIndexNotFound
+
+	if exists && !isIndexable {
+		err = ErrInvalidIndexValue
+	} else if exists {
+		keys, err = index.keysHolding(indexed, limit), nil
+	}
+
+	return keys, err
+}
+
+func (index *secondaryIndex) add(key string, document []byte) {
+
+	index.remove(key)
+
+	value, isIndexable := extractIndexValue(document, index.path)
+
+	if isIndexable {
+
+		if index.keysByValue[value] == nil {
+			index.keysByValue[value] = make(map[string]bool)
+		}
+
+		index.keysByValue[value][key] = true
+		index.valueByKey[key] = value
+	}
+}
+
+func (index *secondaryIndex) remove(key string) {
+
+	value, isIndexed := index.valueByKey[key]
+
+	if isIndexed {
+
+		delete(index.keysByValue[value], key)
+		delete(index.valueByKey, key)
+
+		if len(index.keysByValue[value]) == 0 {
+			delete(index.keysByValue, value)
+		}
+	}
+}
+
+// keysHolding returns the keys holding value in key order, with the namespace of the index taken off
+func (index *secondaryIndex) keysHolding(value string, limit int) []string {
+
+	keys := make([]string, 0, len(index.keysByValue[value]))
+
+	for key := range index.keysByValue[value] {
+		keys = append(keys, strings.TrimPrefix(key, NamespacedKey(index.definition.Namespace, "")))
+	}
+
+	sort.Strings(keys)
+
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	return keys
+}
+
+// extractIndexValue returns the canonical JSON encoding of the string, number or boolean at path in document and
+// whether there is one
+func extractIndexValue(document []byte, path []string) (string, bool) {
+
+	var field interface{}
+
+	isIndexable := json.Unmarshal(document, &field) == nil
+
+	for _, segment := range path {
+
+		if isIndexable {
+			field, isIndexable = fieldAt(field, segment)
+		}
+	}
+
+	return canonicalScalar(field, isIndexable)
+}
+
+// canonicalIndexValue returns the canonical JSON encoding of the JSON scalar value, so equal values match whatever
+// their formatting, and whether value is a scalar at all
+func canonicalIndexValue(value []byte) (string, bool) {
+
+	var field interface{}
+
+	isIndexable := json.Unmarshal(value, &field) == nil
+
+	return canonicalScalar(field, isIndexable)
+}
+
+func canonicalScalar(field interface{}, isIndexable bool) (string, bool) {
+
+	var canonical []byte
+
+	switch field.(type) {
+	case string, float64, bool:
+		canonical, _ = json.Marshal(field)
+	default:
+		isIndexable = false
+	}
+
+	return string(canonical), isIndexable
+}
+
+// fieldAt returns the member segment of an object or the element at position segment of an array
+func fieldAt(field interface{}, segment string) (interface{}, bool) {
+
+	var child interface{}
+	var found bool
+
+	switch container := field.(type) {
+	case map[string]interface{}:
+		child, found = container[segment]
+	case []interface{}:
+
+		position, err := strconv.Atoi(segment)
+
+		if err == nil && position >= 0 && position < len(container) {
+			child, found = container[position], true
+		}
+	}
+
+	return child, found
+}
+
+func validateIndexPath(path string) error {
+
+	var err error
+
+	for _, segment := range strings.Split(path, ".") {
+
+		if segment == "" {
+			err = ErrInvalidIndexPath
+		}
+	}
+
+	return err
+}
+
+// QueryIndex returns, in key order, up to limit keys whose JSON document holds value at the path of the index name,
+// 0 for no limit. Keys of an index on a namespace are returned without the namespace.
+// Returns ErrIndexNotFound if the index does not exist
+// Returns ErrInvalidIndexValue if value is not a JSON string, number or boolean
+// Returns ErrCorruptionAlarm if a corrupt journal entry has been seen, the state can no longer be trusted
+func (state *journalApplier) QueryIndex(name string, value []byte, limit int) ([]string, error) {
+
+	var keys []string
+	err := ErrCorruptionAlarm
+
+	if !state.IsCorruptionAlarmRaised() {
+		keys, err = state.indexes.query(name, value, limit)
+	}
+
+	return keys, err
+}
+
+// applyIndexCommand creates or drops a secondary index. A created index is built from the documents already stored
+// under its prefix, from then on every write maintains it
+func (state *journalApplier) applyIndexCommand(command Command, index uint64) ApplyResult {
+
+	result := ApplyResult{Index: index}
+
+	if command.Op == OpCreateIndex {
+
+		definition := IndexDefinition{
+			Name:      command.Index.Name,
+			Namespace: command.Namespace,
+			Prefix:    command.Key,
+			Path:      command.Index.Path,
+		}
+
+		var created *secondaryIndex
+		created, result.Applied = state.indexes.create(definition)
+
+		if result.Applied {
+			state.keyspace.withPrefix(created.keyspacePrefix, func(key string, value versionedValue) {
+				state.indexes.store(key, value.value)
+			})
+		}
+	} else {
+		result.Applied = state.indexes.drop(command.Index.Name)
+	}
+
+	return result
+}
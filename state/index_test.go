@@ -0,0 +1,150 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jrobison153/raft/journal"
+)
+
+func TestWhenIndexIsCreatedThenDocumentsAlreadyStoredUnderItsPrefixAreIndexed(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine,
+		NewPutCommand("user/2", []byte(`{"address": {"city": "Paris"}}`)),
+		NewPutCommand("user/1", []byte(`{"address": {"city": "Paris"}}`)),
+		NewPutCommand("user/3", []byte(`{"address": {"city": "Oslo"}}`)),
+		NewPutCommand("order/1", []byte(`{"address": {"city": "Paris"}}`)),
+		NewCreateIndexCommand(IndexDefinition{Name: "by-city", Prefix: "user/", Path: "address.city"}))
+
+	keys, err := stateMachine.QueryIndex("by-city", []byte(`"Paris"`), 0)
+
+	if err != nil || !reflect.DeepEqual([]string{"user/1", "user/2"}, keys) {
+		t.Errorf("Expected keys [user/1 user/2] but got %v and error '%v'", keys, err)
+	}
+}
+
+func TestWhenIndexedDocumentsAreUpdatedAndDeletedThenTheIndexFollows(t *testing.T) {
+
+	stateMachine := NewBTreeStateMachine(journal.NewJournalSpy())
+
+	commands := []Command{
+		NewPutCommand("user/1", []byte(`{"age": 30}`)),
+		NewPutCommand("user/2", []byte(`{"age": 30}`)),
+		NewPutCommand("users", []byte(`{"age": 30}`)),
+		NewCreateIndexCommand(IndexDefinition{Name: "by-age", Prefix: "user/", Path: "age"}),
+		NewPutCommand("user/3", []byte(`{"age": 30}`)),
+		NewPutCommand("user/1", []byte(`{"age": 31}`)),
+		NewDeleteCommand("user/2"),
+	}
+
+	for index, command := range commands {
+		stateMachine.applyAt(command.Encode(), uint64(index))
+	}
+
+	keys, _ := stateMachine.QueryIndex("by-age", []byte(`30.0`), 0)
+
+	if !reflect.DeepEqual([]string{"user/3"}, keys) {
+		t.Errorf("Expected keys [user/3] but got %v", keys)
+	}
+}
+
+func TestWhenIndexIsQueriedWithALimitThenOnlyTheFirstKeysAreReturned(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine,
+		NewCreateIndexCommand(IndexDefinition{Name: "by-tag", Prefix: "doc/", Path: "tags.0"}),
+		NewPutCommand("doc/c", []byte(`{"tags": ["red"]}`)),
+		NewPutCommand("doc/a", []byte(`{"tags": ["red"]}`)),
+		NewPutCommand("doc/b", []byte(`{"tags": ["red", "blue"]}`)),
+		NewPutCommand("doc/d", []byte(`not json`)))
+
+	keys, _ := stateMachine.QueryIndex("by-tag", []byte(`"red"`), 2)
+
+	if !reflect.DeepEqual([]string{"doc/a", "doc/b"}, keys) {
+		t.Errorf("Expected keys [doc/a doc/b] but got %v", keys)
+	}
+}
+
+func TestWhenIndexIsOnANamespaceThenOnlyItsKeysAreReturnedWithoutTheNamespace(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine,
+		NewCreateNamespaceCommand("team-a", 0, 0),
+		NewCreateIndexCommand(IndexDefinition{Name: "by-owner", Namespace: "team-a", Path: "owner"}),
+		putInNamespace("team-a", "job/1", `{"owner": "ana"}`),
+		NewPutCommand("job/2", []byte(`{"owner": "ana"}`)))
+
+	keys, _ := stateMachine.QueryIndex("by-owner", []byte(`"ana"`), 0)
+
+	if !reflect.DeepEqual([]string{"job/1"}, keys) {
+		t.Errorf("Expected keys [job/1] but got %v", keys)
+	}
+}
+
+func TestWhenNamespaceIsDeletedThenItsIndexesAreDropped(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine,
+		NewCreateNamespaceCommand("team-a", 0, 0),
+		NewCreateIndexCommand(IndexDefinition{Name: "by-owner", Namespace: "team-a", Path: "owner"}),
+		NewDeleteNamespaceCommand("team-a"))
+
+	_, err := stateMachine.QueryIndex("by-owner", []byte(`"ana"`), 0)
+
+	if ErrIndexNotFound != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrIndexNotFound, err)
+	}
+}
+
+func TestWhenIndexIsCreatedTwiceThenTheSecondCreateIsNotApplied(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewCreateIndexCommand(IndexDefinition{Name: "by-age", Path: "age"}),
+		NewCreateIndexCommand(IndexDefinition{Name: "by-age", Path: "years"}),
+		NewDropIndexCommand("by-age"),
+		NewDropIndexCommand("by-age"))
+
+	if !results[0].Applied || results[1].Applied || !results[2].Applied || results[3].Applied {
+		t.Errorf("Unexpected index command results %+v", results)
+	}
+}
+
+func TestWhenIndexIsQueriedWithAValueThatIsNotAJSONScalarThenTheQueryFails(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine, NewCreateIndexCommand(IndexDefinition{Name: "by-age", Path: "age"}))
+
+	_, err := stateMachine.QueryIndex("by-age", []byte(`{"age": 30}`), 0)
+
+	if ErrInvalidIndexValue != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrInvalidIndexValue, err)
+	}
+}
+
+func TestWhenIndexPathHasAnEmptySegmentThenTheCommandIsInvalid(t *testing.T) {
+
+	err := NewCreateIndexCommand(IndexDefinition{Name: "by-city", Path: "address..city"}).Validate()
+
+	if ErrInvalidIndexPath != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrInvalidIndexPath, err)
+	}
+}
+
+func TestWhenIndexCommandIsEncodedThenItDecodesToTheSameCommand(t *testing.T) {
+
+	command := NewCreateIndexCommand(IndexDefinition{Name: "by-city", Namespace: "a", Prefix: "u/", Path: "city"})
+
+	decoded, err := DecodeCommand(command.Encode())
+
+	if err != nil || !reflect.DeepEqual(command, decoded) {
+		t.Errorf("Expected %+v to survive encoding but got %+v and error '%v'", command, decoded, err)
+	}
+}
@@ -0,0 +1,523 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/jrobison153/raft/journal"
+	"github.com/prometheus/client_golang/prometheus"
+	"log"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// journalApplier renders the committed entries of a journal into a keyspace. It loads the keyspace from the
+// journal on Start and then applies every newly committed entry. State machines embed a journalApplier and differ
+// only in the keyspace they store data in
+type journalApplier struct {
+	journal                journal.Journaler
+	keyspace               keyspace
+	commitListenCh         chan uint64
+	highestSeenCommitIndex atomic.Int64
+	isRunning              bool
+	corruptionAlarm        atomic.Bool
+	results                *ApplyRegistry
+	history                *mvccHistory
+	watchers               *WatchHub
+	appliedEvents          []WatchEvent
+	leases                 *leaseTable
+	namespaces             *namespaceTable
+	indexes                *indexTable
+	queues                 *queueTable
+	chunks                 *chunkTable
+	appliedIndex           atomic.Int64
+	notifiedCommitIndex    atomic.Int64
+	appliedMutex           sync.Mutex
+	appliedCh              chan struct{}
+}
+
+func newJournalApplier(journal journal.Journaler, keyspace keyspace) *journalApplier {
+
+	state := &journalApplier{
+		journal:    journal,
+		keyspace:   keyspace,
+		isRunning:  false,
+		results:    NewApplyRegistry(DefaultAppliedResultRetention),
+		history:    newMVCCHistory(DefaultHistoryRetention),
+		watchers:   NewWatchHub(DefaultWatchBufferSize),
+		leases:     newLeaseTable(),
+		namespaces: newNamespaceTable(),
+		indexes:    newIndexTable(),
+		queues:     newQueueTable(),
+		chunks:     newChunkTable(),
+		appliedCh:  make(chan struct{}),
+	}
+
+	state.highestSeenCommitIndex.Store(-1)
+	state.appliedIndex.Store(-1)
+	state.notifiedCommitIndex.Store(-1)
+
+	return state
+}
+
+// Start registers this state machine as a listener on all commit changes of the journal.
+// Returns ErrAlreadyRunning if this method is called more than once
+// Returns ErrCannotUnmarshalKeyValData if there is are failures trying to load state machine with key value data
+// from the journal
+func (state *journalApplier) Start() error {
+
+	var err error
+
+	if !state.isRunning {
+
+		err = state.renderCurrentStateFromJournal()
+
+		if err == nil {
+
+			state.commitListenCh = make(chan uint64)
+			state.journal.NotifyOfAllCommitChanges(state.commitListenCh)
+
+			go state.listenForStateChanges(state.commitListenCh)
+
+			state.isRunning = true
+		}
+	} else {
+		err = ErrAlreadyRunning
+	}
+
+	return err
+}
+
+// ResolveRequestToData returns the value associated with request.
+// Returns ErrKeyNotfound If the key is not found in the data store
+// Returns ErrCorruptionAlarm if a corrupt journal entry has been seen, the state can no longer be trusted
+func (state *journalApplier) ResolveRequestToData(request []byte) ([]byte, error) {
+
+	item, err := state.ResolveRequestToItem(request)
+
+	return item.Value, err
+}
+
+// ResolveRequestToItem returns the value and metadata of the key in request, as it is now or, when the request has
+// an AtIndex, as it was once the journal entry at that index had been applied.
+// Returns ErrKeyNotfound If the key is not found in the data store
+// Returns ErrCompacted if the history at AtIndex has been compacted away
+// Returns ErrFutureIndex if the entry at AtIndex has not been applied yet
+// Returns ErrCorruptionAlarm if a corrupt journal entry has been seen, the state can no longer be trusted
+func (state *journalApplier) ResolveRequestToItem(request []byte) (VersionedItem, error) {
+
+	key := &Key{}
+
+	marshalErr := json.Unmarshal(request, key)
+
+	var err error
+	var item VersionedItem
+
+	if state.IsCorruptionAlarmRaised() {
+		err = ErrCorruptionAlarm
+	} else if marshalErr == nil {
+		item, err = state.resolveKey(key)
+	} else {
+		log.Printf("Invalid request '%v', must be type Key with the following structure %+v", request, Key{})
+		err = ErrInvalidKeyRequest
+	}
+
+	return item, err
+}
+
+func (state *journalApplier) resolveKey(key *Key) (VersionedItem, error) {
+
+	var versioned versionedValue
+	var found bool
+	var err error
+
+	namespacedKey := NamespacedKey(key.Namespace, key.Key)
+
+	if isHiddenKey(key.Key) {
+		err = ErrReservedKey
+	} else if key.AtIndex != nil {
+		versioned, found, err = state.resolveKeyAt(namespacedKey, *key.AtIndex)
+	} else {
+		versioned, found = state.keyspace.get(namespacedKey)
+	}
+
+	if err == nil && !found {
+		err = ErrKeyNotFound
+	}
+
+	item := VersionedItem{
+		Key:         key.Key,
+		Value:       versioned.value,
+		Version:     versioned.version,
+		CreateIndex: versioned.createIndex,
+		ModifyIndex: versioned.modifyIndex,
+	}
+
+	return item, err
+}
+
+// resolveKeyAt returns the value of keyspace key once the journal entry at index had been applied. The keyspace is
+// read before the history, a value replaced in between is recorded in the history before the keyspace changes
+func (state *journalApplier) resolveKeyAt(key string, index uint64) (versionedValue, bool, error) {
+
+	err := state.history.readableAt(index)
+
+	current, found := state.keyspace.get(key)
+
+	if err == nil && (!found || current.modifyIndex > index) {
+		current, found, err = state.history.getAt(key, index)
+	}
+
+	return current, found, err
+}
+
+// CompactedIndex returns the lowest journal index the state machine can still be read at
+func (state *journalApplier) CompactedIndex() uint64 {
+
+	return state.history.compactedThrough()
+}
+
+// NotifyOfApplyOnIndexOnce writes the result of applying the journal entry at index to ch once it has been applied.
+// Returns ErrApplyResultExpired if the entry was applied so long ago its result is no longer held
+func (state *journalApplier) NotifyOfApplyOnIndexOnce(index uint64, ch chan ApplyResult) error {
+
+	return state.results.NotifyOfApplyOnIndexOnce(index, ch)
+}
+
+// AppliedIndex returns the index of the last journal entry applied to the state machine, -1 if none has been
+func (state *journalApplier) AppliedIndex() int64 {
+
+	return state.appliedIndex.Load()
+}
+
+// WaitForApplied blocks until the journal entry at index has been applied, a read made after it returns sees every
+// write committed up to index.
+// Returns the error of ctx if it is done before the entry is applied
+// Returns ErrCorruptionAlarm if a corrupt journal entry is seen, entries after it are never applied
+func (state *journalApplier) WaitForApplied(ctx context.Context, index uint64) error {
+
+	var err error
+
+	for state.AppliedIndex() < int64(index) && err == nil {
+
+		appliedCh := state.nextApplied()
+
+		if state.IsCorruptionAlarmRaised() {
+			err = ErrCorruptionAlarm
+		} else if state.AppliedIndex() < int64(index) {
+
+			select {
+			case <-appliedCh:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+	}
+
+	return err
+}
+
+// HighestSeenCommitIndex returns the highest commit index the journal has notified the state machine of, -1 if it
+// has not been notified of any
+func (state *journalApplier) HighestSeenCommitIndex() int64 {
+
+	return state.highestSeenCommitIndex.Load()
+}
+
+// ApplyLag returns the number of committed journal entries the state machine has been notified of but not yet applied
+func (state *journalApplier) ApplyLag() int64 {
+
+	lag := state.notifiedCommitIndex.Load() - state.AppliedIndex()
+
+	if lag < 0 {
+		lag = 0
+	}
+
+	return lag
+}
+
+// Collectors returns the Prometheus collectors exposing how far the state machine has applied the journal
+func (state *journalApplier) Collectors() []prometheus.Collector {
+
+	return []prometheus.Collector{
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "raft_state_machine_applied_index",
+				Help: "Index of the last journal entry applied to the state machine",
+			},
+			func() float64 { return float64(state.AppliedIndex()) }),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name: "raft_state_machine_apply_lag_entries",
+				Help: "Committed journal entries not yet applied to the state machine",
+			},
+			func() float64 { return float64(state.ApplyLag()) }),
+	}
+}
+
+// IsCorruptionAlarmRaised returns true once the state machine has been asked to apply a journal entry whose
+// checksum does not match its item. The alarm is never cleared, the node needs operator attention
+func (state *journalApplier) IsCorruptionAlarmRaised() bool {
+
+	return state.corruptionAlarm.Load()
+}
+
+func (state *journalApplier) TypeOfLogger() string {
+
+	return reflect.TypeOf(state.journal).String()
+}
+
+func (state *journalApplier) listenForStateChanges(ch chan uint64) {
+
+	var index uint64
+	for {
+
+		index = <-ch
+
+		state.notifiedCommitIndex.Store(int64(index))
+
+		if !state.IsCorruptionAlarmRaised() {
+			state.applyCommittedUpTo(index)
+		}
+	}
+}
+
+// applyCommittedUpTo applies the entries committed after the highest seen commit index up to index. An index that
+// has already been applied inverts the range and is skipped, any other failure to read the entries is logged and
+// they are read again on the next commit notification
+func (state *journalApplier) applyCommittedUpTo(index uint64) {
+
+	startIndex := uint64(state.HighestSeenCommitIndex() + 1)
+	entries, err := state.journal.GetAllEntriesBetween(startIndex, index)
+
+	if err == nil {
+
+		for entryIndex := startIndex; entries.HasNext() && !state.IsCorruptionAlarmRaised(); entryIndex++ {
+
+			journalEntry, _ := entries.Next()
+
+			// explicitly ignoring error response here, nothing we can do if the data
+			// in the journal is corrupt or invalid. Error will be logged and corrupt entries raise the alarm
+			//nolint:errcheck
+			state.updateStateWithJournalItem(journalEntry, entryIndex)
+		}
+
+		state.highestSeenCommitIndex.Store(int64(index))
+	} else if err != journal.ErrInvertedIndexes {
+		log.Printf("unable to read the entries committed up to index %d: %v", index, err)
+	}
+}
+
+func (state *journalApplier) renderCurrentStateFromJournal() error {
+
+	committedEntries, err := state.journal.OpenCommittedEntries()
+
+	if err == nil {
+
+		defer committedEntries.Close()
+
+		err = state.applyAll(committedEntries)
+	}
+
+	return err
+}
+
+func (state *journalApplier) applyAll(committedEntries journal.Cursor) error {
+
+	var err error
+
+	for committedEntries.HasNext() && err == nil {
+
+		var committedEntry journal.Entry
+		committedEntry, err = committedEntries.Next()
+
+		if err == nil {
+
+			err = state.updateStateWithJournalItem(committedEntry, committedEntries.Index())
+			state.highestSeenCommitIndex.Store(int64(committedEntries.Index()))
+		}
+	}
+
+	return err
+}
+
+func (state *journalApplier) updateStateWithJournalItem(journalEntry journal.Entry, index uint64) error {
+
+	err := journalEntry.Verify()
+
+	result := ApplyResult{Index: index, Err: ErrCorruptionAlarm}
+
+	if err == nil {
+
+		result = state.applyAt(journalEntry.Item, index)
+		err = result.Err
+	} else {
+		state.raiseCorruptionAlarm()
+	}
+
+	state.results.Publish(result)
+
+	return err
+}
+
+// applyAt applies the journal command at index, marks the keyspace and its history as applied through index and
+// then publishes the changes it made to the watchers
+func (state *journalApplier) applyAt(rawCommand []byte, index uint64) ApplyResult {
+
+	state.appliedEvents = state.appliedEvents[:0]
+
+	result := state.applyCommand(rawCommand, index)
+
+	state.keyspace.appliedThrough(index)
+	state.history.appliedThrough(index)
+
+	state.watchers.Publish(index, state.appliedEvents)
+
+	state.appliedIndex.Store(int64(index))
+	state.signalApplied()
+
+	return result
+}
+
+// nextApplied returns the channel closed the next time an entry is applied or the corruption alarm is raised
+func (state *journalApplier) nextApplied() chan struct{} {
+
+	state.appliedMutex.Lock()
+	defer state.appliedMutex.Unlock()
+
+	return state.appliedCh
+}
+
+// signalApplied wakes everyone waiting in WaitForApplied so they can check the applied index again
+func (state *journalApplier) signalApplied() {
+
+	state.appliedMutex.Lock()
+	defer state.appliedMutex.Unlock()
+
+	close(state.appliedCh)
+	state.appliedCh = make(chan struct{})
+}
+
+func (state *journalApplier) raiseCorruptionAlarm() {
+
+	log.Printf("CORRUPTION ALARM: journal entry failed checksum verification, no longer serving reads")
+
+	state.corruptionAlarm.Store(true)
+	state.results.Fail(ErrCorruptionAlarm)
+	state.signalApplied()
+}
+
+// applyCommand applies a journal command to the keyspace. A delete removes the key outright, the delete entry
+// in the journal is the tombstone, so a snapshot taken after it has been applied no longer holds the key and a
+// journal compacted up to that snapshot can drop both the put and the delete. The MVCC history still holds the
+// deleted value until it is compacted
+func (state *journalApplier) applyCommand(rawCommand []byte, index uint64) ApplyResult {
+
+	command, err := DecodeCommand(rawCommand)
+
+	if err == nil {
+		err = command.Validate()
+	}
+
+	if err == nil && !command.isNamespaceAdmin() && !command.isIndexAdmin() {
+		command.Key = NamespacedKey(command.Namespace, command.Key)
+	}
+
+	result := ApplyResult{Index: index, Err: err}
+
+	if err != nil {
+		log.Printf("unable to update state machine, journal entry is not a valid command: %v", err)
+	} else if command.Op == OpGrantLease || command.Op == OpRevokeLease {
+		result = state.applyLeaseCommand(command, index)
+	} else if command.isNamespaceAdmin() {
+		result = state.applyNamespaceCommand(command, index)
+	} else if command.Namespace != "" && !state.namespaces.exists(command.Namespace) {
+		result.Err = ErrNamespaceNotFound
+	} else if state.exceedsQuota(command) {
+		result.Err = ErrQuotaExceeded
+	} else if command.isIndexAdmin() {
+		result = state.applyIndexCommand(command, index)
+	} else if command.isQueueOp() {
+		result = state.applyQueueCommand(command, index)
+	} else if command.Lease != 0 && !state.leases.exists(command.Lease) {
+		result.Err = ErrLeaseNotFound
+	} else if command.Op == OpCompact {
+		state.history.compact(command.Compaction.Index)
+		result.Applied = true
+	} else if command.isChunkOp() {
+		result = state.applyChunkCommand(command, index)
+	} else if command.isCounter() {
+		result = state.applyCounterCommand(command, index)
+	} else if command.Op == OpTxn {
+		state.atomically(index, func(writer revisionWriter) {
+			result.Applied, result.Responses = command.Txn.apply(writer, command.Namespace)
+		})
+	} else {
+		result.Applied, result.Version = state.applyValidCommand(state.writerAt(state.keyspace, index), command)
+	}
+
+	return result
+}
+
+// applyValidCommand applies command if its condition holds, returning whether it was applied and the resulting
+// version of its key
+func (state *journalApplier) applyValidCommand(data revisionWriter, command Command) (bool, uint64) {
+
+	current, exists := data.get(command.Key)
+
+	applied := true
+	version := current.version
+
+	switch {
+	case (command.Op == OpPutIfAbsent || command.Op == OpAcquireLock) && exists:
+		applied = false
+	case command.Op == OpPutIfVersion && current.version != command.Condition.Version:
+		applied = false
+	case command.Op == OpDeleteIfValue && (!exists || !bytes.Equal(current.value, command.Condition.Value)):
+		applied = false
+	case command.Op == OpDelete || command.Op == OpDeleteIfValue:
+		data.delete(command.Key)
+		version = 0
+	default:
+		version = data.put(command.Key, command.Value, command.Lease)
+	}
+
+	return applied, version
+}
+
+// atomically applies the writes made by write through a staged writer at index as one atomic write to the keyspace.
+// The history, lease, namespace and index tables are updated as the writes become visible so no reader sees them
+// ahead of the keyspace
+func (state *journalApplier) atomically(index uint64, write func(writer revisionWriter)) {
+
+	var staged []func()
+
+	state.keyspace.atomically(func(data keyValues) {
+
+		writer := state.writerAt(data, index)
+		writer.staged = &staged
+
+		write(writer)
+	}, func() {
+
+		for _, update := range staged {
+			update()
+		}
+	})
+}
+
+// writerAt returns the revisionWriter applying the writes of the journal entry at index to data
+func (state *journalApplier) writerAt(data keyValues, index uint64) revisionWriter {
+
+	return revisionWriter{
+		data:       data,
+		history:    state.history,
+		leases:     state.leases,
+		namespaces: state.namespaces,
+		indexes:    state.indexes,
+		chunks:     state.chunks,
+		index:      index,
+		events:     &state.appliedEvents,
+	}
+}
@@ -0,0 +1,126 @@
+package state
+
+import (
+	"strings"
+	"sync"
+)
+
+// keyValues reads and writes versioned key value data
+type keyValues interface {
+	get(key string) (versionedValue, bool)
+
+	// set stores value under key as is, a revisionWriter works out its version and indexes
+	set(key string, value versionedValue)
+	delete(key string)
+}
+
+// keyspace stores the key value data rendered from the journal by a journalApplier
+type keyspace interface {
+	keyValues
+
+	// atomically runs apply against the keyspace, readers see either none or all of the writes made by apply. commit
+	// is called as the writes become visible, before any reader can see them
+	atomically(apply func(data keyValues), commit func())
+
+	// appliedThrough is called once the journal entry at index has been applied to the keyspace
+	appliedThrough(index uint64)
+
+	// withPrefix visits every key beginning with prefix and its value, in no particular order
+	withPrefix(prefix string, visit func(key string, value versionedValue))
+}
+
+// versionedValue is a value and its metadata. The version is 1 when the key is created and incremented on each put,
+// createIndex is the journal index of the put that created the key and modifyIndex that of the last put to it.
+// lease is the ID of the lease the key is attached to, 0 for none. Deleting a key discards all of them
+type versionedValue struct {
+	value       []byte
+	version     uint64
+	createIndex uint64
+	modifyIndex uint64
+	lease       uint64
+}
+
+// mapKeyspace guards an unordered Go map with a read write lock, readers never see a map that is being written
+type mapKeyspace struct {
+	mutex  sync.RWMutex
+	values mapValues
+}
+
+// mapValues reads and writes a Go map without any locking
+type mapValues map[string]versionedValue
+
+func newMapKeyspace() *mapKeyspace {
+
+	return &mapKeyspace{
+		values: make(mapValues),
+	}
+}
+
+func (data *mapKeyspace) get(key string) (versionedValue, bool) {
+
+	data.mutex.RLock()
+	defer data.mutex.RUnlock()
+
+	return data.values.get(key)
+}
+
+func (data *mapKeyspace) set(key string, value versionedValue) {
+
+	data.mutex.Lock()
+	defer data.mutex.Unlock()
+
+	data.values.set(key, value)
+}
+
+func (data *mapKeyspace) delete(key string) {
+
+	data.mutex.Lock()
+	defer data.mutex.Unlock()
+
+	data.values.delete(key)
+}
+
+// atomically holds the write lock while apply writes to the map and commit is called, a map cannot be cloned
+// cheaply like the B-Tree so readers wait for the writes of a transaction instead of reading a copy
+func (data *mapKeyspace) atomically(apply func(data keyValues), commit func()) {
+
+	data.mutex.Lock()
+	defer data.mutex.Unlock()
+
+	apply(data.values)
+	commit()
+}
+
+func (data *mapKeyspace) appliedThrough(uint64) {
+	// the journalApplier tracks the applied index, a map keeps no record of it
+}
+
+func (data *mapKeyspace) withPrefix(prefix string, visit func(key string, value versionedValue)) {
+
+	data.mutex.RLock()
+	defer data.mutex.RUnlock()
+
+	for key, value := range data.values {
+
+		if strings.HasPrefix(key, prefix) {
+			visit(key, value)
+		}
+	}
+}
+
+func (values mapValues) get(key string) (versionedValue, bool) {
+
+	value, ok := values[key]
+
+	return value, ok
+}
+
+func (values mapValues) set(key string, value versionedValue) {
+
+	values[key] = value
+}
+
+func (values mapValues) delete(key string) {
+
+	delete(values, key)
+}
@@ -0,0 +1,179 @@
+package state
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrLeaseNotFound   = errors.New("lease does not exist, it has expired or been revoked")
+	ErrInvalidLeaseTTL = errors.New("lease time to live must be at least one second")
+)
+
+// Lease is a time to live that keys can be attached to, revoking the lease deletes every key attached to it. The
+// state machines only record which leases exist and the keys attached to them, when a lease expires is decided by
+// the leader which journals the revoke so every replica deletes the same keys at the same index.
+// A lease ID is one more than the index of the journal entry that granted it so an ID of 0 never names a lease
+type Lease struct {
+	ID         uint64
+	TTLSeconds int64
+	Keys       []string
+}
+
+// leaseTable holds the granted leases and the keys attached to each. leaseTable is safe for concurrent use
+type leaseTable struct {
+	mutex  sync.RWMutex
+	leases map[uint64]*grantedLease
+}
+
+type grantedLease struct {
+	ttlSeconds int64
+	keys       map[string]bool
+}
+
+// LeaseIDGrantedAt returns the ID of the lease granted by the journal entry at index
+func LeaseIDGrantedAt(index uint64) uint64 {
+
+	return index + 1
+}
+
+func newLeaseTable() *leaseTable {
+
+	return &leaseTable{
+		leases: make(map[uint64]*grantedLease),
+	}
+}
+
+func (table *leaseTable) grant(id uint64, ttlSeconds int64) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	table.leases[id] = &grantedLease{ttlSeconds: ttlSeconds, keys: make(map[string]bool)}
+}
+
+// revoke removes the lease, returning the keys attached to it in key order and whether it existed
+func (table *leaseTable) revoke(id uint64) ([]string, bool) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	var keys []string
+
+	granted, exists := table.leases[id]
+
+	if exists {
+
+		keys = granted.sortedKeys()
+		delete(table.leases, id)
+	}
+
+	return keys, exists
+}
+
+func (table *leaseTable) exists(id uint64) bool {
+
+	table.mutex.RLock()
+	defer table.mutex.RUnlock()
+
+	_, exists := table.leases[id]
+
+	return exists
+}
+
+// reattach moves key from lease from to lease to, either may be 0 for no lease
+func (table *leaseTable) reattach(key string, from uint64, to uint64) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	if granted, exists := table.leases[from]; exists {
+		delete(granted.keys, key)
+	}
+
+	if granted, exists := table.leases[to]; exists {
+		granted.keys[key] = true
+	}
+}
+
+func (table *leaseTable) lease(id uint64) (Lease, bool) {
+
+	table.mutex.RLock()
+	defer table.mutex.RUnlock()
+
+	granted, exists := table.leases[id]
+
+	var lease Lease
+
+	if exists {
+		lease = Lease{ID: id, TTLSeconds: granted.ttlSeconds, Keys: granted.sortedKeys()}
+	}
+
+	return lease, exists
+}
+
+// all returns every granted lease in ID order
+func (table *leaseTable) all() []Lease {
+
+	table.mutex.RLock()
+	defer table.mutex.RUnlock()
+
+	leases := make([]Lease, 0, len(table.leases))
+
+	for id, granted := range table.leases {
+		leases = append(leases, Lease{ID: id, TTLSeconds: granted.ttlSeconds, Keys: granted.sortedKeys()})
+	}
+
+	sort.Slice(leases, func(i, j int) bool { return leases[i].ID < leases[j].ID })
+
+	return leases
+}
+
+func (granted *grantedLease) sortedKeys() []string {
+
+	keys := make([]string, 0, len(granted.keys))
+
+	for key := range granted.keys {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Lease returns the granted lease with id
+func (state *journalApplier) Lease(id uint64) (Lease, bool) {
+
+	return state.leases.lease(id)
+}
+
+// Leases returns every granted lease in ID order, a newly elected leader starts tracking their expiry from these
+func (state *journalApplier) Leases() []Lease {
+
+	return state.leases.all()
+}
+
+// applyLeaseCommand grants or revokes a lease. Revoking deletes the keys attached to the lease as one atomic write
+func (state *journalApplier) applyLeaseCommand(command Command, index uint64) ApplyResult {
+
+	result := ApplyResult{Index: index, Applied: true}
+
+	if command.Op == OpGrantLease {
+		state.leases.grant(LeaseIDGrantedAt(index), command.LeaseGrant.TTL)
+	} else {
+
+		var keys []string
+		keys, result.Applied = state.leases.revoke(command.Lease)
+
+		state.atomically(index, func(writer revisionWriter) {
+
+			for _, key := range keys {
+				writer.delete(key)
+			}
+		})
+	}
+
+	return result
+}
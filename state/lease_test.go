@@ -0,0 +1,91 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jrobison153/raft/journal"
+)
+
+func TestWhenLeaseIsRevokedThenTheKeysAttachedToItAreDeleted(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	id := LeaseIDGrantedAt(0)
+
+	results := applyCommands(stateMachine,
+		NewGrantLeaseCommand(10),
+		putWithLease("session", id),
+		NewPutCommand("kept", []byte("value")),
+		NewRevokeLeaseCommand(id))
+
+	_, sessionErr := stateMachine.ResolveRequestToData(createKeyRequest("session"))
+	_, keptErr := stateMachine.ResolveRequestToData(createKeyRequest("kept"))
+	_, isGranted := stateMachine.Lease(id)
+
+	if !results[3].Applied || ErrKeyNotFound != sessionErr || keptErr != nil || isGranted {
+		t.Errorf("Unexpected revoke result %+v with errors '%v' and '%v'", results[3], sessionErr, keptErr)
+	}
+}
+
+func TestWhenPutNamesALeaseThatDoesNotExistThenThePutFails(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine, putWithLease("session", 42))
+
+	_, err := stateMachine.ResolveRequestToData(createKeyRequest("session"))
+
+	if ErrLeaseNotFound != results[0].Err || ErrKeyNotFound != err {
+		t.Errorf("Unexpected put result %+v and error '%v'", results[0], err)
+	}
+}
+
+func TestWhenLeasedKeyIsPutWithoutTheLeaseThenItIsDetachedFromTheLease(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	id := LeaseIDGrantedAt(0)
+
+	applyCommands(stateMachine,
+		NewGrantLeaseCommand(10),
+		putWithLease("session", id),
+		putWithLease("other", id),
+		NewPutCommand("session", []byte("no lease")),
+		NewDeleteCommand("other"))
+
+	lease, _ := stateMachine.Lease(id)
+
+	if len(lease.Keys) != 0 || lease.TTLSeconds != 10 {
+		t.Errorf("Lease should have no keys attached but got %+v", lease)
+	}
+}
+
+func TestWhenLeaseCommandsAreEncodedThenTheyDecodeToTheSameCommands(t *testing.T) {
+
+	for _, command := range []Command{NewGrantLeaseCommand(30), NewRevokeLeaseCommand(7), putWithLease("k", 7)} {
+
+		decoded, err := DecodeCommand(command.Encode())
+
+		if err != nil || !reflect.DeepEqual(command, decoded) {
+			t.Errorf("Expected %+v to survive encoding but got %+v and error '%v'", command, decoded, err)
+		}
+	}
+}
+
+func TestWhenLeaseIsGrantedWithoutATimeToLiveThenTheCommandIsNotValid(t *testing.T) {
+
+	command := NewGrantLeaseCommand(0)
+
+	if ErrInvalidLeaseTTL != command.Validate() {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrInvalidLeaseTTL, command.Validate())
+	}
+}
+
+func putWithLease(key string, lease uint64) Command {
+
+	command := NewPutCommand(key, []byte("value"))
+	command.Lease = lease
+
+	return command
+}
@@ -1,6 +1,7 @@
 package state
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/jrobison153/raft/journal"
 	"reflect"
@@ -12,9 +13,9 @@ func TestWhenMapStateMachineIsCreatedThenTheHighestSeenCommitIndexIsInitialized(
 
 	_, stateMachine := setup()
 
-	if stateMachine.highestSeenCommitIndex != -1 {
+	if stateMachine.HighestSeenCommitIndex() != -1 {
 		t.Errorf("Highest seen commit index should have defaulted to -1 but was %d",
-			stateMachine.highestSeenCommitIndex)
+			stateMachine.HighestSeenCommitIndex())
 	}
 }
 
@@ -71,7 +72,7 @@ func TestWhenStateMachineIsStartedThenItInitializesStateFromJournal(t *testing.T
 
 	for k, v := range rawKeyValData {
 
-		if !reflect.DeepEqual(stateMachine.data[k], v) {
+		if !reflect.DeepEqual(stateMachine.data.values[k].value, v) {
 			t.Errorf("State machine should have been initialized with key '%s' and value '%s'", k, v)
 		}
 	}
@@ -172,10 +173,30 @@ func TestWhenStateMachineUpdatesDueToCommitIndexChangeThenHighestSeenCommitIndex
 	// gross but need to give listener time to update state before reading, eventual consistency :)
 	time.Sleep(50 * time.Millisecond)
 
-	if uint64(stateMachine.highestSeenCommitIndex) != expectedCommitIndex {
+	if uint64(stateMachine.HighestSeenCommitIndex()) != expectedCommitIndex {
 		t.Errorf("State machine should have updated its highest seen commit index to value %d but it was %d",
 			expectedCommitIndex,
-			stateMachine.highestSeenCommitIndex)
+			stateMachine.HighestSeenCommitIndex())
+	}
+}
+
+func TestWhenAnAlreadyAppliedIndexIsCommittedAgainThenItIsSkippedAndLaterEntriesAreStillApplied(t *testing.T) {
+
+	journalSpy, stateMachine := setup()
+
+	appendAndCommit(journalSpy, NewPutCommand("a", []byte("1")).Encode(), NewPutCommand("b", []byte("2")).Encode())
+	<-journalSpy.Commit(0)
+
+	appliedCh := make(chan ApplyResult, 1)
+	_ = stateMachine.NotifyOfApplyOnIndexOnce(2, appliedCh)
+
+	appendAndCommit(journalSpy, NewPutCommand("c", []byte("3")).Encode())
+	result := <-appliedCh
+
+	if result.Err != nil || stateMachine.HighestSeenCommitIndex() != 2 {
+		t.Errorf("Entry at index 2 should have been applied after the stale commit but got %+v and highest seen %d",
+			result,
+			stateMachine.HighestSeenCommitIndex())
 	}
 }
 
@@ -219,6 +240,222 @@ func TestWhenResolvingRequestToGetDataAndRequestIsNotCorrectStructureThenAnError
 	}
 }
 
+func TestWhenDeleteIsCommittedThenTheKeyIsNoLongerFound(t *testing.T) {
+
+	journalSpy, stateMachine := setup()
+
+	loadJournalAndCommit(map[string][]byte{"theKey": []byte("theValue")}, journalSpy)
+	appendAndCommit(journalSpy, NewDeleteCommand("theKey").Encode())
+
+	// gross but need to give listener time to update state before reading, eventual consistency :)
+	time.Sleep(50 * time.Millisecond)
+
+	_, err := stateMachine.ResolveRequestToData(createKeyRequest("theKey"))
+
+	if ErrKeyNotFound != err {
+		t.Errorf("Should have received error '%v' for deleted key but got '%v'", ErrKeyNotFound, err)
+	}
+}
+
+func TestWhenJournalCommandHasUnknownOperationThenAnErrorIsReturned(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	stateMachine := NewMapStateMachine(journalSpy)
+
+	appendAndCommit(journalSpy, []byte(`{"Op":"EXPLODE","Key":"theKey"}`))
+
+	err := stateMachine.Start()
+
+	if ErrUnknownCommandOp != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrUnknownCommandOp, err)
+	}
+}
+
+func TestWhenPutIfAbsentAndKeyExistsThenConditionFailsWithTheCurrentVersion(t *testing.T) {
+
+	results := applyCommands(NewMapStateMachine(journal.NewJournalSpy()),
+		NewPutCommand("theKey", []byte("first")),
+		NewPutCommand("theKey", []byte("second")),
+		NewPutIfAbsentCommand("theKey", []byte("third")),
+		NewPutIfAbsentCommand("otherKey", []byte("other")))
+
+	if results[2].Applied || results[2].Version != 2 || !results[3].Applied || results[3].Version != 1 {
+		t.Errorf("Unexpected put if absent results %+v", results)
+	}
+}
+
+func TestWhenPutIfVersionMatchesThenTheValueIsReplacedAndTheVersionIncremented(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewPutIfVersionCommand("theKey", []byte("created"), 0),
+		NewPutIfVersionCommand("theKey", []byte("stale"), 0),
+		NewPutIfVersionCommand("theKey", []byte("updated"), 1))
+
+	value, _ := stateMachine.ResolveRequestToData(createKeyRequest("theKey"))
+
+	if !results[0].Applied || results[1].Applied || !results[2].Applied || results[2].Version != 2 ||
+		string(value) != "updated" {
+
+		t.Errorf("Unexpected put if version results %+v and value '%s'", results, value)
+	}
+}
+
+func TestWhenDeleteIfValueDoesNotMatchThenTheKeyIsKept(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewPutCommand("theKey", []byte("theValue")),
+		NewDeleteIfValueCommand("theKey", []byte("otherValue")),
+		NewDeleteIfValueCommand("missingKey", []byte("theValue")))
+
+	_, err := stateMachine.ResolveRequestToData(createKeyRequest("theKey"))
+
+	if results[1].Applied || results[1].Version != 1 || results[2].Applied || err != nil {
+		t.Errorf("Unexpected delete if value results %+v and error '%v'", results, err)
+	}
+}
+
+func TestWhenDeleteIfValueMatchesThenTheKeyIsDeleted(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewPutCommand("theKey", []byte("theValue")),
+		NewDeleteIfValueCommand("theKey", []byte("theValue")))
+
+	_, err := stateMachine.ResolveRequestToData(createKeyRequest("theKey"))
+
+	if !results[1].Applied || results[1].Version != 0 || ErrKeyNotFound != err {
+		t.Errorf("Unexpected delete if value results %+v and error '%v'", results, err)
+	}
+}
+
+func TestWhenCommittedEntryIsAppliedThenTheResultIsPublishedForItsIndex(t *testing.T) {
+
+	journalSpy, stateMachine := setup()
+
+	resultCh := make(chan ApplyResult, 1)
+	_ = stateMachine.NotifyOfApplyOnIndexOnce(0, resultCh)
+
+	appendAndCommit(journalSpy, NewPutIfAbsentCommand("theKey", []byte("theValue")).Encode())
+
+	if result := <-resultCh; !result.Applied || result.Version != 1 || result.Index != 0 {
+		t.Errorf("Unexpected apply result %+v", result)
+	}
+}
+
+func TestWhenMapStateMachineIsCreatedThenNoEntryHasBeenApplied(t *testing.T) {
+
+	_, stateMachine := setup()
+
+	if stateMachine.AppliedIndex() != -1 {
+		t.Errorf("Applied index should have defaulted to -1 but was %d", stateMachine.AppliedIndex())
+	}
+}
+
+func TestWhenWaitingForAppliedIndexThenTheWriteCommittedAtIndexIsRead(t *testing.T) {
+
+	journalSpy, stateMachine := setup()
+
+	commitIndex := loadJournalAndCommit(map[string][]byte{"a": []byte("a value")}, journalSpy)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err := stateMachine.WaitForApplied(ctx, commitIndex)
+
+	data, readErr := stateMachine.ResolveRequestToData(createKeyRequest("a"))
+
+	if err != nil || readErr != nil || string(data) != "a value" {
+		t.Errorf("Expected to read 'a value' after waiting but got '%s', errors '%v' and '%v'", data, err, readErr)
+	}
+}
+
+func TestWhenWaitingForIndexThatIsNeverAppliedThenTheContextErrorIsReturned(t *testing.T) {
+
+	_, stateMachine := setup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := stateMachine.WaitForApplied(ctx, 5)
+
+	if context.DeadlineExceeded != err {
+		t.Errorf("Should have received error '%v' but got '%v'", context.DeadlineExceeded, err)
+	}
+}
+
+func TestWhenWaitingForIndexPastACorruptEntryThenErrCorruptionAlarmIsReturned(t *testing.T) {
+
+	stateMachine := setupForCorruptCommit()
+
+	err := stateMachine.WaitForApplied(context.Background(), 5)
+
+	if ErrCorruptionAlarm != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrCorruptionAlarm, err)
+	}
+}
+
+func TestWhenWriterIsWaitingPastACorruptEntryThenItReceivesErrCorruptionAlarm(t *testing.T) {
+
+	journalSpy, stateMachine := setup()
+
+	appendResult := <-journalSpy.Append(createKeyValRequestItem("b", []byte("b value")))
+	next := <-journalSpy.Append(createKeyValRequestItem("c", []byte("c value")))
+
+	resultCh := make(chan ApplyResult, 1)
+	_ = stateMachine.NotifyOfApplyOnIndexOnce(next.Index, resultCh)
+
+	journalSpy.CorruptEntry(appendResult.Index)
+	<-journalSpy.Commit(next.Index)
+
+	select {
+	case result := <-resultCh:
+		if ErrCorruptionAlarm != result.Err {
+			t.Errorf("Should have received error '%v' but got '%v'", ErrCorruptionAlarm, result.Err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("Writer waiting past the corrupt entry should have been failed")
+	}
+}
+
+func TestWhenAllNotifiedEntriesAreAppliedThenThereIsNoApplyLag(t *testing.T) {
+
+	journalSpy, stateMachine := setup()
+
+	commitIndex := loadJournalAndCommit(map[string][]byte{"a": []byte("1"), "b": []byte("2")}, journalSpy)
+
+	_ = stateMachine.WaitForApplied(context.Background(), commitIndex)
+
+	if stateMachine.ApplyLag() != 0 || stateMachine.AppliedIndex() != int64(commitIndex) {
+		t.Errorf("Expected no lag at applied index %d but lag was %d at applied index %d",
+			commitIndex, stateMachine.ApplyLag(), stateMachine.AppliedIndex())
+	}
+}
+
+func TestWhenNotifiedOfCommitsNotYetAppliedThenTheyAreTheApplyLag(t *testing.T) {
+
+	_, stateMachine := setup()
+
+	stateMachine.notifiedCommitIndex.Store(4)
+
+	if stateMachine.ApplyLag() != 5 {
+		t.Errorf("Expected an apply lag of 5 entries but was %d", stateMachine.ApplyLag())
+	}
+}
+
+func TestWhenStateMachineCollectorsRequestedThenAppliedIndexAndLagAreCollected(t *testing.T) {
+
+	_, stateMachine := setup()
+
+	if len(stateMachine.Collectors()) != 2 {
+		t.Errorf("Expected 2 collectors but got %d", len(stateMachine.Collectors()))
+	}
+}
+
 func setup() (*journal.Spy, *MapStateMachine) {
 
 	journalSpy := journal.NewJournalSpy()
@@ -258,6 +495,33 @@ func loadJournalAndCommit(rawKeyValData map[string][]byte, journalSpy *journal.S
 	return commitIndex
 }
 
+// applyCommands applies commands directly, in order, as if they were committed journal entries
+func applyCommands(stateMachine *MapStateMachine, commands ...Command) []ApplyResult {
+
+	results := make([]ApplyResult, 0, len(commands))
+
+	for index, command := range commands {
+		results = append(results, stateMachine.applyAt(command.Encode(), uint64(index)))
+	}
+
+	return results
+}
+
+func appendAndCommit(journalSpy *journal.Spy, entries ...[]byte) uint64 {
+
+	var commitIndex uint64
+
+	for _, entry := range entries {
+
+		appendResult := <-journalSpy.Append(entry)
+		commitIndex = appendResult.Index
+	}
+
+	<-journalSpy.Commit(commitIndex)
+
+	return commitIndex
+}
+
 func createKeyValRequestItem(k string, v []byte) []byte {
 
 	rawData := KeyValItem{
@@ -297,3 +561,94 @@ func setupForBadJournalData() (*MapStateMachine, error) {
 
 	return stateMachine, err
 }
+
+func TestWhenStateMachineIsStartedThenItStreamsCommittedEntriesFromTheJournal(t *testing.T) {
+
+	journalSpy, _ := setup()
+
+	if journalSpy.OpenCommittedEntriesCallCount() != 1 {
+		t.Errorf("State machine should have opened a cursor over the committed entries once but opened %d",
+			journalSpy.OpenCommittedEntriesCallCount())
+	}
+}
+
+func TestWhenStateMachineIsStartedThenHighestSeenCommitIndexIsTheLastRenderedIndex(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	stateMachine := NewMapStateMachine(journalSpy)
+
+	rawKeyValData := map[string][]byte{
+		"a": []byte("a value"),
+		"b": []byte("b value"),
+	}
+
+	expectedIndex := loadJournalAndCommit(rawKeyValData, journalSpy)
+
+	_ = stateMachine.Start()
+
+	if stateMachine.HighestSeenCommitIndex() != int64(expectedIndex) {
+		t.Errorf("Highest seen commit index should have been %d but was %d",
+			expectedIndex,
+			stateMachine.HighestSeenCommitIndex())
+	}
+}
+
+func TestWhenStateMachineIsStartedAndCommittedEntryIsCorruptThenAnErrorIsReturned(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	stateMachine := NewMapStateMachine(journalSpy)
+
+	index := loadJournalAndCommit(map[string][]byte{"a": []byte("a value")}, journalSpy)
+	journalSpy.CorruptEntry(index)
+
+	err := stateMachine.Start()
+
+	if journal.ErrChecksumMismatch != err {
+		t.Errorf("Should have received error '%v' but got '%v'", journal.ErrChecksumMismatch, err)
+	}
+}
+
+func TestWhenCommittedEntryIsCorruptThenTheCorruptionAlarmIsRaised(t *testing.T) {
+
+	stateMachine := setupForCorruptCommit()
+
+	if !stateMachine.IsCorruptionAlarmRaised() {
+		t.Errorf("Corruption alarm should have been raised")
+	}
+}
+
+func TestWhenCorruptionAlarmIsRaisedThenReadsAreRefused(t *testing.T) {
+
+	stateMachine := setupForCorruptCommit()
+
+	_, err := stateMachine.ResolveRequestToData(createKeyRequest("a"))
+
+	if ErrCorruptionAlarm != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrCorruptionAlarm, err)
+	}
+}
+
+func TestWhenCommittedEntryIsCorruptThenItIsNotApplied(t *testing.T) {
+
+	stateMachine := setupForCorruptCommit()
+
+	if _, ok := stateMachine.data.get("b"); ok {
+		t.Errorf("Corrupt entry should not have been applied to the state machine")
+	}
+}
+
+func setupForCorruptCommit() *MapStateMachine {
+
+	journalSpy, stateMachine := setup()
+
+	loadJournalAndCommit(map[string][]byte{"a": []byte("a value")}, journalSpy)
+
+	appendResult := <-journalSpy.Append(createKeyValRequestItem("b", []byte("b value")))
+	journalSpy.CorruptEntry(appendResult.Index)
+
+	<-journalSpy.Commit(appendResult.Index)
+
+	_ = stateMachine.WaitForApplied(context.Background(), appendResult.Index)
+
+	return stateMachine
+}
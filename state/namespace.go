@@ -0,0 +1,397 @@
+package state
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NamespaceKeyPrefix begins the keyspace key of every key stored in a namespace, see NamespacedKey
+const NamespaceKeyPrefix = "__namespace/"
+
+var (
+	ErrNamespaceNotFound = errors.New("namespace does not exist, it has not been created or has been deleted")
+	ErrEmptyNamespace    = errors.New("namespace command must name a namespace")
+	ErrInvalidNamespace  = errors.New("namespace name must not contain '/'")
+	ErrQuotaExceeded     = errors.New("write would exceed the key count or byte quota of its namespace")
+)
+
+// Namespace is a keyspace of its own shared by the state machine with every other namespace. MaxKeys and MaxBytes
+// are the quotas on the number of keys in the namespace and the bytes of their keys and values, 0 for no quota.
+// Keys and Bytes are what the namespace holds as of the last applied journal entry, Bytes includes the chunks staged
+// for keys in the namespace by uploads not yet committed or discarded. The client policy rejects a write that would
+// exceed a quota before it is appended to the journal and the state machines reject it again when it is applied
+type Namespace struct {
+	Name     string
+	MaxKeys  uint64
+	MaxBytes uint64
+	Keys     uint64
+	Bytes    uint64
+}
+
+// namespaceTable holds the created namespaces and the size of each key stored in them. namespaceTable is safe for
+// concurrent use
+type namespaceTable struct {
+	mutex      sync.RWMutex
+	namespaces map[string]*createdNamespace
+}
+
+type createdNamespace struct {
+	maxKeys  uint64
+	maxBytes uint64
+	bytes    uint64
+	staged   uint64
+	keys     map[string]uint64
+}
+
+// NamespacedKey returns the keyspace key that key is stored under in namespace, the empty namespace is the keyspace
+// every key was stored in before namespaces and its keys are stored as is
+func NamespacedKey(namespace string, key string) string {
+
+	namespacedKey := key
+
+	if namespace != "" {
+		namespacedKey = NamespaceKeyPrefix + namespace + "/" + key
+	}
+
+	return namespacedKey
+}
+
+// namespaceOf returns the namespace keyspace key is stored in and whether it is stored in one at all
+func namespaceOf(key string) (string, bool) {
+
+	var namespace string
+
+	isNamespaced := strings.HasPrefix(key, NamespaceKeyPrefix)
+
+	if isNamespaced {
+		namespace, _, isNamespaced = strings.Cut(strings.TrimPrefix(key, NamespaceKeyPrefix), "/")
+	}
+
+	return namespace, isNamespaced
+}
+
+func validateNamespace(name string) error {
+
+	var err error
+
+	if name == "" {
+		err = ErrEmptyNamespace
+	} else if strings.Contains(name, "/") {
+		err = ErrInvalidNamespace
+	}
+
+	return err
+}
+
+func newNamespaceTable() *namespaceTable {
+
+	return &namespaceTable{
+		namespaces: make(map[string]*createdNamespace),
+	}
+}
+
+// create creates the namespace name, returning false if it already exists
+func (table *namespaceTable) create(name string, maxKeys uint64, maxBytes uint64) bool {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	_, exists := table.namespaces[name]
+
+	if !exists {
+		table.namespaces[name] = &createdNamespace{maxKeys: maxKeys, maxBytes: maxBytes, keys: make(map[string]uint64)}
+	}
+
+	return !exists
+}
+
+// remove deletes the namespace name, returning the keyspace keys stored in it in key order and whether it existed
+func (table *namespaceTable) remove(name string) ([]string, bool) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	var keys []string
+
+	created, exists := table.namespaces[name]
+
+	if exists {
+
+		keys = created.sortedKeys()
+		delete(table.namespaces, name)
+	}
+
+	return keys, exists
+}
+
+func (table *namespaceTable) exists(name string) bool {
+
+	table.mutex.RLock()
+	defer table.mutex.RUnlock()
+
+	_, exists := table.namespaces[name]
+
+	return exists
+}
+
+// store records that keyspace key now holds value, keys that are not stored in a namespace are ignored
+func (table *namespaceTable) store(key string, value []byte) {
+
+	table.resize(key, value, true)
+}
+
+// release records that keyspace key was deleted
+func (table *namespaceTable) release(key string) {
+
+	table.resize(key, nil, false)
+}
+
+// stage records that size bytes were staged as chunks for keyspace key, keys that are not stored in a namespace are
+// ignored
+func (table *namespaceTable) stage(key string, size uint64) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	name, isNamespaced := namespaceOf(key)
+
+	if created, exists := table.namespaces[name]; isNamespaced && exists {
+		created.staged += size
+	}
+}
+
+// unstage records that size bytes staged as chunks for keyspace key were committed or discarded. Chunks staged before
+// the namespace was last created were never counted against it
+func (table *namespaceTable) unstage(key string, size uint64) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	name, isNamespaced := namespaceOf(key)
+
+	if created, exists := table.namespaces[name]; isNamespaced && exists {
+
+		if size > created.staged {
+			size = created.staged
+		}
+
+		created.staged -= size
+	}
+}
+
+func (table *namespaceTable) resize(key string, value []byte, isStored bool) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	name, isNamespaced := namespaceOf(key)
+
+	created, exists := table.namespaces[name]
+
+	if isNamespaced && exists {
+
+		created.bytes -= created.keys[key]
+		delete(created.keys, key)
+
+		if isStored {
+
+			size := StoredSize(strings.TrimPrefix(key, NamespacedKey(name, "")), value)
+
+			created.bytes += size
+			created.keys[key] = size
+		}
+	}
+}
+
+func (table *namespaceTable) namespace(name string) (Namespace, bool) {
+
+	table.mutex.RLock()
+	defer table.mutex.RUnlock()
+
+	created, exists := table.namespaces[name]
+
+	var namespace Namespace
+
+	if exists {
+		namespace = created.describe(name)
+	}
+
+	return namespace, exists
+}
+
+// all returns every created namespace in name order
+func (table *namespaceTable) all() []Namespace {
+
+	table.mutex.RLock()
+	defer table.mutex.RUnlock()
+
+	namespaces := make([]Namespace, 0, len(table.namespaces))
+
+	for name, created := range table.namespaces {
+		namespaces = append(namespaces, created.describe(name))
+	}
+
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].Name < namespaces[j].Name })
+
+	return namespaces
+}
+
+func (created *createdNamespace) describe(name string) Namespace {
+
+	return Namespace{
+		Name:     name,
+		MaxKeys:  created.maxKeys,
+		MaxBytes: created.maxBytes,
+		Keys:     uint64(len(created.keys)),
+		Bytes:    created.bytes + created.staged,
+	}
+}
+
+func (created *createdNamespace) sortedKeys() []string {
+
+	keys := make([]string, 0, len(created.keys))
+
+	for key := range created.keys {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// QuotaExceededBy returns true if command would take the namespace over its key count or byte quota. current returns
+// the value a key of the namespace holds and whether it exists, keys being without the namespace like the keys of
+// command. A transaction is checked against the puts of either branch, deletes are not counted
+func (namespace Namespace) QuotaExceededBy(command Command, current func(key string) ([]byte, bool)) bool {
+
+	exceeds := false
+
+	if command.Op == OpTxn {
+
+		for _, ops := range [][]TxnOp{command.Txn.Success, command.Txn.Failure} {
+			exceeds = exceeds || namespace.isExceededBy(namespace.usageAfterPuts(ops, current))
+		}
+	} else if command.countsAgainstQuota() {
+		exceeds = namespace.isExceededBy(namespace.usageAfter(command, current))
+	}
+
+	return exceeds
+}
+
+// usageAfterPuts returns the usage of the namespace once the puts of ops have written their keys, each put counted
+// against the usage before any of them
+func (namespace Namespace) usageAfterPuts(ops []TxnOp, current func(key string) ([]byte, bool)) Namespace {
+
+	usage := namespace
+
+	for _, op := range ops {
+
+		if op.Op == OpPut {
+
+			after := namespace.usageAfter(NewPutCommand(op.Key, op.Value), current)
+
+			usage.Keys += after.Keys - namespace.Keys
+			usage.Bytes += after.Bytes - namespace.Bytes
+		}
+	}
+
+	return usage
+}
+
+// usageAfter returns the usage of the namespace once command has written its key. A chunk adds its bytes without a
+// key and a large value manifest replaces the bytes of the chunks it commits, already counted, with its key
+func (namespace Namespace) usageAfter(command Command, current func(key string) ([]byte, bool)) Namespace {
+
+	currentValue, exists := current(command.Key)
+
+	value := command.Value
+
+	if command.Op == OpPutLarge {
+		value = nil
+	}
+
+	if command.isCounter() {
+		value = currentValue
+	}
+
+	if command.Op == OpPutChunk {
+		namespace.Bytes += uint64(len(command.Value))
+	} else if exists {
+		namespace.Bytes += StoredSize(command.Key, value) - StoredSize(command.Key, currentValue)
+	} else {
+		namespace.Keys++
+		namespace.Bytes += StoredSize(command.Key, value)
+	}
+
+	return namespace
+}
+
+func (namespace Namespace) isExceededBy(usage Namespace) bool {
+
+	return (namespace.MaxKeys > 0 && usage.Keys > namespace.MaxKeys) ||
+		(namespace.MaxBytes > 0 && usage.Bytes > namespace.MaxBytes)
+}
+
+// StoredSize returns the bytes a key and its value count against the quota of their namespace
+func StoredSize(key string, value []byte) uint64 {
+
+	return uint64(len(key) + len(value))
+}
+
+// Namespace returns the created namespace name and its usage
+func (state *journalApplier) Namespace(name string) (Namespace, bool) {
+
+	return state.namespaces.namespace(name)
+}
+
+// Namespaces returns every created namespace in name order
+func (state *journalApplier) Namespaces() []Namespace {
+
+	return state.namespaces.all()
+}
+
+// exceedsQuota returns true if command, its key already a keyspace key, would take its namespace over a quota. The
+// client policy checked the command against the usage when it was proposed, writes proposed concurrently to the same
+// namespace are checked again here one at a time in journal order
+func (state *journalApplier) exceedsQuota(command Command) bool {
+
+	namespace, exists := state.namespaces.namespace(command.Namespace)
+
+	command.Key = strings.TrimPrefix(command.Key, NamespacedKey(command.Namespace, ""))
+
+	return exists && namespace.QuotaExceededBy(command, func(key string) ([]byte, bool) {
+
+		current, found := state.keyspace.get(NamespacedKey(command.Namespace, key))
+
+		return current.value, found
+	})
+}
+
+// applyNamespaceCommand creates or deletes a namespace. Deleting drops the indexes of the namespace and deletes the
+// keys stored in it as one atomic write
+func (state *journalApplier) applyNamespaceCommand(command Command, index uint64) ApplyResult {
+
+	result := ApplyResult{Index: index}
+
+	if command.Op == OpCreateNamespace {
+		result.Applied = state.namespaces.create(command.Namespace, command.Quota.MaxKeys, command.Quota.MaxBytes)
+	} else {
+
+		var keys []string
+		keys, result.Applied = state.namespaces.remove(command.Namespace)
+
+		state.indexes.dropNamespace(command.Namespace)
+
+		state.atomically(index, func(writer revisionWriter) {
+
+			for _, key := range keys {
+				writer.delete(key)
+			}
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,208 @@
+package state
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/jrobison153/raft/journal"
+)
+
+func TestWhenKeyIsPutInANamespaceThenItIsSeparateFromTheSameKeyInOtherNamespaces(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine,
+		NewCreateNamespaceCommand("team-a", 0, 0),
+		NewCreateNamespaceCommand("team-b", 0, 0),
+		putInNamespace("team-a", "config", "a"),
+		putInNamespace("team-b", "config", "b"),
+		NewPutCommand("config", []byte("global")))
+
+	values := make([]string, 0, 3)
+
+	for _, namespace := range []string{"team-a", "team-b", ""} {
+
+		data, _ := stateMachine.ResolveRequestToData(createNamespacedKeyRequest(namespace, "config"))
+		values = append(values, string(data))
+	}
+
+	if !reflect.DeepEqual([]string{"a", "b", "global"}, values) {
+		t.Errorf("Each namespace should have held its own value but got %v", values)
+	}
+}
+
+func TestWhenPutNamesANamespaceThatDoesNotExistThenThePutFails(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine, putInNamespace("missing", "config", "a"))
+
+	if ErrNamespaceNotFound != results[0].Err {
+		t.Errorf("Should have received error '%v' but got %+v", ErrNamespaceNotFound, results[0])
+	}
+}
+
+func TestWhenKeysArePutAndDeletedInANamespaceThenItsUsageIsTracked(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	deleteCommand := NewDeleteCommand("b")
+	deleteCommand.Namespace = "team-a"
+
+	applyCommands(stateMachine,
+		NewCreateNamespaceCommand("team-a", 10, 100),
+		putInNamespace("team-a", "a", "1234"),
+		putInNamespace("team-a", "b", "12"),
+		putInNamespace("team-a", "a", "12"),
+		deleteCommand)
+
+	namespace, _ := stateMachine.Namespace("team-a")
+
+	expected := Namespace{Name: "team-a", MaxKeys: 10, MaxBytes: 100, Keys: 1, Bytes: 3}
+
+	if expected != namespace {
+		t.Errorf("Expected namespace %+v but got %+v", expected, namespace)
+	}
+}
+
+func TestWhenWritesAppliedToANamespaceWouldTogetherExceedItsQuotaThenTheWriteOverTheQuotaFails(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewCreateNamespaceCommand("team-a", 1, 0),
+		putInNamespace("team-a", "a", "1"),
+		putInNamespace("team-a", "b", "2"))
+
+	if ErrQuotaExceeded != results[2].Err {
+		t.Errorf("Should have received error '%v' but got %+v", ErrQuotaExceeded, results[2])
+	}
+}
+
+func TestWhenWriteOverTheNamespaceQuotaFailsThenItIsNotStored(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine,
+		NewCreateNamespaceCommand("team-a", 0, 3),
+		putInNamespace("team-a", "a", "1"),
+		putInNamespace("team-a", "b", "2"))
+
+	_, err := stateMachine.ResolveRequestToData(createNamespacedKeyRequest("team-a", "b"))
+
+	if ErrKeyNotFound != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrKeyNotFound, err)
+	}
+}
+
+func TestWhenNamespaceIsDeletedThenTheKeysStoredInItAreDeleted(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewCreateNamespaceCommand("team-a", 0, 0),
+		putInNamespace("team-a", "config", "a"),
+		NewPutCommand("config", []byte("global")),
+		NewDeleteNamespaceCommand("team-a"))
+
+	_, namespacedErr := stateMachine.ResolveRequestToData(createNamespacedKeyRequest("team-a", "config"))
+	_, globalErr := stateMachine.ResolveRequestToData(createKeyRequest("config"))
+	_, exists := stateMachine.Namespace("team-a")
+
+	if !results[3].Applied || ErrKeyNotFound != namespacedErr || globalErr != nil || exists {
+		t.Errorf("Unexpected delete result %+v with errors '%v' and '%v'", results[3], namespacedErr, globalErr)
+	}
+}
+
+func TestWhenNamespaceIsCreatedTwiceThenTheSecondCreateIsNotApplied(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewCreateNamespaceCommand("team-a", 1, 0),
+		NewCreateNamespaceCommand("team-a", 2, 0))
+
+	namespace, _ := stateMachine.Namespace("team-a")
+
+	if !results[0].Applied || results[1].Applied || namespace.MaxKeys != 1 {
+		t.Errorf("Unexpected create results %+v and namespace %+v", results, namespace)
+	}
+}
+
+func TestWhenNamespacesAreListedThenTheyAreInNameOrder(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine, NewCreateNamespaceCommand("b", 0, 0), NewCreateNamespaceCommand("a", 0, 0))
+
+	namespaces := stateMachine.Namespaces()
+
+	if len(namespaces) != 2 || namespaces[0].Name != "a" || namespaces[1].Name != "b" {
+		t.Errorf("Unexpected namespaces %+v", namespaces)
+	}
+}
+
+func TestWhenNamespaceNameHasASlashThenTheCommandIsInvalid(t *testing.T) {
+
+	err := NewCreateNamespaceCommand("team/a", 0, 0).Validate()
+
+	if ErrInvalidNamespace != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrInvalidNamespace, err)
+	}
+}
+
+func TestWhenNamespaceCommandIsEncodedThenItDecodesToTheSameCommand(t *testing.T) {
+
+	command := NewCreateNamespaceCommand("team-a", 10, 4096)
+
+	decoded, err := DecodeCommand(command.Encode())
+
+	if err != nil || !reflect.DeepEqual(command, decoded) {
+		t.Errorf("Expected %+v to survive encoding but got %+v and error '%v'", command, decoded, err)
+	}
+}
+
+func TestWhenDefaultNamespaceNamesAKeyStoredInANamespaceThenTheKeyIsReserved(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewCreateNamespaceCommand("team-a", 0, 0),
+		putInNamespace("team-a", "config", "a"),
+		NewPutCommand(NamespacedKey("team-a", "config"), []byte("b")))
+
+	_, readErr := stateMachine.ResolveRequestToData(createKeyRequest(NamespacedKey("team-a", "config")))
+	data, _ := stateMachine.ResolveRequestToData(createNamespacedKeyRequest("team-a", "config"))
+
+	if ErrReservedKey != results[2].Err || ErrReservedKey != readErr || string(data) != "a" {
+		t.Errorf("Reserved key should have been rejected but got %+v, error '%v' and value '%s'", results[2], readErr,
+			data)
+	}
+}
+
+func TestWhenTxnNamesAReservedKeyThenTheCommandIsInvalid(t *testing.T) {
+
+	txn := Txn{Success: []TxnOp{{Op: OpPut, Key: NamespacedKey("team-a", "config"), Value: []byte("b")}}}
+
+	err := NewTxnCommand(txn).Validate()
+
+	if ErrReservedKey != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrReservedKey, err)
+	}
+}
+
+func putInNamespace(namespace string, key string, value string) Command {
+
+	command := NewPutCommand(key, []byte(value))
+	command.Namespace = namespace
+
+	return command
+}
+
+func createNamespacedKeyRequest(namespace string, key string) []byte {
+
+	request, _ := json.Marshal(Key{Key: key, Namespace: namespace})
+
+	return request
+}
@@ -0,0 +1,801 @@
+// Package process runs state machines out of process. A Proxy is the state.Renderer of a state machine that is a
+// separate program, written in any language, which the proxy starts and talks to over a Unix socket with the small
+// gRPC protocol in state_machine.proto. A Proxy can also run any other Runner speaking the protocol, see Launcher.
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jrobison153/raft/api/statemachine"
+	"github.com/jrobison153/raft/crypto"
+	"github.com/jrobison153/raft/journal"
+	"github.com/jrobison153/raft/state"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// SocketEnvVar names the environment variable holding the path of the Unix socket a started state machine listens on
+const SocketEnvVar = "RAFT_STATE_MACHINE_SOCKET"
+
+const (
+	defaultSnapshotInterval = 1000
+	defaultStartTimeout     = 5000
+	connectRetryPeriod      = 10 * time.Millisecond
+)
+
+var (
+	ErrStateMachineUnavailable = errors.New("state machine process is not serving and could not be restarted")
+	ErrStateMachineNotStarted  = errors.New("state machine process did not accept connections before the timeout")
+)
+
+// pluginErrors are the errors a state machine process can report that readers and writers act on, the protocol
+// carries errors as their message
+var pluginErrors = []error{
+	state.ErrKeyNotFound,
+	state.ErrInvalidKeyRequest,
+	state.ErrCompacted,
+	state.ErrFutureIndex,
+	state.ErrLeaseNotFound,
+	state.ErrNotACounter,
+	state.ErrCounterOverflow,
+	state.ErrNamespaceNotFound,
+	state.ErrUnknownCommandOp,
+	state.ErrUnsupportedCommandVersion,
+}
+
+// A Config provides fields that can be used to modify the way the proxy runs the state machine process
+type Config struct {
+	// Executable is the path of the state machine program. It is started with Args and SocketEnvVar set to the
+	// path of the Unix socket it must listen on. It is not used by a proxy created with its own Launcher
+	Executable string
+	Args       []string
+	// SnapshotInterval specifies the number of applied journal entries between snapshots taken from the state
+	// machine, a restarted state machine is restored from the latest and replays the entries since. Default value
+	// is 1000
+	SnapshotInterval uint64
+	// StartTimeout specifies the time in milliseconds a started state machine has to accept connections. Default
+	// value is 5000ms
+	StartTimeout int
+	// SnapshotFile, when set, is where the latest snapshot is persisted. A started proxy restores the state machine
+	// from it and only applies the entries committed since. The journal must be persistent for the snapshot to
+	// match it. Default value is empty, snapshots are only held in memory
+	SnapshotFile string
+	// Keyring, when set, seals the persisted snapshot with its active key. Default value is nil, the snapshot is
+	// written in plaintext
+	Keyring *crypto.Keyring
+}
+
+// Proxy is the state.Renderer of a state machine running in its own process. It applies committed journal entries
+// by handing the encoded command to the process and resolves reads by querying it. If the process exits or stops
+// answering it is restarted, restored from the latest snapshot and the entries applied since are replayed, so a
+// state machine that applies deterministically always rejoins at the same state.
+// The proxy holds no history, watches can only start from now. It implements none of the optional capabilities of
+// a state.Renderer, so leases, namespaces, secondary indexes and queues are rejected by the client rather than
+// proposed to a state machine that cannot be asked about them. Proxy is safe for concurrent use
+type Proxy struct {
+	journal                journal.Journaler
+	config                 *Config
+	launch                 Launcher
+	mutex                  sync.Mutex
+	plugin                 Runner
+	appliedIndex           int64
+	snapshot               state.Snapshot
+	hasSnapshot            bool
+	results                *state.ApplyRegistry
+	watchers               *state.WatchHub
+	commitListenCh         chan uint64
+	highestSeenCommitIndex atomic.Int64
+	isRunning              bool
+	isFailed               atomic.Bool
+}
+
+// Runner is a running state machine the Proxy hands entries and reads to with the calls of state_machine.proto.
+// Invoke makes one call, IsDown returns true when an error from Invoke means the state machine is no longer serving
+// and has to be replaced, rather than that it failed the call. Stop releases the state machine
+type Runner interface {
+	Invoke(call func(statemachine.StateMachineClient) error) error
+	IsDown(err error) bool
+	Stop()
+}
+
+// Launcher starts a Runner. The proxy launches a new one whenever the running one is down, so a Launcher must start
+// the same state machine every time. A Launcher that fails returns a stopped Runner along with its error
+type Launcher func() (Runner, error)
+
+// pluginProcess is a running state machine process and the connection to it
+type pluginProcess struct {
+	command    *exec.Cmd
+	connection *grpc.ClientConn
+	client     statemachine.StateMachineClient
+	exited     chan struct{}
+	socketDir  string
+}
+
+func NewDefaultConfig(executable string, args ...string) *Config {
+
+	return &Config{
+		Executable:       executable,
+		Args:             args,
+		SnapshotInterval: defaultSnapshotInterval,
+		StartTimeout:     defaultStartTimeout,
+	}
+}
+
+func NewProxy(journal journal.Journaler, config *Config) *Proxy {
+
+	return NewLauncherProxy(journal, config, func() (Runner, error) { return launch(config) })
+}
+
+// NewLauncherProxy returns a Proxy of the state machines started by launch rather than of a state machine process,
+// the Executable and Args of config are not used
+func NewLauncherProxy(journal journal.Journaler, config *Config, launch Launcher) *Proxy {
+
+	proxy := &Proxy{
+		journal:      journal,
+		config:       config,
+		launch:       launch,
+		appliedIndex: -1,
+		results:      state.NewApplyRegistry(state.DefaultAppliedResultRetention),
+		watchers:     state.NewWatchHub(state.DefaultWatchBufferSize),
+	}
+
+	proxy.highestSeenCommitIndex.Store(-1)
+
+	return proxy
+}
+
+// Start starts the state machine process, applies every committed journal entry to it and then registers for all
+// commit changes of the journal.
+// Returns state.ErrAlreadyRunning if this method is called more than once
+// Returns ErrStateMachineNotStarted if the process does not accept connections within the StartTimeout
+// Returns the errors of applying the committed entries
+func (proxy *Proxy) Start() error {
+
+	var err error
+
+	if proxy.isRunning {
+		err = state.ErrAlreadyRunning
+	} else {
+
+		proxy.plugin, err = proxy.launch()
+
+		if err == nil {
+			err = proxy.restoreSnapshotFile()
+		}
+
+		if err == nil {
+			err = proxy.renderCurrentStateFromJournal()
+		}
+
+		if err == nil {
+
+			proxy.commitListenCh = make(chan uint64)
+			proxy.journal.NotifyOfAllCommitChanges(proxy.commitListenCh)
+
+			go proxy.listenForStateChanges(proxy.commitListenCh)
+
+			proxy.isRunning = true
+		}
+	}
+
+	return err
+}
+
+// Stop stops the state machine process, the proxy applies no more entries and serves no more reads
+func (proxy *Proxy) Stop() {
+
+	proxy.mutex.Lock()
+	defer proxy.mutex.Unlock()
+
+	proxy.isFailed.Store(true)
+	proxy.results.Fail(ErrStateMachineUnavailable)
+
+	if proxy.plugin != nil {
+		proxy.plugin.Stop()
+	}
+}
+
+// ResolveRequestToData returns the value associated with request, see ResolveRequestToItem
+func (proxy *Proxy) ResolveRequestToData(request []byte) ([]byte, error) {
+
+	item, err := proxy.ResolveRequestToItem(request)
+
+	return item.Value, err
+}
+
+// ResolveRequestToItem queries the state machine process for the key in request, a state.Key.
+// Returns state.ErrKeyNotFound If the key is not found
+// Returns ErrStateMachineUnavailable if the process is not serving and could not be restarted
+// Returns the errors the state machine reports
+func (proxy *Proxy) ResolveRequestToItem(request []byte) (state.VersionedItem, error) {
+
+	proxy.mutex.Lock()
+	defer proxy.mutex.Unlock()
+
+	var response *statemachine.QueryResponse
+
+	err := ErrStateMachineUnavailable
+
+	if !proxy.isFailed.Load() {
+
+		err = proxy.invoke(func(client statemachine.StateMachineClient) error {
+
+			var callErr error
+			response, callErr = client.Query(context.Background(), &statemachine.QueryRequest{Request: request})
+
+			return callErr
+		})
+	}
+
+	if err == nil && response.GetError() != "" {
+		err = pluginError(response.GetError())
+	} else if err == nil && !response.GetFound() {
+		err = state.ErrKeyNotFound
+	}
+
+	key := state.Key{}
+	_ = json.Unmarshal(request, &key)
+
+	item := state.VersionedItem{
+		Key:         key.Key,
+		Value:       response.GetValue(),
+		Version:     response.GetVersion(),
+		CreateIndex: response.GetCreateIndex(),
+		ModifyIndex: response.GetModifyIndex(),
+	}
+
+	return item, err
+}
+
+// NotifyOfApplyOnIndexOnce writes the result of applying the journal entry at index to ch once it has been applied.
+// Returns state.ErrApplyResultExpired if the entry was applied so long ago its result is no longer held
+func (proxy *Proxy) NotifyOfApplyOnIndexOnce(index uint64, ch chan state.ApplyResult) error {
+
+	return proxy.results.NotifyOfApplyOnIndexOnce(index, ch)
+}
+
+// Watch returns a watcher streaming the changes the state machine reports as it applies journal entries.
+// Returns state.ErrCompacted if the request has a StartIndex, the proxy holds no history to replay
+// Returns ErrStateMachineUnavailable if the process is not serving and could not be restarted
+func (proxy *Proxy) Watch(request state.WatchRequest) (*state.Watcher, error) {
+
+	var watcher *state.Watcher
+	var err error
+
+	if proxy.isFailed.Load() {
+		err = ErrStateMachineUnavailable
+	} else if request.StartIndex != nil {
+		err = state.ErrCompacted
+	} else {
+		watcher = proxy.watchers.Watch(request)
+	}
+
+	return watcher, err
+}
+
+func (proxy *Proxy) TypeOfLogger() string {
+
+	return reflect.TypeOf(proxy.journal).String()
+}
+
+// renderCurrentStateFromJournal applies the committed entries that follow the restored snapshot, if there is one
+func (proxy *Proxy) renderCurrentStateFromJournal() error {
+
+	committedEntries, err := proxy.journal.OpenCommittedEntries()
+
+	if err == nil {
+
+		defer committedEntries.Close()
+
+		isBehindSnapshot := proxy.hasSnapshot
+
+		if proxy.hasSnapshot {
+			isBehindSnapshot = committedEntries.Seek(uint64(proxy.snapshot.Index)+1) != nil
+		}
+
+		for committedEntries.HasNext() && !isBehindSnapshot && err == nil {
+
+			var committedEntry journal.Entry
+			committedEntry, err = committedEntries.Next()
+
+			if err == nil {
+
+				err = proxy.updateStateWithJournalItem(committedEntry, committedEntries.Index())
+				proxy.highestSeenCommitIndex.Store(int64(committedEntries.Index()))
+			}
+		}
+	}
+
+	return err
+}
+
+// listenForStateChanges applies the entries committed up to each index received on ch. An index that has already
+// been applied is skipped, any other failure to read the entries is logged and they are read again on the next index
+func (proxy *Proxy) listenForStateChanges(ch chan uint64) {
+
+	for index := range ch {
+
+		startIndex := uint64(proxy.highestSeenCommitIndex.Load() + 1)
+		entries, err := proxy.journal.GetAllEntriesBetween(startIndex, index)
+
+		for entryIndex := startIndex; err == nil && entries.HasNext() && !proxy.isFailed.Load(); entryIndex++ {
+
+			journalEntry, _ := entries.Next()
+
+			// the error is logged and fails the proxy, nothing more can be done with it here
+			//nolint:errcheck
+			proxy.updateStateWithJournalItem(journalEntry, entryIndex)
+		}
+
+		if err == nil {
+			proxy.highestSeenCommitIndex.Store(int64(index))
+		} else if err != journal.ErrInvertedIndexes {
+			log.Printf("unable to read the entries committed up to index %d: %v", index, err)
+		}
+	}
+}
+
+// updateStateWithJournalItem applies the journal entry at index and publishes its result and changes. A corrupt
+// entry or a state machine that cannot be restarted fails the proxy, no later entry can be applied in order
+func (proxy *Proxy) updateStateWithJournalItem(journalEntry journal.Entry, index uint64) error {
+
+	err := journalEntry.Verify()
+
+	result := state.ApplyResult{Index: index, Err: state.ErrCorruptionAlarm}
+	var events []state.WatchEvent
+
+	if err == nil {
+		result, events, err = proxy.applyAt(journalEntry.Item, index)
+	}
+
+	proxy.results.Publish(result)
+	proxy.watchers.Publish(index, events)
+
+	if err != nil {
+
+		log.Printf("state machine process failed, no longer applying entries or serving reads: %v", err)
+		proxy.isFailed.Store(true)
+		proxy.results.Fail(ErrStateMachineUnavailable)
+	}
+
+	return err
+}
+
+// applyAt hands the command at index to the state machine process and takes a snapshot when one is due. The error
+// returned is set when the process could not apply the command at all, a command the state machine rejected is
+// reported in the result
+func (proxy *Proxy) applyAt(command []byte, index uint64) (state.ApplyResult, []state.WatchEvent, error) {
+
+	proxy.mutex.Lock()
+	defer proxy.mutex.Unlock()
+
+	var response *statemachine.ApplyResponse
+
+	err := proxy.invoke(func(client statemachine.StateMachineClient) error {
+
+		var callErr error
+		response, callErr = client.Apply(context.Background(),
+			&statemachine.ApplyRequest{Index: index, Command: command})
+
+		return callErr
+	})
+
+	result := state.ApplyResult{Index: index, Err: err}
+
+	if err == nil {
+
+		proxy.appliedIndex = int64(index)
+		proxy.snapshotIfDue()
+
+		result.Applied, result.Version, result.Counter = response.Applied, response.Version, response.Counter
+
+		if response.Error != "" {
+			result.Err = pluginError(response.Error)
+		}
+	}
+
+	return result, newWatchEvents(index, response.GetEvents()), err
+}
+
+// snapshotIfDue takes a snapshot from the state machine process once SnapshotInterval entries have been applied
+// since the last and persists it to the SnapshotFile. A failed snapshot is only logged, the next entry tries again.
+// The proxy mutex must be held
+func (proxy *Proxy) snapshotIfDue() {
+
+	sinceSnapshot := proxy.appliedIndex + 1
+
+	if proxy.hasSnapshot {
+		sinceSnapshot = proxy.appliedIndex - proxy.snapshot.Index
+	}
+
+	if uint64(sinceSnapshot) >= proxy.config.SnapshotInterval {
+
+		var response *statemachine.SnapshotResponse
+
+		err := proxy.plugin.Invoke(func(client statemachine.StateMachineClient) error {
+
+			var callErr error
+			response, callErr = client.Snapshot(context.Background(), &statemachine.SnapshotRequest{})
+
+			return callErr
+		})
+
+		if err == nil {
+			proxy.snapshot = state.Snapshot{Index: int64(response.Index), Data: response.Data}
+			proxy.hasSnapshot = true
+		}
+
+		if err == nil && proxy.config.SnapshotFile != "" {
+			err = state.WriteSnapshotFile(proxy.config.SnapshotFile, proxy.snapshot, proxy.config.Keyring)
+		}
+
+		if err != nil {
+			log.Printf("unable to snapshot the state machine process: %v", err)
+		}
+	}
+}
+
+// invoke makes call to the state machine process. A process that is no longer serving is restarted and the call
+// made once more. The proxy mutex must be held
+func (proxy *Proxy) invoke(call func(statemachine.StateMachineClient) error) error {
+
+	err := proxy.plugin.Invoke(call)
+
+	if err != nil && proxy.plugin.IsDown(err) {
+
+		log.Printf("state machine process is not serving, restarting it: %v", err)
+
+		err = proxy.restart()
+
+		if err == nil {
+			err = proxy.plugin.Invoke(call)
+		}
+	}
+
+	if err != nil && !errors.Is(err, ErrStateMachineUnavailable) {
+		err = fmt.Errorf("%w: %v", ErrStateMachineUnavailable, err)
+	}
+
+	return err
+}
+
+// Swap replaces the running state machine with one started by launch, restored from the latest snapshot and
+// replayed with the entries applied since, and launches it from then on. The running state machine keeps serving
+// if the new one cannot be started or caught up, as a state machine that applies deterministically must be.
+// Returns ErrStateMachineUnavailable if the proxy has failed
+// Returns the errors of launching, restoring or replaying the new state machine
+func (proxy *Proxy) Swap(launch Launcher) error {
+
+	proxy.mutex.Lock()
+	defer proxy.mutex.Unlock()
+
+	var err error
+
+	running := proxy.plugin
+
+	if proxy.isFailed.Load() {
+		err = ErrStateMachineUnavailable
+	} else {
+		proxy.plugin, err = launch()
+	}
+
+	if err == nil {
+		err = proxy.catchUp()
+	}
+
+	if err == nil {
+
+		running.Stop()
+		proxy.launch = launch
+	} else if proxy.plugin != running {
+
+		proxy.plugin.Stop()
+		proxy.plugin = running
+	}
+
+	return err
+}
+
+// restart replaces the state machine with a new one, restores the latest snapshot and replays the journal entries
+// applied since. The proxy mutex must be held
+func (proxy *Proxy) restart() error {
+
+	proxy.plugin.Stop()
+
+	var err error
+
+	proxy.plugin, err = proxy.launch()
+
+	if err == nil {
+		err = proxy.catchUp()
+	}
+
+	return err
+}
+
+// catchUp restores the state machine from the latest snapshot and replays the journal entries applied since. The
+// proxy mutex must be held
+func (proxy *Proxy) catchUp() error {
+
+	var err error
+
+	replayFrom := int64(0)
+
+	if proxy.hasSnapshot {
+
+		err = proxy.restore(proxy.snapshot)
+		replayFrom = proxy.snapshot.Index + 1
+	}
+
+	if err == nil && replayFrom <= proxy.appliedIndex {
+		err = proxy.replay(uint64(replayFrom), uint64(proxy.appliedIndex))
+	}
+
+	return err
+}
+
+// restore hands snapshot to the state machine process to restore its state from. The proxy mutex must be held, or
+// the proxy not yet started
+func (proxy *Proxy) restore(snapshot state.Snapshot) error {
+
+	var restored *statemachine.RestoreResponse
+
+	err := proxy.plugin.Invoke(func(client statemachine.StateMachineClient) error {
+
+		var callErr error
+		restored, callErr = client.Restore(context.Background(),
+			&statemachine.RestoreRequest{Index: uint64(snapshot.Index), Data: snapshot.Data})
+
+		return callErr
+	})
+
+	if err == nil && restored.Error != "" {
+		err = errors.New(restored.Error)
+	}
+
+	return err
+}
+
+// restoreSnapshotFile restores the state machine process from the snapshot persisted to the SnapshotFile, if there
+// is one
+func (proxy *Proxy) restoreSnapshotFile() error {
+
+	var err error
+
+	if proxy.config.SnapshotFile != "" {
+		err = proxy.restoreSnapshot(proxy.config.SnapshotFile)
+	}
+
+	return err
+}
+
+// restoreSnapshot restores the state machine process from the snapshot file at path, a missing file is not an
+// error. A snapshot ahead of the committed entries of the journal was not taken from it and is ignored
+func (proxy *Proxy) restoreSnapshot(path string) error {
+
+	snapshot, err := state.ReadSnapshotFile(path, proxy.config.Keyring)
+
+	committed := <-proxy.journal.GetAllUncommittedEntries()
+	isAhead := snapshot.Index > int64(committed.CommitIndex)
+
+	if err == nil && isAhead {
+		log.Printf("ignoring the snapshot at index %d in '%s', it is ahead of the journal", snapshot.Index, path)
+	} else if err == nil {
+		err = proxy.restore(snapshot)
+	}
+
+	if err == nil && !isAhead {
+		proxy.snapshot, proxy.hasSnapshot = snapshot, true
+		proxy.appliedIndex = snapshot.Index
+		proxy.highestSeenCommitIndex.Store(snapshot.Index)
+	}
+
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+
+	return err
+}
+
+// replay applies the journal entries from index from through index through again, their results were published
+// when they were first applied
+func (proxy *Proxy) replay(from uint64, through uint64) error {
+
+	entries, err := proxy.journal.GetAllEntriesBetween(from, through)
+
+	for index := from; err == nil && entries.HasNext(); index++ {
+
+		var entry journal.Entry
+		entry, err = entries.Next()
+
+		if err == nil {
+
+			request := &statemachine.ApplyRequest{Index: index, Command: entry.Item}
+
+			err = proxy.plugin.Invoke(func(client statemachine.StateMachineClient) error {
+
+				_, callErr := client.Apply(context.Background(), request)
+
+				return callErr
+			})
+		}
+	}
+
+	return err
+}
+
+// launch starts the state machine program and connects to it once it accepts connections on its socket
+func launch(config *Config) (*pluginProcess, error) {
+
+	plugin := &pluginProcess{exited: make(chan struct{})}
+
+	var err error
+
+	plugin.socketDir, err = os.MkdirTemp("", "raft-state-machine-")
+
+	socketPath := filepath.Join(plugin.socketDir, "state-machine.sock")
+
+	if err == nil {
+
+		plugin.command = exec.Command(config.Executable, config.Args...)
+		plugin.command.Env = append(os.Environ(), SocketEnvVar+"="+socketPath)
+		plugin.command.Stdout, plugin.command.Stderr = os.Stdout, os.Stderr
+
+		err = plugin.command.Start()
+	}
+
+	if err == nil {
+
+		go func() {
+			_ = plugin.command.Wait()
+			close(plugin.exited)
+		}()
+
+		err = plugin.connect(socketPath, config.StartTimeout)
+	}
+
+	if err != nil {
+		plugin.Stop()
+	}
+
+	return plugin, err
+}
+
+// connect waits for the process to listen on socketPath and connects to it.
+// Returns ErrStateMachineNotStarted if the process exits or is not listening within timeoutMs
+func (plugin *pluginProcess) connect(socketPath string, timeoutMs int) error {
+
+	deadline := time.Now().Add(time.Duration(timeoutMs) * time.Millisecond)
+
+	err := ErrStateMachineNotStarted
+
+	for isWaiting := true; isWaiting; {
+
+		probe, probeErr := net.Dial("unix", socketPath)
+
+		if probeErr == nil {
+
+			_ = probe.Close()
+			err, isWaiting = nil, false
+		} else if plugin.hasExited() || time.Now().After(deadline) {
+			isWaiting = false
+		} else {
+			time.Sleep(connectRetryPeriod)
+		}
+	}
+
+	if err == nil {
+
+		plugin.connection, err = grpc.Dial("unix:"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+
+	if err == nil {
+		plugin.client = statemachine.NewStateMachineClient(plugin.connection)
+	}
+
+	return err
+}
+
+// Invoke makes call to the process over its connection
+func (plugin *pluginProcess) Invoke(call func(statemachine.StateMachineClient) error) error {
+
+	err := ErrStateMachineUnavailable
+
+	if plugin.client != nil {
+		err = call(plugin.client)
+	}
+
+	return err
+}
+
+// IsDown returns true when err from a call means the process is no longer serving rather than that it failed the call
+func (plugin *pluginProcess) IsDown(err error) bool {
+
+	return plugin.connection == nil || status.Code(err) == codes.Unavailable || plugin.hasExited()
+}
+
+func (plugin *pluginProcess) hasExited() bool {
+
+	isExited := false
+
+	select {
+	case <-plugin.exited:
+		isExited = true
+	default:
+	}
+
+	return isExited
+}
+
+// Stop closes the connection and kills the process, waiting for it to exit
+func (plugin *pluginProcess) Stop() {
+
+	if plugin.connection != nil {
+		_ = plugin.connection.Close()
+	}
+
+	if plugin.command != nil && plugin.command.Process != nil {
+
+		_ = plugin.command.Process.Kill()
+		<-plugin.exited
+	}
+
+	if plugin.socketDir != "" {
+		_ = os.RemoveAll(plugin.socketDir)
+	}
+}
+
+func newWatchEvents(index uint64, applied []*statemachine.Event) []state.WatchEvent {
+
+	events := make([]state.WatchEvent, 0, len(applied))
+
+	for _, change := range applied {
+
+		eventType := state.EventPut
+
+		if change.Deleted {
+			eventType = state.EventDelete
+		}
+
+		events = append(events, state.WatchEvent{
+			Type:        eventType,
+			Index:       index,
+			Key:         change.Key,
+			Value:       change.Value,
+			Version:     change.Version,
+			CreateIndex: change.CreateIndex,
+			ModifyIndex: change.ModifyIndex,
+		})
+	}
+
+	return events
+}
+
+// pluginError returns the error reported by the state machine process with message
+func pluginError(message string) error {
+
+	err := errors.New(message)
+
+	for _, known := range pluginErrors {
+
+		if known.Error() == message {
+			err = known
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,322 @@
+package process
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jrobison153/raft/crypto"
+	"github.com/jrobison153/raft/journal"
+	"github.com/jrobison153/raft/state"
+)
+
+// TestMain runs this test binary as the state machine process when a Proxy starts it
+func TestMain(m *testing.M) {
+
+	if _, isStartedByProxy := os.LookupEnv(SocketEnvVar); isStartedByProxy {
+		log.Fatal(ListenAndServe(newMapPlugin()))
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestWhenCommittedEntriesAreRenderedThenTheyAreReadFromTheProcess(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	appendCommands(journalSpy, state.NewPutCommand("a", []byte("1")), state.NewPutCommand("a", []byte("2")))
+
+	proxy := startProxy(t, journalSpy, NewDefaultConfig(os.Args[0]))
+	defer proxy.Stop()
+
+	item, err := proxy.ResolveRequestToItem(createKeyRequest("a"))
+
+	if err != nil || string(item.Value) != "2" || item.Version != 2 || item.Key != "a" {
+		t.Errorf("Unexpected item %+v and error '%v'", item, err)
+	}
+}
+
+func TestWhenKeyIsNotInTheProcessThenErrKeyNotFoundIsReturned(t *testing.T) {
+
+	proxy := startProxy(t, journal.NewJournalSpy(), NewDefaultConfig(os.Args[0]))
+	defer proxy.Stop()
+
+	_, err := proxy.ResolveRequestToData(createKeyRequest("missing"))
+
+	if state.ErrKeyNotFound != err {
+		t.Errorf("Should have received error '%v' but got '%v'", state.ErrKeyNotFound, err)
+	}
+}
+
+func TestWhenAnAlreadyAppliedIndexIsCommittedAgainThenTheProxyKeepsApplyingLaterEntries(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	appendCommands(journalSpy, state.NewPutCommand("a", []byte("1")), state.NewPutCommand("b", []byte("2")))
+
+	proxy := startProxy(t, journalSpy, NewDefaultConfig(os.Args[0]))
+	defer proxy.Stop()
+
+	<-journalSpy.Commit(0)
+
+	appendCommands(journalSpy, state.NewPutCommand("c", []byte("3")))
+
+	appliedCh := make(chan state.ApplyResult, 1)
+	_ = proxy.NotifyOfApplyOnIndexOnce(2, appliedCh)
+
+	<-journalSpy.Commit(2)
+	<-appliedCh
+
+	item, err := proxy.ResolveRequestToItem(createKeyRequest("c"))
+
+	if err != nil || string(item.Value) != "3" {
+		t.Errorf("Key 'c' should have been applied after the stale commit but got %+v and error '%v'", item, err)
+	}
+}
+
+func TestWhenTheProcessExitsThenItIsRestoredFromTheLatestSnapshotAndReplayed(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	appendCommands(journalSpy,
+		state.NewPutCommand("a", []byte("1")),
+		state.NewPutCommand("b", []byte("2")),
+		state.NewPutCommand("c", []byte("3")))
+
+	config := NewDefaultConfig(os.Args[0])
+	config.SnapshotInterval = 2
+
+	proxy := startProxy(t, journalSpy, config)
+	defer proxy.Stop()
+
+	plugin := proxy.plugin.(*pluginProcess)
+
+	_ = plugin.command.Process.Kill()
+	<-plugin.exited
+
+	for _, key := range []string{"a", "b", "c"} {
+
+		item, err := proxy.ResolveRequestToItem(createKeyRequest(key))
+
+		if err != nil || item.Version != 1 {
+			t.Errorf("Key '%s' should have survived the restart but got %+v and error '%v'", key, item, err)
+		}
+	}
+
+	if !proxy.hasSnapshot || proxy.snapshot.Index != 1 {
+		t.Errorf("Restart should have been restored from the snapshot at index 1 but got %+v", proxy.snapshot)
+	}
+}
+
+func TestWhenStateMachineIsSwappedThenTheNewOneIsCaughtUpAndTheOldOneIsStopped(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	appendCommands(journalSpy,
+		state.NewPutCommand("a", []byte("1")),
+		state.NewPutCommand("b", []byte("2")),
+		state.NewPutCommand("c", []byte("3")))
+
+	config := NewDefaultConfig(os.Args[0])
+	config.SnapshotInterval = 2
+
+	proxy := startProxy(t, journalSpy, config)
+	defer proxy.Stop()
+
+	running := proxy.plugin.(*pluginProcess)
+
+	err := proxy.Swap(func() (Runner, error) { return launch(config) })
+
+	item, getErr := proxy.ResolveRequestToItem(createKeyRequest("c"))
+
+	if err != nil || getErr != nil || item.Version != 1 || proxy.plugin == running || !running.hasExited() {
+		t.Errorf("Expected the swapped in process to serve %+v but got errors '%v' and '%v'", item, err, getErr)
+	}
+}
+
+func TestWhenSwappedInStateMachineCannotBeStartedThenTheRunningOneKeepsServing(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	appendCommands(journalSpy, state.NewPutCommand("a", []byte("1")))
+
+	proxy := startProxy(t, journalSpy, NewDefaultConfig(os.Args[0]))
+	defer proxy.Stop()
+
+	running := proxy.plugin
+
+	err := proxy.Swap(func() (Runner, error) { return launch(NewDefaultConfig(t.TempDir())) })
+
+	item, getErr := proxy.ResolveRequestToItem(createKeyRequest("a"))
+
+	if err == nil || getErr != nil || item.Version != 1 || proxy.plugin != running {
+		t.Errorf("Expected the running process to keep serving but got %+v and errors '%v' and '%v'", item, err, getErr)
+	}
+}
+
+func TestWhenProxyStartsWithAPersistedSnapshotThenItIsRestoredFromItAndAppliesTheEntriesAfter(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	appendCommands(journalSpy,
+		state.NewPutCommand("a", []byte("1")),
+		state.NewPutCommand("b", []byte("2")),
+		state.NewPutCommand("c", []byte("3")))
+	<-journalSpy.Commit(2)
+
+	keyring, _ := crypto.ParseKeyring("1:" + base64.StdEncoding.EncodeToString(make([]byte, 32)))
+
+	config := NewDefaultConfig(os.Args[0])
+	config.SnapshotInterval = 2
+	config.SnapshotFile = filepath.Join(t.TempDir(), "state.snapshot")
+	config.Keyring = keyring
+
+	startProxy(t, journalSpy, config).Stop()
+
+	restarted := startProxy(t, journalSpy, config)
+	defer restarted.Stop()
+
+	_, openWithoutKeyErr := state.ReadSnapshotFile(config.SnapshotFile, nil)
+	item, err := restarted.ResolveRequestToItem(createKeyRequest("c"))
+
+	if err != nil || item.Version != 1 || !restarted.hasSnapshot || restarted.snapshot.Index != 1 ||
+		crypto.ErrUnknownKeyID != openWithoutKeyErr {
+
+		t.Errorf("Expected a restore from the sealed snapshot at index 1 but got %+v, %+v and errors '%v' and '%v'",
+			item, restarted.snapshot, err, openWithoutKeyErr)
+	}
+}
+
+func TestWhenCommittedEntryIsAppliedThenItsResultAndChangesArePublished(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+
+	proxy := startProxy(t, journalSpy, NewDefaultConfig(os.Args[0]))
+	defer proxy.Stop()
+
+	watcher, _ := proxy.Watch(state.WatchRequest{Key: "a"})
+
+	appendCommands(journalSpy, state.NewPutCommand("a", []byte("1")))
+
+	resultCh := make(chan state.ApplyResult, 1)
+	_ = proxy.NotifyOfApplyOnIndexOnce(0, resultCh)
+
+	<-journalSpy.Commit(0)
+
+	result := <-resultCh
+
+	var change state.WatchEvent
+
+	select {
+	case change = <-watcher.Events():
+	case <-time.After(time.Second):
+	}
+
+	if !result.Applied || result.Version != 1 || change.Key != "a" || change.Index != 0 {
+		t.Errorf("Unexpected result %+v and event %+v", result, change)
+	}
+}
+
+func TestWhenApplyResponseIsServedThenTheProxyReadsTheSameChanges(t *testing.T) {
+
+	events := []state.WatchEvent{
+		{Type: state.EventPut, Index: 7, Key: "a", Value: []byte("1"), Version: 3, CreateIndex: 5, ModifyIndex: 7},
+		{Type: state.EventDelete, Index: 7, Key: "b"},
+	}
+
+	response := newApplyResponse(state.ApplyResult{Index: 7, Applied: true, Version: 3}, events)
+
+	if !response.Applied || response.Version != 3 || !reflect.DeepEqual(events, newWatchEvents(7, response.Events)) {
+		t.Errorf("Expected %+v to survive the protocol but got %+v", events, response)
+	}
+}
+
+// mapPlugin is the state machine served by this test binary when it runs as the state machine process
+type mapPlugin struct {
+	Items        map[string]state.VersionedItem
+	appliedIndex uint64
+}
+
+func newMapPlugin() *mapPlugin {
+
+	return &mapPlugin{Items: make(map[string]state.VersionedItem)}
+}
+
+func (plugin *mapPlugin) Apply(index uint64, command state.Command) (state.ApplyResult, []state.WatchEvent) {
+
+	item, exists := plugin.Items[command.Key]
+
+	plugin.appliedIndex = index
+
+	change := state.WatchEvent{Type: state.EventDelete, Index: index, Key: command.Key}
+
+	if command.Op == state.OpDelete {
+		delete(plugin.Items, command.Key)
+		item.Version = 0
+	} else {
+
+		if !exists {
+			item = state.VersionedItem{Key: command.Key, CreateIndex: index}
+		}
+
+		item.Value, item.Version, item.ModifyIndex = command.Value, item.Version+1, index
+		plugin.Items[command.Key] = item
+
+		change.Type, change.Value, change.Version = state.EventPut, item.Value, item.Version
+	}
+
+	return state.ApplyResult{Index: index, Applied: true, Version: item.Version}, []state.WatchEvent{change}
+}
+
+func (plugin *mapPlugin) Query(key state.Key) (state.VersionedItem, error) {
+
+	item, exists := plugin.Items[key.Key]
+
+	var err error
+
+	if !exists {
+		err = state.ErrKeyNotFound
+	}
+
+	return item, err
+}
+
+func (plugin *mapPlugin) Snapshot() (uint64, []byte) {
+
+	data, _ := json.Marshal(plugin.Items)
+
+	return plugin.appliedIndex, data
+}
+
+func (plugin *mapPlugin) Restore(index uint64, data []byte) error {
+
+	plugin.appliedIndex = index
+
+	return json.Unmarshal(data, &plugin.Items)
+}
+
+func startProxy(t *testing.T, journalSpy *journal.Spy, config *Config) *Proxy {
+
+	proxy := NewProxy(journalSpy, config)
+
+	err := proxy.Start()
+
+	if err != nil {
+		t.Fatalf("Unable to start the state machine process: %v", err)
+	}
+
+	return proxy
+}
+
+func appendCommands(journalSpy *journal.Spy, commands ...state.Command) {
+
+	for _, command := range commands {
+		<-journalSpy.Append(command.Encode())
+	}
+}
+
+func createKeyRequest(key string) []byte {
+
+	request, _ := json.Marshal(state.Key{Key: key})
+
+	return request
+}
@@ -0,0 +1,174 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+
+	"github.com/jrobison153/raft/api/statemachine"
+	"github.com/jrobison153/raft/state"
+	"google.golang.org/grpc"
+)
+
+var ErrSocketNotSet = errors.New("state machine socket is not set, the program must be started by a Proxy")
+
+// Plugin is a state machine running in its own process, served to the Proxy that started it by Serve. State
+// machines written in other languages implement the service in state_machine.proto instead.
+// Apply applies the journal command at index and returns its result and the changes it made for watchers. Commands
+// arrive in index order, once each unless the process is restarted when they are replayed from the last snapshot.
+// Query resolves a key, returning state.ErrKeyNotFound for a key that does not exist. Snapshot returns the state
+// and the index it reflects and Restore replaces the state with a snapshot. The proxy makes one call at a time
+type Plugin interface {
+	Apply(index uint64, command state.Command) (state.ApplyResult, []state.WatchEvent)
+	Query(key state.Key) (state.VersionedItem, error)
+	Snapshot() (uint64, []byte)
+	Restore(index uint64, data []byte) error
+}
+
+// ListenAndServe serves plugin on the Unix socket the Proxy that started this process named in SocketEnvVar. It
+// only returns when serving fails.
+// Returns ErrSocketNotSet if SocketEnvVar is not set
+func ListenAndServe(plugin Plugin) error {
+
+	socketPath, isSocketSet := os.LookupEnv(SocketEnvVar)
+
+	var listener net.Listener
+	err := ErrSocketNotSet
+
+	if isSocketSet {
+		listener, err = net.Listen("unix", socketPath)
+	}
+
+	if err == nil {
+		err = Serve(listener, plugin)
+	}
+
+	return err
+}
+
+// Serve serves plugin on listener until it is closed
+func Serve(listener net.Listener, plugin Plugin) error {
+
+	server := grpc.NewServer()
+
+	statemachine.RegisterStateMachineServer(server, NewPluginServer(plugin))
+
+	return server.Serve(listener)
+}
+
+// NewPluginServer returns plugin as the StateMachine service generated from state_machine.proto, for hosts that
+// carry the protocol messages other than over gRPC
+func NewPluginServer(plugin Plugin) statemachine.StateMachineServer {
+
+	return &pluginServer{plugin: plugin}
+}
+
+// pluginServer serves a Plugin as the StateMachine service generated from state_machine.proto
+type pluginServer struct {
+	statemachine.UnimplementedStateMachineServer
+	plugin Plugin
+}
+
+func (server *pluginServer) Apply(
+	ctx context.Context,
+	request *statemachine.ApplyRequest) (*statemachine.ApplyResponse, error) {
+
+	response := &statemachine.ApplyResponse{}
+
+	command, err := state.DecodeCommand(request.Command)
+
+	if err == nil {
+
+		result, events := server.plugin.Apply(request.Index, command)
+
+		response = newApplyResponse(result, events)
+	} else {
+		response.Error = err.Error()
+	}
+
+	return response, nil
+}
+
+func (server *pluginServer) Query(
+	ctx context.Context,
+	request *statemachine.QueryRequest) (*statemachine.QueryResponse, error) {
+
+	response := &statemachine.QueryResponse{}
+
+	key := state.Key{}
+
+	var err error
+
+	if json.Unmarshal(request.Request, &key) != nil {
+		err = state.ErrInvalidKeyRequest
+	}
+
+	var item state.VersionedItem
+
+	if err == nil {
+		item, err = server.plugin.Query(key)
+	}
+
+	if err == nil {
+
+		response.Found, response.Value, response.Version = true, item.Value, item.Version
+		response.CreateIndex, response.ModifyIndex = item.CreateIndex, item.ModifyIndex
+	} else if err != state.ErrKeyNotFound {
+		response.Error = err.Error()
+	}
+
+	return response, nil
+}
+
+func (server *pluginServer) Snapshot(
+	ctx context.Context,
+	request *statemachine.SnapshotRequest) (*statemachine.SnapshotResponse, error) {
+
+	response := &statemachine.SnapshotResponse{}
+
+	response.Index, response.Data = server.plugin.Snapshot()
+
+	return response, nil
+}
+
+func (server *pluginServer) Restore(
+	ctx context.Context,
+	request *statemachine.RestoreRequest) (*statemachine.RestoreResponse, error) {
+
+	response := &statemachine.RestoreResponse{}
+
+	if err := server.plugin.Restore(request.Index, request.Data); err != nil {
+		response.Error = err.Error()
+	}
+
+	return response, nil
+}
+
+func newApplyResponse(result state.ApplyResult, events []state.WatchEvent) *statemachine.ApplyResponse {
+
+	response := &statemachine.ApplyResponse{
+		Applied: result.Applied,
+		Version: result.Version,
+		Counter: result.Counter,
+	}
+
+	if result.Err != nil {
+		response.Error = result.Err.Error()
+	}
+
+	for _, applied := range events {
+
+		response.Events = append(response.Events, &statemachine.Event{
+			Deleted:     applied.Type == state.EventDelete,
+			Key:         applied.Key,
+			Value:       applied.Value,
+			Version:     applied.Version,
+			CreateIndex: applied.CreateIndex,
+			ModifyIndex: applied.ModifyIndex,
+		})
+	}
+
+	return response
+}
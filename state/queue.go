@@ -0,0 +1,255 @@
+package state
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrQueueEmpty               = errors.New("queue has no message visible to consumers")
+	ErrInvalidVisibilityTimeout = errors.New("dequeue visibility timeout must be at least one second")
+	ErrNamespacedQueue          = errors.New("queues are not kept in namespaces, a queue command must not name one")
+	ErrStaleDelivery            = errors.New("message is not delivered or has been delivered again since")
+)
+
+// QueueMessage is a message of a FIFO queue. Messages are delivered in the order they were enqueued, which is journal
+// order. A delivered message is hidden from other consumers until it is acknowledged, which removes it, or returned
+// with a nack, which makes it visible again in its original place. Delivery counts the deliveries of the message and
+// identifies the current one, an ack or nack of an earlier delivery is not applied. The state machines never expire
+// a delivery themselves, the leader journals a nack once VisibilityTimeout seconds have passed without an ack.
+// A message ID is one more than the index of the journal entry that enqueued it so an ID of 0 never names a message
+type QueueMessage struct {
+	Queue             string
+	ID                uint64
+	Body              []byte
+	Delivery          uint64
+	VisibilityTimeout int64
+}
+
+// queueTable holds the messages of every queue in the order they were enqueued. queueTable is safe for concurrent use
+type queueTable struct {
+	mutex  sync.RWMutex
+	queues map[string]*messageQueue
+}
+
+type messageQueue struct {
+	order    []uint64
+	messages map[uint64]*queuedMessage
+}
+
+type queuedMessage struct {
+	message  QueueMessage
+	inFlight bool
+}
+
+// MessageIDEnqueuedAt returns the ID of the message enqueued by the journal entry at index
+func MessageIDEnqueuedAt(index uint64) uint64 {
+
+	return index + 1
+}
+
+func newQueueTable() *queueTable {
+
+	return &queueTable{
+		queues: make(map[string]*messageQueue),
+	}
+}
+
+func (table *queueTable) enqueue(queue string, id uint64, body []byte) QueueMessage {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	messages, exists := table.queues[queue]
+
+	if !exists {
+		messages = &messageQueue{messages: make(map[uint64]*queuedMessage)}
+		table.queues[queue] = messages
+	}
+
+	message := QueueMessage{Queue: queue, ID: id, Body: body}
+
+	messages.order = append(messages.order, id)
+	messages.messages[id] = &queuedMessage{message: message}
+
+	return message
+}
+
+// dequeue delivers the first visible message of queue for visibilityTimeout seconds, returning it and whether there
+// was one
+func (table *queueTable) dequeue(queue string, visibilityTimeout int64) (QueueMessage, bool) {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	queued, isVisible := table.firstVisible(queue)
+
+	var message QueueMessage
+
+	if isVisible {
+
+		queued.inFlight = true
+		queued.message.Delivery++
+		queued.message.VisibilityTimeout = visibilityTimeout
+
+		message = queued.message
+	}
+
+	return message, isVisible
+}
+
+// ack removes message id of queue if delivery is its current delivery, returning whether it did
+func (table *queueTable) ack(queue string, id uint64, delivery uint64) bool {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	_, isDelivered := table.delivered(queue, id, delivery)
+
+	if isDelivered {
+
+		messages := table.queues[queue]
+
+		delete(messages.messages, id)
+
+		position := sort.Search(len(messages.order), func(i int) bool { return messages.order[i] >= id })
+		messages.order = append(messages.order[:position], messages.order[position+1:]...)
+
+		if len(messages.order) == 0 {
+			delete(table.queues, queue)
+		}
+	}
+
+	return isDelivered
+}
+
+// nack makes message id of queue visible again if delivery is its current delivery, returning whether it did
+func (table *queueTable) nack(queue string, id uint64, delivery uint64) bool {
+
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+
+	queued, isDelivered := table.delivered(queue, id, delivery)
+
+	if isDelivered {
+		queued.inFlight = false
+	}
+
+	return isDelivered
+}
+
+// peek returns the message the next dequeue of queue would deliver and whether there is one
+func (table *queueTable) peek(queue string) (QueueMessage, bool) {
+
+	table.mutex.RLock()
+	defer table.mutex.RUnlock()
+
+	queued, isVisible := table.firstVisible(queue)
+
+	var message QueueMessage
+
+	if isVisible {
+		message = queued.message
+	}
+
+	return message, isVisible
+}
+
+// inFlight returns every delivered message not yet acknowledged or returned, by queue and then in queue order
+func (table *queueTable) inFlight() []QueueMessage {
+
+	table.mutex.RLock()
+	defer table.mutex.RUnlock()
+
+	delivered := make([]QueueMessage, 0)
+
+	for _, messages := range table.queues {
+
+		for _, id := range messages.order {
+
+			if messages.messages[id].inFlight {
+				delivered = append(delivered, messages.messages[id].message)
+			}
+		}
+	}
+
+	sort.SliceStable(delivered, func(i, j int) bool { return delivered[i].Queue < delivered[j].Queue })
+
+	return delivered
+}
+
+func (table *queueTable) firstVisible(queue string) (*queuedMessage, bool) {
+
+	var first *queuedMessage
+
+	if messages, exists := table.queues[queue]; exists {
+
+		for position := 0; position < len(messages.order) && first == nil; position++ {
+
+			if queued := messages.messages[messages.order[position]]; !queued.inFlight {
+				first = queued
+			}
+		}
+	}
+
+	return first, first != nil
+}
+
+func (table *queueTable) delivered(queue string, id uint64, delivery uint64) (*queuedMessage, bool) {
+
+	var queued *queuedMessage
+
+	if messages, exists := table.queues[queue]; exists {
+		queued = messages.messages[id]
+	}
+
+	return queued, queued != nil && queued.inFlight && queued.message.Delivery == delivery
+}
+
+// Peek returns the message the next dequeue of queue would deliver.
+// Returns ErrQueueEmpty if the queue has no visible message
+// Returns ErrCorruptionAlarm if a corrupt journal entry has been seen, the state can no longer be trusted
+func (state *journalApplier) Peek(queue string) (QueueMessage, error) {
+
+	message, isVisible := state.queues.peek(queue)
+
+	var err error
+
+	if state.IsCorruptionAlarmRaised() {
+		message, err = QueueMessage{}, ErrCorruptionAlarm
+	} else if !isVisible {
+		err = ErrQueueEmpty
+	}
+
+	return message, err
+}
+
+// InFlight returns every delivered message not yet acknowledged or returned, a newly elected leader starts tracking
+// their visibility timeouts from these
+func (state *journalApplier) InFlight() []QueueMessage {
+
+	return state.queues.inFlight()
+}
+
+// applyQueueCommand enqueues, dequeues, acknowledges or returns a message. A dequeue of a queue without a visible
+// message, or an ack or nack of a delivery that is not current, is not applied. The result Message is the message
+// enqueued or delivered
+func (state *journalApplier) applyQueueCommand(command Command, index uint64) ApplyResult {
+
+	result := ApplyResult{Index: index}
+
+	switch command.Op {
+	case OpEnqueue:
+		result.Applied = true
+		result.Message = state.queues.enqueue(command.Key, MessageIDEnqueuedAt(index), command.Value)
+	case OpDequeue:
+		result.Message, result.Applied = state.queues.dequeue(command.Key, command.Queue.VisibilityTimeout)
+	case OpAck:
+		result.Applied = state.queues.ack(command.Key, command.Queue.MessageID, command.Queue.Delivery)
+	default:
+		result.Applied = state.queues.nack(command.Key, command.Queue.MessageID, command.Queue.Delivery)
+	}
+
+	return result
+}
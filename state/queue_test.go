@@ -0,0 +1,146 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jrobison153/raft/journal"
+)
+
+func TestWhenMessagesAreDequeuedThenTheyAreDeliveredInTheOrderTheyWereEnqueued(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewEnqueueCommand("jobs", []byte("first")),
+		NewEnqueueCommand("other", []byte("other")),
+		NewEnqueueCommand("jobs", []byte("second")),
+		NewDequeueCommand("jobs", 30),
+		NewDequeueCommand("jobs", 30),
+		NewDequeueCommand("jobs", 30))
+
+	bodies := []string{string(results[3].Message.Body), string(results[4].Message.Body)}
+
+	if !reflect.DeepEqual([]string{"first", "second"}, bodies) || results[5].Applied {
+		t.Errorf("Expected [first second] then an empty queue but got %v and %+v", bodies, results[5])
+	}
+}
+
+func TestWhenMessageIsEnqueuedThenItsIDFollowsFromItsJournalIndex(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewEnqueueCommand("jobs", []byte("first")),
+		NewEnqueueCommand("jobs", []byte("second")),
+		NewDequeueCommand("jobs", 30))
+
+	expected := QueueMessage{Queue: "jobs", ID: MessageIDEnqueuedAt(0), Body: []byte("first"), Delivery: 1,
+		VisibilityTimeout: 30}
+
+	if results[1].Message.ID != MessageIDEnqueuedAt(1) || !reflect.DeepEqual(expected, results[2].Message) {
+		t.Errorf("Unexpected enqueue result %+v and delivery %+v", results[1], results[2].Message)
+	}
+}
+
+func TestWhenDeliveredMessageIsAcknowledgedThenItIsRemoved(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewEnqueueCommand("jobs", []byte("first")),
+		NewDequeueCommand("jobs", 30),
+		NewAckCommand("jobs", MessageIDEnqueuedAt(0), 1))
+
+	_, err := stateMachine.Peek("jobs")
+
+	if !results[2].Applied || ErrQueueEmpty != err || len(stateMachine.InFlight()) != 0 {
+		t.Errorf("Unexpected ack result %+v and peek error '%v'", results[2], err)
+	}
+}
+
+func TestWhenDeliveredMessageIsNackedThenItIsDeliveredAgainBeforeLaterMessages(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewEnqueueCommand("jobs", []byte("first")),
+		NewEnqueueCommand("jobs", []byte("second")),
+		NewDequeueCommand("jobs", 30),
+		NewNackCommand("jobs", MessageIDEnqueuedAt(0), 1),
+		NewDequeueCommand("jobs", 30))
+
+	redelivered := results[4].Message
+
+	if !results[3].Applied || string(redelivered.Body) != "first" || redelivered.Delivery != 2 {
+		t.Errorf("Unexpected nack result %+v and redelivery %+v", results[3], redelivered)
+	}
+}
+
+func TestWhenAckIsForAnEarlierDeliveryThenItIsNotApplied(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewEnqueueCommand("jobs", []byte("first")),
+		NewDequeueCommand("jobs", 30),
+		NewNackCommand("jobs", MessageIDEnqueuedAt(0), 1),
+		NewDequeueCommand("jobs", 30),
+		NewAckCommand("jobs", MessageIDEnqueuedAt(0), 1))
+
+	inFlight := stateMachine.InFlight()
+
+	if results[4].Applied || len(inFlight) != 1 || inFlight[0].Delivery != 2 {
+		t.Errorf("Unexpected ack result %+v and in flight messages %+v", results[4], inFlight)
+	}
+}
+
+func TestWhenQueueIsPeekedThenTheNextVisibleMessageIsReturnedWithoutDeliveringIt(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine,
+		NewEnqueueCommand("jobs", []byte("first")),
+		NewEnqueueCommand("jobs", []byte("second")),
+		NewDequeueCommand("jobs", 30))
+
+	message, err := stateMachine.Peek("jobs")
+	again, _ := stateMachine.Peek("jobs")
+
+	if err != nil || string(message.Body) != "second" || message.Delivery != 0 || !reflect.DeepEqual(message, again) {
+		t.Errorf("Unexpected peeked message %+v and error '%v'", message, err)
+	}
+}
+
+func TestWhenDequeueHasNoVisibilityTimeoutThenTheCommandIsInvalid(t *testing.T) {
+
+	err := NewDequeueCommand("jobs", 0).Validate()
+
+	if ErrInvalidVisibilityTimeout != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrInvalidVisibilityTimeout, err)
+	}
+}
+
+func TestWhenQueueCommandNamesANamespaceThenTheCommandIsInvalid(t *testing.T) {
+
+	command := NewEnqueueCommand("jobs", []byte("first"))
+	command.Namespace = "team-a"
+
+	err := command.Validate()
+
+	if ErrNamespacedQueue != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrNamespacedQueue, err)
+	}
+}
+
+func TestWhenQueueCommandIsEncodedThenItDecodesToTheSameCommand(t *testing.T) {
+
+	for _, command := range []Command{NewDequeueCommand("jobs", 30), NewAckCommand("jobs", 7, 2)} {
+
+		decoded, err := DecodeCommand(command.Encode())
+
+		if err != nil || !reflect.DeepEqual(command, decoded) {
+			t.Errorf("Expected %+v to survive encoding but got %+v and error '%v'", command, decoded, err)
+		}
+	}
+}
@@ -1,7 +1,53 @@
 package state
 
+// Renderer renders the committed entries of a journal into state that can be read. Every committed entry produces
+// an ApplyResult, writers register for the result of their entry's index to learn what the state machine did with
+// it and that reads will now see it. Leases, namespaces, secondary indexes, queues, ordered scans and chunked reads are
+// optional, a Renderer keeps them only if it also implements LeaseKeeper, NamespaceKeeper, IndexQuerier, QueueKeeper,
+// Scanner or LargeValueReader
 type Renderer interface {
 	ResolveRequestToData(request []byte) ([]byte, error)
+	ResolveRequestToItem(request []byte) (VersionedItem, error)
 	Start() error
 	TypeOfLogger() string
+	NotifyOfApplyOnIndexOnce(index uint64, ch chan ApplyResult) error
+	Watch(request WatchRequest) (*Watcher, error)
+}
+
+// LeaseKeeper is a Renderer that keeps the leases granted and revoked by the entries it applies
+type LeaseKeeper interface {
+	Lease(id uint64) (Lease, bool)
+	Leases() []Lease
+}
+
+// NamespaceKeeper is a Renderer that keeps namespaces and their usage
+type NamespaceKeeper interface {
+	Namespace(name string) (Namespace, bool)
+	Namespaces() []Namespace
+}
+
+// IndexQuerier is a Renderer that keeps secondary indexes
+type IndexQuerier interface {
+	QueryIndex(name string, value []byte, limit int) ([]string, error)
+}
+
+// Scanner is a Renderer that keeps its keys ordered and can scan ranges of them
+type Scanner interface {
+	Scan(request ScanRequest) ([]KeyValItem, error)
+}
+
+// LargeValueReader is a Renderer that keeps the chunks large values were committed in
+type LargeValueReader interface {
+	ResolveRequestToChunks(request []byte) (VersionedItem, [][]byte, error)
+}
+
+// UploadStager is a Renderer that stages the chunks of large value uploads until they are committed or discarded
+type UploadStager interface {
+	StagedUploads() []StagedUpload
+}
+
+// QueueKeeper is a Renderer that keeps queues and their in flight deliveries
+type QueueKeeper interface {
+	Peek(queue string) (QueueMessage, error)
+	InFlight() []QueueMessage
 }
@@ -0,0 +1,70 @@
+package state
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	// LockKeyPrefix begins the key of every lock, see NewAcquireLockCommand
+	LockKeyPrefix = "__lock/"
+
+	// ElectionKeyPrefix begins the key of every election, the leader holds it exactly like a lock
+	ElectionKeyPrefix = "__election/"
+
+	// SequenceKeyPrefix begins the key each sequence keeps the last ID it reserved under, see NewNextSequenceCommand
+	SequenceKeyPrefix = "__sequence/"
+)
+
+var ErrReservedKey = errors.New("key begins with a prefix reserved for the keys the node keeps itself")
+
+// reservedKeyWriters maps the prefixes of the keyspace keys the node keeps for itself to the only operations that
+// may write them. A key stored in a namespace is only ever written through its namespace
+var reservedKeyWriters = map[string][]CommandOp{
+	NamespaceKeyPrefix: nil,
+	LockKeyPrefix:      {OpAcquireLock},
+	ElectionKeyPrefix:  {OpAcquireLock},
+	SequenceKeyPrefix:  {OpNextSequence},
+}
+
+// IsReservedKey returns true if key begins with a reserved prefix
+func IsReservedKey(key string) bool {
+
+	isReserved := false
+
+	for prefix := range reservedKeyWriters {
+		isReserved = isReserved || strings.HasPrefix(key, prefix)
+	}
+
+	return isReserved
+}
+
+// isReservedFrom returns true if key begins with a reserved prefix op may not write
+func isReservedFrom(key string, op CommandOp) bool {
+
+	isReserved := false
+
+	for prefix, writers := range reservedKeyWriters {
+		isReserved = isReserved || (strings.HasPrefix(key, prefix) && !isOneOf(op, writers))
+	}
+
+	return isReserved
+}
+
+// isHiddenKey returns true if key is stored in a namespace, the default namespace can neither read nor write it.
+// The other reserved keys can be read and watched by anyone
+func isHiddenKey(key string) bool {
+
+	return strings.HasPrefix(key, NamespaceKeyPrefix)
+}
+
+func isOneOf(op CommandOp, ops []CommandOp) bool {
+
+	isOne := false
+
+	for _, candidate := range ops {
+		isOne = isOne || candidate == op
+	}
+
+	return isOne
+}
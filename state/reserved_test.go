@@ -0,0 +1,63 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/jrobison153/raft/journal"
+)
+
+func TestWhenLockOrElectionKeyIsWrittenByAnyOperationButAcquireLockThenTheKeyIsReserved(t *testing.T) {
+
+	for _, command := range []Command{
+		NewPutCommand(LockKeyPrefix+"jobs", []byte("mine")),
+		NewPutIfAbsentCommand(ElectionKeyPrefix+"primary", []byte("mine")),
+		NewDeleteCommand(ElectionKeyPrefix + "primary"),
+		NewTxnCommand(Txn{Success: []TxnOp{{Op: OpDelete, Key: LockKeyPrefix + "jobs"}}}),
+	} {
+
+		if err := command.Validate(); ErrReservedKey != err {
+			t.Errorf("Should have received error '%v' for %+v but got '%v'", ErrReservedKey, command, err)
+		}
+	}
+}
+
+func TestWhenLockIsAcquiredTwiceThenOnlyTheFirstHolderHoldsItAndItCanBeRead(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewGrantLeaseCommand(10),
+		NewGrantLeaseCommand(10),
+		NewAcquireLockCommand(LockKeyPrefix+"jobs", []byte("first"), LeaseIDGrantedAt(0)),
+		NewAcquireLockCommand(LockKeyPrefix+"jobs", []byte("second"), LeaseIDGrantedAt(1)))
+
+	data, err := stateMachine.ResolveRequestToData(createKeyRequest(LockKeyPrefix + "jobs"))
+
+	if !results[2].Applied || results[3].Applied || err != nil || string(data) != "first" {
+		t.Errorf("Lock should have been held by the first acquirer but got %+v, '%s' and error '%v'", results, data,
+			err)
+	}
+}
+
+func TestWhenLockIsAcquiredWithoutALeaseThenTheCommandIsNotValid(t *testing.T) {
+
+	err := NewAcquireLockCommand(LockKeyPrefix+"jobs", nil, 0).Validate()
+
+	if ErrLockWithoutLease != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrLockWithoutLease, err)
+	}
+}
+
+func TestWhenSequenceKeyIsWrittenByAnyOperationButNextSequenceThenTheKeyIsReserved(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewNextSequenceCommand(SequenceKeyPrefix+"orders", 10),
+		NewIncrementCommand(SequenceKeyPrefix+"orders", 100),
+		NewPutCommand(SequenceKeyPrefix+"orders", []byte("0")))
+
+	if !results[0].Applied || ErrReservedKey != results[1].Err || ErrReservedKey != results[2].Err {
+		t.Errorf("Only the sequence should have written its key but got %+v", results)
+	}
+}
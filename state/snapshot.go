@@ -0,0 +1,97 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/jrobison153/raft/crypto"
+)
+
+const (
+	snapshotFormatVersion = 1
+	snapshotHeaderSize    = 5
+)
+
+var (
+	ErrBadSnapshotHeader       = errors.New("file does not have a valid state machine snapshot header")
+	ErrUnsupportedSnapshotFile = errors.New("snapshot file format version is not supported")
+
+	snapshotMagic = []byte("RSNP")
+)
+
+// Snapshot is a point in time copy of the state machine data, it reflects every journal entry up to and including
+// Index. Data is opaque, it is the state as encoded by the state machine that took the snapshot
+type Snapshot struct {
+	Index int64
+	Data  []byte
+}
+
+// WriteSnapshotFile atomically writes snapshot to path. A snapshot file is a magic number and format version
+// followed by the JSON encoded snapshot wrapped in a crypto envelope, sealed with the active key of keyring or left
+// in plaintext when keyring is nil
+func WriteSnapshotFile(path string, snapshot Snapshot, keyring *crypto.Keyring) error {
+
+	body, _ := json.Marshal(snapshot)
+
+	envelope, err := crypto.SealEnvelope(keyring, body)
+
+	if err == nil {
+
+		contents := append(append([]byte{}, snapshotMagic...), snapshotFormatVersion)
+
+		err = writeFileAtomically(path, append(contents, envelope...))
+	}
+
+	return err
+}
+
+// ReadSnapshotFile reads the snapshot written to path by WriteSnapshotFile, opening it with keyring if it is sealed
+func ReadSnapshotFile(path string, keyring *crypto.Keyring) (Snapshot, error) {
+
+	snapshot := Snapshot{}
+
+	contents, err := os.ReadFile(path)
+
+	if err == nil {
+		err = checkSnapshotHeader(contents)
+	}
+
+	var body []byte
+
+	if err == nil {
+		body, err = crypto.OpenEnvelope(keyring, contents[snapshotHeaderSize:])
+	}
+
+	if err == nil {
+		err = json.Unmarshal(body, &snapshot)
+	}
+
+	return snapshot, err
+}
+
+func checkSnapshotHeader(contents []byte) error {
+
+	var err error
+
+	if len(contents) < snapshotHeaderSize || string(contents[:len(snapshotMagic)]) != string(snapshotMagic) {
+		err = ErrBadSnapshotHeader
+	} else if contents[len(snapshotMagic)] != snapshotFormatVersion {
+		err = ErrUnsupportedSnapshotFile
+	}
+
+	return err
+}
+
+func writeFileAtomically(path string, contents []byte) error {
+
+	tmpPath := path + ".tmp"
+
+	err := os.WriteFile(tmpPath, contents, 0o640)
+
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+
+	return err
+}
@@ -0,0 +1,54 @@
+package state
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jrobison153/raft/crypto"
+)
+
+func TestWhenSnapshotWrittenWithKeyringThenItIsEncryptedOnDiskAndReadsBack(t *testing.T) {
+
+	keyring, _ := crypto.ParseKeyring("1:" + base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	path := filepath.Join(t.TempDir(), "state.snapshot")
+
+	snapshot := Snapshot{Index: 12, Data: []byte("customer private data")}
+
+	_ = WriteSnapshotFile(path, snapshot, keyring)
+
+	raw, _ := os.ReadFile(path)
+	readBack, err := ReadSnapshotFile(path, keyring)
+
+	if err != nil || readBack.Index != 12 || string(readBack.Data) != "customer private data" ||
+		bytes.Contains(raw, []byte(base64.StdEncoding.EncodeToString(snapshot.Data))) {
+		t.Errorf("Expected an encrypted snapshot to read back but got %+v and error '%v'", readBack, err)
+	}
+}
+
+func TestWhenSnapshotWrittenWithoutKeyringThenItReadsBack(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "state.snapshot")
+
+	_ = WriteSnapshotFile(path, Snapshot{Index: 3}, nil)
+
+	readBack, err := ReadSnapshotFile(path, nil)
+
+	if err != nil || readBack.Index != 3 {
+		t.Errorf("Expected snapshot at index 3 but got %+v and error '%v'", readBack, err)
+	}
+}
+
+func TestWhenFileIsNotASnapshotThenBadHeaderErrorIsReturned(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "state.snapshot")
+	_ = os.WriteFile(path, []byte("something else"), 0o640)
+
+	_, err := ReadSnapshotFile(path, nil)
+
+	if ErrBadSnapshotHeader != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrBadSnapshotHeader, err)
+	}
+}
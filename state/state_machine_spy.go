@@ -7,15 +7,30 @@ import (
 )
 
 type Spy struct {
-	renderedState [][]byte
-	journal       journal.Journaler
-	startCalled   bool
+	renderedState   [][]byte
+	journal         journal.Journaler
+	startCalled     bool
+	corruptionAlarm bool
+	results         *ApplyRegistry
+	watchers        *WatchHub
+	leases          []Lease
+	namespaces      []Namespace
+	indexedKeys     map[string][]string
+	queuedMessages  []QueueMessage
+	inFlight        []QueueMessage
+
+	applySubscriptions                map[uint64]bool
+	isFailingNextNotifyOfApplyOnIndex bool
 }
 
 func NewStateMachineSpy(journal journal.Journaler) *Spy {
 	return &Spy{
 		renderedState: make([][]byte, 0, 16),
 		journal:       journal,
+		results:       NewApplyRegistry(DefaultAppliedResultRetention),
+		watchers:      NewWatchHub(DefaultWatchBufferSize),
+
+		applySubscriptions: make(map[uint64]bool),
 	}
 }
 
@@ -25,13 +40,22 @@ func (spy *Spy) ResolveRequestToData(request []byte) ([]byte, error) {
 	data = spy.retrieveItemFromStore(request, data)
 
 	var err error
-	if data == nil {
+	if spy.corruptionAlarm {
+		err = ErrCorruptionAlarm
+	} else if data == nil {
 		err = errors.New("failing for test purposes, requested data does not exist")
 	}
 
 	return data, err
 }
 
+func (spy *Spy) ResolveRequestToItem(request []byte) (VersionedItem, error) {
+
+	data, err := spy.ResolveRequestToData(request)
+
+	return VersionedItem{Value: data, Version: 1}, err
+}
+
 func (spy *Spy) retrieveItemFromStore(request []byte, data []byte) []byte {
 	for _, storedData := range spy.renderedState {
 
@@ -61,3 +85,164 @@ func (spy *Spy) AddStateMachineData(item []byte) {
 
 	spy.renderedState = append(spy.renderedState, item)
 }
+
+func (spy *Spy) RaiseCorruptionAlarm() {
+
+	spy.corruptionAlarm = true
+}
+
+func (spy *Spy) NotifyOfApplyOnIndexOnce(index uint64, ch chan ApplyResult) error {
+
+	var err error
+
+	if spy.isFailingNextNotifyOfApplyOnIndex {
+
+		spy.isFailingNextNotifyOfApplyOnIndex = false
+		err = errors.New("failing NotifyOfApplyOnIndexOnce for test purposes")
+	} else {
+
+		spy.applySubscriptions[index] = true
+		err = spy.results.NotifyOfApplyOnIndexOnce(index, ch)
+	}
+
+	return err
+}
+
+func (spy *Spy) FailNextNotifyOfApplyOnIndexOnce() {
+
+	spy.isFailingNextNotifyOfApplyOnIndex = true
+}
+
+func (spy *Spy) RegisteredForApplyOnIndex(index uint64) bool {
+
+	return spy.applySubscriptions[index]
+}
+
+func (spy *Spy) PublishApplyResult(result ApplyResult) {
+
+	spy.results.Publish(result)
+}
+
+func (spy *Spy) Watch(request WatchRequest) (*Watcher, error) {
+
+	var watcher *Watcher
+	var err error
+
+	if spy.corruptionAlarm {
+		err = ErrCorruptionAlarm
+	} else {
+		watcher = spy.watchers.Watch(request)
+	}
+
+	return watcher, err
+}
+
+func (spy *Spy) PublishWatchEvents(index uint64, events []WatchEvent) {
+
+	spy.watchers.Publish(index, events)
+}
+
+func (spy *Spy) Lease(id uint64) (Lease, bool) {
+
+	var lease Lease
+	found := false
+
+	for _, granted := range spy.leases {
+
+		if granted.ID == id {
+			lease, found = granted, true
+		}
+	}
+
+	return lease, found
+}
+
+func (spy *Spy) Leases() []Lease {
+
+	return spy.leases
+}
+
+func (spy *Spy) AddLease(lease Lease) {
+
+	spy.leases = append(spy.leases, lease)
+}
+
+func (spy *Spy) Namespace(name string) (Namespace, bool) {
+
+	var namespace Namespace
+	found := false
+
+	for _, created := range spy.namespaces {
+
+		if created.Name == name {
+			namespace, found = created, true
+		}
+	}
+
+	return namespace, found
+}
+
+func (spy *Spy) Namespaces() []Namespace {
+
+	return spy.namespaces
+}
+
+func (spy *Spy) AddNamespace(namespace Namespace) {
+
+	spy.namespaces = append(spy.namespaces, namespace)
+}
+
+func (spy *Spy) QueryIndex(name string, value []byte, limit int) ([]string, error) {
+
+	keys, exists := spy.indexedKeys[name]
+
+	var err error
+
+	if !exists {
+		err = ErrIndexNotFound
+	}
+
+	return keys, err
+}
+
+// IndexReturns makes queries of the index name return keys
+func (spy *Spy) IndexReturns(name string, keys []string) {
+
+	if spy.indexedKeys == nil {
+		spy.indexedKeys = make(map[string][]string)
+	}
+
+	spy.indexedKeys[name] = keys
+}
+
+func (spy *Spy) Peek(queue string) (QueueMessage, error) {
+
+	var message QueueMessage
+	err := ErrQueueEmpty
+
+	for position := len(spy.queuedMessages) - 1; position >= 0; position-- {
+
+		if spy.queuedMessages[position].Queue == queue {
+			message, err = spy.queuedMessages[position], nil
+		}
+	}
+
+	return message, err
+}
+
+func (spy *Spy) InFlight() []QueueMessage {
+
+	return spy.inFlight
+}
+
+// AddQueuedMessage makes message visible for Peek, messages of a queue are peeked in the order they were added
+func (spy *Spy) AddQueuedMessage(message QueueMessage) {
+
+	spy.queuedMessages = append(spy.queuedMessages, message)
+}
+
+// AddInFlightMessage makes InFlight return message
+func (spy *Spy) AddInFlightMessage(message QueueMessage) {
+
+	spy.inFlight = append(spy.inFlight, message)
+}
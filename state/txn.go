@@ -0,0 +1,266 @@
+package state
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/jrobison153/raft/api/envelope"
+)
+
+// CompareTarget is the property of a key a Compare examines
+type CompareTarget int32
+
+const (
+	CompareValue CompareTarget = iota
+	CompareVersion
+	CompareCreateIndex
+	CompareModifyIndex
+)
+
+// CompareResult is the relation a Compare requires between the key property and the compared value
+type CompareResult int32
+
+const (
+	CompareEqual CompareResult = iota
+	CompareNotEqual
+	CompareGreater
+	CompareLess
+)
+
+var ErrInvalidTxn = errors.New("transaction compares and operations must have keys and use supported operations")
+
+// Txn is a multi key transaction applied atomically as a single journal entry. When every Compare holds the
+// Success operations are applied, otherwise the Failure operations are. Operations are OpPut, OpDelete or OpGet, a
+// put keeps the key attached to the lease it already was
+type Txn struct {
+	Compares []Compare `json:",omitempty"`
+	Success  []TxnOp   `json:",omitempty"`
+	Failure  []TxnOp   `json:",omitempty"`
+}
+
+// Compare is a transaction condition on the value, version, create index or modify index of Key. Version holds the
+// number compared against for every target but CompareValue. A key that does not exist has a nil value and 0 for
+// each number
+type Compare struct {
+	Key     string
+	Target  CompareTarget
+	Result  CompareResult
+	Value   []byte `json:",omitempty"`
+	Version uint64 `json:",omitempty"`
+}
+
+type TxnOp struct {
+	Op    CommandOp
+	Key   string
+	Value []byte `json:",omitempty"`
+}
+
+// TxnOpResult is the outcome of a single transaction operation. For gets Value is the value read, for every
+// operation Version, CreateIndex and ModifyIndex are those of the key after the operation and Found whether the key
+// existed before it
+type TxnOpResult struct {
+	Key         string
+	Value       []byte `json:",omitempty"`
+	Version     uint64
+	CreateIndex uint64
+	ModifyIndex uint64
+	Found       bool
+}
+
+// NewTxnCommand returns the command that applies txn atomically once it is committed
+func NewTxnCommand(txn Txn) Command {
+
+	return Command{Version: CommandVersion, Op: OpTxn, Txn: &txn}
+}
+
+// validate returns ErrInvalidTxn if a compare or operation has no key or an operation is not a put, delete or get
+// and ErrReservedKey if a put or delete names a reserved key or a compare or get names a key stored in a namespace
+func (txn *Txn) validate() error {
+
+	var err error
+
+	for _, compare := range txn.Compares {
+
+		if compare.Key == "" {
+			err = ErrInvalidTxn
+		} else if isHiddenKey(compare.Key) && err == nil {
+			err = ErrReservedKey
+		}
+	}
+
+	for _, op := range append(append([]TxnOp{}, txn.Success...), txn.Failure...) {
+
+		if op.Key == "" || (op.Op != OpPut && op.Op != OpDelete && op.Op != OpGet) {
+			err = ErrInvalidTxn
+		} else if (isReservedFrom(op.Key, op.Op) || isHiddenKey(op.Key)) && err == nil {
+			err = ErrReservedKey
+		}
+	}
+
+	return err
+}
+
+// apply evaluates the compares against the keys of namespace in data and applies the chosen branch to them,
+// returning whether the compares held and the result of each operation in the branch
+func (txn *Txn) apply(data revisionWriter, namespace string) (bool, []TxnOpResult) {
+
+	succeeded := true
+
+	for _, compare := range txn.Compares {
+		succeeded = succeeded && compare.holds(data, namespace)
+	}
+
+	ops := txn.Failure
+
+	if succeeded {
+		ops = txn.Success
+	}
+
+	results := make([]TxnOpResult, 0, len(ops))
+
+	for _, op := range ops {
+		results = append(results, op.apply(data, namespace))
+	}
+
+	return succeeded, results
+}
+
+func (compare Compare) holds(data revisionWriter, namespace string) bool {
+
+	current, _ := data.get(NamespacedKey(namespace, compare.Key))
+
+	var relation int
+
+	switch compare.Target {
+	case CompareVersion:
+		relation = compareVersions(current.version, compare.Version)
+	case CompareCreateIndex:
+		relation = compareVersions(current.createIndex, compare.Version)
+	case CompareModifyIndex:
+		relation = compareVersions(current.modifyIndex, compare.Version)
+	default:
+		relation = bytes.Compare(current.value, compare.Value)
+	}
+
+	holds := relation == 0
+
+	switch compare.Result {
+	case CompareNotEqual:
+		holds = relation != 0
+	case CompareGreater:
+		holds = relation > 0
+	case CompareLess:
+		holds = relation < 0
+	}
+
+	return holds
+}
+
+func compareVersions(current uint64, expected uint64) int {
+
+	relation := 0
+
+	if current < expected {
+		relation = -1
+	} else if current > expected {
+		relation = 1
+	}
+
+	return relation
+}
+
+func (op TxnOp) apply(data revisionWriter, namespace string) TxnOpResult {
+
+	key := NamespacedKey(namespace, op.Key)
+
+	current, found := data.get(key)
+
+	switch op.Op {
+	case OpPut:
+		data.put(key, op.Value, current.lease)
+	case OpDelete:
+		data.delete(key)
+	}
+
+	current, _ = data.get(key)
+
+	result := TxnOpResult{
+		Key:         op.Key,
+		Version:     current.version,
+		CreateIndex: current.createIndex,
+		ModifyIndex: current.modifyIndex,
+		Found:       found,
+	}
+
+	if op.Op == OpGet {
+		result.Value = current.value
+	}
+
+	return result
+}
+
+// encode returns the envelope payload of the transaction
+func (txn *Txn) encode() *envelope.Txn {
+
+	encoded := &envelope.Txn{
+		Success: encodeTxnOps(txn.Success),
+		Failure: encodeTxnOps(txn.Failure),
+	}
+
+	for _, compare := range txn.Compares {
+
+		encoded.Compares = append(encoded.Compares, &envelope.Compare{
+			Key:     []byte(compare.Key),
+			Target:  envelope.CompareTarget(compare.Target),
+			Result:  envelope.CompareResult(compare.Result),
+			Value:   compare.Value,
+			Version: compare.Version,
+		})
+	}
+
+	return encoded
+}
+
+func encodeTxnOps(ops []TxnOp) []*envelope.TxnOp {
+
+	var encoded []*envelope.TxnOp
+
+	for _, op := range ops {
+		encoded = append(encoded, &envelope.TxnOp{Op: envelope.Op(op.Op), Key: []byte(op.Key), Value: op.Value})
+	}
+
+	return encoded
+}
+
+// decodeTxn returns the transaction carried in the envelope payload encoded
+func decodeTxn(encoded *envelope.Txn) *Txn {
+
+	txn := &Txn{
+		Success: decodeTxnOps(encoded.Success),
+		Failure: decodeTxnOps(encoded.Failure),
+	}
+
+	for _, compare := range encoded.Compares {
+
+		txn.Compares = append(txn.Compares, Compare{
+			Key:     string(compare.Key),
+			Target:  CompareTarget(compare.Target),
+			Result:  CompareResult(compare.Result),
+			Value:   compare.Value,
+			Version: compare.Version,
+		})
+	}
+
+	return txn
+}
+
+func decodeTxnOps(encoded []*envelope.TxnOp) []TxnOp {
+
+	var ops []TxnOp
+
+	for _, op := range encoded {
+		ops = append(ops, TxnOp{Op: CommandOp(op.Op), Key: string(op.Key), Value: op.Value})
+	}
+
+	return ops
+}
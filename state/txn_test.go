@@ -0,0 +1,146 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jrobison153/raft/journal"
+)
+
+func TestWhenTxnCommandIsEncodedThenItDecodesToTheSameTxn(t *testing.T) {
+
+	command := NewTxnCommand(Txn{
+		Compares: []Compare{
+			{Key: "a", Target: CompareVersion, Result: CompareGreater, Version: 2},
+			{Key: "b", Target: CompareValue, Result: CompareEqual, Value: []byte("b value")},
+		},
+		Success: []TxnOp{{Op: OpPut, Key: "a", Value: []byte("new a")}, {Op: OpGet, Key: "b"}},
+		Failure: []TxnOp{{Op: OpDelete, Key: "a"}},
+	})
+
+	decoded, err := DecodeCommand(command.Encode())
+
+	if err != nil || !reflect.DeepEqual(command, decoded) {
+		t.Errorf("Expected %+v to survive encoding but got %+v and error '%v'", command.Txn, decoded.Txn, err)
+	}
+}
+
+func TestWhenTxnComparesHoldThenTheSuccessOperationsAreApplied(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	results := applyCommands(stateMachine,
+		NewPutCommand("from", []byte("10")),
+		NewTxnCommand(Txn{
+			Compares: []Compare{{Key: "from", Result: CompareEqual, Value: []byte("10")}},
+			Success: []TxnOp{
+				{Op: OpPut, Key: "from", Value: []byte("5")},
+				{Op: OpPut, Key: "to", Value: []byte("5")},
+				{Op: OpGet, Key: "from"},
+			},
+			Failure: []TxnOp{{Op: OpGet, Key: "from"}},
+		}))
+
+	txnResult := results[1]
+
+	if !txnResult.Applied || len(txnResult.Responses) != 3 || txnResult.Responses[0].Version != 2 ||
+		txnResult.Responses[1].Found || string(txnResult.Responses[2].Value) != "5" {
+
+		t.Errorf("Unexpected transaction result %+v", txnResult)
+	}
+}
+
+func TestWhenTxnCompareFailsThenOnlyTheFailureOperationsAreApplied(t *testing.T) {
+
+	stateMachine := setupBTreeStateMachine(map[string][]byte{})
+
+	txnResult := stateMachine.applyCommand(NewTxnCommand(Txn{
+		Compares: []Compare{{Key: "lock", Target: CompareVersion, Result: CompareEqual, Version: 1}},
+		Success:  []TxnOp{{Op: OpPut, Key: "lock", Value: []byte("owner")}},
+		Failure:  []TxnOp{{Op: OpPut, Key: "waiting", Value: []byte("owner")}},
+	}).Encode(), 0)
+
+	items, _ := stateMachine.Scan(ScanRequest{})
+
+	if txnResult.Applied || len(txnResult.Responses) != 1 || keysOf(items) != "[waiting]" {
+		t.Errorf("Unexpected transaction result %+v with keys %s", txnResult, keysOf(items))
+	}
+}
+
+func TestWhenTxnOperationHasNoKeyThenTheTxnIsNotValid(t *testing.T) {
+
+	command := NewTxnCommand(Txn{Success: []TxnOp{{Op: OpPut, Value: []byte("no key")}}})
+
+	if ErrInvalidTxn != command.Validate() {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrInvalidTxn, command.Validate())
+	}
+}
+
+func TestWhenGetIsProposedOutsideATxnThenItIsNotValid(t *testing.T) {
+
+	command := Command{Version: CommandVersion, Op: OpGet, Key: "theKey"}
+
+	if ErrUnknownCommandOp != command.Validate() {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrUnknownCommandOp, command.Validate())
+	}
+}
+
+func TestWhenTxnNamesANamespaceThenItsOperationsApplyToTheKeysOfTheNamespace(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	command := NewTxnCommand(Txn{Success: []TxnOp{{Op: OpPut, Key: "config", Value: []byte("a")}}})
+	command.Namespace = "team-a"
+
+	results := applyCommands(stateMachine, NewCreateNamespaceCommand("team-a", 0, 0), command)
+
+	data, _ := stateMachine.ResolveRequestToData(createNamespacedKeyRequest("team-a", "config"))
+	_, err := stateMachine.ResolveRequestToData(createKeyRequest("config"))
+
+	if string(data) != "a" || ErrKeyNotFound != err || results[1].Responses[0].Key != "config" {
+		t.Errorf("Put should have been stored in the namespace but got '%s', error '%v' and %+v", data, err,
+			results[1])
+	}
+}
+
+func TestWhenTxnPutsALeasedKeyThenTheKeyStaysAttachedToItsLease(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	id := LeaseIDGrantedAt(0)
+
+	applyCommands(stateMachine,
+		NewGrantLeaseCommand(10),
+		putWithLease("session", id),
+		NewTxnCommand(Txn{Success: []TxnOp{{Op: OpPut, Key: "session", Value: []byte("renewed")}}}),
+		NewRevokeLeaseCommand(id))
+
+	_, err := stateMachine.ResolveRequestToData(createKeyRequest("session"))
+
+	if ErrKeyNotFound != err {
+		t.Errorf("Revoking the lease should have deleted the key put by the txn but got error '%v'", err)
+	}
+}
+
+func TestWhenTxnIsAppliedToTheBTreeThenTheNamespaceAndIndexTablesHoldItsWrites(t *testing.T) {
+
+	stateMachine := NewBTreeStateMachine(journal.NewJournalSpy())
+
+	command := NewTxnCommand(Txn{Success: []TxnOp{{Op: OpPut, Key: "user/1", Value: []byte(`{"city":"Oslo"}`)}}})
+	command.Namespace = "team-a"
+
+	for index, entry := range []Command{
+		NewCreateNamespaceCommand("team-a", 0, 0),
+		NewCreateIndexCommand(IndexDefinition{Name: "by-city", Namespace: "team-a", Prefix: "user/", Path: "city"}),
+		command,
+	} {
+		stateMachine.applyCommand(entry.Encode(), uint64(index))
+	}
+
+	namespace, _ := stateMachine.Namespace("team-a")
+	keys, err := stateMachine.QueryIndex("by-city", []byte(`"Oslo"`), 0)
+
+	if namespace.Keys != 1 || err != nil || len(keys) != 1 {
+		t.Errorf("Txn write should have been counted and indexed but got %+v, %v and error '%v'", namespace, keys, err)
+	}
+}
@@ -0,0 +1,7 @@
+// Package guest exports a state machine written in Go from a WebAssembly module with the ABI the wasm package runs.
+// The module is built as a reactor for wasip1, e.g.
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o state-machine.wasm ./cmd/state-machine
+//
+// and registers its process.Plugin with Export from an init function, main is never run by a reactor.
+package guest
@@ -0,0 +1,113 @@
+//go:build wasip1
+
+package guest
+
+import (
+	"context"
+	"unsafe"
+
+	"github.com/jrobison153/raft/api/statemachine"
+	"github.com/jrobison153/raft/state/process"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	server statemachine.StateMachineServer
+
+	// requests holds the buffers allocated for the host until the call they are for takes them, so they are not
+	// collected while only the host knows of them
+	requests = make(map[uint32][]byte)
+
+	// response holds the message returned by the last call until the host has read it on the next
+	response []byte
+)
+
+// Export makes plugin the state machine the module serves, it must be called before the host applies any entry
+func Export(plugin process.Plugin) {
+
+	server = process.NewPluginServer(plugin)
+}
+
+//go:wasmexport alloc
+func alloc(size uint32) uint32 {
+
+	// one byte more so an empty request still has an address
+	buffer := make([]byte, size+1)
+	pointer := uint32(uintptr(unsafe.Pointer(&buffer[0])))
+
+	requests[pointer] = buffer
+
+	return pointer
+}
+
+//go:wasmexport apply
+func apply(pointer uint32, size uint32) uint64 {
+
+	request := &statemachine.ApplyRequest{}
+
+	return serve(pointer, size, request, func() (proto.Message, error) {
+		return server.Apply(context.Background(), request)
+	})
+}
+
+//go:wasmexport query
+func query(pointer uint32, size uint32) uint64 {
+
+	request := &statemachine.QueryRequest{}
+
+	return serve(pointer, size, request, func() (proto.Message, error) {
+		return server.Query(context.Background(), request)
+	})
+}
+
+//go:wasmexport snapshot
+func snapshot() uint64 {
+
+	message, _ := server.Snapshot(context.Background(), &statemachine.SnapshotRequest{})
+
+	return respond(message)
+}
+
+//go:wasmexport restore
+func restore(pointer uint32, size uint32) uint64 {
+
+	request := &statemachine.RestoreRequest{}
+
+	return serve(pointer, size, request, func() (proto.Message, error) {
+		return server.Restore(context.Background(), request)
+	})
+}
+
+// serve decodes the request the host wrote to the buffer at pointer into request and responds with the message
+// call returns. A request that cannot be decoded is a bug in the host, it traps
+func serve(pointer uint32, size uint32, request proto.Message, call func() (proto.Message, error)) uint64 {
+
+	buffer := requests[pointer]
+	delete(requests, pointer)
+
+	if err := proto.Unmarshal(buffer[:size], request); err != nil {
+		panic(err)
+	}
+
+	message, _ := call()
+
+	return respond(message)
+}
+
+// respond keeps message encoded for the host to read and returns where it is, the pointer in the high 32 bits and
+// the size in the low 32 bits
+func respond(message proto.Message) uint64 {
+
+	encoded, err := proto.Marshal(message)
+
+	if err != nil {
+		panic(err)
+	}
+
+	// one byte more so an empty message still has an address
+	response = append(encoded, 0)
+
+	pointer := uint32(uintptr(unsafe.Pointer(&response[0])))
+
+	return uint64(pointer)<<32 | uint64(len(encoded))
+}
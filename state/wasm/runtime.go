@@ -0,0 +1,267 @@
+// Package wasm runs state machines compiled to WebAssembly inside the node with wazero, a WebAssembly runtime
+// written in pure Go. A module is run by a process.Proxy like a state machine process, so it is restored from the
+// latest snapshot and replayed whenever it traps, and a new module can be swapped in with Proxy Swap.
+//
+// A module exports its memory and the functions
+//
+//	alloc(size i32) -> pointer i32
+//	apply(pointer i32, size i32) -> response i64
+//	query(pointer i32, size i32) -> response i64
+//	snapshot() -> response i64
+//	restore(pointer i32, size i32) -> response i64
+//
+// The host writes the request messages of state_machine.proto to buffers it allocates with alloc, apply takes an
+// ApplyRequest, query a QueryRequest and restore a RestoreRequest. Every call returns its response message, the
+// pointer to it in the high 32 bits and its size in the low 32 bits, which must stay readable until the next call.
+// The module is sandboxed, it can import the WASI preview 1 functions but sees no files, environment or network and
+// its clocks and random source are fake, so it applies deterministically. The guest package exports a state machine
+// written in Go with this ABI.
+package wasm
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/jrobison153/raft/api/statemachine"
+	"github.com/jrobison153/raft/journal"
+	"github.com/jrobison153/raft/state/process"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	allocExport    = "alloc"
+	applyExport    = "apply"
+	queryExport    = "query"
+	snapshotExport = "snapshot"
+	restoreExport  = "restore"
+
+	// reactorStartFunction initializes a reactor module, one that is called into rather than run to exit
+	reactorStartFunction = "_initialize"
+)
+
+var (
+	ErrMissingExport   = errors.New("module does not export memory, alloc, apply, query, snapshot and restore")
+	ErrOutOfBoundsCall = errors.New("module allocated or returned a message outside of its memory")
+)
+
+// abiExports are the functions a module must export besides its memory
+var abiExports = []string{allocExport, applyExport, queryExport, snapshotExport, restoreExport}
+
+// instance is a running module, served to a process.Proxy as the StateMachine client of state_machine.proto
+type instance struct {
+	runtime wazero.Runtime
+	module  api.Module
+}
+
+// NewLauncher returns the process.Launcher of the state machine compiled to module, each launch instantiates the
+// module afresh in a runtime of its own. The module is compiled by the first launch and the compiled code is kept for
+// the life of the launcher, so an instance that trapped is replaced without compiling the module again
+func NewLauncher(module []byte) process.Launcher {
+
+	cache := wazero.NewCompilationCache()
+
+	return func() (process.Runner, error) {
+
+		return instantiate(module, cache)
+	}
+}
+
+// NewProxy returns the state.Renderer of the state machine compiled to module, see process.Proxy. The Executable
+// and Args of config are not used
+func NewProxy(journal journal.Journaler, config *process.Config, module []byte) *process.Proxy {
+
+	return process.NewLauncherProxy(journal, config, NewLauncher(module))
+}
+
+// instantiate compiles module, or takes it from cache, and instantiates it, its stdout and stderr are the node's.
+// Returns ErrMissingExport if the module does not export the ABI
+// Returns the errors of wazero compiling or instantiating the module
+func instantiate(module []byte, cache wazero.CompilationCache) (*instance, error) {
+
+	ctx := context.Background()
+
+	running := &instance{
+		runtime: wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCompilationCache(cache)),
+	}
+
+	_, err := wasi_snapshot_preview1.Instantiate(ctx, running.runtime)
+
+	var compiled wazero.CompiledModule
+
+	if err == nil {
+		compiled, err = running.runtime.CompileModule(ctx, module)
+	}
+
+	if err == nil {
+
+		config := wazero.NewModuleConfig().
+			WithStartFunctions(reactorStartFunction).
+			WithStdout(os.Stdout).
+			WithStderr(os.Stderr)
+
+		running.module, err = running.runtime.InstantiateModule(ctx, compiled, config)
+	}
+
+	if err == nil && !exportsABI(running.module) {
+		err = ErrMissingExport
+	}
+
+	if err != nil {
+		running.Stop()
+	}
+
+	return running, err
+}
+
+func exportsABI(module api.Module) bool {
+
+	exports := module.Memory() != nil
+
+	for _, name := range abiExports {
+		exports = exports && module.ExportedFunction(name) != nil
+	}
+
+	return exports
+}
+
+// Invoke makes call to the module.
+// Returns process.ErrStateMachineUnavailable if the module is not running
+func (running *instance) Invoke(call func(statemachine.StateMachineClient) error) error {
+
+	err := process.ErrStateMachineUnavailable
+
+	if running.module != nil {
+		err = call(running)
+	}
+
+	return err
+}
+
+// IsDown returns true for any error, a module that trapped or broke the ABI is in a state that cannot be trusted
+// and must be instantiated again
+func (running *instance) IsDown(err error) bool {
+
+	return true
+}
+
+// Stop closes the runtime of the module, releasing the module with it
+func (running *instance) Stop() {
+
+	_ = running.runtime.Close(context.Background())
+
+	running.module = nil
+}
+
+func (running *instance) Apply(
+	ctx context.Context,
+	request *statemachine.ApplyRequest,
+	_ ...grpc.CallOption) (*statemachine.ApplyResponse, error) {
+
+	response := &statemachine.ApplyResponse{}
+
+	return response, running.call(ctx, applyExport, request, response)
+}
+
+func (running *instance) Query(
+	ctx context.Context,
+	request *statemachine.QueryRequest,
+	_ ...grpc.CallOption) (*statemachine.QueryResponse, error) {
+
+	response := &statemachine.QueryResponse{}
+
+	return response, running.call(ctx, queryExport, request, response)
+}
+
+func (running *instance) Snapshot(
+	ctx context.Context,
+	_ *statemachine.SnapshotRequest,
+	_ ...grpc.CallOption) (*statemachine.SnapshotResponse, error) {
+
+	response := &statemachine.SnapshotResponse{}
+
+	return response, running.call(ctx, snapshotExport, nil, response)
+}
+
+func (running *instance) Restore(
+	ctx context.Context,
+	request *statemachine.RestoreRequest,
+	_ ...grpc.CallOption) (*statemachine.RestoreResponse, error) {
+
+	response := &statemachine.RestoreResponse{}
+
+	return response, running.call(ctx, restoreExport, request, response)
+}
+
+// call writes request, if there is one, to the memory of the module, calls export with it and reads what export
+// returns into response.
+// Returns ErrOutOfBoundsCall if a message does not fit the memory of the module
+// Returns the error of the module trapping
+func (running *instance) call(ctx context.Context, export string, request proto.Message, response proto.Message) error {
+
+	var params []uint64
+	var err error
+
+	if request != nil {
+
+		var pointer, size uint32
+		pointer, size, err = running.write(ctx, request)
+
+		params = []uint64{uint64(pointer), uint64(size)}
+	}
+
+	var results []uint64
+
+	if err == nil {
+		results, err = running.module.ExportedFunction(export).Call(ctx, params...)
+	}
+
+	if err == nil {
+		err = running.read(results[0], response)
+	}
+
+	return err
+}
+
+// write copies request encoded into a buffer the module allocates and returns where it is
+func (running *instance) write(ctx context.Context, request proto.Message) (uint32, uint32, error) {
+
+	encoded, err := proto.Marshal(request)
+
+	var results []uint64
+
+	if err == nil {
+		results, err = running.module.ExportedFunction(allocExport).Call(ctx, uint64(len(encoded)))
+	}
+
+	var pointer uint32
+
+	if err == nil {
+
+		pointer = uint32(results[0])
+
+		if !running.module.Memory().Write(pointer, encoded) {
+			err = ErrOutOfBoundsCall
+		}
+	}
+
+	return pointer, uint32(len(encoded)), err
+}
+
+// read decodes the message at the packed location returned by a call into response
+func (running *instance) read(location uint64, response proto.Message) error {
+
+	encoded, isInBounds := running.module.Memory().Read(uint32(location>>32), uint32(location))
+
+	err := ErrOutOfBoundsCall
+
+	if isInBounds {
+		err = proto.Unmarshal(encoded, response)
+	}
+
+	return err
+}
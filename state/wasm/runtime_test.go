@@ -0,0 +1,176 @@
+package wasm
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/jrobison153/raft/journal"
+	"github.com/jrobison153/raft/state"
+	"github.com/jrobison153/raft/state/process"
+)
+
+// mapLauncher launches testdata/mapmodule compiled to WebAssembly, nil when the toolchain cannot build wasip1
+// reactors. The tests share it so the module is only compiled once
+var mapLauncher process.Launcher
+
+// emptyModule is a valid WebAssembly module that exports nothing
+var emptyModule = []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+
+// TestMain builds the state machine module the tests run
+func TestMain(m *testing.M) {
+
+	dir, err := os.MkdirTemp("", "raft-wasm-")
+
+	path := filepath.Join(dir, "map.wasm")
+
+	if err == nil {
+
+		build := exec.Command("go", "build", "-buildmode=c-shared", "-o", path, "./testdata/mapmodule")
+		build.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+
+		var output []byte
+		output, err = build.CombinedOutput()
+
+		if err != nil {
+			log.Printf("unable to build the test module, skipping the tests that run it: %v\n%s", err, output)
+		}
+	}
+
+	var module []byte
+
+	if err == nil {
+		module, err = os.ReadFile(path)
+	}
+
+	if err == nil {
+		mapLauncher = NewLauncher(module)
+	}
+
+	code := m.Run()
+
+	_ = os.RemoveAll(dir)
+
+	os.Exit(code)
+}
+
+func TestWhenCommittedEntriesAreRenderedThenTheyAreReadFromTheModule(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	appendCommands(journalSpy, state.NewPutCommand("a", []byte("1")), state.NewPutCommand("a", []byte("2")))
+
+	proxy := startProxy(t, journalSpy, process.NewDefaultConfig(""))
+	defer proxy.Stop()
+
+	item, err := proxy.ResolveRequestToItem(createKeyRequest("a"))
+
+	if err != nil || string(item.Value) != "2" || item.Version != 2 || item.Key != "a" {
+		t.Errorf("Unexpected item %+v and error '%v'", item, err)
+	}
+}
+
+func TestWhenTheModuleTrapsThenItIsInstantiatedAgainAndCaughtUp(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	appendCommands(journalSpy,
+		state.NewPutCommand("a", []byte("1")),
+		state.NewPutCommand("b", []byte("2")),
+		state.NewPutCommand("c", []byte("3")))
+
+	config := process.NewDefaultConfig("")
+	config.SnapshotInterval = 2
+
+	proxy := startProxy(t, journalSpy, config)
+	defer proxy.Stop()
+
+	_, trapErr := proxy.ResolveRequestToItem(createKeyRequest("trap"))
+
+	item, err := proxy.ResolveRequestToItem(createKeyRequest("c"))
+
+	if !errors.Is(trapErr, process.ErrStateMachineUnavailable) || err != nil || item.Version != 1 {
+		t.Errorf("Expected the trap to be reported and 'c' to survive it but got %+v and errors '%v' and '%v'",
+			item, trapErr, err)
+	}
+}
+
+func TestWhenModuleIsSwappedInThenItIsCaughtUpAndServes(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	appendCommands(journalSpy, state.NewPutCommand("a", []byte("1")), state.NewPutCommand("b", []byte("2")))
+
+	proxy := startProxy(t, journalSpy, process.NewDefaultConfig(""))
+	defer proxy.Stop()
+
+	err := proxy.Swap(mapLauncher)
+
+	item, getErr := proxy.ResolveRequestToItem(createKeyRequest("b"))
+
+	if err != nil || getErr != nil || string(item.Value) != "2" {
+		t.Errorf("Expected the swapped in module to serve 'b' but got %+v and errors '%v' and '%v'", item, err, getErr)
+	}
+}
+
+func TestWhenSwappedInModuleDoesNotExportTheABIThenTheRunningModuleKeepsServing(t *testing.T) {
+
+	journalSpy := journal.NewJournalSpy()
+	appendCommands(journalSpy, state.NewPutCommand("a", []byte("1")))
+
+	proxy := startProxy(t, journalSpy, process.NewDefaultConfig(""))
+	defer proxy.Stop()
+
+	err := proxy.Swap(NewLauncher(emptyModule))
+
+	item, getErr := proxy.ResolveRequestToItem(createKeyRequest("a"))
+
+	if ErrMissingExport != err || getErr != nil || item.Version != 1 {
+		t.Errorf("Expected error '%v' and 'a' still served but got %+v and errors '%v' and '%v'",
+			ErrMissingExport, item, err, getErr)
+	}
+}
+
+func TestWhenModuleDoesNotExportTheABIThenItIsNotLaunched(t *testing.T) {
+
+	runner, err := NewLauncher(emptyModule)()
+
+	callErr := runner.Invoke(nil)
+
+	if ErrMissingExport != err || process.ErrStateMachineUnavailable != callErr {
+		t.Errorf("Should have received errors '%v' and '%v' but got '%v' and '%v'",
+			ErrMissingExport, process.ErrStateMachineUnavailable, err, callErr)
+	}
+}
+
+func startProxy(t *testing.T, journalSpy *journal.Spy, config *process.Config) *process.Proxy {
+
+	if mapLauncher == nil {
+		t.Skip("the test module could not be built for wasip1")
+	}
+
+	proxy := process.NewLauncherProxy(journalSpy, config, mapLauncher)
+
+	err := proxy.Start()
+
+	if err != nil {
+		t.Fatalf("Unable to start the state machine module: %v", err)
+	}
+
+	return proxy
+}
+
+func appendCommands(journalSpy *journal.Spy, commands ...state.Command) {
+
+	for _, command := range commands {
+		<-journalSpy.Append(command.Encode())
+	}
+}
+
+func createKeyRequest(key string) []byte {
+
+	request, _ := json.Marshal(state.Key{Key: key})
+
+	return request
+}
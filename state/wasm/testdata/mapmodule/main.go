@@ -0,0 +1,79 @@
+// Command mapmodule is the state machine module the wasm tests run, a map of the keys put and deleted. Querying the
+// key "trap" traps the module
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/jrobison153/raft/state"
+	"github.com/jrobison153/raft/state/wasm/guest"
+)
+
+type mapPlugin struct {
+	Items        map[string]state.VersionedItem
+	appliedIndex uint64
+}
+
+func init() {
+
+	guest.Export(&mapPlugin{Items: make(map[string]state.VersionedItem)})
+}
+
+func main() {}
+
+func (plugin *mapPlugin) Apply(index uint64, command state.Command) (state.ApplyResult, []state.WatchEvent) {
+
+	item, exists := plugin.Items[command.Key]
+
+	plugin.appliedIndex = index
+
+	change := state.WatchEvent{Type: state.EventDelete, Index: index, Key: command.Key}
+
+	if command.Op == state.OpDelete {
+		delete(plugin.Items, command.Key)
+		item.Version = 0
+	} else {
+
+		if !exists {
+			item = state.VersionedItem{Key: command.Key, CreateIndex: index}
+		}
+
+		item.Value, item.Version, item.ModifyIndex = command.Value, item.Version+1, index
+		plugin.Items[command.Key] = item
+
+		change.Type, change.Value, change.Version = state.EventPut, item.Value, item.Version
+	}
+
+	return state.ApplyResult{Index: index, Applied: true, Version: item.Version}, []state.WatchEvent{change}
+}
+
+func (plugin *mapPlugin) Query(key state.Key) (state.VersionedItem, error) {
+
+	if key.Key == "trap" {
+		panic("trap requested")
+	}
+
+	item, exists := plugin.Items[key.Key]
+
+	var err error
+
+	if !exists {
+		err = state.ErrKeyNotFound
+	}
+
+	return item, err
+}
+
+func (plugin *mapPlugin) Snapshot() (uint64, []byte) {
+
+	data, _ := json.Marshal(plugin.Items)
+
+	return plugin.appliedIndex, data
+}
+
+func (plugin *mapPlugin) Restore(index uint64, data []byte) error {
+
+	plugin.appliedIndex = index
+
+	return json.Unmarshal(data, &plugin.Items)
+}
@@ -0,0 +1,330 @@
+package state
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultWatchBufferSize is the number of events a watcher can fall behind by before it is dropped
+const DefaultWatchBufferSize = 1024
+
+var (
+	ErrWatcherTooSlow = errors.New("watcher fell too far behind the applied events and was dropped")
+	ErrWatchCancelled = errors.New("watch was cancelled")
+)
+
+// EventType is the kind of change a WatchEvent reports
+type EventType int32
+
+const (
+	EventPut EventType = iota
+	EventDelete
+)
+
+// WatchEvent is a change made to Key by the journal entry at Index. Puts carry the new value and metadata of the key,
+// deletes only the key
+type WatchEvent struct {
+	Type        EventType
+	Index       uint64
+	Key         string
+	Value       []byte `json:",omitempty"`
+	Version     uint64
+	CreateIndex uint64
+	ModifyIndex uint64
+}
+
+// WatchRequest selects the keys a watcher receives events for, Key alone or every key beginning with Key when Prefix
+// is set. A StartIndex replays the changes made from that index on before streaming new ones, nil streams only
+// changes applied after the watch starts. The keys stored in a namespace are never selected
+type WatchRequest struct {
+	Key        string
+	Prefix     bool
+	StartIndex *uint64
+}
+
+// Watcher streams the events selected by its request in index order. Events are delivered on a buffered channel,
+// a watcher that lets the buffer fill is dropped rather than blocking the state machine. Events is closed when the
+// watcher is dropped or cancelled and Err then reports why
+type Watcher struct {
+	request        WatchRequest
+	events         chan WatchEvent
+	startedThrough int64
+	hub            *WatchHub
+	err            error
+}
+
+// WatchHub fans the events of each applied journal entry out to the watchers selecting them. WatchHub is safe for
+// concurrent use
+type WatchHub struct {
+	mutex      sync.Mutex
+	watchers   map[*Watcher]bool
+	bufferSize int
+}
+
+func NewWatchHub(bufferSize int) *WatchHub {
+
+	return &WatchHub{
+		watchers:   make(map[*Watcher]bool),
+		bufferSize: bufferSize,
+	}
+}
+
+// Watch returns a watcher receiving the events published from now on that request selects
+func (hub *WatchHub) Watch(request WatchRequest) *Watcher {
+
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	return hub.watchFrom(request, nil, -1)
+}
+
+// watchFrom returns a watcher that first receives backlog and then the published events selected by request.
+// Events of entries at or below startedThrough are covered by the backlog and are skipped. The hub mutex must be held
+func (hub *WatchHub) watchFrom(request WatchRequest, backlog []WatchEvent, startedThrough int64) *Watcher {
+
+	watcher := &Watcher{
+		request:        request,
+		events:         make(chan WatchEvent, len(backlog)+hub.bufferSize),
+		startedThrough: startedThrough,
+		hub:            hub,
+	}
+
+	for _, event := range backlog {
+		watcher.events <- event
+	}
+
+	hub.watchers[watcher] = true
+
+	return watcher
+}
+
+// Publish delivers the events of the journal entry at index to the watchers selecting them. Publish never blocks, a
+// watcher without room for an event is dropped with ErrWatcherTooSlow
+func (hub *WatchHub) Publish(index uint64, events []WatchEvent) {
+
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	for watcher := range hub.watchers {
+
+		if int64(index) > watcher.startedThrough {
+			watcher.deliver(events)
+		}
+	}
+}
+
+func (watcher *Watcher) deliver(events []WatchEvent) {
+
+	for _, event := range events {
+
+		if watcher.err == nil && watcher.request.selects(event) {
+
+			select {
+			case watcher.events <- event:
+			default:
+				watcher.hub.drop(watcher, ErrWatcherTooSlow)
+			}
+		}
+	}
+}
+
+// drop closes the events of watcher, the hub mutex must be held
+func (hub *WatchHub) drop(watcher *Watcher, err error) {
+
+	if hub.watchers[watcher] {
+
+		delete(hub.watchers, watcher)
+
+		watcher.err = err
+		close(watcher.events)
+	}
+}
+
+// Events returns the channel the watcher events are delivered on
+func (watcher *Watcher) Events() <-chan WatchEvent {
+
+	return watcher.events
+}
+
+// Err returns why the events channel was closed, nil while the watcher is still receiving events
+func (watcher *Watcher) Err() error {
+
+	watcher.hub.mutex.Lock()
+	defer watcher.hub.mutex.Unlock()
+
+	return watcher.err
+}
+
+// Cancel stops the watcher and closes its events channel, cancelling a stopped watcher does nothing
+func (watcher *Watcher) Cancel() {
+
+	watcher.hub.mutex.Lock()
+	defer watcher.hub.mutex.Unlock()
+
+	watcher.hub.drop(watcher, ErrWatchCancelled)
+}
+
+func (request WatchRequest) selects(event WatchEvent) bool {
+
+	selected := event.Key == request.Key
+
+	if request.Prefix {
+		selected = strings.HasPrefix(event.Key, request.Key)
+	}
+
+	selected = selected && !isHiddenKey(event.Key)
+
+	if request.StartIndex != nil {
+		selected = selected && event.Index >= *request.StartIndex
+	}
+
+	return selected
+}
+
+// Watch returns a watcher streaming the changes request selects as journal entries are applied, the state machine
+// applies entries as the journal notifies it of commits through NotifyOfAllCommitChanges. A request with a
+// StartIndex first receives the changes made from that index on, as held by the keyspace and the MVCC history.
+// Returns ErrCompacted if the history at StartIndex has been compacted
+// Returns ErrReservedKey if the request names a key stored in a namespace
+// Returns ErrCorruptionAlarm if a corrupt journal entry has been seen, the state can no longer be trusted
+func (state *journalApplier) Watch(request WatchRequest) (*Watcher, error) {
+
+	state.watchers.mutex.Lock()
+	defer state.watchers.mutex.Unlock()
+
+	var watcher *Watcher
+	var backlog []WatchEvent
+	var err error
+
+	appliedThrough := state.history.lastApplied()
+
+	if state.IsCorruptionAlarmRaised() {
+		err = ErrCorruptionAlarm
+	} else if isHiddenKey(request.Key) {
+		err = ErrReservedKey
+	} else if request.StartIndex != nil {
+		backlog, err = state.eventsBetween(request, appliedThrough)
+	}
+
+	if err == nil {
+		watcher = state.watchers.watchFrom(request, backlog, appliedThrough)
+	}
+
+	return watcher, err
+}
+
+// eventsBetween returns the changes selected by request made from its StartIndex through index through, in index
+// order and key order within an index. The current values are read from the keyspace before the replaced ones are
+// read from the history, a value replaced in between is found in both and reported once.
+// Returns ErrCompacted if the StartIndex is below the compacted index
+func (state *journalApplier) eventsBetween(request WatchRequest, through int64) ([]WatchEvent, error) {
+
+	var current []WatchEvent
+
+	state.keyspace.withPrefix(request.Key, func(key string, value versionedValue) {
+
+		event := revision{index: value.modifyIndex, value: value}.event(key)
+
+		if int64(event.Index) <= through && request.selects(event) {
+			current = append(current, event)
+		}
+	})
+
+	events, err := state.history.eventsBetween(*request.StartIndex, through, request.selects)
+
+	if err == nil {
+		events = append(events, current...)
+		sortEvents(events)
+		events = withoutRepeats(events)
+	}
+
+	return events, err
+}
+
+// eventsBetween returns the replaced values and deletes selected by selects made from index from through index
+// through.
+// Returns ErrCompacted if from is below the compacted index
+func (history *mvccHistory) eventsBetween(
+	from uint64,
+	through int64,
+	selects func(WatchEvent) bool) ([]WatchEvent, error) {
+
+	history.mutex.RLock()
+	defer history.mutex.RUnlock()
+
+	var events []WatchEvent
+	var err error
+
+	if from < history.compactedIndex {
+		err = ErrCompacted
+	} else {
+
+		for key, revisions := range history.revisions {
+
+			for _, revision := range revisions {
+
+				event := revision.event(key)
+
+				if revision.index >= from && int64(revision.index) <= through && selects(event) {
+					events = append(events, event)
+				}
+			}
+		}
+	}
+
+	return events, err
+}
+
+// sortEvents sorts events in index order and key order within an index
+func sortEvents(events []WatchEvent) {
+
+	sort.SliceStable(events, func(i, j int) bool {
+
+		if events[i].Index == events[j].Index {
+			return events[i].Key < events[j].Key
+		}
+
+		return events[i].Index < events[j].Index
+	})
+}
+
+// withoutRepeats drops the sorted events that repeat the change reported by the event before them
+func withoutRepeats(events []WatchEvent) []WatchEvent {
+
+	kept := events[:0]
+
+	for _, event := range events {
+
+		if len(kept) == 0 || !event.isSameChange(kept[len(kept)-1]) {
+			kept = append(kept, event)
+		}
+	}
+
+	return kept
+}
+
+// isSameChange returns true if event and other report the same change, a transaction writing a key more than once
+// makes several changes to it at one index
+func (event WatchEvent) isSameChange(other WatchEvent) bool {
+
+	return event.Key == other.Key && event.Index == other.Index && event.Type == other.Type &&
+		event.Version == other.Version
+}
+
+func (revision revision) event(key string) WatchEvent {
+
+	event := WatchEvent{Type: EventDelete, Index: revision.index, Key: key}
+
+	if !revision.deleted {
+
+		event.Type = EventPut
+		event.Value = revision.value.value
+		event.Version = revision.value.version
+		event.CreateIndex = revision.value.createIndex
+		event.ModifyIndex = revision.value.modifyIndex
+	}
+
+	return event
+}
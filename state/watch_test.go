@@ -0,0 +1,136 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/jrobison153/raft/journal"
+)
+
+func TestWhenWatchedKeyIsChangedThenPutAndDeleteEventsAreDelivered(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	watcher, _ := stateMachine.Watch(WatchRequest{Key: "theKey"})
+
+	applyCommands(stateMachine,
+		NewPutCommand("theKey", []byte("theValue")),
+		NewPutCommand("otherKey", []byte("otherValue")),
+		NewDeleteCommand("theKey"))
+
+	put, deleted := <-watcher.Events(), <-watcher.Events()
+
+	if put.Type != EventPut || string(put.Value) != "theValue" || put.Version != 1 || put.Index != 0 ||
+		deleted.Type != EventDelete || deleted.Index != 2 || len(watcher.Events()) != 0 {
+
+		t.Errorf("Unexpected events %+v and %+v", put, deleted)
+	}
+}
+
+func TestWhenPrefixIsWatchedFromAPastIndexThenHistoryIsReplayedBeforeNewChanges(t *testing.T) {
+
+	stateMachine := setupBTreeStateMachine(map[string][]byte{})
+
+	for index, key := range []string{"app/a", "other", "app/b"} {
+		stateMachine.applyAt(NewPutCommand(key, []byte("value")).Encode(), uint64(index))
+	}
+
+	startIndex := uint64(1)
+	watcher, err := stateMachine.Watch(WatchRequest{Key: "app/", Prefix: true, StartIndex: &startIndex})
+
+	stateMachine.applyAt(NewPutCommand("app/c", []byte("value")).Encode(), 3)
+
+	replayed, live := <-watcher.Events(), <-watcher.Events()
+
+	if err != nil || replayed.Key != "app/b" || live.Key != "app/c" || len(watcher.Events()) != 0 {
+		t.Errorf("Unexpected events %+v and %+v with error '%v'", replayed, live, err)
+	}
+}
+
+func TestWhenKeyIsWatchedFromAPastIndexThenItsReplacedAndCurrentValuesAreReplayedInOrder(t *testing.T) {
+
+	stateMachine := setupBTreeStateMachine(map[string][]byte{})
+
+	for index, value := range []string{"first", "second", "third"} {
+		stateMachine.applyAt(NewPutCommand("theKey", []byte(value)).Encode(), uint64(index))
+	}
+
+	startIndex := uint64(1)
+	watcher, err := stateMachine.Watch(WatchRequest{Key: "theKey", StartIndex: &startIndex})
+
+	replaced, current := <-watcher.Events(), <-watcher.Events()
+
+	if err != nil || string(replaced.Value) != "second" || string(current.Value) != "third" ||
+		len(watcher.Events()) != 0 {
+
+		t.Errorf("Unexpected events %+v and %+v with error '%v'", replaced, current, err)
+	}
+}
+
+func TestWhenReservedPrefixIsWatchedThenTheChangesOfNamespacesAreNotDelivered(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	watcher, err := stateMachine.Watch(WatchRequest{Key: "__", Prefix: true})
+	_, namespaceErr := stateMachine.Watch(WatchRequest{Key: NamespaceKeyPrefix, Prefix: true})
+
+	applyCommands(stateMachine,
+		NewCreateNamespaceCommand("team-a", 0, 0),
+		putInNamespace("team-a", "config", "tenant"),
+		NewNextSequenceCommand(SequenceKeyPrefix+"orders", 1))
+
+	event := <-watcher.Events()
+
+	if err != nil || ErrReservedKey != namespaceErr || event.Key != SequenceKeyPrefix+"orders" ||
+		len(watcher.Events()) != 0 {
+
+		t.Errorf("Unexpected event %+v with errors '%v' and '%v'", event, err, namespaceErr)
+	}
+}
+
+func TestWhenWatcherFallsBehindThenItIsDroppedAsTooSlow(t *testing.T) {
+
+	hub := NewWatchHub(1)
+	watcher := hub.Watch(WatchRequest{Key: "theKey"})
+
+	hub.Publish(0, []WatchEvent{{Index: 0, Key: "theKey"}})
+	hub.Publish(1, []WatchEvent{{Index: 1, Key: "theKey"}})
+
+	<-watcher.Events()
+	_, isOpen := <-watcher.Events()
+
+	if isOpen || ErrWatcherTooSlow != watcher.Err() {
+		t.Errorf("Watcher should have been dropped with '%v' but got '%v'", ErrWatcherTooSlow, watcher.Err())
+	}
+}
+
+func TestWhenWatcherIsCancelledThenItsEventsAreClosed(t *testing.T) {
+
+	hub := NewWatchHub(DefaultWatchBufferSize)
+	watcher := hub.Watch(WatchRequest{Key: "theKey"})
+
+	watcher.Cancel()
+	hub.Publish(0, []WatchEvent{{Index: 0, Key: "theKey"}})
+
+	_, isOpen := <-watcher.Events()
+
+	if isOpen || ErrWatchCancelled != watcher.Err() {
+		t.Errorf("Watcher should have been closed with '%v' but got '%v'", ErrWatchCancelled, watcher.Err())
+	}
+}
+
+func TestWhenWatchStartsBelowTheCompactedIndexThenErrCompactedIsReturned(t *testing.T) {
+
+	stateMachine := NewMapStateMachine(journal.NewJournalSpy())
+
+	applyCommands(stateMachine,
+		NewPutCommand("theKey", []byte("first")),
+		NewPutCommand("theKey", []byte("second")),
+		NewCompactCommand(1))
+
+	startIndex := uint64(0)
+	_, err := stateMachine.Watch(WatchRequest{Key: "theKey", StartIndex: &startIndex})
+
+	if ErrCompacted != err {
+		t.Errorf("Should have received error '%v' but got '%v'", ErrCompacted, err)
+	}
+}
@@ -14,6 +14,24 @@ type PrometheusMetrics struct {
 	isRunning bool
 }
 
+// registry holds every metric served by the Prometheus HTTP server, components outside the gRPC server add their
+// own metrics via Register
+var registry = prometheus.NewRegistry()
+
+// Register adds collectors to the metrics served by the Prometheus HTTP server. Collectors that are already
+// registered are ignored
+func Register(collectors ...prometheus.Collector) {
+
+	for _, collector := range collectors {
+
+		err := registry.Register(collector)
+
+		if err != nil {
+			log.Printf("Unable to register metrics collector: %v", err)
+		}
+	}
+}
+
 func NewPrometheusMetrics() *PrometheusMetrics {
 
 	return &PrometheusMetrics{isRunning: false}
@@ -31,16 +49,14 @@ func (metrics *PrometheusMetrics) EnableMetrics(server *grpc.Server) {
 
 	grpc_prometheus.Register(server)
 
-	promRegistry := prometheus.NewRegistry()
-
 	grpcMetrics := grpc_prometheus.NewServerMetrics()
-	promRegistry.MustRegister(grpcMetrics)
+	registry.MustRegister(grpcMetrics)
 	grpcMetrics.InitializeMetrics(server)
 
 	const promServerPort = 9090
 
 	promHttpServer := &http.Server{
-		Handler: promhttp.HandlerFor(promRegistry, promhttp.HandlerOpts{}),
+		Handler: promhttp.HandlerFor(registry, promhttp.HandlerOpts{}),
 		Addr:    fmt.Sprintf("0.0.0.0:%d", promServerPort)}
 
 	go func() {
@@ -1,6 +1,8 @@
 package telemetry
 
 import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"google.golang.org/grpc"
 	"testing"
 )
@@ -17,3 +19,41 @@ func TestWhenAddingServerOptionsThenGrpcStreamInterceptorsAreAdded(t *testing.T)
 			len(opts))
 	}
 }
+
+func TestWhenCollectorRegisteredThenItIsGatheredByTheRegistry(t *testing.T) {
+
+	counter := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "raft_test_register_total",
+		Help: "counter used to test registration",
+	})
+
+	Register(counter)
+
+	families, _ := registry.Gather()
+
+	if !hasMetricFamily(families, "raft_test_register_total") {
+		t.Errorf("Registered collector should have been gathered by the registry")
+	}
+}
+
+func TestWhenCollectorRegisteredTwiceThenRegisterDoesNotPanic(t *testing.T) {
+
+	counter := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "raft_test_register_twice_total",
+		Help: "counter used to test duplicate registration",
+	})
+
+	Register(counter)
+	Register(counter)
+}
+
+func hasMetricFamily(families []*dto.MetricFamily, name string) bool {
+
+	found := false
+
+	for _, family := range families {
+		found = found || family.GetName() == name
+	}
+
+	return found
+}